@@ -114,7 +114,9 @@ func NewBackendPublicKey(ds ds.TxnDatastore, cfg *RecordBackendConfig) (be *Reco
 // values passed into [ProvidersBackend.Store] must be of type [peer.AddrInfo].
 // The values returned from [ProvidersBackend.Fetch] will be of type
 // [*providerSet] (unexported). The cfg parameter can be nil, in which case the
-// [DefaultProviderBackendConfig] will be used.
+// [DefaultProviderBackendConfig] will be used. If the write-behind buffer is
+// enabled via [ProvidersBackendConfig.BatchSize], dstore must implement
+// [ds.Batching].
 func NewBackendProvider(pstore peerstore.Peerstore, dstore ds.Datastore, cfg *ProvidersBackendConfig) (be *ProvidersBackend, err error) {
 	if cfg == nil {
 		if cfg, err = DefaultProviderBackendConfig(); err != nil {
@@ -136,5 +138,20 @@ func NewBackendProvider(pstore peerstore.Peerstore, dstore ds.Datastore, cfg *Pr
 		datastore: dstore,
 	}
 
+	if cfg.BatchSize > 0 {
+		batching, ok := dstore.(ds.Batching)
+		if !ok {
+			return nil, fmt.Errorf("datastore doesn't support batching")
+		}
+
+		if cfg.BatchInterval <= 0 {
+			return nil, fmt.Errorf("batch interval must be greater than zero")
+		}
+
+		p.batching = batching
+		p.pending = make(map[string][]byte, cfg.BatchSize)
+		p.startFlushLoop()
+	}
+
 	return p, nil
 }
@@ -57,6 +57,33 @@ type ProvidersBackend struct {
 	gcCancelMu sync.RWMutex
 	gcCancel   context.CancelFunc
 	gcDone     chan struct{}
+
+	// batching holds a reference to the datastore as a [ds.Batching] datastore.
+	// This is only set if the write-behind buffer is enabled (see
+	// [ProvidersBackendConfig.BatchSize]).
+	batching ds.Batching
+
+	// pendingMu guards pending and flushing
+	pendingMu sync.RWMutex
+
+	// pending holds provider records that were stored but not yet written to
+	// the datastore. It maps the string representation of the datastore key to
+	// the marshalled expiryRecord.
+	pending map[string][]byte
+
+	// flushing holds provider records that are currently being written to the
+	// datastore in a batch. Fetch considers these records until the batch
+	// was committed.
+	flushing map[string][]byte
+
+	// flushMu serializes batch writes so that an older batch cannot overwrite
+	// the records of a newer one.
+	flushMu sync.Mutex
+
+	// flushCancel stops the periodic flush loop and flushDone is closed when
+	// the loop has exited.
+	flushCancel context.CancelFunc
+	flushDone   chan struct{}
 }
 
 var (
@@ -64,6 +91,21 @@ var (
 	_ io.Closer = (*ProvidersBackend)(nil)
 )
 
+// MaxProviderMetadataSize is the maximum number of bytes of metadata that a
+// provider can attach to its provider record. Provider records with larger
+// metadata are rejected.
+const MaxProviderMetadataSize = 1024 // MAGIC
+
+// ProviderInfo extends [peer.AddrInfo] with the opaque metadata that a provider
+// has attached to its provider record. The metadata can, for example, contain
+// the retrieval protocols that the provider supports. It is nil if the
+// provider hasn't attached any metadata. Use [DHT.FindProvidersWithMetadataAsync]
+// to receive provider records of this type.
+type ProviderInfo struct {
+	peer.AddrInfo
+	Metadata []byte
+}
+
 // ProvidersBackendConfig is used to construct a [ProvidersBackend]. Use
 // [DefaultProviderBackendConfig] to get a default configuration struct and then
 // modify it to your liking.
@@ -99,6 +141,21 @@ type ProvidersBackendConfig struct {
 	// If you're manually configuring this backend, make sure to align the
 	// filter with the one configured in [Config.AddressFilter].
 	AddressFilter AddressFilter
+
+	// BatchSize specifies the number of provider records that are buffered in
+	// memory before they are written to the datastore as a single batch. A value
+	// of zero disables the write-behind buffer, and every provider record is
+	// written to the datastore right away. If batching is enabled, the
+	// datastore passed to [NewBackendProvider] must implement [ds.Batching].
+	// Buffered records are visible to [ProvidersBackend.Fetch] and are written
+	// to the datastore when the backend is closed. Records that fail to be
+	// written are retried with the next batch.
+	BatchSize int
+
+	// BatchInterval specifies the maximum amount of time provider records stay
+	// in the write-behind buffer before they are flushed to the datastore. This
+	// setting is only considered if BatchSize is greater than zero.
+	BatchInterval time.Duration
 }
 
 // DefaultProviderBackendConfig returns a default [ProvidersBackend]
@@ -120,26 +177,41 @@ func DefaultProviderBackendConfig() (*ProvidersBackendConfig, error) {
 		Logger:          slog.Default(),
 		Tele:            telemetry,
 		AddressFilter:   AddrFilterIdentity, // verify alignment with [Config.AddressFilter]
+		BatchSize:       0,                  // disabled by default
+		BatchInterval:   time.Second,        // MAGIC
 	}, nil
 }
 
 // Store implements the [Backend] interface. In the case of a [ProvidersBackend]
-// this method accepts a [peer.AddrInfo] as a value and stores it in the
-// configured datastore.
+// this method accepts a [peer.AddrInfo] or a [ProviderInfo] as a value and
+// stores it in the configured datastore. The metadata of a [ProviderInfo] must
+// not exceed [MaxProviderMetadataSize] bytes.
 func (p *ProvidersBackend) Store(ctx context.Context, key string, value any) (any, error) {
-	addrInfo, ok := value.(peer.AddrInfo)
-	if !ok {
-		return nil, fmt.Errorf("expected peer.AddrInfo value type, got: %T", value)
+	var info ProviderInfo
+	switch v := value.(type) {
+	case peer.AddrInfo:
+		info = ProviderInfo{AddrInfo: v}
+	case ProviderInfo:
+		info = v
+	default:
+		return nil, fmt.Errorf("expected peer.AddrInfo or ProviderInfo value type, got: %T", value)
+	}
+
+	if len(info.Metadata) > MaxProviderMetadataSize {
+		return nil, fmt.Errorf("provider metadata too large: %d > %d bytes", len(info.Metadata), MaxProviderMetadataSize)
 	}
 
+	addrInfo := info.AddrInfo
+
 	rec := expiryRecord{
-		expiry: p.cfg.clk.Now(),
+		expiry:   p.cfg.clk.Now(),
+		metadata: info.Metadata,
 	}
 
 	cacheKey := newDatastoreKey(p.namespace, key).String()
 	dsKey := newDatastoreKey(p.namespace, key, string(addrInfo.ID))
 	if provs, ok := p.cache.Get(cacheKey); ok {
-		provs.addProvider(addrInfo, rec.expiry)
+		provs.addProvider(info, rec.expiry)
 	}
 
 	filtered := p.cfg.AddressFilter(addrInfo.Addrs)
@@ -147,6 +219,17 @@ func (p *ProvidersBackend) Store(ctx context.Context, key string, value any) (an
 
 	_, found := p.gcSkip.LoadOrStore(dsKey.String(), struct{}{})
 
+	if p.batching != nil {
+		if p.bufferPut(dsKey, rec.MarshalBinary()) {
+			if dropped, err := p.flush(false); dropped > 0 {
+				return nil, fmt.Errorf("flush write-behind buffer: %w", err)
+			} else if err != nil {
+				p.log.LogAttrs(ctx, slog.LevelWarn, "failed flushing provider records", slog.String("err", err.Error()))
+			}
+		}
+		return value, nil
+	}
+
 	if err := p.datastore.Put(ctx, dsKey, rec.MarshalBinary()); err != nil {
 		p.cache.Remove(cacheKey)
 
@@ -159,12 +242,12 @@ func (p *ProvidersBackend) Store(ctx context.Context, key string, value any) (an
 		return nil, fmt.Errorf("datastore put: %w", err)
 	}
 
-	return addrInfo, nil
+	return value, nil
 }
 
 // Fetch implements the [Backend] interface. In the case of a [ProvidersBackend]
-// this method returns a [providerSet] (unexported) that contains all peer IDs
-// and known multiaddresses for the given key. The key parameter should be of
+// this method returns a [providerSet] (unexported) that contains all peer IDs,
+// known multiaddresses, and metadata for the given key. The key parameter should be of
 // the form "/providers/$binary_multihash".
 func (p *ProvidersBackend) Fetch(ctx context.Context, key string) (any, error) {
 	qKey := newDatastoreKey(p.namespace, key)
@@ -188,42 +271,61 @@ func (p *ProvidersBackend) Fetch(ctx context.Context, key string) (any, error) {
 
 	now := p.cfg.clk.Now()
 	out := &providerSet{
-		providers: []peer.AddrInfo{},
+		providers: []ProviderInfo{},
 		set:       make(map[peer.ID]time.Time),
 	}
 
-	for e := range q.Next() {
-		if e.Error != nil {
-			p.log.LogAttrs(ctx, slog.LevelWarn, "Fetch datastore entry contains error", slog.String("key", e.Key), slog.String("err", e.Error.Error()))
-			continue
-		}
-
+	add := func(key string, value []byte) {
 		rec := expiryRecord{}
-		if err = rec.UnmarshalBinary(e.Value); err != nil {
-			p.log.LogAttrs(ctx, slog.LevelWarn, "Fetch provider record unmarshalling failed", slog.String("key", e.Key), slog.String("err", err.Error()))
-			p.delete(ctx, ds.RawKey(e.Key))
-			continue
+		if err := rec.UnmarshalBinary(value); err != nil {
+			p.log.LogAttrs(ctx, slog.LevelWarn, "Fetch provider record unmarshalling failed", slog.String("key", key), slog.String("err", err.Error()))
+			p.delete(ctx, ds.RawKey(key))
+			return
 		} else if now.Sub(rec.expiry) > p.cfg.ProvideValidity {
 			// record is expired
-			p.delete(ctx, ds.RawKey(e.Key))
-			continue
+			p.delete(ctx, ds.RawKey(key))
+			return
 		}
 
-		idx := strings.LastIndex(e.Key, "/")
-		binPeerID, err := base32.RawStdEncoding.DecodeString(e.Key[idx+1:])
+		idx := strings.LastIndex(key, "/")
+		binPeerID, err := base32.RawStdEncoding.DecodeString(key[idx+1:])
 		if err != nil {
-			p.log.LogAttrs(ctx, slog.LevelWarn, "base32 key decoding error", slog.String("key", e.Key), slog.String("err", err.Error()))
-			p.delete(ctx, ds.RawKey(e.Key))
-			continue
+			p.log.LogAttrs(ctx, slog.LevelWarn, "base32 key decoding error", slog.String("key", key), slog.String("err", err.Error()))
+			p.delete(ctx, ds.RawKey(key))
+			return
 		}
 
 		maddrs := p.addrBook.Addrs(peer.ID(binPeerID))
-		addrInfo := peer.AddrInfo{
-			ID:    peer.ID(binPeerID),
-			Addrs: p.cfg.AddressFilter(maddrs),
+		info := ProviderInfo{
+			AddrInfo: peer.AddrInfo{
+				ID:    peer.ID(binPeerID),
+				Addrs: p.cfg.AddressFilter(maddrs),
+			},
+			Metadata: rec.metadata,
 		}
 
-		out.addProvider(addrInfo, rec.expiry)
+		out.addProvider(info, rec.expiry)
+	}
+
+	// records in the write-behind buffer are newer than the ones in the
+	// datastore, so add them after the datastore records.
+	pending := p.bufferedRecords(qKey)
+
+	for e := range q.Next() {
+		if e.Error != nil {
+			p.log.LogAttrs(ctx, slog.LevelWarn, "Fetch datastore entry contains error", slog.String("key", e.Key), slog.String("err", e.Error.Error()))
+			continue
+		}
+
+		if _, found := pending[e.Key]; found {
+			continue
+		}
+
+		add(e.Key, e.Value)
+	}
+
+	for key, value := range pending {
+		add(key, value)
 	}
 
 	if len(out.providers) == 0 {
@@ -274,6 +376,19 @@ func (p *ProvidersBackend) Validate(ctx context.Context, key string, values ...a
 // when the [DHT] "shuts down"/closes.
 func (p *ProvidersBackend) Close() error {
 	p.StopGarbageCollection()
+
+	if p.batching == nil {
+		return nil
+	}
+
+	// a flush that is running completes because it doesn't use the loop's context
+	p.flushCancel()
+	<-p.flushDone
+
+	if _, err := p.flush(true); err != nil {
+		return fmt.Errorf("flush write-behind buffer: %w", err)
+	}
+
 	return nil
 }
 
@@ -386,6 +501,155 @@ func (p *ProvidersBackend) collectGarbage(ctx context.Context) {
 	}
 }
 
+// startFlushLoop starts the loop that periodically writes all buffered
+// provider records to the datastore. The loop is stopped in [Close].
+func (p *ProvidersBackend) startFlushLoop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.flushCancel = cancel
+	p.flushDone = make(chan struct{})
+
+	ticker := p.cfg.clk.Ticker(p.cfg.BatchInterval)
+
+	go func() {
+		defer close(p.flushDone)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := p.flush(false); err != nil {
+					p.log.LogAttrs(ctx, slog.LevelWarn, "failed flushing provider records", slog.String("err", err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// maxBufferedBatches is the number of batches of provider records that the
+// write-behind buffer holds at most while the datastore fails to write them.
+const maxBufferedBatches = 4
+
+// bufferPut adds the given provider record to the write-behind buffer. It
+// returns true if the buffer has reached the configured
+// [ProvidersBackendConfig.BatchSize] and should be flushed.
+func (p *ProvidersBackend) bufferPut(dsKey ds.Key, value []byte) bool {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	p.pending[dsKey.String()] = value
+
+	return len(p.pending) >= p.cfg.BatchSize
+}
+
+// bufferedRecords returns all provider records below the given prefix that are
+// in the write-behind buffer or currently being flushed. The returned map is
+// keyed by the string representation of the datastore key.
+func (p *ProvidersBackend) bufferedRecords(prefix ds.Key) map[string][]byte {
+	out := map[string][]byte{}
+	if p.batching == nil {
+		return out
+	}
+
+	p.pendingMu.RLock()
+	defer p.pendingMu.RUnlock()
+
+	// pending records take precedence over the ones being flushed
+	for _, m := range []map[string][]byte{p.flushing, p.pending} {
+		for key, value := range m {
+			if prefix.IsAncestorOf(ds.RawKey(key)) {
+				out[key] = value
+			}
+		}
+	}
+
+	return out
+}
+
+// flush writes all provider records in the write-behind buffer to the
+// datastore in a single batch. The batch is written with a context that is
+// never cancelled, so neither the request that filled the buffer nor closing
+// the backend can interrupt it. If the batch cannot be written, the records
+// are put back into the buffer and retried with the next flush. If final is
+// true or the buffer would exceed [maxBufferedBatches], the records are
+// dropped instead and the affected cache and garbage collection entries are
+// removed - this mirrors the behaviour of a failed datastore put in the
+// unbuffered case. flush returns the number of dropped records and the error
+// that prevented writing the batch.
+func (p *ProvidersBackend) flush(final bool) (int, error) {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+
+	p.pendingMu.Lock()
+	p.flushing = p.pending
+	p.pending = make(map[string][]byte, p.cfg.BatchSize)
+	p.pendingMu.Unlock()
+
+	ctx := context.Background()
+
+	var err error
+	if len(p.flushing) > 0 {
+		err = p.writeBatch(ctx, p.flushing)
+	}
+
+	// put failed records back in the same critical section so that Fetch never misses them
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	failed := p.flushing
+	p.flushing = nil
+
+	if err == nil {
+		return 0, nil
+	}
+
+	if !final && len(p.pending)+len(failed) <= maxBufferedBatches*p.cfg.BatchSize {
+		for key, value := range failed {
+			// records that were stored while the batch was written are newer
+			if _, found := p.pending[key]; !found {
+				p.pending[key] = value
+			}
+		}
+		return 0, fmt.Errorf("records re-queued: %w", err)
+	}
+
+	dropped := 0
+	for key := range failed {
+		// a newer record for the same provider is still buffered
+		if _, found := p.pending[key]; found {
+			continue
+		}
+		p.cache.Remove(ds.RawKey(key).Parent().String())
+		p.gcSkip.Delete(key)
+		dropped++
+	}
+
+	p.log.LogAttrs(ctx, slog.LevelWarn, "dropped buffered provider records", slog.Int("count", dropped), slog.String("err", err.Error()))
+
+	return dropped, err
+}
+
+// writeBatch writes the given records to the datastore as a single batch.
+func (p *ProvidersBackend) writeBatch(ctx context.Context, records map[string][]byte) error {
+	batch, err := p.batching.Batch(ctx)
+	if err != nil {
+		return fmt.Errorf("new batch: %w", err)
+	}
+
+	for key, value := range records {
+		if err := batch.Put(ctx, ds.RawKey(key), value); err != nil {
+			return fmt.Errorf("batch put: %w", err)
+		}
+	}
+
+	if err := batch.Commit(ctx); err != nil {
+		return fmt.Errorf("batch commit: %w", err)
+	}
+
+	return nil
+}
+
 // trackCacheQuery updates the prometheus metrics about cache hit/miss performance
 func (p *ProvidersBackend) trackCacheQuery(ctx context.Context, hit bool) {
 	set := tele.FromContext(ctx,
@@ -407,19 +671,26 @@ func (p *ProvidersBackend) delete(ctx context.Context, dsKey ds.Key) {
 // for any provider record. This record doesn't include any peer IDs or
 // multiaddresses because peer IDs are part of the key that this record gets
 // stored under and multiaddresses are stored in the addrBook. This record
-// just tracks the expiry time of the record. It implements binary marshalling
-// and unmarshalling methods for easy (de)serialization into the datastore.
+// tracks the expiry time of the record and the optional provider metadata. It
+// implements binary marshalling and unmarshalling methods for easy
+// (de)serialization into the datastore.
 type expiryRecord struct {
-	expiry time.Time
+	expiry   time.Time
+	metadata []byte
 }
 
 // MarshalBinary returns the byte slice that should be stored in the datastore.
 // This method doesn't comply to the [encoding.BinaryMarshaler] interface
 // because it doesn't return an error. We don't need the conformance here
 // though.
+//
+// The metadata is appended verbatim after the varint-encoded expiry time.
+// Records without metadata therefore have the same format as records that
+// were written before metadata support was added.
 func (e *expiryRecord) MarshalBinary() (data []byte) {
-	buf := make([]byte, 16)
+	buf := make([]byte, binary.MaxVarintLen64+len(e.metadata))
 	n := binary.PutVarint(buf, e.expiry.UnixNano())
+	n += copy(buf[n:], e.metadata)
 	return buf[:n]
 }
 
@@ -434,25 +705,38 @@ func (e *expiryRecord) UnmarshalBinary(data []byte) error {
 
 	e.expiry = time.Unix(0, nsec)
 
+	e.metadata = nil
+	if n > 0 && len(data) > n {
+		e.metadata = make([]byte, len(data)-n)
+		copy(e.metadata, data[n:])
+	}
+
 	return nil
 }
 
 // A providerSet is used to gather provider information in a single struct. It
 // also makes sure that the user doesn't add any duplicate peers.
 type providerSet struct {
-	providers []peer.AddrInfo
+	providers []ProviderInfo
 	set       map[peer.ID]time.Time
 }
 
-// addProvider adds the given address information to the providerSet. If the
-// provider already exists, only the time is updated.
-func (ps *providerSet) addProvider(addrInfo peer.AddrInfo, t time.Time) {
-	_, found := ps.set[addrInfo.ID]
+// addProvider adds the given provider information to the providerSet. If the
+// provider already exists, only the time and metadata are updated.
+func (ps *providerSet) addProvider(info ProviderInfo, t time.Time) {
+	_, found := ps.set[info.ID]
 	if !found {
-		ps.providers = append(ps.providers, addrInfo)
+		ps.providers = append(ps.providers, info)
+	} else {
+		for i := range ps.providers {
+			if ps.providers[i].ID == info.ID {
+				ps.providers[i].Metadata = info.Metadata
+				break
+			}
+		}
 	}
 
-	ps.set[addrInfo.ID] = t
+	ps.set[info.ID] = t
 }
 
 // newDatastoreKey assembles a datastore for the given namespace and set of
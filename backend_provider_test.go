@@ -2,8 +2,10 @@ package zikade
 
 import (
 	"context"
+	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -177,3 +179,289 @@ func TestProvidersBackend_Validate(t *testing.T) {
 		assert.Equal(t, 0, idx)
 	})
 }
+
+func TestProvidersBackend_WriteBatch(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	newBatchingBackend := func(t *testing.T, clk clock.Clock) *ProvidersBackend {
+		cfg, err := DefaultProviderBackendConfig()
+		require.NoError(t, err)
+
+		cfg.clk = clk
+		cfg.Logger = devnull
+		cfg.BatchSize = 2
+		cfg.BatchInterval = time.Minute
+
+		return newBackendProvider(t, cfg)
+	}
+
+	t.Run("flush on size", func(t *testing.T) {
+		b := newBatchingBackend(t, clock.NewMock())
+
+		p1, p2 := newAddrInfo(t), newAddrInfo(t)
+
+		_, err := b.Store(ctx, "random-key", p1)
+		require.NoError(t, err)
+
+		_, err = b.datastore.Get(ctx, newDatastoreKey(namespaceProviders, "random-key", string(p1.ID)))
+		assert.ErrorIs(t, err, ds.ErrNotFound)
+
+		_, err = b.Store(ctx, "random-key", p2)
+		require.NoError(t, err)
+
+		for _, p := range []peer.AddrInfo{p1, p2} {
+			_, err = b.datastore.Get(ctx, newDatastoreKey(namespaceProviders, "random-key", string(p.ID)))
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("flush on interval", func(t *testing.T) {
+		clk := clock.NewMock()
+		b := newBatchingBackend(t, clk)
+
+		p := newAddrInfo(t)
+		dsKey := newDatastoreKey(namespaceProviders, "random-key", string(p.ID))
+
+		_, err := b.Store(ctx, "random-key", p)
+		require.NoError(t, err)
+
+		_, err = b.datastore.Get(ctx, dsKey)
+		assert.ErrorIs(t, err, ds.ErrNotFound)
+
+		clk.Add(b.cfg.BatchInterval)
+
+		assert.Eventually(t, func() bool {
+			_, err = b.datastore.Get(ctx, dsKey)
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("fetch sees pending writes", func(t *testing.T) {
+		b := newBatchingBackend(t, clock.NewMock())
+
+		p := newAddrInfo(t)
+		_, err := b.Store(ctx, "random-key", p)
+		require.NoError(t, err)
+
+		val, err := b.Fetch(ctx, "random-key")
+		require.NoError(t, err)
+
+		set, ok := val.(*providerSet)
+		require.True(t, ok)
+		require.Len(t, set.providers, 1)
+		assert.Equal(t, p.ID, set.providers[0].ID)
+	})
+
+	t.Run("flush ignores request context", func(t *testing.T) {
+		b, dstore := newFailingBatchingBackend(t, clock.NewMock())
+
+		p1, p2 := newAddrInfo(t), newAddrInfo(t)
+
+		_, err := b.Store(ctx, "random-key", p1)
+		require.NoError(t, err)
+
+		// the request that fills the buffer is cancelled but the batch is written anyway
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = b.Store(cctx, "random-key", p2)
+		require.NoError(t, err)
+
+		for _, p := range []peer.AddrInfo{p1, p2} {
+			_, err = dstore.Get(ctx, newDatastoreKey(namespaceProviders, "random-key", string(p.ID)))
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("failed flush re-queues records", func(t *testing.T) {
+		clk := clock.NewMock()
+		b, dstore := newFailingBatchingBackend(t, clk)
+		dstore.fail.Store(true)
+
+		p1, p2 := newAddrInfo(t), newAddrInfo(t)
+
+		_, err := b.Store(ctx, "random-key", p1)
+		require.NoError(t, err)
+		_, err = b.Store(ctx, "random-key", p2)
+		require.NoError(t, err)
+
+		// the records are still served from the buffer
+		val, err := b.Fetch(ctx, "random-key")
+		require.NoError(t, err)
+		require.Len(t, val.(*providerSet).providers, 2)
+
+		// and written with the next flush
+		dstore.fail.Store(false)
+		clk.Add(b.cfg.BatchInterval)
+
+		assert.Eventually(t, func() bool {
+			for _, p := range []peer.AddrInfo{p1, p2} {
+				if _, err := dstore.Get(ctx, newDatastoreKey(namespaceProviders, "random-key", string(p.ID))); err != nil {
+					return false
+				}
+			}
+			return true
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("failed flush drops records when buffer is full", func(t *testing.T) {
+		b, dstore := newFailingBatchingBackend(t, clock.NewMock())
+		dstore.fail.Store(true)
+
+		var err error
+		for i := 0; i <= maxBufferedBatches*b.cfg.BatchSize; i++ {
+			_, err = b.Store(ctx, "random-key", newAddrInfo(t))
+			if err != nil {
+				break
+			}
+		}
+		require.Error(t, err)
+
+		// the dropped records are no longer protected from garbage collection
+		b.gcSkip.Range(func(key, value any) bool {
+			t.Errorf("unexpected garbage collection skip entry %s", key)
+			return true
+		})
+	})
+
+	t.Run("close drops records that cannot be written", func(t *testing.T) {
+		b, dstore := newFailingBatchingBackend(t, clock.NewMock())
+		dstore.fail.Store(true)
+
+		p := newAddrInfo(t)
+		dsKey := newDatastoreKey(namespaceProviders, "random-key", string(p.ID))
+
+		_, err := b.Store(ctx, "random-key", p)
+		require.NoError(t, err)
+
+		require.Error(t, b.Close())
+
+		_, found := b.gcSkip.Load(dsKey.String())
+		assert.False(t, found)
+	})
+
+	t.Run("close flushes", func(t *testing.T) {
+		b := newBatchingBackend(t, clock.NewMock())
+
+		p := newAddrInfo(t)
+		_, err := b.Store(ctx, "random-key", p)
+		require.NoError(t, err)
+
+		require.NoError(t, b.Close())
+
+		_, err = b.datastore.Get(ctx, newDatastoreKey(namespaceProviders, "random-key", string(p.ID)))
+		assert.NoError(t, err)
+	})
+}
+
+// failingBatchingDatastore is a datastore whose batches fail to commit while
+// fail is set or when the context of the batch is cancelled.
+type failingBatchingDatastore struct {
+	Datastore
+	fail atomic.Bool
+}
+
+func (f *failingBatchingDatastore) Batch(ctx context.Context) (ds.Batch, error) {
+	b, err := f.Datastore.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &failingBatch{Batch: b, fail: &f.fail}, nil
+}
+
+type failingBatch struct {
+	ds.Batch
+	fail *atomic.Bool
+}
+
+func (b *failingBatch) Commit(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if b.fail.Load() {
+		return errors.New("commit failed")
+	}
+	return b.Batch.Commit(ctx)
+}
+
+// newFailingBatchingBackend returns a batching provider backend whose
+// datastore can be told to fail writing batches.
+func newFailingBatchingBackend(t *testing.T, clk clock.Clock) (*ProvidersBackend, *failingBatchingDatastore) {
+	h := newTestHost(t, libp2p.NoListenAddrs)
+
+	mem, err := InMemoryDatastore()
+	require.NoError(t, err)
+	dstore := &failingBatchingDatastore{Datastore: mem}
+
+	t.Cleanup(func() {
+		if err = mem.Close(); err != nil {
+			t.Logf("closing datastore: %s", err)
+		}
+		if err = h.Close(); err != nil {
+			t.Logf("closing host: %s", err)
+		}
+	})
+
+	cfg, err := DefaultProviderBackendConfig()
+	require.NoError(t, err)
+
+	cfg.clk = clk
+	cfg.Logger = devnull
+	cfg.BatchSize = 2
+	cfg.BatchInterval = time.Minute
+
+	b, err := NewBackendProvider(h.Peerstore(), dstore, cfg)
+	require.NoError(t, err)
+
+	return b, dstore
+}
+
+func TestProvidersBackend_Store_metadata(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	b := newBackendProvider(t, nil)
+
+	t.Run("fetch returns metadata", func(t *testing.T) {
+		info := ProviderInfo{AddrInfo: newAddrInfo(t), Metadata: []byte("transport-bitswap")}
+
+		_, err := b.Store(ctx, "metadata-key", info)
+		require.NoError(t, err)
+
+		val, err := b.Fetch(ctx, "metadata-key")
+		require.NoError(t, err)
+
+		set, ok := val.(*providerSet)
+		require.True(t, ok)
+		require.Len(t, set.providers, 1)
+		assert.Equal(t, info.ID, set.providers[0].ID)
+		assert.Equal(t, info.Metadata, set.providers[0].Metadata)
+	})
+
+	t.Run("metadata too large", func(t *testing.T) {
+		info := ProviderInfo{AddrInfo: newAddrInfo(t), Metadata: make([]byte, MaxProviderMetadataSize+1)}
+
+		_, err := b.Store(ctx, "large-metadata-key", info)
+		assert.Error(t, err)
+	})
+}
+
+func TestExpiryRecord_metadata(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+
+	t.Run("without metadata", func(t *testing.T) {
+		rec := expiryRecord{expiry: now}
+
+		decoded := expiryRecord{}
+		require.NoError(t, decoded.UnmarshalBinary(rec.MarshalBinary()))
+		assert.True(t, now.Equal(decoded.expiry))
+		assert.Nil(t, decoded.metadata)
+	})
+
+	t.Run("with metadata", func(t *testing.T) {
+		rec := expiryRecord{expiry: now, metadata: []byte("transport-bitswap")}
+
+		decoded := expiryRecord{}
+		require.NoError(t, decoded.UnmarshalBinary(rec.MarshalBinary()))
+		assert.True(t, now.Equal(decoded.expiry))
+		assert.Equal(t, rec.metadata, decoded.metadata)
+	})
+}
@@ -19,6 +19,8 @@ import (
 	"go.uber.org/zap/exp/zapslog"
 	"golang.org/x/exp/slog"
 
+	"github.com/plprobelab/zikade/internal/coord/cplutil"
+	"github.com/plprobelab/zikade/internal/coord/kbucket"
 	"github.com/plprobelab/zikade/internal/coord/routing"
 	"github.com/plprobelab/zikade/kadt"
 )
@@ -71,6 +73,10 @@ type (
 	}
 
 	AddressFilter func([]ma.Multiaddr) []ma.Multiaddr
+
+	// RoutingTableOpt describes which routing table implementation the [DHT]
+	// uses if [Config.RoutingTable] is nil.
+	RoutingTableOpt string
 )
 
 const (
@@ -91,6 +97,18 @@ const (
 	// to client mode.
 	ModeOptAutoServer ModeOpt = "auto-server"
 
+	// RoutingTableOptTrie configures the DHT to use a [triert.TrieRT]
+	// routing table that holds all nodes that pass their connectivity check.
+	RoutingTableOptTrie RoutingTableOpt = "trie"
+
+	// RoutingTableOptKBucket configures the DHT to use a routing table made
+	// of k-buckets as described in the original Kademlia paper. Buckets hold
+	// at most [Config.BucketSize] nodes and are only split around the local
+	// node's key. A full bucket prefers its long-lived nodes and only gives
+	// way to a new node once its least recently seen node fails a
+	// connectivity check.
+	RoutingTableOptKBucket RoutingTableOpt = "kbucket"
+
 	// modeClient means that the [DHT] is currently operating in client [mode].
 	// For more information, check ModeOpt documentation.
 	modeClient mode = "client"
@@ -114,6 +132,18 @@ type Config struct {
 	// Query holds the configuration used for queries managed by the DHT.
 	Query *QueryConfig
 
+	// Routing holds the configuration used for maintaining the routing table.
+	Routing *RoutingConfig
+
+	// Diversity holds the configuration that limits the number of peers from
+	// the same network in the routing table and among the peers that records
+	// are stored with.
+	Diversity *DiversityConfig
+
+	// ConnManager holds the configuration for tagging routing table peers in
+	// the libp2p connection manager.
+	ConnManager *ConnManagerConfig
+
 	// BucketSize determines the number of closer peers to return
 	BucketSize int
 
@@ -128,11 +158,15 @@ type Config struct {
 
 	// RoutingTable holds a reference to the specific routing table
 	// implementation that this DHT should use. If this field is nil, the
-	// [triert.TrieRT] routing table will be used. This field will be nil
-	// in the default configuration because a routing table requires information
-	// about the local node.
+	// routing table selected by RoutingTableType will be used. This field
+	// will be nil in the default configuration because a routing table
+	// requires information about the local node.
 	RoutingTable kadt.RoutingTable
 
+	// RoutingTableType selects the routing table implementation that is used
+	// if RoutingTable is nil.
+	RoutingTableType RoutingTableOpt
+
 	// The Backends field holds a map of key namespaces to their corresponding
 	// backend implementation. For example, if we received an IPNS record, the
 	// key will have the form "/ipns/$binary_id". We will forward the handling
@@ -157,6 +191,19 @@ type Config struct {
 	// This datastore must be thread-safe.
 	Datastore Datastore
 
+	// ProviderBatchSize is the number of provider records that the default
+	// providers backend buffers in memory before it writes them to the
+	// Datastore as a single batch. Zero disables the write-behind buffer. See
+	// [ProvidersBackendConfig.BatchSize]. This setting is not considered if
+	// the providers backend is registered in the Backends map.
+	ProviderBatchSize int
+
+	// ProviderBatchInterval is the maximum amount of time provider records
+	// stay in the buffer of the default providers backend before they are
+	// written to the Datastore. This setting is only considered if
+	// ProviderBatchSize is greater than zero.
+	ProviderBatchInterval time.Duration
+
 	// Logger can be used to configure a custom structured logger instance.
 	// By default go.uber.org/zap is used (wrapped in ipfs/go-log).
 	Logger *slog.Logger
@@ -190,20 +237,26 @@ type Config struct {
 // fields come from separate top-level methods prefixed with Default.
 func DefaultConfig() *Config {
 	return &Config{
-		Clock:             clock.New(),
-		Mode:              ModeOptAutoClient,
-		BucketSize:        20, // MAGIC
-		BootstrapPeers:    DefaultBootstrapPeers(),
-		ProtocolID:        ProtocolIPFS,
-		RoutingTable:      nil,                  // nil because a routing table requires information about the local node. triert.TrieRT will be used if this field is nil.
-		Backends:          map[string]Backend{}, // if empty and [ProtocolIPFS] is used, it'll be populated with the ipns, pk and providers backends
-		Datastore:         nil,
-		Logger:            slog.New(zapslog.NewHandler(logging.Logger("dht").Desugar().Core())),
-		TimeoutStreamIdle: time.Minute, // MAGIC
-		AddressFilter:     AddrFilterPrivate,
-		MeterProvider:     otel.GetMeterProvider(),
-		TracerProvider:    otel.GetTracerProvider(),
-		Query:             DefaultQueryConfig(),
+		Clock:                 clock.New(),
+		Mode:                  ModeOptAutoClient,
+		BucketSize:            20, // MAGIC
+		BootstrapPeers:        DefaultBootstrapPeers(),
+		ProtocolID:            ProtocolIPFS,
+		RoutingTableType:      RoutingTableOptTrie,
+		RoutingTable:          nil,                  // nil because a routing table requires information about the local node. RoutingTableType selects the routing table if this field is nil.
+		Backends:              map[string]Backend{}, // if empty and [ProtocolIPFS] is used, it'll be populated with the ipns, pk and providers backends
+		Datastore:             nil,
+		ProviderBatchSize:     0,           // disabled by default
+		ProviderBatchInterval: time.Second, // MAGIC
+		Logger:                slog.New(zapslog.NewHandler(logging.Logger("dht").Desugar().Core())),
+		TimeoutStreamIdle:     time.Minute, // MAGIC
+		AddressFilter:         AddrFilterPrivate,
+		MeterProvider:         otel.GetMeterProvider(),
+		TracerProvider:        otel.GetTracerProvider(),
+		Query:                 DefaultQueryConfig(),
+		Routing:               DefaultRoutingConfig(),
+		Diversity:             DefaultDiversityConfig(),
+		ConnManager:           DefaultConnManagerConfig(),
 	}
 }
 
@@ -219,6 +272,18 @@ func DefaultRoutingTable(nodeID kadt.PeerID) (routing.RoutingTableCpl[kadt.Key,
 	return rt, nil
 }
 
+// KBucketRoutingTable returns a routing table made of k-buckets that hold at
+// most bucketSize nodes each. See [RoutingTableOptKBucket].
+func KBucketRoutingTable(nodeID kadt.PeerID, bucketSize int) (routing.RoutingTableCpl[kadt.Key, kadt.PeerID], error) {
+	rtCfg := kbucket.DefaultConfig()
+	rtCfg.BucketSize = bucketSize
+	rt, err := kbucket.New[kadt.Key, kadt.PeerID](nodeID, rtCfg)
+	if err != nil {
+		return nil, fmt.Errorf("new k-bucket routing table: %w", err)
+	}
+	return rt, nil
+}
+
 // InMemoryDatastore returns an in-memory leveldb datastore.
 func InMemoryDatastore() (Datastore, error) {
 	return leveldb.NewDatastore("", nil)
@@ -241,6 +306,16 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid mode option: %s", c.Mode)
 	}
 
+	switch c.RoutingTableType {
+	case RoutingTableOptTrie:
+	case RoutingTableOptKBucket:
+	default:
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("invalid routing table type: %s", c.RoutingTableType),
+		}
+	}
+
 	if c.Query == nil {
 		return &ConfigurationError{
 			Component: "Config",
@@ -255,6 +330,55 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Routing == nil {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("routing configuration must not be nil"),
+		}
+	}
+
+	if err := c.Routing.Validate(); err != nil {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("invalid routing configuration: %w", err),
+		}
+	}
+
+	if c.Diversity == nil {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("diversity configuration must not be nil"),
+		}
+	}
+
+	if err := c.Diversity.Validate(); err != nil {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("invalid diversity configuration: %w", err),
+		}
+	}
+
+	if c.ConnManager == nil {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("connection manager configuration must not be nil"),
+		}
+	}
+
+	if err := c.ConnManager.Validate(); err != nil {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("invalid connection manager configuration: %w", err),
+		}
+	}
+
+	if c.RoutingTable == nil && c.RoutingTableType == RoutingTableOptKBucket && (c.Diversity.MaxPeersPerCpl > 0 || c.Diversity.MaxPeersPerTable > 0) {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("routing table diversity limits require the trie routing table"),
+		}
+	}
+
 	if c.BucketSize == 0 {
 		return &ConfigurationError{
 			Component: "Config",
@@ -292,6 +416,20 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.ProviderBatchSize < 0 {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("provider batch size must not be negative"),
+		}
+	}
+
+	if c.ProviderBatchSize > 0 && c.ProviderBatchInterval <= 0 {
+		return &ConfigurationError{
+			Component: "Config",
+			Err:       fmt.Errorf("provider batch interval must be greater than zero"),
+		}
+	}
+
 	if c.TimeoutStreamIdle <= 0 {
 		return &ConfigurationError{
 			Component: "Config",
@@ -329,6 +467,16 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// cached lookup results must expire before the provider records do
+	if be, ok := c.Backends[namespaceProviders].(*ProvidersBackend); ok && c.Query.ProviderCacheSize > 0 {
+		if c.Query.ProviderCacheTTL >= be.cfg.ProvideValidity {
+			return &ConfigurationError{
+				Component: "Config",
+				Err:       fmt.Errorf("provider cache ttl must be shorter than the provide validity"),
+			}
+		}
+	}
+
 	if c.AddressFilter == nil {
 		return &ConfigurationError{
 			Component: "Config",
@@ -388,6 +536,51 @@ type QueryConfig struct {
 	// RequestTimeout defines the time to wait before terminating a request to a node that has not responded.
 	RequestTimeout time.Duration
 
+	// AdaptiveRequestTimeout specifies whether the time to wait for a response
+	// from a node should be derived from the round-trip times observed for
+	// earlier requests to that node, similar to how TCP computes its
+	// retransmission timeout. Nodes that have not been contacted before use
+	// a percentile of the round-trip times recently observed across all nodes.
+	// If true, RequestTimeout is the upper bound of the timeout.
+	AdaptiveRequestTimeout bool
+
+	// RequestTimeoutMin defines the lower bound of adaptive request timeouts.
+	// This setting is only considered if AdaptiveRequestTimeout is true.
+	RequestTimeoutMin time.Duration
+
+	// RequestTimeoutPercentile defines the percentile of recently observed
+	// round-trip times that is used as the request timeout for nodes that
+	// have not been contacted before. This setting is only considered if
+	// AdaptiveRequestTimeout is true.
+	RequestTimeoutPercentile float64
+
+	// AdaptiveConcurrency specifies whether the number of queries in flight
+	// and the number of concurrent requests of each query should be adjusted
+	// at runtime instead of using fixed values. Each query raises its number
+	// of concurrent requests while requests succeed quickly and lowers it when
+	// requests fail or responses slow down, much like TCP congestion control.
+	// The number of queries in flight is lowered when the DHT's event loop is
+	// busy and raised when it has spare capacity. If true, Concurrency and
+	// RequestConcurrency are the initial values.
+	AdaptiveConcurrency bool
+
+	// MaxConcurrency defines the upper bound of the number of queries in
+	// flight. This setting is only considered if AdaptiveConcurrency is true.
+	MaxConcurrency int
+
+	// MaxRequestConcurrency defines the upper bound of the number of
+	// concurrent requests of each query. This setting is only considered if
+	// AdaptiveConcurrency is true.
+	MaxRequestConcurrency int
+
+	// PreferScoredNodes specifies whether queries should contact peers that
+	// responded quickly and reliably in the past before other peers that are
+	// roughly as close to the target. The scores only change the order in
+	// which peers are contacted. The results of queries, and therefore the
+	// peers that records are stored with, remain the closest peers to the
+	// target by XOR distance.
+	PreferScoredNodes bool
+
 	// DefaultQuorum specifies the minimum number of identical responses before
 	// a SearchValue/GetValue operation returns. The responses must not only be
 	// identical, but the responses must also correspond to the "best" records
@@ -395,6 +588,43 @@ type QueryConfig struct {
 	// operation. A DefaultQuorum of 0 means that we search the network until
 	// we have exhausted the keyspace.
 	DefaultQuorum int
+
+	// ResolveProviderAddrs specifies whether FindProvidersAsync should look up
+	// the addresses of providers that remote peers returned without any
+	// multiaddresses. Such providers are only sent to the caller after their
+	// addresses were looked up. If the lookup fails, the provider is sent
+	// without addresses.
+	ResolveProviderAddrs bool
+
+	// ResolveProviderAddrsConcurrency defines the maximum number of concurrent
+	// address lookups for a single FindProvidersAsync call. This setting is
+	// only considered if ResolveProviderAddrs is true.
+	ResolveProviderAddrsConcurrency int
+
+	// ResolveProviderAddrsTimeout defines the time to wait for the addresses of
+	// a single provider before sending it to the caller without addresses.
+	// This setting is only considered if ResolveProviderAddrs is true.
+	ResolveProviderAddrsTimeout time.Duration
+
+	// ProviderCacheSize defines the maximum number of CIDs for which the
+	// providers that FindProvidersAsync discovered in the network are cached.
+	// Subsequent lookups for the same CID are answered from this cache instead
+	// of querying the network again. The cache is never used to answer
+	// requests from other peers. A value of 0 disables the cache.
+	ProviderCacheSize int
+
+	// ProviderCacheTTL defines for how long the results of a provider lookup
+	// are cached. It must be shorter than the provider record validity
+	// ([ProvidersBackendConfig.ProvideValidity]). This setting is only
+	// considered if ProviderCacheSize is greater than zero.
+	ProviderCacheTTL time.Duration
+
+	// CoalesceLookups specifies whether concurrent lookups for the same key,
+	// e.g., from concurrent FindProvidersAsync or GetValue calls, should share
+	// a single query. If true, callers that start a lookup while a query with
+	// the same message type, key and number of results is in flight attach to
+	// that query and receive the same stream of results.
+	CoalesceLookups bool
 }
 
 // DefaultQueryConfig returns the default query configuration options for a DHT.
@@ -405,6 +635,25 @@ func DefaultQueryConfig() *QueryConfig {
 		RequestConcurrency: 3,               // MAGIC
 		RequestTimeout:     time.Minute,     // MAGIC
 		DefaultQuorum:      0,               // MAGIC
+
+		AdaptiveRequestTimeout:   false,
+		RequestTimeoutMin:        time.Second, // MAGIC
+		RequestTimeoutPercentile: 0.99,        // MAGIC
+
+		AdaptiveConcurrency:   false,
+		MaxConcurrency:        10, // MAGIC
+		MaxRequestConcurrency: 10, // MAGIC
+
+		PreferScoredNodes: false,
+
+		ResolveProviderAddrs:            false,
+		ResolveProviderAddrsConcurrency: 3,                // MAGIC
+		ResolveProviderAddrsTimeout:     10 * time.Second, // MAGIC
+
+		ProviderCacheSize: 0,               // disabled by default
+		ProviderCacheTTL:  5 * time.Minute, // MAGIC
+
+		CoalesceLookups: false,
 	}
 }
 
@@ -437,6 +686,41 @@ func (cfg *QueryConfig) Validate() error {
 		}
 	}
 
+	if cfg.AdaptiveRequestTimeout && cfg.RequestTimeoutMin < 1 {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("request timeout min must be greater than zero"),
+		}
+	}
+
+	if cfg.AdaptiveRequestTimeout && cfg.RequestTimeoutMin > cfg.RequestTimeout {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("request timeout min must not be greater than request timeout"),
+		}
+	}
+
+	if cfg.AdaptiveRequestTimeout && (cfg.RequestTimeoutPercentile <= 0 || cfg.RequestTimeoutPercentile > 1) {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("request timeout percentile must be greater than zero and not greater than one"),
+		}
+	}
+
+	if cfg.AdaptiveConcurrency && cfg.MaxConcurrency < cfg.Concurrency {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("max concurrency must not be less than concurrency"),
+		}
+	}
+
+	if cfg.AdaptiveConcurrency && cfg.MaxRequestConcurrency < cfg.RequestConcurrency {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("max request concurrency must not be less than request concurrency"),
+		}
+	}
+
 	if cfg.DefaultQuorum < 0 {
 		return &ConfigurationError{
 			Component: "QueryConfig",
@@ -444,5 +728,552 @@ func (cfg *QueryConfig) Validate() error {
 		}
 	}
 
+	if cfg.ResolveProviderAddrs && cfg.ResolveProviderAddrsConcurrency < 1 {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("resolve provider addrs concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.ResolveProviderAddrs && cfg.ResolveProviderAddrsTimeout < 1 {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("resolve provider addrs timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.ProviderCacheSize < 0 {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("provider cache size must not be negative"),
+		}
+	}
+
+	if cfg.ProviderCacheSize > 0 && cfg.ProviderCacheTTL < 1 {
+		return &ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("provider cache ttl must be greater than zero"),
+		}
+	}
+
 	return nil
 }
+
+// RoutingConfig contains the configuration options for maintaining the routing
+// table of a [DHT] and for storing records with the closest peers.
+type RoutingConfig struct {
+	// BootstrapTimeout defines the time to wait before terminating a
+	// bootstrap that is not making progress.
+	BootstrapTimeout time.Duration
+
+	// BootstrapRequestConcurrency defines the maximum number of concurrent
+	// requests that a bootstrap may have in flight.
+	BootstrapRequestConcurrency int
+
+	// BootstrapRequestTimeout defines the time to wait for a response from a
+	// single peer during a bootstrap.
+	BootstrapRequestTimeout time.Duration
+
+	// ConnectivityCheckTimeout defines the time to wait for a response from a
+	// peer whose connectivity is checked before it is added to the routing
+	// table and while it is in the routing table.
+	ConnectivityCheckTimeout time.Duration
+
+	// ProbeRequestConcurrency defines the maximum number of concurrent
+	// connectivity checks of peers in the routing table.
+	ProbeRequestConcurrency int
+
+	// ProbeCheckInterval defines the time between connectivity checks of the
+	// same peer in the routing table.
+	ProbeCheckInterval time.Duration
+
+	// ProbeMaxCheckInterval defines the time between connectivity checks of
+	// peers that have proven to be consistently useful. Peers are checked
+	// less often the more useful they are. It must not be less than
+	// ProbeCheckInterval.
+	ProbeMaxCheckInterval time.Duration
+
+	// ProbeMaxFailures defines the number of consecutive connectivity checks
+	// a peer in the routing table may fail before it is removed.
+	ProbeMaxFailures int
+
+	// ProbeFailureBackoff defines the time to wait before checking a peer
+	// again after its first failed connectivity check. The time doubles with
+	// every further failure.
+	ProbeFailureBackoff time.Duration
+
+	// ProbeOfflineWindow defines the number of most recent connectivity checks
+	// that are used to detect that the local node lost connectivity. No peers
+	// are removed from the routing table while connectivity is lost. A value
+	// of 0 disables the detection.
+	ProbeOfflineWindow int
+
+	// ProbeOfflineThreshold defines the share of failed checks among the most
+	// recent ProbeOfflineWindow checks at or above which connectivity is
+	// considered lost. It must be greater than zero and not greater than one.
+	ProbeOfflineThreshold float64
+
+	// IncludeQueueCapacity defines the maximum number of peers that are
+	// queued as candidates for inclusion in the routing table.
+	IncludeQueueCapacity int
+
+	// IncludeRequestConcurrency defines the maximum number of concurrent
+	// connectivity checks of candidates for inclusion in the routing table.
+	IncludeRequestConcurrency int
+
+	// IncludeReplacementCapacity defines the maximum number of verified peers
+	// that are kept for each bucket of the routing table to replace peers
+	// that are removed from it. A value of 0 disables replacements.
+	IncludeReplacementCapacity int
+
+	// IncludeCandidateTTL defines the time a candidate may wait for inclusion
+	// in the routing table before it is dropped.
+	IncludeCandidateTTL time.Duration
+
+	// ExploreTimeout defines the time to wait before terminating the
+	// exploration of a bucket of the routing table that is not making
+	// progress.
+	ExploreTimeout time.Duration
+
+	// ExploreRequestConcurrency defines the maximum number of concurrent
+	// requests that the exploration of a bucket may have in flight.
+	ExploreRequestConcurrency int
+
+	// ExploreRequestTimeout defines the time to wait for a response from a
+	// single peer during the exploration of a bucket.
+	ExploreRequestTimeout time.Duration
+
+	// ExploreMaximumCpl defines the maximum common prefix length of the
+	// buckets that are explored. All buckets from this common prefix length
+	// to zero are explored on a repeating schedule. Exploring a bucket with a
+	// common prefix length greater than 15 requires searching for a random
+	// peer ID that falls into it, which takes twice as long for every
+	// additional bit. Buckets with a common prefix length greater than 20
+	// are therefore explored by looking up the local node's own peer ID,
+	// whose closest peers fill these buckets. It must be between 1 and 255.
+	ExploreMaximumCpl int
+
+	// ExploreInterval defines the time between explorations of the bucket
+	// with the maximum common prefix length. This setting is not considered
+	// if AdaptiveExplore is true.
+	ExploreInterval time.Duration
+
+	// ExploreIntervalMultiplier defines the factor that is applied to
+	// ExploreInterval for each common prefix length below the maximum to
+	// explore more distant buckets less often. It must not be less than one.
+	// This setting is not considered if AdaptiveExplore is true.
+	ExploreIntervalMultiplier float64
+
+	// ExploreIntervalJitter defines the factor by which the time between
+	// explorations is randomly increased. It must be between 0 and 0.05.
+	// This setting is not considered if AdaptiveExplore is true.
+	ExploreIntervalJitter float64
+
+	// AdaptiveExplore specifies whether the buckets of the routing table
+	// should be explored on a schedule that adapts to their occupancy instead
+	// of on fixed intervals. Buckets that hold fewer than [Config.BucketSize]
+	// peers or whose number of peers recently changed are explored sooner,
+	// while full buckets whose peers are stable are explored later.
+	AdaptiveExplore bool
+
+	// ExploreMinInterval defines the time to wait between explorations of the
+	// most under-filled buckets. This setting is only considered if
+	// AdaptiveExplore is true.
+	ExploreMinInterval time.Duration
+
+	// ExploreMaxInterval defines the time to wait between explorations of
+	// full and stable buckets. This setting is only considered if
+	// AdaptiveExplore is true.
+	ExploreMaxInterval time.Duration
+
+	// BroadcastConcurrency defines the maximum number of lookups for the
+	// closest peers to store a record with that may be waiting for responses
+	// at any one time.
+	BroadcastConcurrency int
+
+	// BroadcastTimeout defines the time to wait before terminating a lookup
+	// for the closest peers to store a record with that is not making
+	// progress.
+	BroadcastTimeout time.Duration
+
+	// BroadcastRequestConcurrency defines the maximum number of concurrent
+	// requests that each lookup for the closest peers to store a record with
+	// may have in flight.
+	BroadcastRequestConcurrency int
+
+	// BroadcastRequestTimeout defines the time to wait for a response from a
+	// single peer during a lookup for the closest peers to store a record
+	// with.
+	BroadcastRequestTimeout time.Duration
+}
+
+// DefaultRoutingConfig returns the default routing configuration options for a DHT.
+func DefaultRoutingConfig() *RoutingConfig {
+	return &RoutingConfig{
+		BootstrapTimeout:            5 * time.Minute, // MAGIC
+		BootstrapRequestConcurrency: 3,               // MAGIC
+		BootstrapRequestTimeout:     time.Minute,     // MAGIC
+
+		ConnectivityCheckTimeout: time.Minute, // MAGIC
+
+		ProbeRequestConcurrency: 3,              // MAGIC
+		ProbeCheckInterval:      6 * time.Hour,  // MAGIC
+		ProbeMaxCheckInterval:   24 * time.Hour, // MAGIC
+		ProbeMaxFailures:        3,              // MAGIC
+		ProbeFailureBackoff:     time.Minute,    // MAGIC
+		ProbeOfflineWindow:      10,             // MAGIC
+		ProbeOfflineThreshold:   0.8,            // MAGIC
+
+		IncludeQueueCapacity:       128,              // MAGIC
+		IncludeRequestConcurrency:  3,                // MAGIC
+		IncludeReplacementCapacity: 8,                // MAGIC
+		IncludeCandidateTTL:        10 * time.Minute, // MAGIC
+
+		ExploreTimeout:            5 * time.Minute, // MAGIC
+		ExploreRequestConcurrency: 3,               // MAGIC
+		ExploreRequestTimeout:     time.Minute,     // MAGIC
+		ExploreMaximumCpl:         14,              // MAGIC
+		ExploreInterval:           time.Hour,       // MAGIC
+		ExploreIntervalMultiplier: 1,               // MAGIC
+		ExploreIntervalJitter:     0,               // MAGIC
+
+		AdaptiveExplore:    false,
+		ExploreMinInterval: 10 * time.Minute, // MAGIC
+		ExploreMaxInterval: 12 * time.Hour,   // MAGIC
+
+		BroadcastConcurrency:        3,               // MAGIC
+		BroadcastTimeout:            5 * time.Minute, // MAGIC
+		BroadcastRequestConcurrency: 3,               // MAGIC
+		BroadcastRequestTimeout:     time.Minute,     // MAGIC
+	}
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *RoutingConfig) Validate() error {
+	if cfg.BootstrapTimeout < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("bootstrap timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.BootstrapRequestConcurrency < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("bootstrap request concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.BootstrapRequestTimeout < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("bootstrap request timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.ConnectivityCheckTimeout < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("connectivity check timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.ProbeRequestConcurrency < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe request concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.ProbeCheckInterval < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe check interval must be greater than zero"),
+		}
+	}
+
+	if cfg.ProbeMaxCheckInterval < cfg.ProbeCheckInterval {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe max check interval must not be less than probe check interval"),
+		}
+	}
+
+	if cfg.ProbeMaxFailures < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe max failures must be greater than zero"),
+		}
+	}
+
+	if cfg.ProbeFailureBackoff < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe failure backoff must be greater than zero"),
+		}
+	}
+
+	if cfg.ProbeOfflineWindow < 0 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe offline window must not be negative"),
+		}
+	}
+
+	if cfg.ProbeOfflineThreshold <= 0 || cfg.ProbeOfflineThreshold > 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe offline threshold must be greater than zero and not greater than one"),
+		}
+	}
+
+	if cfg.IncludeQueueCapacity < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("include queue capacity must be greater than zero"),
+		}
+	}
+
+	if cfg.IncludeRequestConcurrency < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("include request concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.IncludeReplacementCapacity < 0 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("include replacement capacity must not be negative"),
+		}
+	}
+
+	if cfg.IncludeCandidateTTL < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("include candidate ttl must be greater than zero"),
+		}
+	}
+
+	if cfg.ExploreTimeout < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.ExploreRequestConcurrency < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore request concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.ExploreRequestTimeout < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore request timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.ExploreMaximumCpl < 1 || cfg.ExploreMaximumCpl > cplutil.MaxCpl {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore maximum cpl must be greater than zero and not greater than %d", cplutil.MaxCpl),
+		}
+	}
+
+	if !cfg.AdaptiveExplore && cfg.ExploreInterval < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore interval must be greater than zero"),
+		}
+	}
+
+	if !cfg.AdaptiveExplore && cfg.ExploreIntervalMultiplier < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore interval multiplier must not be less than one"),
+		}
+	}
+
+	if !cfg.AdaptiveExplore && (cfg.ExploreIntervalJitter < 0 || cfg.ExploreIntervalJitter > 0.05) {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore interval jitter must be between 0 and 0.05"),
+		}
+	}
+
+	if cfg.AdaptiveExplore && cfg.ExploreMinInterval < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore min interval must be greater than zero"),
+		}
+	}
+
+	if cfg.AdaptiveExplore && cfg.ExploreMaxInterval < cfg.ExploreMinInterval {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore max interval must not be less than explore min interval"),
+		}
+	}
+
+	if cfg.BroadcastConcurrency < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("broadcast concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.BroadcastTimeout < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("broadcast timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.BroadcastRequestConcurrency < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("broadcast request concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.BroadcastRequestTimeout < 1 {
+		return &ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("broadcast request timeout must be greater than zero"),
+		}
+	}
+
+	return nil
+}
+
+// DiversityConfig contains the configuration options that limit the number of
+// peers whose addresses belong to the same group, e.g., the same IP network or
+// autonomous system. A limit of zero disables the respective check. All checks
+// are disabled by default.
+type DiversityConfig struct {
+	// MaxPeersPerCpl is the maximum number of peers in the routing table that
+	// share the same common prefix length with the local node and belong to
+	// the same group. Only applies if [Config.RoutingTable] is nil and
+	// [Config.RoutingTableType] is [RoutingTableOptTrie].
+	MaxPeersPerCpl int
+
+	// MaxPeersPerTable is the maximum number of peers in the routing table
+	// that belong to the same group. Only applies if [Config.RoutingTable] is
+	// nil and [Config.RoutingTableType] is [RoutingTableOptTrie].
+	MaxPeersPerTable int
+
+	// MaxPeersPerBroadcast is the maximum number of peers that belong to the
+	// same group among the peers that a record is stored with.
+	MaxPeersPerBroadcast int
+
+	// Group assigns the addresses of peers to groups. See [IPGroupPrefix] and
+	// [IPGroupASN].
+	Group IPGroupFunc
+}
+
+// DefaultDiversityConfig returns the default diversity configuration options
+// for a DHT.
+func DefaultDiversityConfig() *DiversityConfig {
+	return &DiversityConfig{
+		MaxPeersPerCpl:       0,
+		MaxPeersPerTable:     0,
+		MaxPeersPerBroadcast: 0,
+		Group:                IPGroupPrefix(16, 32), // MAGIC
+	}
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *DiversityConfig) Validate() error {
+	if cfg.MaxPeersPerCpl < 0 {
+		return &ConfigurationError{
+			Component: "DiversityConfig",
+			Err:       fmt.Errorf("max peers per cpl must not be negative"),
+		}
+	}
+
+	if cfg.MaxPeersPerTable < 0 {
+		return &ConfigurationError{
+			Component: "DiversityConfig",
+			Err:       fmt.Errorf("max peers per table must not be negative"),
+		}
+	}
+
+	if cfg.MaxPeersPerBroadcast < 0 {
+		return &ConfigurationError{
+			Component: "DiversityConfig",
+			Err:       fmt.Errorf("max peers per broadcast must not be negative"),
+		}
+	}
+
+	if cfg.enabled() && cfg.Group == nil {
+		return &ConfigurationError{
+			Component: "DiversityConfig",
+			Err:       fmt.Errorf("group function must not be nil"),
+		}
+	}
+
+	return nil
+}
+
+// enabled returns true if any diversity limit is enabled.
+func (cfg *DiversityConfig) enabled() bool {
+	return cfg.MaxPeersPerCpl > 0 || cfg.MaxPeersPerTable > 0 || cfg.MaxPeersPerBroadcast > 0
+}
+
+// ConnManagerConfig contains the configuration options for tagging the peers
+// in the routing table in the libp2p connection manager. Tagged peers are
+// less likely to have their connections trimmed, which spares the DHT from
+// reconnecting to them and failing connectivity checks. Peers are tagged when
+// they are added to the routing table and untagged when they are removed.
+type ConnManagerConfig struct {
+	// Weight is the value of the tag of a routing table peer that shares no
+	// common prefix with the local node. Zero disables tagging unless
+	// CplWeight is positive.
+	Weight int
+
+	// CplWeight is added to the value of the tag of a routing table peer for
+	// every bit of the common prefix it shares with the local node, so that
+	// peers close to the local node in the keyspace are kept longer.
+	CplWeight int
+
+	// Protect protects the connections of routing table peers from being
+	// trimmed by the connection manager at all.
+	Protect bool
+}
+
+// DefaultConnManagerConfig returns the default connection manager
+// configuration options for a DHT.
+func DefaultConnManagerConfig() *ConnManagerConfig {
+	return &ConnManagerConfig{
+		Weight:    5, // MAGIC
+		CplWeight: 1, // MAGIC
+		Protect:   false,
+	}
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *ConnManagerConfig) Validate() error {
+	if cfg.Weight < 0 {
+		return &ConfigurationError{
+			Component: "ConnManagerConfig",
+			Err:       fmt.Errorf("weight must not be negative"),
+		}
+	}
+
+	if cfg.CplWeight < 0 {
+		return &ConfigurationError{
+			Component: "ConnManagerConfig",
+			Err:       fmt.Errorf("cpl weight must not be negative"),
+		}
+	}
+
+	return nil
+}
+
+// enabled returns true if routing table peers are tagged or protected.
+func (cfg *ConnManagerConfig) enabled() bool {
+	return cfg.Weight > 0 || cfg.CplWeight > 0 || cfg.Protect
+}
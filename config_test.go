@@ -8,6 +8,8 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -22,6 +24,24 @@ func TestConfig_Validate(t *testing.T) {
 		assert.Error(t, cfg.Validate())
 	})
 
+	t.Run("invalid routing table type", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.RoutingTableType = "invalid"
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("kbucket routing table with diversity limits", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.RoutingTableType = RoutingTableOptKBucket
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Diversity.MaxPeersPerBroadcast = 2
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Diversity.MaxPeersPerTable = 2
+		assert.Error(t, cfg.Validate())
+	})
+
 	t.Run("nil Query configuration", func(t *testing.T) {
 		cfg := DefaultConfig()
 		cfg.Query = nil
@@ -34,6 +54,43 @@ func TestConfig_Validate(t *testing.T) {
 		assert.Error(t, cfg.Validate())
 	})
 
+	t.Run("nil Routing configuration", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Routing = nil
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("invalid Routing configuration", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Routing.AdaptiveExplore = true
+		cfg.Routing.ExploreMinInterval = 0
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("nil Diversity configuration", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Diversity = nil
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("invalid Diversity configuration", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Diversity.MaxPeersPerCpl = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("nil ConnManager configuration", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.ConnManager = nil
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("invalid ConnManager configuration", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.ConnManager.Weight = -1
+		assert.Error(t, cfg.Validate())
+	})
+
 	t.Run("empty protocol", func(t *testing.T) {
 		cfg := DefaultConfig()
 		cfg.ProtocolID = ""
@@ -46,6 +103,22 @@ func TestConfig_Validate(t *testing.T) {
 		assert.Error(t, cfg.Validate())
 	})
 
+	t.Run("negative provider batch size", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.ProviderBatchSize = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("provider batch interval only considered with batching", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.ProviderBatchInterval = 0
+		assert.NoError(t, cfg.Validate())
+		cfg.ProviderBatchSize = 10
+		assert.Error(t, cfg.Validate())
+		cfg.ProviderBatchInterval = time.Second
+		assert.NoError(t, cfg.Validate())
+	})
+
 	t.Run("0 stream idle timeout", func(t *testing.T) {
 		cfg := DefaultConfig()
 		cfg.TimeoutStreamIdle = time.Duration(0)
@@ -107,6 +180,19 @@ func TestConfig_Validate(t *testing.T) {
 		assert.Error(t, cfg.Validate())
 	})
 
+	t.Run("provider cache ttl exceeds provide validity", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.ProtocolID = ProtocolIPFS
+		cfg.Backends[namespaceIPNS] = &RecordBackend{}
+		cfg.Backends[namespacePublicKey] = &RecordBackend{}
+		cfg.Backends[namespaceProviders] = newBackendProvider(t, nil)
+		cfg.Query.ProviderCacheSize = 10
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Query.ProviderCacheTTL = 48 * time.Hour
+		assert.Error(t, cfg.Validate())
+	})
+
 	t.Run("nil address filter", func(t *testing.T) {
 		cfg := DefaultConfig()
 		cfg.AddressFilter = nil
@@ -205,6 +291,47 @@ func TestQueryConfig_Validate(t *testing.T) {
 		assert.Error(t, cfg.Validate())
 	})
 
+	t.Run("adaptive concurrency bounds", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+
+		cfg.MaxConcurrency = 0
+		cfg.MaxRequestConcurrency = 0
+		assert.NoError(t, cfg.Validate()) // not considered when disabled
+
+		cfg.AdaptiveConcurrency = true
+		assert.Error(t, cfg.Validate())
+		cfg.MaxConcurrency = cfg.Concurrency
+		assert.Error(t, cfg.Validate())
+		cfg.MaxRequestConcurrency = cfg.RequestConcurrency
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("adaptive request timeout min", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+
+		cfg.RequestTimeoutMin = 0
+		assert.NoError(t, cfg.Validate()) // not considered when disabled
+
+		cfg.AdaptiveRequestTimeout = true
+		assert.Error(t, cfg.Validate())
+		cfg.RequestTimeoutMin = cfg.RequestTimeout + 1
+		assert.Error(t, cfg.Validate())
+		cfg.RequestTimeoutMin = cfg.RequestTimeout
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("adaptive request timeout percentile", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.AdaptiveRequestTimeout = true
+
+		cfg.RequestTimeoutPercentile = 0
+		assert.Error(t, cfg.Validate())
+		cfg.RequestTimeoutPercentile = 1.1
+		assert.Error(t, cfg.Validate())
+		cfg.RequestTimeoutPercentile = 1
+		assert.NoError(t, cfg.Validate())
+	})
+
 	t.Run("negative default quorum", func(t *testing.T) {
 		cfg := DefaultQueryConfig()
 
@@ -213,4 +340,233 @@ func TestQueryConfig_Validate(t *testing.T) {
 		cfg.DefaultQuorum = -1
 		assert.Error(t, cfg.Validate())
 	})
+
+	t.Run("resolve provider addrs concurrency positive", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.ResolveProviderAddrs = true
+
+		cfg.ResolveProviderAddrsConcurrency = 0
+		assert.Error(t, cfg.Validate())
+		cfg.ResolveProviderAddrsConcurrency = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("resolve provider addrs timeout positive", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.ResolveProviderAddrs = true
+
+		cfg.ResolveProviderAddrsTimeout = 0
+		assert.Error(t, cfg.Validate())
+		cfg.ResolveProviderAddrsTimeout = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("provider cache size not negative", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+
+		cfg.ProviderCacheSize = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("provider cache ttl positive", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.ProviderCacheSize = 10
+
+		cfg.ProviderCacheTTL = 0
+		assert.Error(t, cfg.Validate())
+		cfg.ProviderCacheTTL = -1
+		assert.Error(t, cfg.Validate())
+	})
+}
+
+func TestRoutingConfig_Validate(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("probe max check interval not less than check interval", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.ProbeMaxCheckInterval = cfg.ProbeCheckInterval - 1
+		assert.Error(t, cfg.Validate())
+		cfg.ProbeMaxCheckInterval = cfg.ProbeCheckInterval
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("probe offline threshold between 0 and 1", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.ProbeOfflineThreshold = 0
+		assert.Error(t, cfg.Validate())
+		cfg.ProbeOfflineThreshold = 1.1
+		assert.Error(t, cfg.Validate())
+		cfg.ProbeOfflineThreshold = 1
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("include replacement capacity not negative", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.IncludeReplacementCapacity = -1
+		assert.Error(t, cfg.Validate())
+		cfg.IncludeReplacementCapacity = 0
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("explore maximum cpl between 1 and 255", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.ExploreMaximumCpl = 0
+		assert.Error(t, cfg.Validate())
+		cfg.ExploreMaximumCpl = 256
+		assert.Error(t, cfg.Validate())
+		cfg.ExploreMaximumCpl = 21
+		assert.NoError(t, cfg.Validate())
+		cfg.ExploreMaximumCpl = 255
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("explore interval only considered without adaptive explore", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.ExploreInterval = 0
+		assert.Error(t, cfg.Validate())
+		cfg.AdaptiveExplore = true
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("broadcast concurrency positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.BroadcastConcurrency = 0
+		assert.Error(t, cfg.Validate())
+		cfg.BroadcastConcurrency = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("broadcast request timeout positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.BroadcastRequestTimeout = 0
+		assert.Error(t, cfg.Validate())
+		cfg.BroadcastRequestTimeout = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("explore min interval positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.AdaptiveExplore = true
+
+		cfg.ExploreMinInterval = 0
+		assert.Error(t, cfg.Validate())
+		cfg.ExploreMinInterval = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("explore max interval not less than min interval", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.AdaptiveExplore = true
+
+		cfg.ExploreMaxInterval = cfg.ExploreMinInterval - 1
+		assert.Error(t, cfg.Validate())
+		cfg.ExploreMaxInterval = cfg.ExploreMinInterval
+		assert.NoError(t, cfg.Validate())
+	})
+}
+
+func TestDiversityConfig_Validate(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		cfg := DefaultDiversityConfig()
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("limits not negative", func(t *testing.T) {
+		cfg := DefaultDiversityConfig()
+		cfg.MaxPeersPerCpl = -1
+		assert.Error(t, cfg.Validate())
+
+		cfg = DefaultDiversityConfig()
+		cfg.MaxPeersPerTable = -1
+		assert.Error(t, cfg.Validate())
+
+		cfg = DefaultDiversityConfig()
+		cfg.MaxPeersPerBroadcast = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("group only required with limits", func(t *testing.T) {
+		cfg := DefaultDiversityConfig()
+		cfg.Group = nil
+		assert.NoError(t, cfg.Validate())
+
+		cfg.MaxPeersPerBroadcast = 2
+		assert.Error(t, cfg.Validate())
+	})
+}
+
+func TestConnManagerConfig_Validate(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		cfg := DefaultConnManagerConfig()
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("weights not negative", func(t *testing.T) {
+		cfg := DefaultConnManagerConfig()
+		cfg.Weight = -1
+		assert.Error(t, cfg.Validate())
+
+		cfg = DefaultConnManagerConfig()
+		cfg.CplWeight = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("disabled with zero weights", func(t *testing.T) {
+		cfg := DefaultConnManagerConfig()
+		assert.True(t, cfg.enabled())
+
+		cfg.Weight = 0
+		cfg.CplWeight = 0
+		assert.NoError(t, cfg.Validate())
+		assert.False(t, cfg.enabled())
+
+		cfg.Protect = true
+		assert.True(t, cfg.enabled())
+	})
+}
+
+func TestDefaultRoutingConfig_matchesCoordinator(t *testing.T) {
+	cfg := DefaultRoutingConfig()
+	rcfg := coord.DefaultRoutingConfig()
+	bcfg := coord.DefaultBroadcastConfig()
+
+	assert.Equal(t, rcfg.BootstrapTimeout, cfg.BootstrapTimeout)
+	assert.Equal(t, rcfg.BootstrapRequestConcurrency, cfg.BootstrapRequestConcurrency)
+	assert.Equal(t, rcfg.BootstrapRequestTimeout, cfg.BootstrapRequestTimeout)
+	assert.Equal(t, rcfg.ConnectivityCheckTimeout, cfg.ConnectivityCheckTimeout)
+	assert.Equal(t, rcfg.ProbeRequestConcurrency, cfg.ProbeRequestConcurrency)
+	assert.Equal(t, rcfg.ProbeCheckInterval, cfg.ProbeCheckInterval)
+	assert.Equal(t, rcfg.ProbeMaxCheckInterval, cfg.ProbeMaxCheckInterval)
+	assert.Equal(t, rcfg.ProbeMaxFailures, cfg.ProbeMaxFailures)
+	assert.Equal(t, rcfg.ProbeFailureBackoff, cfg.ProbeFailureBackoff)
+	assert.Equal(t, rcfg.ProbeOfflineWindow, cfg.ProbeOfflineWindow)
+	assert.Equal(t, rcfg.ProbeOfflineThreshold, cfg.ProbeOfflineThreshold)
+	assert.Equal(t, rcfg.IncludeQueueCapacity, cfg.IncludeQueueCapacity)
+	assert.Equal(t, rcfg.IncludeRequestConcurrency, cfg.IncludeRequestConcurrency)
+	assert.Equal(t, rcfg.IncludeReplacementCapacity, cfg.IncludeReplacementCapacity)
+	assert.Equal(t, rcfg.IncludeCandidateTTL, cfg.IncludeCandidateTTL)
+	assert.Equal(t, rcfg.ExploreTimeout, cfg.ExploreTimeout)
+	assert.Equal(t, rcfg.ExploreRequestConcurrency, cfg.ExploreRequestConcurrency)
+	assert.Equal(t, rcfg.ExploreRequestTimeout, cfg.ExploreRequestTimeout)
+	assert.Equal(t, rcfg.ExploreMaximumCpl, cfg.ExploreMaximumCpl)
+	assert.Equal(t, rcfg.ExploreInterval, cfg.ExploreInterval)
+	assert.Equal(t, rcfg.ExploreIntervalMultiplier, cfg.ExploreIntervalMultiplier)
+	assert.Equal(t, rcfg.ExploreIntervalJitter, cfg.ExploreIntervalJitter)
+	assert.Equal(t, rcfg.ExploreAdaptiveSchedule, cfg.AdaptiveExplore)
+	assert.Equal(t, rcfg.ExploreMinInterval, cfg.ExploreMinInterval)
+	assert.Equal(t, rcfg.ExploreMaxInterval, cfg.ExploreMaxInterval)
+	assert.Equal(t, bcfg.Concurrency, cfg.BroadcastConcurrency)
+	assert.Equal(t, bcfg.Timeout, cfg.BroadcastTimeout)
+	assert.Equal(t, bcfg.RequestConcurrency, cfg.BroadcastRequestConcurrency)
+	assert.Equal(t, bcfg.RequestTimeout, cfg.BroadcastRequestTimeout)
 }
@@ -0,0 +1,120 @@
+package zikade
+
+import (
+	"context"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/plprobelab/zikade/internal/coord"
+	"github.com/plprobelab/zikade/internal/coord/routing"
+	"github.com/plprobelab/zikade/kadt"
+)
+
+// connManagerTag is the tag that routing table peers carry in the libp2p
+// connection manager.
+const connManagerTag = "zikade-rt"
+
+var _ coord.RoutingNotifier = (*connManagerNotifier)(nil)
+
+// connManagerNotifier is a [coord.RoutingNotifier] that tags the peers in the
+// routing table in the libp2p connection manager, so that their connections
+// are less likely to be trimmed. Peers are tagged when they are added to the
+// routing table and untagged when they are removed from it.
+type connManagerNotifier struct {
+	cm   connmgr.ConnManager
+	self kadt.Key
+	cfg  *ConnManagerConfig
+
+	mu     sync.Mutex
+	tagged map[peer.ID]struct{}
+}
+
+func newConnManagerNotifier(cm connmgr.ConnManager, self kadt.PeerID, cfg *ConnManagerConfig) *connManagerNotifier {
+	return &connManagerNotifier{
+		cm:     cm,
+		self:   self.Key(),
+		cfg:    cfg,
+		tagged: make(map[peer.ID]struct{}),
+	}
+}
+
+// Notify implements [coord.RoutingNotifier].
+func (n *connManagerNotifier) Notify(ctx context.Context, ev coord.RoutingNotification) {
+	switch ev := ev.(type) {
+	case *coord.EventRoutingUpdated:
+		n.tag(ev.NodeID)
+	case *coord.EventRoutingRemoved:
+		n.untag(ev.NodeID)
+	}
+}
+
+// weight returns the weight of the tag for the given peer. Peers that share a
+// longer common prefix with the local node are weighted more.
+func (n *connManagerNotifier) weight(p kadt.PeerID) int {
+	return n.cfg.Weight + n.cfg.CplWeight*n.self.CommonPrefixLength(p.Key())
+}
+
+// tagRoutingTable tags the peers that are already in rt, e.g., because the
+// table was supplied with [Config.RoutingTable]. It must be called after the
+// notifier receives routing notifications so that no peer is missed.
+func (n *connManagerNotifier) tagRoutingTable(rt routing.RoutingTableCpl[kadt.Key, kadt.PeerID]) {
+	size := 0
+	for cpl := 0; cpl < n.self.BitLen(); cpl++ {
+		size += rt.CplSize(cpl)
+	}
+
+	for _, p := range rt.NearestNodes(n.self, size) {
+		n.mu.Lock()
+		// A peer that was removed in the meantime has been untagged already or
+		// is untagged once the notification of its removal arrives.
+		if _, found := rt.GetNode(p.Key()); found {
+			n.tagLocked(p)
+		}
+		n.mu.Unlock()
+	}
+}
+
+func (n *connManagerNotifier) tag(p kadt.PeerID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.tagLocked(p)
+}
+
+// tagLocked must only be called while n.mu is held.
+func (n *connManagerNotifier) tagLocked(p kadt.PeerID) {
+	n.cm.TagPeer(peer.ID(p), connManagerTag, n.weight(p))
+	if n.cfg.Protect {
+		n.cm.Protect(peer.ID(p), connManagerTag)
+	}
+	n.tagged[peer.ID(p)] = struct{}{}
+}
+
+func (n *connManagerNotifier) untag(p kadt.PeerID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.cm.UntagPeer(peer.ID(p), connManagerTag)
+	if n.cfg.Protect {
+		n.cm.Unprotect(peer.ID(p), connManagerTag)
+	}
+	delete(n.tagged, peer.ID(p))
+}
+
+// Close removes the tags of all peers that are still tagged.
+func (n *connManagerNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for p := range n.tagged {
+		n.cm.UntagPeer(p, connManagerTag)
+		if n.cfg.Protect {
+			n.cm.Unprotect(p, connManagerTag)
+		}
+	}
+	n.tagged = make(map[peer.ID]struct{})
+
+	return nil
+}
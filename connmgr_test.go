@@ -0,0 +1,186 @@
+package zikade
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+	bcm "github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/plprobelab/go-libdht/kad/triert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord"
+	"github.com/plprobelab/zikade/internal/kadtest"
+	"github.com/plprobelab/zikade/kadt"
+)
+
+// recordingConnManager is a [connmgr.ConnManager] that records tags and
+// protections.
+type recordingConnManager struct {
+	connmgr.NullConnMgr
+	tags      map[peer.ID]int
+	protected map[peer.ID]bool
+}
+
+func newRecordingConnManager() *recordingConnManager {
+	return &recordingConnManager{
+		tags:      make(map[peer.ID]int),
+		protected: make(map[peer.ID]bool),
+	}
+}
+
+func (r *recordingConnManager) TagPeer(p peer.ID, tag string, weight int) {
+	r.tags[p] = weight
+}
+
+func (r *recordingConnManager) UntagPeer(p peer.ID, tag string) {
+	delete(r.tags, p)
+}
+
+func (r *recordingConnManager) Protect(p peer.ID, tag string) {
+	r.protected[p] = true
+}
+
+func (r *recordingConnManager) Unprotect(p peer.ID, tag string) bool {
+	delete(r.protected, p)
+	return false
+}
+
+func TestConnManagerNotifier(t *testing.T) {
+	ctx := context.Background()
+
+	self := kadt.PeerID("self")
+	far := kadt.PeerID("far")
+	near := kadt.PeerID("near")
+
+	// make sure the peers share prefixes of different lengths with the local node
+	for i := 0; self.Key().CommonPrefixLength(near.Key()) <= self.Key().CommonPrefixLength(far.Key()); i++ {
+		near = kadt.PeerID("near" + strconv.Itoa(i))
+	}
+
+	t.Run("tag and untag", func(t *testing.T) {
+		cm := newRecordingConnManager()
+		cfg := DefaultConnManagerConfig()
+		n := newConnManagerNotifier(cm, self, cfg)
+
+		n.Notify(ctx, &coord.EventRoutingUpdated{NodeID: far})
+		n.Notify(ctx, &coord.EventRoutingUpdated{NodeID: near})
+
+		require.Equal(t, cfg.Weight+cfg.CplWeight*self.Key().CommonPrefixLength(far.Key()), cm.tags[peer.ID(far)])
+		require.Greater(t, cm.tags[peer.ID(near)], cm.tags[peer.ID(far)])
+		require.Empty(t, cm.protected)
+
+		n.Notify(ctx, &coord.EventRoutingRemoved{NodeID: far})
+		require.NotContains(t, cm.tags, peer.ID(far))
+		require.Contains(t, cm.tags, peer.ID(near))
+
+		// closing untags the remaining peers
+		require.NoError(t, n.Close())
+		require.Empty(t, cm.tags)
+	})
+
+	t.Run("protect", func(t *testing.T) {
+		cm := newRecordingConnManager()
+		cfg := DefaultConnManagerConfig()
+		cfg.Protect = true
+		n := newConnManagerNotifier(cm, self, cfg)
+
+		n.Notify(ctx, &coord.EventRoutingUpdated{NodeID: far})
+		require.True(t, cm.protected[peer.ID(far)])
+
+		n.Notify(ctx, &coord.EventRoutingRemoved{NodeID: far})
+		require.False(t, cm.protected[peer.ID(far)])
+	})
+
+	t.Run("tags routing table peers", func(t *testing.T) {
+		cm := newRecordingConnManager()
+		cfg := DefaultConnManagerConfig()
+		n := newConnManagerNotifier(cm, self, cfg)
+
+		rt, err := triert.New[kadt.Key, kadt.PeerID](self, nil)
+		require.NoError(t, err)
+		rt.AddNode(far)
+		rt.AddNode(near)
+
+		n.tagRoutingTable(rt)
+		require.Equal(t, cfg.Weight+cfg.CplWeight*self.Key().CommonPrefixLength(far.Key()), cm.tags[peer.ID(far)])
+		require.Equal(t, cfg.Weight+cfg.CplWeight*self.Key().CommonPrefixLength(near.Key()), cm.tags[peer.ID(near)])
+	})
+
+	t.Run("ignores other notifications", func(t *testing.T) {
+		cm := newRecordingConnManager()
+		n := newConnManagerNotifier(cm, self, DefaultConnManagerConfig())
+
+		n.Notify(ctx, &coord.EventBootstrapFinished{})
+		require.Empty(t, cm.tags)
+	})
+}
+
+func TestConnManagerTagsRoutingTablePeers(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	cm, err := bcm.NewConnManager(10, 100)
+	require.NoError(t, err)
+
+	listenOpt := libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")
+	h := newTestHost(t, listenOpt, libp2p.ConnectionManager(cm))
+	rh := newTestHost(t, listenOpt)
+	t.Cleanup(func() {
+		require.NoError(t, h.Close())
+		require.NoError(t, rh.Close())
+	})
+
+	cfg := DefaultConfig()
+	cfg.Mode = ModeOptServer
+	d, err := New(h, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, d.Close()) })
+
+	// replacing the routing notifier doesn't stop the tagging
+	d.kad.SetRoutingNotifier(coord.NewBufferedRoutingNotifier())
+
+	rcfg := DefaultConfig()
+	rcfg.Mode = ModeOptServer
+	rd, err := New(rh, rcfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, rd.Close()) })
+
+	err = d.AddAddresses(ctx, []peer.AddrInfo{{ID: rh.ID(), Addrs: rh.Addrs()}}, time.Minute)
+	require.NoError(t, err)
+
+	// the remote peer is tagged once it was added to the routing table
+	require.Eventually(t, func() bool {
+		info := cm.GetTagInfo(rh.ID())
+		return info != nil && info.Tags[connManagerTag] > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, d.kad.IsRoutable(ctx, kadt.PeerID(rh.ID())))
+}
+
+func TestConnManagerTagsSuppliedRoutingTable(t *testing.T) {
+	cm, err := bcm.NewConnManager(10, 100)
+	require.NoError(t, err)
+
+	h := newTestHost(t, libp2p.NoListenAddrs, libp2p.ConnectionManager(cm))
+	t.Cleanup(func() { require.NoError(t, h.Close()) })
+
+	rt, err := triert.New[kadt.Key, kadt.PeerID](kadt.PeerID(h.ID()), nil)
+	require.NoError(t, err)
+
+	remote := newAddrInfo(t).ID
+	rt.AddNode(kadt.PeerID(remote))
+
+	cfg := DefaultConfig()
+	cfg.RoutingTable = rt
+	d, err := New(h, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, d.Close()) })
+
+	// the peer was in the routing table before the connection manager was attached
+	info := cm.GetTagInfo(remote)
+	require.NotNil(t, info)
+	require.Greater(t, info.Tags[connManagerTag], 0)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/plprobelab/go-libdht/kad/triert"
 	"golang.org/x/exp/slog"
 
 	"github.com/plprobelab/zikade/internal/coord"
@@ -58,6 +59,14 @@ type DHT struct {
 	// tele holds a reference to a telemetry struct
 	tele *Telemetry
 
+	// providerCache caches the results of recent provider lookups. It is nil
+	// if [QueryConfig.ProviderCacheSize] is 0.
+	providerCache *providerCache
+
+	// connMgr tags routing table peers in the libp2p connection manager. It
+	// is nil if tagging is disabled in [ConnManagerConfig].
+	connMgr *connManagerNotifier
+
 	// indicates whether this DHT instance was stopped ([DHT.Close] was called).
 	stopped atomic.Bool
 }
@@ -84,6 +93,21 @@ func New(h host.Host, cfg *Config) (*DHT, error) {
 	// Use the configured routing table if it was provided
 	if cfg.RoutingTable != nil {
 		d.rt = cfg.RoutingTable
+	} else if cfg.RoutingTableType == RoutingTableOptKBucket {
+		if d.rt, err = KBucketRoutingTable(nid, cfg.BucketSize); err != nil {
+			return nil, fmt.Errorf("new k-bucket routing table: %w", err)
+		}
+	} else if cfg.Diversity.MaxPeersPerCpl > 0 || cfg.Diversity.MaxPeersPerTable > 0 {
+		filter, err := NewPeerDiversityFilter(h, cfg.Diversity.Group, cfg.Diversity.MaxPeersPerCpl, cfg.Diversity.MaxPeersPerTable)
+		if err != nil {
+			return nil, fmt.Errorf("new peer diversity filter: %w", err)
+		}
+
+		rtCfg := triert.DefaultConfig[kadt.Key, kadt.PeerID]()
+		rtCfg.NodeFilter = filter
+		if d.rt, err = triert.New[kadt.Key, kadt.PeerID](nid, rtCfg); err != nil {
+			return nil, fmt.Errorf("new trie routing table: %w", err)
+		}
 	} else if d.rt, err = DefaultRoutingTable(nid); err != nil {
 		return nil, fmt.Errorf("new trie routing table: %w", err)
 	}
@@ -109,6 +133,14 @@ func New(h host.Host, cfg *Config) (*DHT, error) {
 		d.backends[ns] = traceWrapBackend(ns, be, d.tele.Tracer)
 	}
 
+	// initialize the client-side cache for provider lookups
+	if cfg.Query.ProviderCacheSize > 0 {
+		d.providerCache, err = newProviderCache(cfg.Clock, cfg.Query.ProviderCacheSize, cfg.Query.ProviderCacheTTL, d.tele)
+		if err != nil {
+			return nil, fmt.Errorf("new provider cache: %w", err)
+		}
+	}
+
 	// instantiate a new Kademlia DHT coordinator.
 	coordCfg := coord.DefaultCoordinatorConfig()
 	coordCfg.Clock = cfg.Clock
@@ -119,15 +151,64 @@ func New(h host.Host, cfg *Config) (*DHT, error) {
 	coordCfg.Query.Clock = cfg.Clock
 	coordCfg.Query.Logger = cfg.Logger.With("behaviour", "pooledquery")
 	coordCfg.Query.Tracer = cfg.TracerProvider.Tracer(tele.TracerName)
+	coordCfg.Query.Meter = cfg.MeterProvider.Meter(tele.MeterName)
 	coordCfg.Query.Concurrency = cfg.Query.Concurrency
 	coordCfg.Query.Timeout = cfg.Query.Timeout
 	coordCfg.Query.RequestConcurrency = cfg.Query.RequestConcurrency
 	coordCfg.Query.RequestTimeout = cfg.Query.RequestTimeout
+	coordCfg.Query.AdaptiveRequestTimeout = cfg.Query.AdaptiveRequestTimeout
+	coordCfg.Query.RequestTimeoutMin = cfg.Query.RequestTimeoutMin
+	coordCfg.Query.RequestTimeoutPercentile = cfg.Query.RequestTimeoutPercentile
+	coordCfg.Query.AdaptiveConcurrency = cfg.Query.AdaptiveConcurrency
+	coordCfg.Query.MaxConcurrency = cfg.Query.MaxConcurrency
+	coordCfg.Query.MaxRequestConcurrency = cfg.Query.MaxRequestConcurrency
+	coordCfg.Query.PreferScoredNodes = cfg.Query.PreferScoredNodes
+	coordCfg.CoalesceLookups = cfg.Query.CoalesceLookups
 
 	coordCfg.Routing.Clock = cfg.Clock
 	coordCfg.Routing.Logger = cfg.Logger.With("behaviour", "routing")
 	coordCfg.Routing.Tracer = cfg.TracerProvider.Tracer(tele.TracerName)
 	coordCfg.Routing.Meter = cfg.MeterProvider.Meter(tele.MeterName)
+	coordCfg.Routing.BootstrapTimeout = cfg.Routing.BootstrapTimeout
+	coordCfg.Routing.BootstrapRequestConcurrency = cfg.Routing.BootstrapRequestConcurrency
+	coordCfg.Routing.BootstrapRequestTimeout = cfg.Routing.BootstrapRequestTimeout
+	coordCfg.Routing.ConnectivityCheckTimeout = cfg.Routing.ConnectivityCheckTimeout
+	coordCfg.Routing.ProbeRequestConcurrency = cfg.Routing.ProbeRequestConcurrency
+	coordCfg.Routing.ProbeCheckInterval = cfg.Routing.ProbeCheckInterval
+	coordCfg.Routing.ProbeMaxCheckInterval = cfg.Routing.ProbeMaxCheckInterval
+	coordCfg.Routing.ProbeMaxFailures = cfg.Routing.ProbeMaxFailures
+	coordCfg.Routing.ProbeFailureBackoff = cfg.Routing.ProbeFailureBackoff
+	coordCfg.Routing.ProbeOfflineWindow = cfg.Routing.ProbeOfflineWindow
+	coordCfg.Routing.ProbeOfflineThreshold = cfg.Routing.ProbeOfflineThreshold
+	coordCfg.Routing.IncludeQueueCapacity = cfg.Routing.IncludeQueueCapacity
+	coordCfg.Routing.IncludeRequestConcurrency = cfg.Routing.IncludeRequestConcurrency
+	coordCfg.Routing.IncludeReplacementCapacity = cfg.Routing.IncludeReplacementCapacity
+	coordCfg.Routing.IncludeCandidateTTL = cfg.Routing.IncludeCandidateTTL
+	coordCfg.Routing.IncludeBucketSize = cfg.BucketSize
+	coordCfg.Routing.ExploreTimeout = cfg.Routing.ExploreTimeout
+	coordCfg.Routing.ExploreRequestConcurrency = cfg.Routing.ExploreRequestConcurrency
+	coordCfg.Routing.ExploreRequestTimeout = cfg.Routing.ExploreRequestTimeout
+	coordCfg.Routing.ExploreMaximumCpl = cfg.Routing.ExploreMaximumCpl
+	if cfg.Routing.AdaptiveExplore {
+		coordCfg.Routing.ExploreAdaptiveSchedule = true
+		coordCfg.Routing.ExploreMinInterval = cfg.Routing.ExploreMinInterval
+		coordCfg.Routing.ExploreMaxInterval = cfg.Routing.ExploreMaxInterval
+		coordCfg.Routing.ExploreBucketSize = cfg.BucketSize
+	} else {
+		coordCfg.Routing.ExploreInterval = cfg.Routing.ExploreInterval
+		coordCfg.Routing.ExploreIntervalMultiplier = cfg.Routing.ExploreIntervalMultiplier
+		coordCfg.Routing.ExploreIntervalJitter = cfg.Routing.ExploreIntervalJitter
+	}
+
+	coordCfg.Broadcast.Clock = cfg.Clock
+	coordCfg.Broadcast.Meter = cfg.MeterProvider.Meter(tele.MeterName)
+	coordCfg.Broadcast.Concurrency = cfg.Routing.BroadcastConcurrency
+	coordCfg.Broadcast.Timeout = cfg.Routing.BroadcastTimeout
+	coordCfg.Broadcast.RequestConcurrency = cfg.Routing.BroadcastRequestConcurrency
+	coordCfg.Broadcast.RequestTimeout = cfg.Routing.BroadcastRequestTimeout
+	if cfg.Diversity.MaxPeersPerBroadcast > 0 {
+		coordCfg.Broadcast.Select = newDiversitySelector(cfg.Diversity.Group, hostAddrsFn(h), cfg.Diversity.MaxPeersPerBroadcast)
+	}
 
 	rtr := &router{
 		host:       h,
@@ -140,6 +221,15 @@ func New(h host.Host, cfg *Config) (*DHT, error) {
 		return nil, fmt.Errorf("new coordinator: %w", err)
 	}
 
+	// tag routing table peers in the connection manager so that their
+	// connections aren't trimmed like any other. The notifier observes the
+	// routing table alongside any other routing notifier.
+	if cfg.ConnManager.enabled() {
+		d.connMgr = newConnManagerNotifier(h.ConnManager(), nid, cfg.ConnManager)
+		d.kad.AddRoutingObserver(d.connMgr)
+		d.connMgr.tagRoutingTable(d.rt)
+	}
+
 	// determine mode to start in
 	switch cfg.Mode {
 	case ModeOptClient, ModeOptAutoClient:
@@ -189,6 +279,8 @@ func (d *DHT) initAminoBackends() (map[string]Backend, error) {
 	pbeCfg.AddressFilter = d.cfg.AddressFilter
 	pbeCfg.Tele = d.tele
 	pbeCfg.clk = d.cfg.Clock
+	pbeCfg.BatchSize = d.cfg.ProviderBatchSize
+	pbeCfg.BatchInterval = d.cfg.ProviderBatchInterval
 
 	pbe, err := NewBackendProvider(d.host.Peerstore(), dstore, pbeCfg)
 	if err != nil {
@@ -234,6 +326,12 @@ func (d *DHT) Close() error {
 		d.debugErr(err, "failed closing coordinator")
 	}
 
+	if d.connMgr != nil {
+		if err := d.connMgr.Close(); err != nil {
+			d.debugErr(err, "failed untagging routing table peers")
+		}
+	}
+
 	for ns, b := range d.backends {
 		closer, ok := b.(io.Closer)
 		if !ok {
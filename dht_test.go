@@ -47,6 +47,41 @@ func TestNew(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "adaptive explore schedule",
+			cfgBuilder: func(c *Config) *Config {
+				c.Routing.AdaptiveExplore = true
+				return c
+			},
+			wantBuilder: func(dht *DHT) *DHT {
+				return dht
+			},
+			wantErr: false,
+		},
+		{
+			name: "kbucket routing table",
+			cfgBuilder: func(c *Config) *Config {
+				c.RoutingTableType = RoutingTableOptKBucket
+				return c
+			},
+			wantBuilder: func(dht *DHT) *DHT {
+				return dht
+			},
+			wantErr: false,
+		},
+		{
+			name: "diversity limits",
+			cfgBuilder: func(c *Config) *Config {
+				c.Diversity.MaxPeersPerCpl = 2
+				c.Diversity.MaxPeersPerTable = 3
+				c.Diversity.MaxPeersPerBroadcast = 2
+				return c
+			},
+			wantBuilder: func(dht *DHT) *DHT {
+				return dht
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -70,6 +105,24 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_providerBatching(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Logger = devnull
+	cfg.ProviderBatchSize = 10
+	cfg.ProviderBatchInterval = time.Minute
+
+	d := newTestDHTWithConfig(t, cfg)
+
+	// the default providers backend buffers records in batches
+	tbe, ok := d.backends[namespaceProviders].(*tracedBackend)
+	require.True(t, ok)
+	be, ok := tbe.backend.(*ProvidersBackend)
+	require.True(t, ok)
+	assert.Equal(t, 10, be.cfg.BatchSize)
+	assert.Equal(t, time.Minute, be.cfg.BatchInterval)
+	assert.NotNil(t, be.batching)
+}
+
 func TestAddAddresses(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -1,12 +1,14 @@
 package zikade
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/libp2p/go-libp2p-kbucket/peerdiversity"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/plprobelab/go-libdht/kad/triert"
 	"github.com/plprobelab/zikade/kadt"
 )
@@ -159,3 +161,213 @@ func (r *rtPeerIPGroupFilter) Decrement(g peerdiversity.PeerGroupInfo) {
 func (r *rtPeerIPGroupFilter) PeerAddresses(p peer.ID) []ma.Multiaddr {
 	return r.multiaddrsFn(p)
 }
+
+var _ triert.NodeFilter[kadt.Key, kadt.PeerID] = (*PeerDiversityFilter)(nil)
+
+// PeerDiversityFilter is a `triert.NodeFilter` that limits the number of peers
+// in the TrieRT Routing Table whose addresses belong to the same group. In
+// contrast to [TrieRTPeerDiversityFilter], the grouping of addresses is
+// configurable through an [IPGroupFunc]. Peers without any address that can
+// be assigned to a group are not allowed into the routing table.
+type PeerDiversityFilter struct {
+	mu sync.Mutex
+
+	self kadt.Key
+
+	maxPerCpl   int
+	maxForTable int
+
+	group   IPGroupFunc
+	addrsFn func(peer.ID) []ma.Multiaddr
+
+	peers           map[kadt.PeerID]peerDiversityInfo
+	cplGroupCount   map[int]map[string]int
+	tableGroupCount map[string]int
+}
+
+// peerDiversityInfo records the cpl and groups a peer was counted with when it
+// was added to the routing table.
+type peerDiversityInfo struct {
+	cpl    int
+	groups []string
+}
+
+// NewPeerDiversityFilter constructs a [PeerDiversityFilter] for the routing
+// table of the given host. The addresses of a peer are assigned to groups
+// using group.
+// `maxPerCpl` represents the maximum number of peers per common prefix length
+// allowed to share the same group. `maxForTable` represents the maximum number
+// of peers in the routing table allowed to share the same group. A limit of
+// zero disables the respective check.
+func NewPeerDiversityFilter(h host.Host, group IPGroupFunc, maxPerCpl, maxForTable int) (*PeerDiversityFilter, error) {
+	if group == nil {
+		return nil, fmt.Errorf("group function must not be nil")
+	}
+
+	if maxPerCpl < 0 {
+		return nil, fmt.Errorf("max peers per cpl must not be negative")
+	}
+
+	if maxForTable < 0 {
+		return nil, fmt.Errorf("max peers for table must not be negative")
+	}
+
+	return newPeerDiversityFilter(kadt.PeerID(h.ID()).Key(), group, hostAddrsFn(h), maxPerCpl, maxForTable), nil
+}
+
+func newPeerDiversityFilter(self kadt.Key, group IPGroupFunc, addrsFn func(peer.ID) []ma.Multiaddr, maxPerCpl, maxForTable int) *PeerDiversityFilter {
+	return &PeerDiversityFilter{
+		self:            self,
+		maxPerCpl:       maxPerCpl,
+		maxForTable:     maxForTable,
+		group:           group,
+		addrsFn:         addrsFn,
+		peers:           make(map[kadt.PeerID]peerDiversityInfo),
+		cplGroupCount:   make(map[int]map[string]int),
+		tableGroupCount: make(map[string]int),
+	}
+}
+
+// TryAdd is called by TrieRT when a new node is added to the routing table.
+func (f *PeerDiversityFilter) TryAdd(rt *triert.TrieRT[kadt.Key, kadt.PeerID], n kadt.PeerID) bool {
+	groups := peerGroups(f.group, f.addrsFn(peer.ID(n)))
+	if len(groups) == 0 {
+		return false
+	}
+
+	cpl := f.self.CommonPrefixLength(n.Key())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, found := f.peers[n]; found {
+		return true
+	}
+
+	for _, g := range groups {
+		if f.maxForTable > 0 && f.tableGroupCount[g] >= f.maxForTable {
+			return false
+		}
+		if f.maxPerCpl > 0 && f.cplGroupCount[cpl][g] >= f.maxPerCpl {
+			return false
+		}
+	}
+
+	if _, ok := f.cplGroupCount[cpl]; !ok {
+		f.cplGroupCount[cpl] = make(map[string]int)
+	}
+	for _, g := range groups {
+		f.tableGroupCount[g]++
+		f.cplGroupCount[cpl][g]++
+	}
+	f.peers[n] = peerDiversityInfo{cpl: cpl, groups: groups}
+
+	return true
+}
+
+// Remove is called by TrieRT when a node is removed from the routing table.
+func (f *PeerDiversityFilter) Remove(n kadt.PeerID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, found := f.peers[n]
+	if !found {
+		return
+	}
+	delete(f.peers, n)
+
+	for _, g := range info.groups {
+		f.tableGroupCount[g]--
+		if f.tableGroupCount[g] == 0 {
+			delete(f.tableGroupCount, g)
+		}
+
+		f.cplGroupCount[info.cpl][g]--
+		if f.cplGroupCount[info.cpl][g] == 0 {
+			delete(f.cplGroupCount[info.cpl], g)
+		}
+	}
+	if len(f.cplGroupCount[info.cpl]) == 0 {
+		delete(f.cplGroupCount, info.cpl)
+	}
+}
+
+// newDiversitySelector returns a function that selects at most n of the given
+// nodes, which are ordered by distance to a key, for storing a record with.
+// Nodes are selected in order but at most maxPerGroup of the selected nodes
+// may share the same group. Nodes without any address that can be assigned to
+// a group are always selected.
+func newDiversitySelector(group IPGroupFunc, addrsFn func(peer.ID) []ma.Multiaddr, maxPerGroup int) func(nodes []kadt.PeerID, n int) []kadt.PeerID {
+	return func(nodes []kadt.PeerID, n int) []kadt.PeerID {
+		selected := make([]kadt.PeerID, 0, n)
+		counts := make(map[string]int)
+
+		for _, node := range nodes {
+			if len(selected) >= n {
+				break
+			}
+
+			groups := peerGroups(group, addrsFn(peer.ID(node)))
+
+			allowed := true
+			for _, g := range groups {
+				if counts[g] >= maxPerGroup {
+					allowed = false
+					break
+				}
+			}
+			if !allowed {
+				continue
+			}
+
+			for _, g := range groups {
+				counts[g]++
+			}
+			selected = append(selected, node)
+		}
+
+		return selected
+	}
+}
+
+// hostAddrsFn returns a function that returns the addresses of a peer. These
+// are the remote addresses of the connections to the peer or, if there are
+// none, the addresses of the peer in the peerstore.
+func hostAddrsFn(h host.Host) func(peer.ID) []ma.Multiaddr {
+	return func(p peer.ID) []ma.Multiaddr {
+		cs := h.Network().ConnsToPeer(p)
+		if len(cs) == 0 {
+			return h.Peerstore().Addrs(p)
+		}
+
+		addrs := make([]ma.Multiaddr, 0, len(cs))
+		for _, c := range cs {
+			addrs = append(addrs, c.RemoteMultiaddr())
+		}
+		return addrs
+	}
+}
+
+// peerGroups returns the distinct groups of the IP addresses in addrs.
+func peerGroups(group IPGroupFunc, addrs []ma.Multiaddr) []string {
+	var groups []string
+	seen := make(map[string]struct{})
+	for _, addr := range addrs {
+		ip, err := manet.ToIP(addr)
+		if err != nil {
+			continue
+		}
+
+		g := group(ip)
+		if g == "" {
+			continue
+		}
+
+		if _, found := seen[g]; found {
+			continue
+		}
+		seen[g] = struct{}{}
+		groups = append(groups, g)
+	}
+	return groups
+}
@@ -204,3 +204,86 @@ func TestRTPeerDiversityFilter(t *testing.T) {
 	success = rt.AddNode(kadt.PeerID(h2.ID()))
 	require.False(t, success)
 }
+
+func (suite *DiversityFilterTestSuite) TestPeerDiversityFilter() {
+	t := suite.T()
+
+	addrsFn := func(p peer.ID) []ma.Multiaddr {
+		for _, pi := range suite.Peers {
+			if pi.ID == p {
+				return pi.Addrs
+			}
+		}
+		return nil
+	}
+
+	self := kadt.PeerID(suite.Peers["1EoooPEER1"].ID)
+	filter := newPeerDiversityFilter(self.Key(), IPGroupPrefix(16, 32), addrsFn, 2, 3)
+
+	rt, err := triert.New[kadt.Key, kadt.PeerID](self, &triert.Config[kadt.Key, kadt.PeerID]{NodeFilter: filter})
+	require.NoError(t, err)
+
+	add := func(name string) bool {
+		return rt.AddNode(kadt.PeerID(suite.Peers[name].ID))
+	}
+
+	// add 3 peers with the same IP group (1.1.0.0/16)
+	require.True(t, add("1EoooPEER2"))
+	require.True(t, add("1EoooPEER3"))
+	require.True(t, add("1EoooPEER4"))
+
+	// the table limit for 1.1.0.0/16 is reached
+	require.False(t, add("1EoooPEER5"))
+
+	// removing 1EoooPEER2 frees up a slot in the table but not in bucket 2
+	require.True(t, rt.RemoveKey(kadt.PeerID(suite.Peers["1EoooPEER2"].ID).Key()))
+	require.False(t, add("1EoooPEER8"))
+
+	// bucket 0 has no other peer in 1.1.0.0/16
+	require.True(t, add("1EoooPEER6"))
+
+	// 1EoooPEER9 has no address that can be grouped
+	require.False(t, add("1EoooPEER9"))
+
+	// one of the addresses of 1EooPEER11 belongs to a full group
+	require.False(t, add("1EooPEER11"))
+
+	// all ip6 addresses are in 2000:1234::/32
+	require.True(t, add("1EooPEER12"))
+	require.True(t, add("1EooPEER14"))
+	require.False(t, add("1EooPEER15")) // full for cpl 1
+	require.True(t, add("1EooPEER13"))  // different cpl
+
+	// removing a peer releases its groups
+	require.True(t, rt.RemoveKey(kadt.PeerID(suite.Peers["1EooPEER14"].ID).Key()))
+	require.True(t, add("1EooPEER15"))
+}
+
+func (suite *DiversityFilterTestSuite) TestDiversitySelector() {
+	t := suite.T()
+
+	addrsFn := func(p peer.ID) []ma.Multiaddr {
+		for _, pi := range suite.Peers {
+			if pi.ID == p {
+				return pi.Addrs
+			}
+		}
+		return nil
+	}
+
+	sel := newDiversitySelector(IPGroupPrefix(16, 32), addrsFn, 2)
+
+	nodes := []kadt.PeerID{
+		kadt.PeerID(suite.Peers["1EoooPEER2"].ID), // 1.1.0.0/16
+		kadt.PeerID(suite.Peers["1EoooPEER3"].ID), // 1.1.0.0/16
+		kadt.PeerID(suite.Peers["1EoooPEER4"].ID), // 1.1.0.0/16, skipped
+		kadt.PeerID(suite.Peers["1EoooPEER9"].ID), // no address
+		kadt.PeerID(suite.Peers["1EooPEER11"].ID), // 1.1.0.0/16 and 2000:1234::/32, skipped
+		kadt.PeerID(suite.Peers["1EoooPEER7"].ID), // 1.2.0.0/16
+		kadt.PeerID(suite.Peers["1EooPEER12"].ID), // 2000:1234::/32
+	}
+
+	require.Equal(t, []kadt.PeerID{nodes[0], nodes[1], nodes[3], nodes[5], nodes[6]}, sel(nodes, 5))
+	require.Equal(t, []kadt.PeerID{nodes[0], nodes[1], nodes[3]}, sel(nodes, 3))
+	require.Empty(t, sel(nil, 3))
+}
@@ -0,0 +1,199 @@
+package zikade
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	asnutil "github.com/libp2p/go-libp2p-asn-util"
+)
+
+// An IPGroupFunc assigns an IP address to a group of addresses that are
+// likely to be operated by the same entity. The peer diversity filter limits
+// the number of peers whose addresses belong to the same group. An
+// IPGroupFunc returns the empty string if it can't assign the address to a
+// group. See [IPGroupPrefix] and [IPGroupASN].
+type IPGroupFunc func(ip net.IP) string
+
+// IPGroupPrefix returns an [IPGroupFunc] that groups IPv4 addresses by their
+// first v4Bits bits and IPv6 addresses by their first v6Bits bits. The group
+// of an address is its network in CIDR notation, e.g., 1.2.0.0/16. The
+// grouping used by [NewRTPeerDiversityFilter] is roughly equivalent to
+// IPGroupPrefix(16, 32).
+func IPGroupPrefix(v4Bits, v6Bits int) IPGroupFunc {
+	v4Mask := net.CIDRMask(v4Bits, 8*net.IPv4len)
+	v6Mask := net.CIDRMask(v6Bits, 8*net.IPv6len)
+
+	return func(ip net.IP) string {
+		if ip4 := ip.To4(); ip4 != nil {
+			return fmt.Sprintf("%s/%d", ip4.Mask(v4Mask), v4Bits)
+		}
+		if ip6 := ip.To16(); ip6 != nil {
+			return fmt.Sprintf("%s/%d", ip6.Mask(v6Mask), v6Bits)
+		}
+		return ""
+	}
+}
+
+// IPGroupASN returns an [IPGroupFunc] that groups addresses by the
+// autonomous system that announces them according to table. The group of an
+// address is the AS number prefixed with "AS", e.g., AS13335. Addresses that
+// aren't covered by table are grouped by fallback. If fallback is nil, these
+// addresses aren't assigned to any group.
+func IPGroupASN(table ASNTable, fallback IPGroupFunc) IPGroupFunc {
+	return func(ip net.IP) string {
+		if asn, ok := table.ASN(ip); ok {
+			return "AS" + strconv.FormatUint(uint64(asn), 10)
+		}
+		if fallback != nil {
+			return fallback(ip)
+		}
+		return ""
+	}
+}
+
+// An ASNTable maps IP addresses to the number of the autonomous system (AS)
+// that announces them.
+type ASNTable interface {
+	// ASN returns the number of the autonomous system that announces ip and
+	// true, or false if the address isn't covered by the table.
+	ASN(ip net.IP) (uint32, bool)
+}
+
+// EmbeddedASNTable returns the [ASNTable] that is embedded in
+// go-libp2p-asn-util. It only covers IPv6 addresses and is built on its first
+// use, which takes a moment.
+func EmbeddedASNTable() ASNTable {
+	return embeddedASNTable{}
+}
+
+type embeddedASNTable struct{}
+
+func (embeddedASNTable) ASN(ip net.IP) (uint32, bool) {
+	if ip.To4() != nil || ip.To16() == nil {
+		return 0, false
+	}
+
+	s, err := asnutil.Store.AsnForIPv6(ip)
+	if err != nil || s == "" {
+		return 0, false
+	}
+
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(asn), true
+}
+
+// NewASNTable returns an [ASNTable] from a map of networks in CIDR notation
+// to the number of the autonomous system that announces them. Addresses are
+// matched against the longest network that contains them.
+func NewASNTable(networks map[string]uint32) (ASNTable, error) {
+	t := &prefixASNTable{
+		v4: make(map[int]map[string]uint32),
+		v6: make(map[int]map[string]uint32),
+	}
+
+	for cidr, asn := range networks {
+		if err := t.add(cidr, asn); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// ParseASNTable reads an [ASNTable] from r. Each line holds a network in
+// CIDR notation followed by the number of the autonomous system that
+// announces it, separated by whitespace. The number may be prefixed with
+// "AS". Empty lines and lines starting with # are ignored. For example:
+//
+//	# Cloudflare
+//	104.16.0.0/13 AS13335
+//	2606:4700::/32 13335
+func ParseASNTable(r io.Reader) (ASNTable, error) {
+	t := &prefixASNTable{
+		v4: make(map[int]map[string]uint32),
+		v6: make(map[int]map[string]uint32),
+	}
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected network and AS number", line)
+		}
+
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(fields[1]), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid AS number %q: %w", line, fields[1], err)
+		}
+
+		if err := t.add(fields[0], uint32(asn)); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read asn table: %w", err)
+	}
+
+	return t, nil
+}
+
+// prefixASNTable is an [ASNTable] that holds the networks of each address
+// family by prefix length.
+type prefixASNTable struct {
+	v4 map[int]map[string]uint32 // maps prefix lengths to networks to AS numbers
+	v6 map[int]map[string]uint32 // maps prefix lengths to networks to AS numbers
+}
+
+func (t *prefixASNTable) add(cidr string, asn uint32) error {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid network %q: %w", cidr, err)
+	}
+
+	ones, bits := n.Mask.Size()
+	nets := t.v6
+	if bits == 8*net.IPv4len {
+		nets = t.v4
+	}
+
+	if nets[ones] == nil {
+		nets[ones] = make(map[string]uint32)
+	}
+	nets[ones][n.IP.String()] = asn
+
+	return nil
+}
+
+func (t *prefixASNTable) ASN(ip net.IP) (uint32, bool) {
+	nets, bits := t.v6, 8*net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, nets, bits = ip4, t.v4, 8*net.IPv4len
+	} else if ip = ip.To16(); ip == nil {
+		return 0, false
+	}
+
+	// find the longest matching network
+	for ones := bits; ones >= 0; ones-- {
+		if len(nets[ones]) == 0 {
+			continue
+		}
+		if asn, ok := nets[ones][ip.Mask(net.CIDRMask(ones, bits)).String()]; ok {
+			return asn, true
+		}
+	}
+
+	return 0, false
+}
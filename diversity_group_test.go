@@ -0,0 +1,109 @@
+package zikade
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPGroupPrefix(t *testing.T) {
+	group := IPGroupPrefix(16, 32)
+
+	require.Equal(t, "1.2.0.0/16", group(net.ParseIP("1.2.3.4")))
+	require.Equal(t, "1.2.0.0/16", group(net.ParseIP("1.2.255.255")))
+	require.Equal(t, "2001:db8::/32", group(net.ParseIP("2001:db8:1:2::1")))
+	require.Equal(t, "", group(nil))
+
+	group = IPGroupPrefix(24, 48)
+	require.Equal(t, "1.2.3.0/24", group(net.ParseIP("1.2.3.4")))
+	require.Equal(t, "2001:db8:1::/48", group(net.ParseIP("2001:db8:1:2::1")))
+}
+
+func TestIPGroupASN(t *testing.T) {
+	table, err := NewASNTable(map[string]uint32{
+		"1.2.0.0/16": 64500,
+	})
+	require.NoError(t, err)
+
+	group := IPGroupASN(table, IPGroupPrefix(16, 32))
+	require.Equal(t, "AS64500", group(net.ParseIP("1.2.3.4")))
+	require.Equal(t, "5.6.0.0/16", group(net.ParseIP("5.6.7.8")))
+
+	group = IPGroupASN(table, nil)
+	require.Equal(t, "", group(net.ParseIP("5.6.7.8")))
+}
+
+func TestNewASNTable(t *testing.T) {
+	table, err := NewASNTable(map[string]uint32{
+		"1.0.0.0/8":       64500,
+		"1.2.0.0/16":      64501,
+		"1.2.3.0/24":      64502,
+		"2001:db8::/32":   64503,
+		"2001:db8:1::/48": 64504,
+	})
+	require.NoError(t, err)
+
+	tcs := map[string]uint32{
+		"1.9.9.9":         64500,
+		"1.2.9.9":         64501,
+		"1.2.3.4":         64502,
+		"2001:db8:2::1":   64503,
+		"2001:db8:1:2::1": 64504,
+	}
+	for ip, want := range tcs {
+		got, ok := table.ASN(net.ParseIP(ip))
+		require.True(t, ok, ip)
+		require.Equal(t, want, got, ip)
+	}
+
+	_, ok := table.ASN(net.ParseIP("2.2.2.2"))
+	require.False(t, ok)
+
+	_, ok = table.ASN(net.ParseIP("2001:db9::1"))
+	require.False(t, ok)
+
+	_, err = NewASNTable(map[string]uint32{"1.2.3.4": 64500})
+	require.Error(t, err)
+}
+
+func TestParseASNTable(t *testing.T) {
+	input := `
+# comment
+1.2.0.0/16 AS64500
+2001:db8::/32	64501
+`
+	table, err := ParseASNTable(strings.NewReader(input))
+	require.NoError(t, err)
+
+	asn, ok := table.ASN(net.ParseIP("1.2.3.4"))
+	require.True(t, ok)
+	require.EqualValues(t, 64500, asn)
+
+	asn, ok = table.ASN(net.ParseIP("2001:db8::1"))
+	require.True(t, ok)
+	require.EqualValues(t, 64501, asn)
+
+	_, err = ParseASNTable(strings.NewReader("1.2.0.0/16"))
+	require.Error(t, err)
+
+	_, err = ParseASNTable(strings.NewReader("1.2.0.0/16 ASX"))
+	require.Error(t, err)
+
+	_, err = ParseASNTable(strings.NewReader("1.2.0.0 64500"))
+	require.Error(t, err)
+}
+
+func TestEmbeddedASNTable(t *testing.T) {
+	table := EmbeddedASNTable()
+
+	// the embedded table doesn't cover IPv4 addresses
+	_, ok := table.ASN(net.ParseIP("1.1.1.1"))
+	require.False(t, ok)
+
+	// Cloudflare
+	asn, ok := table.ASN(net.ParseIP("2606:4700:4700::1111"))
+	require.True(t, ok)
+	require.EqualValues(t, 13335, asn)
+}
@@ -1,6 +1,9 @@
 package zikade
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // A ConfigurationError is returned when a component's configuration is found to be invalid or unusable.
 type ConfigurationError struct {
@@ -20,3 +23,6 @@ func (e *ConfigurationError) Error() string {
 func (e *ConfigurationError) Unwrap() error {
 	return e.Err
 }
+
+// ErrOperationNotFound is returned by [DHT.CancelOperation] when there is no running operation with the given id.
+var ErrOperationNotFound = errors.New("operation not found")
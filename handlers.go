@@ -147,7 +147,7 @@ func (d *DHT) handleGetValue(ctx context.Context, remote peer.ID, req *pb.Messag
 	if ok {
 		resp.ProviderPeers = make([]*pb.Message_Peer, len(pset.providers))
 		for i, p := range pset.providers {
-			resp.ProviderPeers[i] = pb.FromAddrInfo(p)
+			resp.ProviderPeers[i] = providerToPB(p)
 		}
 
 		return resp, nil
@@ -167,8 +167,8 @@ func (d *DHT) handleAddProvider(ctx context.Context, remote peer.ID, req *pb.Mes
 		return nil, fmt.Errorf("no provider peers given")
 	}
 
-	var addrInfos []peer.AddrInfo
-	for _, addrInfo := range req.ProviderAddrInfos() {
+	var providers []any
+	for i, addrInfo := range req.ProviderAddrInfos() {
 		addrInfo := addrInfo // TODO: remove after go.mod was updated to go 1.21
 
 		if addrInfo.ID != remote {
@@ -179,7 +179,20 @@ func (d *DHT) handleAddProvider(ctx context.Context, remote peer.ID, req *pb.Mes
 			return nil, fmt.Errorf("no addresses for provider")
 		}
 
-		addrInfos = append(addrInfos, addrInfo)
+		// only hand a ProviderInfo to the backend if the provider attached
+		// metadata. This keeps custom backends that only support
+		// peer.AddrInfo values working.
+		metadata := req.ProviderPeers[i].GetMetadata()
+		if len(metadata) == 0 {
+			providers = append(providers, addrInfo)
+			continue
+		}
+
+		if len(metadata) > MaxProviderMetadataSize {
+			return nil, fmt.Errorf("provider metadata too large")
+		}
+
+		providers = append(providers, ProviderInfo{AddrInfo: addrInfo, Metadata: metadata})
 	}
 
 	backend, ok := d.backends[namespaceProviders]
@@ -187,8 +200,8 @@ func (d *DHT) handleAddProvider(ctx context.Context, remote peer.ID, req *pb.Mes
 		return nil, fmt.Errorf("unsupported record type: %s", namespaceProviders)
 	}
 
-	for _, addrInfo := range addrInfos {
-		if _, err := backend.Store(ctx, k, addrInfo); err != nil {
+	for _, provider := range providers {
+		if _, err := backend.Store(ctx, k, provider); err != nil {
 			return nil, fmt.Errorf("storing provider record: %w", err)
 		}
 	}
@@ -231,7 +244,7 @@ func (d *DHT) handleGetProviders(ctx context.Context, remote peer.ID, req *pb.Me
 
 	pbProviders := make([]*pb.Message_Peer, len(pset.providers))
 	for i, p := range pset.providers {
-		pbProviders[i] = pb.FromAddrInfo(p)
+		pbProviders[i] = providerToPB(p)
 	}
 
 	resp.ProviderPeers = pbProviders
@@ -239,6 +252,14 @@ func (d *DHT) handleGetProviders(ctx context.Context, remote peer.ID, req *pb.Me
 	return resp, nil
 }
 
+// providerToPB converts the given provider information into its protobuf
+// representation that we send to remote peers.
+func providerToPB(p ProviderInfo) *pb.Message_Peer {
+	mp := pb.FromAddrInfo(p.AddrInfo)
+	mp.Metadata = p.Metadata
+	return mp
+}
+
 // closerPeers returns the closest peers to the given target key this host knows
 // about. It doesn't return 1) itself 2) the peer that asked for closer peers.
 func (d *DHT) closerPeers(ctx context.Context, remote peer.ID, target kadt.Key) []*pb.Message_Peer {
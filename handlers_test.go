@@ -1170,6 +1170,43 @@ func TestDHT_handleAddProvider_happy_path(t *testing.T) {
 	assert.False(t, found) // only cache on Fetch, not on write
 }
 
+func TestDHT_handleAddProvider_with_metadata(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDHT(t)
+
+	addrInfo := newAddrInfo(t)
+	key := []byte("random-key")
+	metadata := []byte("transport-bitswap")
+
+	req := newAddProviderRequest(key, addrInfo)
+	req.ProviderPeers[0].Metadata = metadata
+
+	_, err := d.handleAddProvider(ctx, addrInfo.ID, req)
+	require.NoError(t, err)
+
+	res, err := d.handleGetProviders(ctx, newPeerID(t), &pb.Message{
+		Type: pb.Message_GET_PROVIDERS,
+		Key:  key,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, res.ProviderPeers, 1)
+	assert.Equal(t, []byte(addrInfo.ID), res.ProviderPeers[0].Id)
+	assert.Equal(t, metadata, res.ProviderPeers[0].Metadata)
+}
+
+func TestDHT_handleAddProvider_metadata_too_large(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDHT(t)
+
+	addrInfo := newAddrInfo(t)
+	req := newAddProviderRequest([]byte("random-key"), addrInfo)
+	req.ProviderPeers[0].Metadata = make([]byte, MaxProviderMetadataSize+1)
+
+	_, err := d.handleAddProvider(ctx, addrInfo.ID, req)
+	assert.Error(t, err)
+}
+
 func TestDHT_handleAddProvider_key_size_check(t *testing.T) {
 	d := newTestDHT(t)
 
@@ -2,18 +2,134 @@ package coord
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/benbjohnson/clock"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 
+	"github.com/plprobelab/zikade/errs"
 	"github.com/plprobelab/zikade/internal/coord/brdcst"
 	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/coord/query"
 	"github.com/plprobelab/zikade/kadt"
 	"github.com/plprobelab/zikade/pb"
 	"github.com/plprobelab/zikade/tele"
 )
 
+// BroadcastConfig specifies the configuration of the query pool that a [PooledBroadcastBehaviour] uses to find the
+// nodes a record is stored with.
+type BroadcastConfig struct {
+	// Clock is a clock that may replaced by a mock when testing
+	Clock clock.Clock
+
+	// Meter is the meter that should be used to record metrics.
+	Meter metric.Meter
+
+	// Concurrency is the maximum number of broadcast queries that may be waiting for message responses at any one time.
+	Concurrency int
+
+	// Timeout the time to wait before terminating a broadcast query that is not making progress.
+	Timeout time.Duration
+
+	// RequestConcurrency is the maximum number of concurrent requests that each broadcast query may have in flight.
+	RequestConcurrency int
+
+	// RequestTimeout is the timeout broadcast queries should use for contacting a single node
+	RequestTimeout time.Duration
+
+	// Select optionally selects the at most n nodes a record is stored with from the closest nodes found by the
+	// lookup of a broadcast, which are ordered by distance to the record's key. When set, the lookup searches for
+	// more nodes than the record should be stored with so that Select can pass over some of them. With the static
+	// strategy, Select chooses the nodes from the closest nodes in the routing table instead.
+	// If nil, the record is stored with the n closest nodes.
+	Select func(nodes []kadt.PeerID, n int) []kadt.PeerID
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *BroadcastConfig) Validate() error {
+	if cfg.Clock == nil {
+		return &errs.ConfigurationError{
+			Component: "BroadcastConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+
+	if cfg.Meter == nil {
+		return &errs.ConfigurationError{
+			Component: "BroadcastConfig",
+			Err:       fmt.Errorf("meter must not be nil"),
+		}
+	}
+
+	if cfg.Concurrency < 1 {
+		return &errs.ConfigurationError{
+			Component: "BroadcastConfig",
+			Err:       fmt.Errorf("concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.Timeout < 1 {
+		return &errs.ConfigurationError{
+			Component: "BroadcastConfig",
+			Err:       fmt.Errorf("timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.RequestConcurrency < 1 {
+		return &errs.ConfigurationError{
+			Component: "BroadcastConfig",
+			Err:       fmt.Errorf("request concurrency must be greater than zero"),
+		}
+	}
+
+	if cfg.RequestTimeout < 1 {
+		return &errs.ConfigurationError{
+			Component: "BroadcastConfig",
+			Err:       fmt.Errorf("request timeout must be greater than zero"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultBroadcastConfig returns the default configuration options for the query pool of a [PooledBroadcastBehaviour].
+func DefaultBroadcastConfig() *BroadcastConfig {
+	return &BroadcastConfig{
+		Clock: clock.New(),
+		Meter: tele.NoopMeter(),
+
+		Concurrency:        3,               // MAGIC
+		Timeout:            5 * time.Minute, // MAGIC
+		RequestConcurrency: 3,               // MAGIC
+		RequestTimeout:     time.Minute,     // MAGIC
+	}
+}
+
+// broadcastSelectOversampling is the factor by which the number of nodes a broadcast lookup searches for is
+// increased when the nodes a record is stored with are chosen by [BroadcastConfig.Select].
+const broadcastSelectOversampling = 2 // MAGIC
+
+// newBroadcastPool creates the broadcast pool used by a [PooledBroadcastBehaviour] from the given configuration.
+func newBroadcastPool(self kadt.PeerID, cfg *BroadcastConfig) (*brdcst.Pool[kadt.Key, kadt.PeerID, *pb.Message], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	qpCfg := query.DefaultPoolConfig()
+	qpCfg.Clock = cfg.Clock
+	qpCfg.Meter = cfg.Meter
+	qpCfg.Concurrency = cfg.Concurrency
+	qpCfg.Timeout = cfg.Timeout
+	qpCfg.QueryConcurrency = cfg.RequestConcurrency
+	qpCfg.RequestTimeout = cfg.RequestTimeout
+
+	return brdcst.NewPool[kadt.Key, kadt.PeerID, *pb.Message](self, brdcst.NewConfigPool(qpCfg))
+}
+
 type PooledBroadcastBehaviour struct {
 	logger *slog.Logger
 	tracer trace.Tracer
@@ -169,6 +285,7 @@ func (b *PooledBroadcastBehaviour) perfomNextInbound(ctx context.Context) (Behav
 			Message: ev.Message,
 			Seed:    ev.Seed,
 			Config:  ev.Config,
+			Select:  ev.Select,
 		}
 		if ev.Notify != nil {
 			b.notifiers[ev.QueryID] = &queryNotifier[*EventBroadcastFinished]{monitor: ev.Notify}
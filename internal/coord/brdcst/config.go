@@ -2,6 +2,7 @@ package brdcst
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/plprobelab/zikade/internal/coord/query"
 )
@@ -29,6 +30,15 @@ func DefaultConfigPool() *ConfigPool {
 	}
 }
 
+// NewConfigPool returns the configuration options for a Pool whose query pool
+// uses the given configuration. Options may be overridden before passing to
+// NewPool.
+func NewConfigPool(qpCfg *query.PoolConfig) *ConfigPool {
+	return &ConfigPool{
+		pCfg: qpCfg,
+	}
+}
+
 // Config is an interface that all broadcast configurations must implement.
 // Because we have multiple ways of broadcasting records to the network, like
 // [FollowUp] or [Static], the [EventPoolStartBroadcast] has a configuration
@@ -44,11 +54,34 @@ func (c *ConfigOptimistic) broadcastConfig() {}
 func (c *ConfigStatic) broadcastConfig()     {}
 
 // ConfigFollowUp specifies the configuration for the [FollowUp] state machine.
-type ConfigFollowUp struct{}
+// Zero values use the configuration of the query pool for the lookup of the
+// closest nodes.
+type ConfigFollowUp struct {
+	// NumResults is the number of closest nodes that the record is stored with.
+	NumResults int
+
+	// Concurrency is the maximum number of concurrent requests of the lookup.
+	Concurrency int
+
+	// RequestTimeout is the timeout for contacting a single node during the lookup.
+	RequestTimeout time.Duration
+}
 
 // Validate checks the configuration options and returns an error if any have
 // invalid values.
 func (c *ConfigFollowUp) Validate() error {
+	if c.NumResults < 0 {
+		return fmt.Errorf("num results must not be negative")
+	}
+
+	if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency must not be negative")
+	}
+
+	if c.RequestTimeout < 0 {
+		return fmt.Errorf("request timeout must not be negative")
+	}
+
 	return nil
 }
 
@@ -24,6 +24,24 @@ func TestConfigFollowUp_Validate(t *testing.T) {
 		cfg := DefaultConfigFollowUp()
 		assert.NoError(t, cfg.Validate())
 	})
+
+	t.Run("num results not negative", func(t *testing.T) {
+		cfg := DefaultConfigFollowUp()
+		cfg.NumResults = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("concurrency not negative", func(t *testing.T) {
+		cfg := DefaultConfigFollowUp()
+		cfg.Concurrency = -1
+		assert.Error(t, cfg.Validate())
+	})
+
+	t.Run("request timeout not negative", func(t *testing.T) {
+		cfg := DefaultConfigFollowUp()
+		cfg.RequestTimeout = -1
+		assert.Error(t, cfg.Validate())
+	})
 }
 
 func TestConfigOptimistic_Validate(t *testing.T) {
@@ -33,6 +33,10 @@ type FollowUp[K kad.Key[K], N kad.NodeID[K], M coordt.Message] struct {
 	// the message that we will send to the closest nodes in the follow-up phase
 	msg M
 
+	// selectFn optionally selects the nodes to store the record with from the
+	// closest nodes that the query found. If nil, all of them are used.
+	selectFn func([]N) []N
+
 	// the closest nodes to the target key. This will be filled after the query
 	// for the closest nodes has finished (when the query pool emits a
 	// [query.StatePoolQueryFinished] event).
@@ -151,9 +155,12 @@ func (f *FollowUp[K, N, M]) handleEvent(ctx context.Context, ev BroadcastEvent)
 	switch ev := ev.(type) {
 	case *EventBroadcastStart[K, N]:
 		return &query.EventPoolAddFindCloserQuery[K, N]{
-			QueryID: f.queryID,
-			Target:  ev.Target,
-			Seed:    ev.Seed,
+			QueryID:        f.queryID,
+			Target:         ev.Target,
+			Seed:           ev.Seed,
+			NumResults:     f.cfg.NumResults,
+			Concurrency:    f.cfg.Concurrency,
+			RequestTimeout: f.cfg.RequestTimeout,
 		}
 	case *EventBroadcastStop:
 		if f.isQueryDone() {
@@ -221,7 +228,12 @@ func (f *FollowUp[K, N, M]) advancePool(ctx context.Context, ev query.PoolEvent)
 			QueryID: f.queryID,
 		}, true
 	case *query.StatePoolQueryFinished[K, N]:
-		if len(st.ClosestNodes) == 0 {
+		closest := st.ClosestNodes
+		if f.selectFn != nil {
+			closest = f.selectFn(closest)
+		}
+
+		if len(closest) == 0 {
 			return &StateBroadcastFinished[K, N]{
 				QueryID:   f.queryID,
 				Contacted: make([]N, 0),
@@ -232,9 +244,9 @@ func (f *FollowUp[K, N, M]) advancePool(ctx context.Context, ev query.PoolEvent)
 			}, true
 		}
 
-		f.closest = st.ClosestNodes
+		f.closest = closest
 
-		for _, n := range st.ClosestNodes {
+		for _, n := range closest {
 			f.todo[n.String()] = n
 		}
 
@@ -120,7 +120,9 @@ func (p *Pool[K, N, M]) handleEvent(ctx context.Context, ev PoolEvent) (sm Broad
 		// first initialize the state machine for the broadcast desired strategy
 		switch cfg := ev.Config.(type) {
 		case *ConfigFollowUp:
-			p.bcs[ev.QueryID] = NewFollowUp[K, N, M](ev.QueryID, p.qp, ev.Message, cfg)
+			fu := NewFollowUp[K, N, M](ev.QueryID, p.qp, ev.Message, cfg)
+			fu.selectFn = ev.Select
+			p.bcs[ev.QueryID] = fu
 		case *ConfigStatic:
 			p.bcs[ev.QueryID] = NewStatic[K, N, M](ev.QueryID, ev.Message, cfg)
 		case *ConfigOptimistic:
@@ -287,6 +289,10 @@ type EventPoolStartBroadcast[K kad.Key[K], N kad.NodeID[K], M coordt.Message] st
 	Message M              // the message that we want to send to the closest peers (this encapsulates the payload we want to store)
 	Seed    []N            // the closest nodes we know so far and from where we start the operation
 	Config  Config         // the configuration for this operation. Most importantly, this defines the broadcast strategy ([FollowUp] or [Static])
+
+	// Select optionally selects the nodes a [FollowUp] broadcast stores the record with from the closest nodes
+	// its lookup found, which are ordered by distance to Target. If nil, the record is stored with all of them.
+	Select func([]N) []N
 }
 
 // EventPoolStopBroadcast notifies broadcast [Pool] to stop a broadcast
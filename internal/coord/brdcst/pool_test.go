@@ -272,6 +272,67 @@ func TestPool_FollowUp_stop_during_followup_phase(t *testing.T) {
 	require.Len(t, st.Errors, 2)
 }
 
+func TestPool_FollowUp_select(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfigPool()
+
+	self := tiny.NewNode(0)
+
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	msg := tiny.Message{Content: "store this"}
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00000011) // 3
+
+	queryID := coordt.QueryID("test")
+
+	// only store the record with the node farthest from the target
+	var candidates []tiny.Node
+	state := p.Advance(ctx, &EventPoolStartBroadcast[tiny.Key, tiny.Node, tiny.Message]{
+		QueryID: queryID,
+		Target:  target,
+		Message: msg,
+		Seed:    []tiny.Node{a, b},
+		Config:  DefaultConfigFollowUp(),
+		Select: func(nodes []tiny.Node) []tiny.Node {
+			candidates = nodes
+			return nodes[len(nodes)-1:]
+		},
+	})
+
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+
+	state = p.Advance(ctx, &EventPoolGetCloserNodesSuccess[tiny.Key, tiny.Node]{
+		QueryID:     queryID,
+		Target:      target,
+		NodeID:      a,
+		CloserNodes: []tiny.Node{a, b},
+	})
+	require.IsType(t, &StatePoolWaiting{}, state)
+
+	state = p.Advance(ctx, &EventPoolGetCloserNodesSuccess[tiny.Key, tiny.Node]{
+		QueryID:     queryID,
+		Target:      target,
+		NodeID:      b,
+		CloserNodes: []tiny.Node{a, b},
+	})
+
+	// the selection is made from the closest nodes in order of distance
+	require.Equal(t, []tiny.Node{b, a}, candidates)
+
+	st, ok := state.(*StatePoolStoreRecord[tiny.Key, tiny.Node, tiny.Message])
+	require.True(t, ok, "state is %T", state)
+	require.Equal(t, a, st.NodeID)
+
+	// no other node is asked to store the record
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolWaiting{}, state)
+}
+
 func TestPool_empty_seed(t *testing.T) {
 	ctx := context.Background()
 	cfg := DefaultConfigPool()
@@ -15,6 +15,9 @@ type EventStartBroadcast struct {
 	Seed    []kadt.PeerID
 	Config  brdcst.Config
 	Notify  QueryMonitor[*EventBroadcastFinished]
+
+	// Select optionally selects the nodes the record is stored with from the closest nodes found, see [brdcst.EventPoolStartBroadcast].
+	Select func([]kadt.PeerID) []kadt.PeerID
 }
 
 func (*EventStartBroadcast) behaviourEvent() {}
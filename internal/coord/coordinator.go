@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/plprobelab/go-libdht/kad"
@@ -19,6 +20,7 @@ import (
 	"github.com/plprobelab/zikade/errs"
 	"github.com/plprobelab/zikade/internal/coord/brdcst"
 	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/coord/query"
 	"github.com/plprobelab/zikade/internal/coord/routing"
 	"github.com/plprobelab/zikade/kadt"
 	"github.com/plprobelab/zikade/pb"
@@ -62,14 +64,251 @@ type Coordinator struct {
 	// tele provides tracing and metric reporting capabilities
 	tele *Telemetry
 
-	// routingNotifierMu guards access to routingNotifier which may be changed during coordinator operation
+	// routingNotifierMu guards access to routingNotifier and routingObservers which may be changed during
+	// coordinator operation
 	routingNotifierMu sync.RWMutex
 
 	// routingNotifier receives routing notifications
 	routingNotifier RoutingNotifier
 
+	// routingObservers receive routing notifications in addition to routingNotifier
+	routingObservers []RoutingNotifier
+
 	// lastQueryID holds the last numeric query id generated
 	lastQueryID atomic.Uint64
+
+	// lookups keeps track of message queries that are shared between
+	// concurrent callers (see [CoordinatorConfig.CoalesceLookups]).
+	lookups *lookupRegistry
+
+	// ops keeps track of the queries and broadcasts started by callers so they can be inspected and cancelled.
+	ops *operationRegistry
+
+	// load measures the utilization of the event loop. It is nil if [QueryConfig.AdaptiveConcurrency] is false.
+	load *loadMonitor
+}
+
+// QueryOptions holds the optional settings of a single query that is started
+// by the [Coordinator].
+type QueryOptions struct {
+	// DisjointPaths is the number of disjoint paths the query should use (see
+	// [query.DisjointQuery]). Zero uses the default of a single path.
+	DisjointPaths int
+
+	// Priority is the priority class of the query. It defaults to
+	// [query.PriorityInteractive].
+	Priority query.Priority
+
+	// Termination decides whether the query should finish before it ran to
+	// completion. Nil uses [query.KClosestPolicy].
+	Termination query.TerminationPolicy
+
+	// NumResults is the number of closest nodes the query looks for or a
+	// broadcast stores the record with. Zero uses the value that was passed
+	// to the method of the [Coordinator].
+	NumResults int
+
+	// Concurrency is the maximum number of concurrent requests of the query.
+	// Zero uses [QueryConfig.RequestConcurrency].
+	Concurrency int
+
+	// RequestTimeout is the timeout for contacting a single node. Zero uses
+	// [QueryConfig.RequestTimeout].
+	RequestTimeout time.Duration
+
+	// Timeout is the maximum time the caller waits for the query to finish.
+	// Zero waits until the context of the caller is done.
+	Timeout time.Duration
+
+	// SeedCount is the number of closest nodes in the routing table that the
+	// query starts from. Zero uses the default of the method of the
+	// [Coordinator].
+	SeedCount int
+
+	// Broadcast is the strategy that a broadcast uses to store a record.
+	Broadcast BroadcastStrategy
+
+	// OnPath, if not nil, is called with the path that the query or broadcast
+	// took through the network before the method of the [Coordinator]
+	// returns.
+	OnPath func(context.Context, Path)
+}
+
+// BroadcastStrategy is the way that a broadcast stores a record with the
+// closest nodes to its key.
+type BroadcastStrategy int
+
+const (
+	// BroadcastFollowUp looks up the closest nodes first and then stores the
+	// record with them. It is the default.
+	BroadcastFollowUp BroadcastStrategy = iota
+
+	// BroadcastStatic stores the record with the closest nodes in the
+	// routing table without looking up closer nodes.
+	BroadcastStatic
+)
+
+// A QueryOption configures a single query that is started by the [Coordinator].
+type QueryOption func(*QueryOptions)
+
+// WithDisjointPaths configures a query to perform an S/Kademlia style lookup
+// over the given number of disjoint paths.
+func WithDisjointPaths(paths int) QueryOption {
+	return func(o *QueryOptions) {
+		o.DisjointPaths = paths
+	}
+}
+
+// WithPriority configures the priority class of a query. The query pool takes
+// turns between the queries of the different classes so that long-running
+// background queries cannot starve interactive ones.
+func WithPriority(p query.Priority) QueryOption {
+	return func(o *QueryOptions) {
+		o.Priority = p
+	}
+}
+
+// WithTermination configures the policy that decides whether a query should
+// finish before it ran to completion, e.g., [query.RequestBudgetPolicy].
+// Message queries with a termination policy are never shared with other
+// callers (see [CoordinatorConfig.CoalesceLookups]).
+func WithTermination(p query.TerminationPolicy) QueryOption {
+	return func(o *QueryOptions) {
+		o.Termination = p
+	}
+}
+
+// WithNumResults configures the number of closest nodes a query looks for or
+// a broadcast stores the record with.
+func WithNumResults(n int) QueryOption {
+	return func(o *QueryOptions) {
+		o.NumResults = n
+	}
+}
+
+// WithRequestConcurrency configures the maximum number of concurrent requests
+// of a query.
+func WithRequestConcurrency(n int) QueryOption {
+	return func(o *QueryOptions) {
+		o.Concurrency = n
+	}
+}
+
+// WithRequestTimeout configures the timeout for contacting a single node
+// during a query. It takes precedence over adaptive request timeouts.
+func WithRequestTimeout(d time.Duration) QueryOption {
+	return func(o *QueryOptions) {
+		o.RequestTimeout = d
+	}
+}
+
+// WithTimeout configures the maximum time to wait for a query or broadcast
+// to finish. The query is stopped when the timeout expires, and the caller
+// receives a [context.DeadlineExceeded] error.
+func WithTimeout(d time.Duration) QueryOption {
+	return func(o *QueryOptions) {
+		o.Timeout = d
+	}
+}
+
+// WithSeedCount configures the number of closest nodes in the routing table
+// that a query or broadcast starts from.
+func WithSeedCount(n int) QueryOption {
+	return func(o *QueryOptions) {
+		o.SeedCount = n
+	}
+}
+
+// WithBroadcastStrategy configures the strategy that a broadcast uses to store
+// a record.
+func WithBroadcastStrategy(s BroadcastStrategy) QueryOption {
+	return func(o *QueryOptions) {
+		o.Broadcast = s
+	}
+}
+
+// WithPath configures a query or broadcast to record the path that it takes
+// through the network and to pass it to fn when the caller stops waiting for
+// it. Message queries that record their path are never shared with other
+// callers (see [CoordinatorConfig.CoalesceLookups]).
+func WithPath(fn func(context.Context, Path)) QueryOption {
+	return func(o *QueryOptions) {
+		o.OnPath = fn
+	}
+}
+
+// validate checks the query options and returns an error if any have invalid values.
+func (o *QueryOptions) validate() error {
+	if o.Termination != nil {
+		if err := o.Termination.Validate(); err != nil {
+			return fmt.Errorf("termination policy: %w", err)
+		}
+	}
+	if o.NumResults < 0 {
+		return fmt.Errorf("num results must not be negative")
+	}
+	if o.Concurrency < 0 {
+		return fmt.Errorf("request concurrency must not be negative")
+	}
+	if o.RequestTimeout < 0 {
+		return fmt.Errorf("request timeout must not be negative")
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if o.SeedCount < 0 {
+		return fmt.Errorf("seed count must not be negative")
+	}
+	switch o.Broadcast {
+	case BroadcastFollowUp, BroadcastStatic:
+	default:
+		return fmt.Errorf("unknown broadcast strategy: %d", o.Broadcast)
+	}
+	return nil
+}
+
+// shareable reports whether a message query with these options may be shared
+// with other callers that look up the same key. Options that change how the
+// query itself is performed, rather than how long a caller waits for it,
+// prevent sharing.
+func (o *QueryOptions) shareable() bool {
+	return o.Termination == nil && o.NumResults == 0 && o.Concurrency == 0 && o.RequestTimeout == 0 && o.SeedCount == 0 && o.OnPath == nil
+}
+
+// numResults returns the number of closest nodes the query should look for,
+// falling back to n if the options don't specify it.
+func (o *QueryOptions) numResults(n int) int {
+	if o.NumResults > 0 {
+		return o.NumResults
+	}
+	return n
+}
+
+// seedCount returns the number of seed nodes the query should start from,
+// falling back to n if the options don't specify it.
+func (o *QueryOptions) seedCount(n int) int {
+	if o.SeedCount > 0 {
+		return o.SeedCount
+	}
+	return n
+}
+
+// withTimeout returns a context that expires after the timeout of the options,
+// if there is one.
+func (o *QueryOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout > 0 {
+		return context.WithTimeout(ctx, o.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// newQueryOptions applies the given options to a new [QueryOptions].
+func newQueryOptions(opts []QueryOption) *QueryOptions {
+	o := &QueryOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
 type RoutingNotifier interface {
@@ -94,6 +333,16 @@ type CoordinatorConfig struct {
 
 	// Query is the configuration used for the [PooledQueryBehaviour] which manages the execution of user queries.
 	Query QueryConfig
+
+	// Broadcast is the configuration used for the [PooledBroadcastBehaviour] which stores records with the closest nodes.
+	Broadcast BroadcastConfig
+
+	// CoalesceLookups specifies whether concurrent message queries for the
+	// same message type, key and number of results should be deduplicated. If
+	// true, callers that request a lookup while such a query is in flight
+	// attach to that query and receive the same stream of results instead of
+	// starting a new query.
+	CoalesceLookups bool
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -136,12 +385,15 @@ func DefaultCoordinatorConfig() *CoordinatorConfig {
 		Logger:         tele.DefaultLogger("coord"),
 		MeterProvider:  otel.GetMeterProvider(),
 		TracerProvider: otel.GetTracerProvider(),
+
+		CoalesceLookups: false,
 	}
 
 	cfg.Query = *DefaultQueryConfig()
 	cfg.Query.Clock = cfg.Clock
 	cfg.Query.Logger = cfg.Logger.With("behaviour", "pooledquery")
 	cfg.Query.Tracer = cfg.TracerProvider.Tracer(tele.TracerName)
+	cfg.Query.Meter = cfg.MeterProvider.Meter(tele.MeterName)
 
 	cfg.Routing = *DefaultRoutingConfig()
 	cfg.Routing.Clock = cfg.Clock
@@ -149,6 +401,10 @@ func DefaultCoordinatorConfig() *CoordinatorConfig {
 	cfg.Routing.Tracer = cfg.TracerProvider.Tracer(tele.TracerName)
 	cfg.Routing.Meter = cfg.MeterProvider.Meter(tele.MeterName)
 
+	cfg.Broadcast = *DefaultBroadcastConfig()
+	cfg.Broadcast.Clock = cfg.Clock
+	cfg.Broadcast.Meter = cfg.MeterProvider.Meter(tele.MeterName)
+
 	return cfg
 }
 
@@ -175,9 +431,14 @@ func NewCoordinator(self kadt.PeerID, rtr coordt.Router[kadt.Key, kadt.PeerID, *
 		return nil, fmt.Errorf("routing behaviour: %w", err)
 	}
 
-	networkBehaviour := NewNetworkBehaviour(rtr, cfg.Logger, tele.Tracer)
+	if cfg.Query.PreferScoredNodes {
+		// let queries prefer nodes that the routing behaviour scored highly among nodes of similar distance
+		queryBehaviour.SetNodeScorer(routingBehaviour)
+	}
+
+	networkBehaviour := NewNetworkBehaviour(rtr, cfg.Clock, cfg.Logger, tele.Tracer)
 
-	b, err := brdcst.NewPool[kadt.Key, kadt.PeerID, *pb.Message](self, nil)
+	b, err := newBroadcastPool(self, &cfg.Broadcast)
 	if err != nil {
 		return nil, fmt.Errorf("broadcast: %w", err)
 	}
@@ -195,6 +456,9 @@ func NewCoordinator(self kadt.PeerID, rtr coordt.Router[kadt.Key, kadt.PeerID, *
 		cancel: cancel,
 		done:   make(chan struct{}),
 
+		lookups: newLookupRegistry(),
+		ops:     newOperationRegistry(cfg.Clock),
+
 		networkBehaviour: networkBehaviour,
 		routingBehaviour: routingBehaviour,
 		queryBehaviour:   queryBehaviour,
@@ -203,6 +467,10 @@ func NewCoordinator(self kadt.PeerID, rtr coordt.Router[kadt.Key, kadt.PeerID, *
 		routingNotifier: nullRoutingNotifier{},
 	}
 
+	if cfg.Query.AdaptiveConcurrency {
+		d.load = newLoadMonitor(cfg.Clock)
+	}
+
 	go d.eventLoop(ctx)
 
 	return d, nil
@@ -226,26 +494,35 @@ func (c *Coordinator) eventLoop(ctx context.Context) {
 	defer span.End()
 
 	for {
-		var ev BehaviourEvent
-		var ok bool
+		var b Behaviour[BehaviourEvent, BehaviourEvent]
 
 		select {
 		case <-ctx.Done():
 			// coordinator is closing
 			return
 		case <-c.networkBehaviour.Ready():
-			ev, ok = c.networkBehaviour.Perform(ctx)
+			b = c.networkBehaviour
 		case <-c.routingBehaviour.Ready():
-			ev, ok = c.routingBehaviour.Perform(ctx)
+			b = c.routingBehaviour
 		case <-c.queryBehaviour.Ready():
-			ev, ok = c.queryBehaviour.Perform(ctx)
+			b = c.queryBehaviour
 		case <-c.brdcstBehaviour.Ready():
-			ev, ok = c.brdcstBehaviour.Perform(ctx)
+			b = c.brdcstBehaviour
 		}
 
+		start := c.cfg.Clock.Now()
+
+		ev, ok := b.Perform(ctx)
 		if ok {
 			c.dispatchEvent(ctx, ev)
 		}
+
+		if c.load != nil {
+			// report the share of time spent performing work so queries can be throttled when the event loop is busy
+			if utilization, ok := c.load.record(c.cfg.Clock.Since(start)); ok {
+				c.queryBehaviour.Notify(ctx, &EventNotifyLoad{Utilization: utilization})
+			}
+		}
 	}
 }
 
@@ -255,6 +532,7 @@ func (c *Coordinator) dispatchEvent(ctx context.Context, ev BehaviourEvent) {
 
 	switch ev := ev.(type) {
 	case NetworkCommand:
+		c.ops.track(ev)
 		c.networkBehaviour.Notify(ctx, ev)
 	case QueryCommand:
 		c.queryBehaviour.Notify(ctx, ev)
@@ -265,8 +543,12 @@ func (c *Coordinator) dispatchEvent(ctx context.Context, ev BehaviourEvent) {
 	case RoutingNotification:
 		c.routingNotifierMu.RLock()
 		rn := c.routingNotifier
+		observers := c.routingObservers
 		c.routingNotifierMu.RUnlock()
 		rn.Notify(ctx, ev)
+		for _, o := range observers {
+			o.Notify(ctx, ev)
+		}
 	default:
 		panic(fmt.Sprintf("unexpected event: %T", ev))
 	}
@@ -278,6 +560,18 @@ func (c *Coordinator) SetRoutingNotifier(rn RoutingNotifier) {
 	c.routingNotifierMu.Unlock()
 }
 
+// AddRoutingObserver registers rn to receive all routing notifications in addition to the notifier set with
+// [Coordinator.SetRoutingNotifier] and any observers that were added before. Observers are notified in the
+// order they were added, after the routing notifier.
+func (c *Coordinator) AddRoutingObserver(rn RoutingNotifier) {
+	c.routingNotifierMu.Lock()
+	// copy the observers so that a concurrent dispatch keeps iterating its own slice
+	observers := make([]RoutingNotifier, len(c.routingObservers), len(c.routingObservers)+1)
+	copy(observers, c.routingObservers)
+	c.routingObservers = append(observers, rn)
+	c.routingNotifierMu.Unlock()
+}
+
 // IsRoutable reports whether the supplied node is present in the local routing table.
 func (c *Coordinator) IsRoutable(ctx context.Context, id kadt.PeerID) bool {
 	_, exists := c.rt.GetNode(id.Key())
@@ -301,15 +595,24 @@ func (c *Coordinator) GetClosestNodes(ctx context.Context, k kadt.Key, n int) ([
 // numResults specifies the minimum number of nodes to successfully contact before considering iteration complete.
 // The query is considered to be exhausted when it has received responses from at least this number of nodes
 // and there are no closer nodes remaining to be contacted. A default of 20 is used if this value is less than 1.
-func (c *Coordinator) QueryClosest(ctx context.Context, target kadt.Key, fn coordt.QueryFunc, numResults int) ([]kadt.PeerID, coordt.QueryStats, error) {
+//
+// The supplied [QueryOption] values change how the lookup is performed, e.g., [WithDisjointPaths].
+func (c *Coordinator) QueryClosest(ctx context.Context, target kadt.Key, fn coordt.QueryFunc, numResults int, opts ...QueryOption) ([]kadt.PeerID, coordt.QueryStats, error) {
 	ctx, span := c.tele.Tracer.Start(ctx, "Coordinator.Query")
 	defer span.End()
 	c.cfg.Logger.Debug("starting query for closest nodes", tele.LogAttrKey(target))
 
-	ctx, cancel := context.WithCancel(ctx)
+	qopts := newQueryOptions(opts)
+	if err := qopts.validate(); err != nil {
+		return nil, coordt.QueryStats{}, err
+	}
+
+	ctx, cancel := qopts.withTimeout(ctx)
 	defer cancel()
 
-	seedIDs, err := c.GetClosestNodes(ctx, target, 20)
+	numResults = qopts.numResults(numResults)
+
+	seedIDs, err := c.GetClosestNodes(ctx, target, qopts.seedCount(20))
 	if err != nil {
 		return nil, coordt.QueryStats{}, err
 	}
@@ -317,18 +620,31 @@ func (c *Coordinator) QueryClosest(ctx context.Context, target kadt.Key, fn coor
 	waiter := NewQueryWaiter(numResults)
 	queryID := c.newOperationID()
 
+	release := c.ops.register(newOperation(queryID, OperationFindCloser, 0, target, c.cfg.Clock.Now()), c.queryBehaviour, cancel)
+	defer release()
+	if qopts.OnPath != nil {
+		c.ops.recordPath(queryID)
+	}
+
 	cmd := &EventStartFindCloserQuery{
 		QueryID:           queryID,
 		Target:            target,
 		KnownClosestNodes: seedIDs,
 		Notify:            waiter,
 		NumResults:        numResults,
+		DisjointPaths:     qopts.DisjointPaths,
+		Priority:          qopts.Priority,
+		Termination:       qopts.Termination,
+		Concurrency:       qopts.Concurrency,
+		RequestTimeout:    qopts.RequestTimeout,
 	}
 
 	// queue the start of the query
 	c.queryBehaviour.Notify(ctx, cmd)
 
-	return c.waitForQuery(ctx, queryID, waiter, fn)
+	closest, stats, err := c.waitForQuery(ctx, queryID, waiter, fn)
+	c.reportPath(ctx, queryID, qopts.OnPath)
+	return closest, stats, err
 }
 
 // QueryMessage starts a query that iterates over the closest nodes to the target key in the supplied message.
@@ -342,7 +658,9 @@ func (c *Coordinator) QueryClosest(ctx context.Context, target kadt.Key, fn coor
 // numResults specifies the minimum number of nodes to successfully contact before considering iteration complete.
 // The query is considered to be exhausted when it has received responses from at least this number of nodes
 // and there are no closer nodes remaining to be contacted. A default of 20 is used if this value is less than 1.
-func (c *Coordinator) QueryMessage(ctx context.Context, msg *pb.Message, fn coordt.QueryFunc, numResults int) ([]kadt.PeerID, coordt.QueryStats, error) {
+//
+// The supplied [QueryOption] values change how the lookup is performed, e.g., [WithDisjointPaths].
+func (c *Coordinator) QueryMessage(ctx context.Context, msg *pb.Message, fn coordt.QueryFunc, numResults int, opts ...QueryOption) ([]kadt.PeerID, coordt.QueryStats, error) {
 	ctx, span := c.tele.Tracer.Start(ctx, "Coordinator.QueryMessage")
 	defer span.End()
 	if msg == nil {
@@ -350,21 +668,37 @@ func (c *Coordinator) QueryMessage(ctx context.Context, msg *pb.Message, fn coor
 	}
 	c.cfg.Logger.Debug("starting query with message", tele.LogAttrKey(msg.Target()), slog.String("type", msg.Type.String()))
 
-	ctx, cancel := context.WithCancel(ctx)
+	qopts := newQueryOptions(opts)
+	if err := qopts.validate(); err != nil {
+		return nil, coordt.QueryStats{}, err
+	}
+
+	ctx, cancel := qopts.withTimeout(ctx)
 	defer cancel()
 
+	numResults = qopts.numResults(numResults)
 	if numResults < 1 {
-		numResults = 20 // TODO: parameterize
+		numResults = 20
 	}
 
-	seedIDs, err := c.GetClosestNodes(ctx, msg.Target(), numResults)
+	seedIDs, err := c.GetClosestNodes(ctx, msg.Target(), qopts.seedCount(numResults))
 	if err != nil {
 		return nil, coordt.QueryStats{}, err
 	}
 
+	if c.cfg.CoalesceLookups && qopts.shareable() {
+		return c.sharedQueryMessage(ctx, cancel, msg, fn, numResults, seedIDs, qopts)
+	}
+
 	waiter := NewQueryWaiter(numResults)
 	queryID := c.newOperationID()
 
+	release := c.ops.register(newOperation(queryID, OperationMessage, msg.GetType(), msg.Target(), c.cfg.Clock.Now()), c.queryBehaviour, cancel)
+	defer release()
+	if qopts.OnPath != nil {
+		c.ops.recordPath(queryID)
+	}
+
 	cmd := &EventStartMessageQuery{
 		QueryID:           queryID,
 		Target:            msg.Target(),
@@ -372,40 +706,142 @@ func (c *Coordinator) QueryMessage(ctx context.Context, msg *pb.Message, fn coor
 		KnownClosestNodes: seedIDs,
 		Notify:            waiter,
 		NumResults:        numResults,
+		DisjointPaths:     qopts.DisjointPaths,
+		Priority:          qopts.Priority,
+		Termination:       qopts.Termination,
+		Concurrency:       qopts.Concurrency,
+		RequestTimeout:    qopts.RequestTimeout,
 	}
 
 	// queue the start of the query
 	c.queryBehaviour.Notify(ctx, cmd)
 
 	closest, stats, err := c.waitForQuery(ctx, queryID, waiter, fn)
+	c.reportPath(ctx, queryID, qopts.OnPath)
 	return closest, stats, err
 }
 
-func (c *Coordinator) BroadcastRecord(ctx context.Context, msg *pb.Message) error {
+// sharedQueryMessage is like QueryMessage but attaches the caller to an
+// in-flight query for the same [lookupKey] if there is one. Only if
+// there is none, a new query is started. The query is detached from the
+// context of the caller that started it, so that it continues to run for all
+// other attached callers if that caller goes away. The query is stopped once
+// all callers have detached from it.
+func (c *Coordinator) sharedQueryMessage(ctx context.Context, cancel context.CancelFunc, msg *pb.Message, fn coordt.QueryFunc, numResults int, seedIDs []kadt.PeerID, qopts *QueryOptions) ([]kadt.PeerID, coordt.QueryStats, error) {
+	waiter := NewQueryWaiter(numResults)
+	lookup, created := c.lookups.attach(newLookupKey(msg, numResults, qopts), c.newOperationID(), waiter)
+	defer c.stopQuery(ctx, lookup.queryID, waiter)
+
+	// every attached caller registers the operation so that it is listed until the last caller detached
+	release := c.ops.register(newOperation(lookup.queryID, OperationMessage, msg.GetType(), msg.Target(), c.cfg.Clock.Now()), c.queryBehaviour, cancel)
+	defer release()
+
+	if created {
+		source := NewQueryWaiter(numResults)
+		go lookup.run(c.lookups, source)
+
+		cmd := &EventStartMessageQuery{
+			QueryID:           lookup.queryID,
+			Target:            msg.Target(),
+			Message:           msg,
+			KnownClosestNodes: seedIDs,
+			Notify:            source,
+			NumResults:        numResults,
+			DisjointPaths:     qopts.DisjointPaths,
+			Priority:          qopts.Priority,
+			Termination:       qopts.Termination,
+		}
+
+		// queue the start of the query with a context that only carries the
+		// tracing information but is never cancelled.
+		qctx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+		c.queryBehaviour.Notify(qctx, cmd)
+	} else {
+		c.cfg.Logger.Debug("attached to in-flight query", "query_id", lookup.queryID, tele.LogAttrKey(msg.Target()), slog.String("type", msg.Type.String()))
+	}
+
+	return c.waitForQuery(ctx, lookup.queryID, waiter, fn)
+}
+
+// stopQuery stops the query with the given id on behalf of the caller that
+// waits for the query's events on the given waiter. If the query is shared
+// between multiple callers, the caller is only detached from it, and the
+// query is stopped when the last caller detached.
+func (c *Coordinator) stopQuery(ctx context.Context, queryID coordt.QueryID, waiter *QueryWaiter) {
+	if !c.lookups.detach(queryID, waiter) {
+		return
+	}
+	c.queryBehaviour.Notify(ctx, &EventStopQuery{QueryID: queryID})
+}
+
+// BroadcastRecord stores the record in the supplied message with the closest nodes to its key. It returns
+// statistics on the requests that the broadcast made.
+//
+// The supplied [QueryOption] values change how the broadcast is performed, e.g., [WithBroadcastStrategy].
+func (c *Coordinator) BroadcastRecord(ctx context.Context, msg *pb.Message, opts ...QueryOption) (coordt.QueryStats, error) {
 	ctx, span := c.tele.Tracer.Start(ctx, "Coordinator.BroadcastRecord")
 	defer span.End()
 	if msg == nil {
-		return fmt.Errorf("no message supplied for broadcast")
+		return coordt.QueryStats{}, fmt.Errorf("no message supplied for broadcast")
 	}
 	c.cfg.Logger.Debug("starting broadcast with message", tele.LogAttrKey(msg.Target()), slog.String("type", msg.Type.String()))
 
-	ctx, cancel := context.WithCancel(ctx)
+	qopts := newQueryOptions(opts)
+	if err := qopts.validate(); err != nil {
+		return coordt.QueryStats{}, err
+	}
+
+	ctx, cancel := qopts.withTimeout(ctx)
 	defer cancel()
 
-	seeds, err := c.GetClosestNodes(ctx, msg.Target(), 20) // TODO: parameterize
+	numResults := qopts.numResults(20)
+
+	seedCount := qopts.seedCount(numResults)
+	fetchCount := seedCount
+
+	var cfg brdcst.Config
+	var sel func([]kadt.PeerID) []kadt.PeerID
+	switch qopts.Broadcast {
+	case BroadcastStatic:
+		cfg = brdcst.DefaultConfigStatic()
+		if c.cfg.Broadcast.Select != nil {
+			// the record is stored with the seeds themselves, so they are chosen from more of the closest nodes
+			fetchCount = seedCount * broadcastSelectOversampling
+		}
+	default:
+		fcfg := brdcst.DefaultConfigFollowUp()
+		fcfg.NumResults = numResults
+		fcfg.Concurrency = qopts.Concurrency
+		fcfg.RequestTimeout = qopts.RequestTimeout
+		if c.cfg.Broadcast.Select != nil {
+			fcfg.NumResults = numResults * broadcastSelectOversampling
+			sel = func(nodes []kadt.PeerID) []kadt.PeerID {
+				return c.cfg.Broadcast.Select(nodes, numResults)
+			}
+		}
+		cfg = fcfg
+	}
+
+	seeds, err := c.GetClosestNodes(ctx, msg.Target(), fetchCount)
 	if err != nil {
-		return err
+		return coordt.QueryStats{}, err
+	}
+	if qopts.Broadcast == BroadcastStatic && c.cfg.Broadcast.Select != nil {
+		seeds = c.cfg.Broadcast.Select(seeds, seedCount)
 	}
-	return c.broadcast(ctx, msg, seeds, brdcst.DefaultConfigFollowUp())
+
+	return c.broadcast(ctx, msg, seeds, cfg, sel, qopts.OnPath)
 }
 
-func (c *Coordinator) BroadcastStatic(ctx context.Context, msg *pb.Message, seeds []kadt.PeerID) error {
+// BroadcastStatic stores the record in the supplied message with the given nodes. It returns statistics on
+// the requests that the broadcast made.
+func (c *Coordinator) BroadcastStatic(ctx context.Context, msg *pb.Message, seeds []kadt.PeerID) (coordt.QueryStats, error) {
 	ctx, span := c.tele.Tracer.Start(ctx, "Coordinator.BroadcastStatic")
 	defer span.End()
-	return c.broadcast(ctx, msg, seeds, brdcst.DefaultConfigStatic())
+	return c.broadcast(ctx, msg, seeds, brdcst.DefaultConfigStatic(), nil, nil)
 }
 
-func (c *Coordinator) broadcast(ctx context.Context, msg *pb.Message, seeds []kadt.PeerID, cfg brdcst.Config) error {
+func (c *Coordinator) broadcast(ctx context.Context, msg *pb.Message, seeds []kadt.PeerID, cfg brdcst.Config, sel func([]kadt.PeerID) []kadt.PeerID, onPath func(context.Context, Path)) (coordt.QueryStats, error) {
 	ctx, span := c.tele.Tracer.Start(ctx, "Coordinator.broadcast")
 	defer span.End()
 
@@ -415,6 +851,12 @@ func (c *Coordinator) broadcast(ctx context.Context, msg *pb.Message, seeds []ka
 	waiter := NewBroadcastWaiter(0) // zero capacity since waitForBroadcast ignores progress events
 	queryID := c.newOperationID()
 
+	release := c.ops.register(newOperation(queryID, OperationBroadcast, msg.GetType(), msg.Target(), c.cfg.Clock.Now()), c.brdcstBehaviour, cancel)
+	defer release()
+	if onPath != nil {
+		c.ops.recordPath(queryID)
+	}
+
 	cmd := &EventStartBroadcast{
 		QueryID: queryID,
 		Target:  msg.Target(),
@@ -422,54 +864,83 @@ func (c *Coordinator) broadcast(ctx context.Context, msg *pb.Message, seeds []ka
 		Seed:    seeds,
 		Notify:  waiter,
 		Config:  cfg,
+		Select:  sel,
 	}
 
 	// queue the start of the query
 	c.brdcstBehaviour.Notify(ctx, cmd)
 
 	contacted, _, err := c.waitForBroadcast(ctx, waiter)
+	stats := c.operationStats(queryID)
+	c.reportPath(ctx, queryID, onPath)
 	if err != nil {
-		return err
+		// stop the broadcast since nobody is waiting for it anymore
+		c.brdcstBehaviour.Notify(ctx, &EventStopQuery{QueryID: queryID})
+		return stats, err
 	}
 
 	if len(contacted) == 0 {
-		return fmt.Errorf("no peers contacted")
+		return stats, fmt.Errorf("no peers contacted")
 	}
 
 	// TODO: define threshold below which we consider the provide to have failed
 
-	return nil
+	return stats, nil
+}
+
+// reportPath passes the path that the operation with the given id took through the network so far to fn,
+// unless fn is nil.
+func (c *Coordinator) reportPath(ctx context.Context, queryID coordt.QueryID, fn func(context.Context, Path)) {
+	if fn == nil {
+		return
+	}
+	path, found := c.ops.path(queryID)
+	if !found {
+		return
+	}
+	fn(ctx, path)
+}
+
+// operationStats returns the statistics of the requests that the running operation with the given id made
+// so far. The end time of the statistics is set to the current time.
+func (c *Coordinator) operationStats(queryID coordt.QueryID) coordt.QueryStats {
+	op, _ := c.ops.get(queryID)
+	op.Stats.End = c.cfg.Clock.Now()
+	return op.Stats
 }
 
 func (c *Coordinator) waitForQuery(ctx context.Context, queryID coordt.QueryID, waiter *QueryWaiter, fn coordt.QueryFunc) ([]kadt.PeerID, coordt.QueryStats, error) {
 	var lastStats coordt.QueryStats
+	progressed := waiter.Progressed()
 	for {
 		select {
 		case <-ctx.Done():
+			// stop the query since nobody is waiting for it anymore
+			c.stopQuery(context.Background(), queryID, waiter)
+			lastStats.End = c.cfg.Clock.Now()
 			return nil, lastStats, ctx.Err()
 
-		case wev, more := <-waiter.Progressed():
+		case wev, more := <-progressed:
 			if !more {
-				return nil, lastStats, ctx.Err()
+				// all progress events were delivered, the finished event follows
+				progressed = nil
+				continue
 			}
 			ctx, ev := wev.Ctx, wev.Event
 			c.cfg.Logger.Debug("query made progress", "query_id", queryID, tele.LogAttrPeerID(ev.NodeID), slog.Duration("elapsed", c.cfg.Clock.Since(ev.Stats.Start)), slog.Int("requests", ev.Stats.Requests), slog.Int("failures", ev.Stats.Failure))
-			lastStats = coordt.QueryStats{
-				Start:    ev.Stats.Start,
-				Requests: ev.Stats.Requests,
-				Success:  ev.Stats.Success,
-				Failure:  ev.Stats.Failure,
-			}
+			lastStats = c.progressStats(queryID, ev.Stats)
 			err := fn(ctx, ev.NodeID, ev.Response, lastStats)
 			if errors.Is(err, coordt.ErrSkipRemaining) {
 				// done
 				c.cfg.Logger.Debug("query done", "query_id", queryID)
-				c.queryBehaviour.Notify(ctx, &EventStopQuery{QueryID: queryID})
+				c.stopQuery(ctx, queryID, waiter)
+				lastStats.End = c.cfg.Clock.Now()
 				return nil, lastStats, nil
 			}
 			if err != nil {
 				// user defined error that terminates the query
-				c.queryBehaviour.Notify(ctx, &EventStopQuery{QueryID: queryID})
+				c.stopQuery(ctx, queryID, waiter)
+				lastStats.End = c.cfg.Clock.Now()
 				return nil, lastStats, err
 			}
 		case wev, more := <-waiter.Finished():
@@ -477,12 +948,7 @@ func (c *Coordinator) waitForQuery(ctx context.Context, queryID coordt.QueryID,
 			for pev := range waiter.Progressed() {
 				ctx, ev := pev.Ctx, pev.Event
 				c.cfg.Logger.Debug("query made progress", "query_id", queryID, tele.LogAttrPeerID(ev.NodeID), slog.Duration("elapsed", c.cfg.Clock.Since(ev.Stats.Start)), slog.Int("requests", ev.Stats.Requests), slog.Int("failures", ev.Stats.Failure))
-				lastStats = coordt.QueryStats{
-					Start:    ev.Stats.Start,
-					Requests: ev.Stats.Requests,
-					Success:  ev.Stats.Success,
-					Failure:  ev.Stats.Failure,
-				}
+				lastStats = c.progressStats(queryID, ev.Stats)
 				if err := fn(ctx, ev.NodeID, ev.Response, lastStats); err != nil {
 					return nil, lastStats, err
 				}
@@ -491,15 +957,44 @@ func (c *Coordinator) waitForQuery(ctx context.Context, queryID coordt.QueryID,
 				return nil, lastStats, ctx.Err()
 			}
 
+			if err := ctx.Err(); err != nil {
+				// query was stopped because the caller went away or the operation was cancelled
+				return nil, lastStats, err
+			}
+
 			// query is done
-			lastStats.Exhausted = true
-			c.cfg.Logger.Debug("query ran to exhaustion", "query_id", queryID, slog.Duration("elapsed", wev.Event.Stats.End.Sub(wev.Event.Stats.Start)), slog.Int("requests", wev.Event.Stats.Requests), slog.Int("failures", wev.Event.Stats.Failure))
+			lastStats = coordt.QueryStats{
+				Start:       wev.Event.Stats.Start,
+				End:         wev.Event.Stats.End,
+				Requests:    wev.Event.Stats.Requests,
+				Success:     wev.Event.Stats.Success,
+				Failure:     wev.Event.Stats.Failure,
+				Escalations: wev.Event.Stats.Escalations,
+				Exhausted:   true,
+			}
+			c.cfg.Logger.Debug("query ran to exhaustion", "query_id", queryID, slog.Duration("elapsed", wev.Event.Stats.End.Sub(wev.Event.Stats.Start)), slog.Int("requests", wev.Event.Stats.Requests), slog.Int("failures", wev.Event.Stats.Failure), slog.Int("escalations", wev.Event.Stats.Escalations))
 			return wev.Event.ClosestNodes, lastStats, nil
 
 		}
 	}
 }
 
+// progressStats returns the statistics of the query with the given id after it made progress. The statistics
+// recorded for the operation are preferred over those of the progress event, which may be incomplete.
+func (c *Coordinator) progressStats(queryID coordt.QueryID, stats query.QueryStats) coordt.QueryStats {
+	c.ops.escalated(queryID, stats.Escalations)
+	if op, found := c.ops.get(queryID); found {
+		return op.Stats
+	}
+	return coordt.QueryStats{
+		Start:       stats.Start,
+		Requests:    stats.Requests,
+		Success:     stats.Success,
+		Failure:     stats.Failure,
+		Escalations: stats.Escalations,
+	}
+}
+
 func (c *Coordinator) waitForBroadcast(ctx context.Context, waiter *BroadcastWaiter) ([]kadt.PeerID, map[string]struct {
 	Node kadt.PeerID
 	Err  error
@@ -575,6 +1070,27 @@ func (c *Coordinator) NotifyNonConnectivity(ctx context.Context, id kadt.PeerID)
 	})
 }
 
+// Operations returns the queries and broadcasts that were started by callers of the coordinator and are still
+// running, ordered by the time they were started.
+func (c *Coordinator) Operations() []Operation {
+	return c.ops.list()
+}
+
+// Operation returns the query or broadcast with the given id and whether it is still running.
+func (c *Coordinator) Operation(id coordt.QueryID) (Operation, bool) {
+	return c.ops.get(id)
+}
+
+// CancelOperation stops the query or broadcast with the given id. All callers waiting for it return with a
+// context cancellation error. It returns false if no operation with the id is running.
+func (c *Coordinator) CancelOperation(ctx context.Context, id coordt.QueryID) bool {
+	ctx, span := c.tele.Tracer.Start(ctx, "Coordinator.CancelOperation")
+	defer span.End()
+
+	c.cfg.Logger.Debug("cancelling operation", "query_id", id)
+	return c.ops.cancel(ctx, id)
+}
+
 func (c *Coordinator) newOperationID() coordt.QueryID {
 	next := c.lastQueryID.Add(1)
 	return coordt.QueryID(fmt.Sprintf("%016x", next))
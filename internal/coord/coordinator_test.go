@@ -4,11 +4,13 @@ import (
 	"context"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/coord/query"
 	"github.com/plprobelab/zikade/internal/kadtest"
 	"github.com/plprobelab/zikade/internal/nettest"
 	"github.com/plprobelab/zikade/kadt"
@@ -49,6 +51,77 @@ func TestConfigValidate(t *testing.T) {
 	})
 }
 
+func TestBroadcastConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultBroadcastConfig()
+
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultBroadcastConfig()
+
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("meter is not nil", func(t *testing.T) {
+		cfg := DefaultBroadcastConfig()
+
+		cfg.Meter = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("concurrency positive", func(t *testing.T) {
+		cfg := DefaultBroadcastConfig()
+
+		cfg.Concurrency = 0
+		require.Error(t, cfg.Validate())
+		cfg.Concurrency = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("timeout positive", func(t *testing.T) {
+		cfg := DefaultBroadcastConfig()
+
+		cfg.Timeout = 0
+		require.Error(t, cfg.Validate())
+		cfg.Timeout = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("request concurrency positive", func(t *testing.T) {
+		cfg := DefaultBroadcastConfig()
+
+		cfg.RequestConcurrency = 0
+		require.Error(t, cfg.Validate())
+		cfg.RequestConcurrency = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("request timeout positive", func(t *testing.T) {
+		cfg := DefaultBroadcastConfig()
+
+		cfg.RequestTimeout = 0
+		require.Error(t, cfg.Validate())
+		cfg.RequestTimeout = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestNewCoordinatorInvalidBroadcastConfig(t *testing.T) {
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(1, clk)
+	require.NoError(t, err)
+
+	ccfg := DefaultCoordinatorConfig()
+	ccfg.Clock = clk
+	ccfg.Broadcast.Concurrency = 0
+
+	_, err = NewCoordinator(nodes[0].NodeID, nodes[0].Router, nodes[0].RoutingTable, ccfg)
+	require.Error(t, err)
+}
+
 func TestExhaustiveQuery(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -86,6 +159,158 @@ func TestExhaustiveQuery(t *testing.T) {
 	require.Contains(t, visited, nodes[3].NodeID.String())
 }
 
+func TestQueryTermination(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+	ccfg := DefaultCoordinatorConfig()
+	ccfg.Clock = clk
+
+	self := nodes[0].NodeID
+	c, err := NewCoordinator(self, nodes[0].Router, nodes[0].RoutingTable, ccfg)
+	require.NoError(t, err)
+
+	target := nodes[3].NodeID.Key()
+
+	visited := make(map[string]int)
+	qfn := func(ctx context.Context, id kadt.PeerID, msg *pb.Message, stats coordt.QueryStats) error {
+		visited[id.String()]++
+		return nil
+	}
+
+	// an invalid policy is rejected
+	_, _, err = c.QueryClosest(ctx, target, qfn, 20, WithTermination(query.RequestBudgetPolicy{}))
+	require.Error(t, err)
+
+	// the query stops after a single request instead of visiting all nodes on the way to D
+	_, _, err = c.QueryClosest(ctx, target, qfn, 20, WithTermination(query.RequestBudgetPolicy{Requests: 1}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{nodes[1].NodeID.String(): 1}, visited)
+}
+
+func TestQueryOptions(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+	ccfg := DefaultCoordinatorConfig()
+	ccfg.Clock = clk
+
+	self := nodes[0].NodeID
+	c, err := NewCoordinator(self, nodes[0].Router, nodes[0].RoutingTable, ccfg)
+	require.NoError(t, err)
+
+	target := nodes[3].NodeID.Key()
+
+	var progress []coordt.QueryStats
+	qfn := func(ctx context.Context, id kadt.PeerID, msg *pb.Message, stats coordt.QueryStats) error {
+		progress = append(progress, stats)
+		return nil
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := []QueryOption{
+			WithNumResults(-1),
+			WithRequestConcurrency(-1),
+			WithRequestTimeout(-time.Second),
+			WithTimeout(-time.Second),
+			WithSeedCount(-1),
+			WithBroadcastStrategy(BroadcastStrategy(-1)),
+		}
+		for _, opt := range invalid {
+			_, _, err := c.QueryClosest(ctx, target, qfn, 20, opt)
+			require.Error(t, err)
+
+			_, err = c.BroadcastRecord(ctx, &pb.Message{Type: pb.Message_PUT_VALUE, Key: []byte("key")}, opt)
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		progress = nil
+		_, stats, err := c.QueryClosest(ctx, target, qfn, 20, WithRequestConcurrency(1), WithRequestTimeout(time.Second), WithSeedCount(1))
+		require.NoError(t, err)
+
+		// every node on the way to D was contacted once
+		require.True(t, stats.Exhausted)
+		require.Equal(t, 3, stats.Requests)
+		require.Equal(t, 3, stats.Success)
+		require.Equal(t, 0, stats.Failure)
+
+		// the statistics passed to the query function include the response that is being handled
+		require.Len(t, progress, 3)
+		for i, st := range progress {
+			require.GreaterOrEqual(t, st.Success, 1)
+			if i > 0 {
+				require.GreaterOrEqual(t, st.Success, progress[i-1].Success)
+			}
+		}
+	})
+
+	t.Run("escalations", func(t *testing.T) {
+		progress = nil
+
+		// B is the closest node to its own key so its response doesn't bring the query closer and the query escalates
+		target := nodes[1].NodeID.Key()
+		_, stats, err := c.QueryClosest(ctx, target, qfn, 20, WithRequestConcurrency(1), WithSeedCount(1))
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.Escalations)
+
+		// the statistics passed to the query function never count more escalations than the query made
+		require.NotEmpty(t, progress)
+		for _, st := range progress {
+			require.LessOrEqual(t, st.Escalations, stats.Escalations)
+		}
+	})
+
+	t.Run("broadcast", func(t *testing.T) {
+		msg := &pb.Message{Type: pb.Message_PUT_VALUE, Key: []byte("key")}
+
+		// the static strategy only stores the record with the seeds from the routing table
+		stats, err := c.BroadcastRecord(ctx, msg, WithBroadcastStrategy(BroadcastStatic), WithSeedCount(1))
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.Requests)
+		require.Equal(t, 1, stats.Success)
+
+		// the default strategy first looks up the closest nodes
+		stats, err = c.BroadcastRecord(ctx, msg, WithNumResults(2))
+		require.NoError(t, err)
+		require.Greater(t, stats.Requests, 2)
+		require.False(t, stats.End.IsZero())
+	})
+}
+
+func TestBroadcastRecordSelectsStaticSeeds(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+
+	var selected []int
+	ccfg := DefaultCoordinatorConfig()
+	ccfg.Clock = clk
+	ccfg.Broadcast.Select = func(nodes []kadt.PeerID, n int) []kadt.PeerID {
+		selected = append(selected, n)
+		return nodes[:n]
+	}
+
+	self := nodes[0].NodeID
+	c, err := NewCoordinator(self, nodes[0].Router, nodes[0].RoutingTable, ccfg)
+	require.NoError(t, err)
+
+	// the static strategy stores the record with the seeds that Select chose
+	msg := &pb.Message{Type: pb.Message_PUT_VALUE, Key: []byte("key")}
+	stats, err := c.BroadcastRecord(ctx, msg, WithBroadcastStrategy(BroadcastStatic), WithSeedCount(1))
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, selected)
+	require.Equal(t, 1, stats.Requests)
+	require.Equal(t, 1, stats.Success)
+}
+
 func TestRoutingUpdatedEventEmittedForCloserNodes(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -194,6 +419,37 @@ func TestBootstrap(t *testing.T) {
 	require.True(t, d.IsRoutable(ctx, nodes[3].NodeID))
 }
 
+func TestRoutingObserversReceiveNotifications(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+
+	ccfg := DefaultCoordinatorConfig()
+	ccfg.Clock = clk
+
+	d, err := NewCoordinator(nodes[0].NodeID, nodes[0].Router, nodes[0].RoutingTable, ccfg)
+	require.NoError(t, err)
+
+	// the observers keep receiving notifications when the routing notifier is replaced
+	o1 := NewBufferedRoutingNotifier()
+	o2 := NewBufferedRoutingNotifier()
+	d.AddRoutingObserver(o1)
+	d.SetRoutingNotifier(NewBufferedRoutingNotifier())
+	d.AddRoutingObserver(o2)
+	rn := NewBufferedRoutingNotifier()
+	d.SetRoutingNotifier(rn)
+
+	err = d.Bootstrap(ctx, []kadt.PeerID{nodes[1].NodeID})
+	require.NoError(t, err)
+
+	for _, n := range []*BufferedRoutingNotifier{rn, o1, o2} {
+		_, err = n.Expect(ctx, &EventBootstrapFinished{})
+		require.NoError(t, err)
+	}
+}
+
 func TestIncludeNode(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -43,12 +43,13 @@ var (
 type QueryFunc func(ctx context.Context, id kadt.PeerID, resp *pb.Message, stats QueryStats) error
 
 type QueryStats struct {
-	Start     time.Time // Start is the time the query began executing.
-	End       time.Time // End is the time the query stopped executing.
-	Requests  int       // Requests is a count of the number of requests made by the query.
-	Success   int       // Success is a count of the number of nodes the query succesfully contacted.
-	Failure   int       // Failure is a count of the number of nodes the query received an error response from.
-	Exhausted bool      // Exhausted is true if the query ended after visiting every node it could.
+	Start       time.Time // Start is the time the query began executing.
+	End         time.Time // End is the time the query stopped executing.
+	Requests    int       // Requests is a count of the number of requests made by the query.
+	Success     int       // Success is a count of the number of nodes the query succesfully contacted.
+	Failure     int       // Failure is a count of the number of nodes the query received an error response from.
+	Escalations int       // Escalations is a count of the number of times the query stalled and contacted all remaining closest nodes at once.
+	Exhausted   bool      // Exhausted is true if the query ended after visiting every node it could.
 }
 
 var (
@@ -3,6 +3,7 @@ package cplutil
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	mh "github.com/multiformats/go-multihash"
@@ -12,20 +13,126 @@ import (
 
 //go:generate go run ./gen.go
 
+// DefaultSearchBudget is the number of candidate peer IDs a [PeerIDGenerator] hashes at most per call when searching
+// for a peer ID whose key has a common prefix length greater than 15 with the supplied key. A call with this budget
+// takes around a millisecond so that a search can be spread across the advances of a state machine without holding
+// up other work.
+const DefaultSearchBudget = 1 << 12
+
+// MaxSearchCpl is the largest common prefix length that peer IDs should be searched for. Finding a peer ID for it
+// takes 2^21 candidates on average, around half a second of hashing spread over about 500 calls with the
+// [DefaultSearchBudget]. Every further bit doubles that.
+const MaxSearchCpl = 20
+
+// MaxCpl is the largest common prefix length that two distinct [kadt.Key] values can have.
+const MaxCpl = 255
+
+// ErrSearchIncomplete is returned by [PeerIDGenerator.GenRandPeerID] when no peer ID was found within the budget
+// of the generator. The search continues where it left off when the generator is called again with the same key.
+var ErrSearchIncomplete = errors.New("peer ID search incomplete")
+
+// maxCachedPerCpl is the number of peer IDs found as a by-product of a search that a [PeerIDGenerator] keeps per
+// common prefix length.
+const maxCachedPerCpl = 4
+
 // GenRandPeerID generates a random [kadt.PeerID] whose key has a common prefix length of exactly cpl with the supplied key.
+// Peer IDs for a cpl of 15 or less are taken from a precomputed table. Larger cpls up to [MaxSearchCpl] are
+// searched for until a peer ID is found, which blocks the caller for as long as the search takes. Use a
+// [PeerIDGenerator] to spread the search across several calls.
 // Ported from go-libp2p-kbucket
 func GenRandPeerID(k kadt.Key, cpl int) (kadt.PeerID, error) {
-	if cpl > 15 {
-		return "", fmt.Errorf("cannot generate peer ID for Cpl greater than 15")
+	if cpl > MaxSearchCpl {
+		return "", fmt.Errorf("cannot generate peer ID for Cpl %d, must not be greater than %d", cpl, MaxSearchCpl)
+	}
+
+	g := NewPeerIDGenerator(DefaultSearchBudget)
+	for {
+		id, err := g.GenRandPeerID(k, cpl)
+		if !errors.Is(err, ErrSearchIncomplete) {
+			return id, err
+		}
+	}
+}
+
+// A PeerIDGenerator generates random peer IDs whose keys have a given common prefix length with a key.
+//
+// A peer ID is hashed to derive its key so there is no way to construct a peer ID for a common prefix length
+// beyond the 16 bits covered by the precomputed table other than searching for one. Finding a peer ID whose key
+// has a common prefix length of exactly cpl takes 2^(cpl+1) candidates on average. The generator therefore limits
+// the number of candidates it tries per call and resumes the search where it left off on the next call for the
+// same key. Peer IDs that are found along the way for other common prefix lengths are kept for later calls.
+// Searches for common prefix lengths greater than [MaxSearchCpl] are unlikely to finish in reasonable time.
+// A PeerIDGenerator is not safe for concurrent use.
+type PeerIDGenerator struct {
+	budget int
+
+	key   kadt.Key              // the key that is being searched for
+	next  [32 + 2]byte          // the next candidate peer ID
+	found map[int][]kadt.PeerID // peer IDs found for key by common prefix length
+}
+
+// NewPeerIDGenerator returns a new generator that tries at most budget candidates per call when searching for a
+// peer ID.
+func NewPeerIDGenerator(budget int) *PeerIDGenerator {
+	return &PeerIDGenerator{
+		budget: budget,
+	}
+}
+
+// GenRandPeerID generates a random [kadt.PeerID] whose key has a common prefix length of exactly cpl with the
+// supplied key. It returns an error if cpl is out of range or an error wrapping [ErrSearchIncomplete] if no peer
+// ID could be found within the generator's budget. In the latter case a later call may succeed.
+func (g *PeerIDGenerator) GenRandPeerID(k kadt.Key, cpl int) (kadt.PeerID, error) {
+	if cpl < 0 || cpl >= k.BitLen() {
+		return "", fmt.Errorf("cannot generate peer ID for Cpl %d, must be between 0 and %d", cpl, k.BitLen()-1)
+	}
+
+	if cpl <= 15 {
+		targetPrefix := prefix(k, cpl)
+
+		// Convert to a known peer ID.
+		key := keyPrefixMap[targetPrefix]
+		id := [32 + 2]byte{mh.SHA2_256, 32}
+		binary.BigEndian.PutUint32(id[2:], key)
+		return kadt.PeerID(string(id[:])), nil
+	}
+
+	if g.found == nil || g.key.Compare(k) != 0 {
+		g.key = k
+		g.found = make(map[int][]kadt.PeerID)
+		g.next = [32 + 2]byte{mh.SHA2_256, 32}
+		_, _ = rand.Read(g.next[2:])
 	}
 
-	targetPrefix := prefix(k, cpl)
+	if ids := g.found[cpl]; len(ids) > 0 {
+		g.found[cpl] = ids[1:]
+		return ids[0], nil
+	}
+
+	for i := 0; i < g.budget; i++ {
+		id := kadt.PeerID(string(g.next[:]))
+		g.advance()
+
+		idCpl := k.CommonPrefixLength(id.Key())
+		if idCpl == cpl {
+			return id, nil
+		}
+		if idCpl > 15 && len(g.found[idCpl]) < maxCachedPerCpl {
+			g.found[idCpl] = append(g.found[idCpl], id)
+		}
+	}
 
-	// Convert to a known peer ID.
-	key := keyPrefixMap[targetPrefix]
-	id := [32 + 2]byte{mh.SHA2_256, 32}
-	binary.BigEndian.PutUint32(id[2:], key)
-	return kadt.PeerID(string(id[:])), nil
+	return "", fmt.Errorf("no peer ID found for Cpl %d within %d candidates: %w", cpl, g.budget, ErrSearchIncomplete)
+}
+
+// advance moves on to the next candidate peer ID by incrementing the digest of the multihash.
+func (g *PeerIDGenerator) advance() {
+	for i := len(g.next) - 1; i >= 2; i-- {
+		g.next[i]++
+		if g.next[i] != 0 {
+			return
+		}
+	}
 }
 
 type keybit interface {
@@ -58,3 +58,69 @@ func TestGenRandPeerID(t *testing.T) {
 		}
 	}
 }
+
+func TestGenRandPeerIDLargeCpl(t *testing.T) {
+	var buf [32]byte
+	_, _ = rand.Read(buf[:])
+	k := kadt.NewKey(buf[:])
+
+	// the search is repeated with the default budget until a peer ID is found
+	for cpl := 16; cpl < 19; cpl++ {
+		id, err := GenRandPeerID(k, cpl)
+		require.NoError(t, err)
+
+		assert.Equal(t, cpl, k.CommonPrefixLength(id.Key()))
+	}
+}
+
+func TestGenRandPeerIDOutOfRange(t *testing.T) {
+	k := kadt.NewKey([]byte("key"))
+
+	_, err := GenRandPeerID(k, -1)
+	require.Error(t, err)
+
+	_, err = GenRandPeerID(k, 256)
+	require.Error(t, err)
+
+	// larger cpls can't be found in reasonable time
+	_, err = GenRandPeerID(k, MaxSearchCpl+1)
+	require.Error(t, err)
+}
+
+func TestPeerIDGenerator(t *testing.T) {
+	var buf [32]byte
+	_, _ = rand.Read(buf[:])
+	k := kadt.NewKey(buf[:])
+
+	t.Run("budget exhausted", func(t *testing.T) {
+		g := NewPeerIDGenerator(1)
+		_, err := g.GenRandPeerID(k, 255)
+		require.ErrorIs(t, err, ErrSearchIncomplete)
+	})
+
+	t.Run("resumes search", func(t *testing.T) {
+		// a single call is unlikely to find a peer ID but the search continues with every call
+		g := NewPeerIDGenerator(1 << 10)
+
+		const cpl = 16
+		var id kadt.PeerID
+		var err error
+		for i := 0; i < 1<<10; i++ {
+			id, err = g.GenRandPeerID(k, cpl)
+			if err == nil {
+				break
+			}
+		}
+		require.NoError(t, err)
+		assert.Equal(t, cpl, k.CommonPrefixLength(id.Key()))
+	})
+
+	t.Run("small cpl from table", func(t *testing.T) {
+		g := NewPeerIDGenerator(0)
+		for cpl := 0; cpl <= 15; cpl++ {
+			id, err := g.GenRandPeerID(k, cpl)
+			require.NoError(t, err)
+			assert.Equal(t, cpl, k.CommonPrefixLength(id.Key()))
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package coord
 
 import (
+	"time"
+
 	"github.com/plprobelab/zikade/internal/coord/coordt"
 	"github.com/plprobelab/zikade/internal/coord/query"
 	"github.com/plprobelab/zikade/kadt"
@@ -91,7 +93,12 @@ type EventStartMessageQuery struct {
 	Message           *pb.Message
 	KnownClosestNodes []kadt.PeerID
 	Notify            QueryMonitor[*EventQueryFinished]
-	NumResults        int // the minimum number of nodes to successfully contact before considering iteration complete
+	NumResults        int                     // the minimum number of nodes to successfully contact before considering iteration complete
+	DisjointPaths     int                     // the number of disjoint paths the query should use, zero uses the default
+	Priority          query.Priority          // the priority class of the query
+	Termination       query.TerminationPolicy // decides whether the query should finish early, nil uses the default
+	Concurrency       int                     // the maximum number of concurrent requests, zero uses the default
+	RequestTimeout    time.Duration           // the timeout for contacting a single node, zero uses the default
 }
 
 func (*EventStartMessageQuery) behaviourEvent() {}
@@ -102,7 +109,12 @@ type EventStartFindCloserQuery struct {
 	Target            kadt.Key
 	KnownClosestNodes []kadt.PeerID
 	Notify            QueryMonitor[*EventQueryFinished]
-	NumResults        int // the minimum number of nodes to successfully contact before considering iteration complete
+	NumResults        int                     // the minimum number of nodes to successfully contact before considering iteration complete
+	DisjointPaths     int                     // the number of disjoint paths the query should use, zero uses the default
+	Priority          query.Priority          // the priority class of the query
+	Termination       query.TerminationPolicy // decides whether the query should finish early, nil uses the default
+	Concurrency       int                     // the maximum number of concurrent requests, zero uses the default
+	RequestTimeout    time.Duration           // the timeout for contacting a single node, zero uses the default
 }
 
 func (*EventStartFindCloserQuery) behaviourEvent() {}
@@ -115,6 +127,14 @@ type EventStopQuery struct {
 func (*EventStopQuery) behaviourEvent() {}
 func (*EventStopQuery) queryCommand()   {}
 
+// EventNotifyLoad notifies the query behaviour of the utilization of the coordinator's event loop.
+type EventNotifyLoad struct {
+	Utilization float64 // the fraction of time the event loop was busy in the last measurement interval
+}
+
+func (*EventNotifyLoad) behaviourEvent() {}
+func (*EventNotifyLoad) queryCommand()   {}
+
 // EventAddNode notifies the routing behaviour of a potential new peer.
 type EventAddNode struct {
 	NodeID kadt.PeerID
@@ -130,6 +150,7 @@ type EventGetCloserNodesSuccess struct {
 	To          kadt.PeerID // To is the peer that the GetCloserNodes request was sent to.
 	Target      kadt.Key
 	CloserNodes []kadt.PeerID
+	RTT         time.Duration // RTT is the time it took to receive the response.
 }
 
 func (*EventGetCloserNodesSuccess) behaviourEvent()      {}
@@ -155,6 +176,7 @@ type EventSendMessageSuccess struct {
 	To          kadt.PeerID // To is the peer that the SendMessage request was sent to.
 	Response    *pb.Message
 	CloserNodes []kadt.PeerID
+	RTT         time.Duration // RTT is the time it took to receive the response.
 }
 
 func (*EventSendMessageSuccess) behaviourEvent()      {}
@@ -240,6 +262,15 @@ type EventNotifyNonConnectivity struct {
 func (*EventNotifyNonConnectivity) behaviourEvent() {}
 func (*EventNotifyNonConnectivity) routingCommand() {}
 
+// EventNotifyResponse notifies a behaviour that a peer responded to a request sent by a query.
+type EventNotifyResponse struct {
+	NodeID kadt.PeerID
+	RTT    time.Duration // the time it took to receive the response
+}
+
+func (*EventNotifyResponse) behaviourEvent() {}
+func (*EventNotifyResponse) routingCommand() {}
+
 // EventRoutingPoll notifies a routing behaviour that it may proceed with any pending work.
 type EventRoutingPoll struct{}
 
@@ -0,0 +1,223 @@
+package kbucket
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/plprobelab/go-libdht/kad/key"
+	"github.com/plprobelab/go-libdht/kad/triert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord/routing"
+	"github.com/plprobelab/zikade/kadt"
+)
+
+// lookupWorkload describes a simulated network in which the lookup quality of routing tables is compared.
+type lookupWorkload struct {
+	name    string
+	nodes   int     // the number of nodes in the network
+	known   int     // the number of other nodes each node is offered for its routing table
+	offline float64 // the fraction of nodes that don't respond during lookups
+	lookups int     // the number of lookups performed
+}
+
+// lookupResult holds the averaged outcome of the lookups of a workload.
+type lookupResult struct {
+	size     float64 // the mean routing table size
+	recall   float64 // the mean fraction of the closest online nodes that were found
+	requests float64 // the mean number of requests per lookup
+}
+
+// lookupTable is a routing table whose size can be measured.
+type lookupTable interface {
+	routing.RoutingTableCpl[kadt.Key, kadt.PeerID]
+	Size() int
+}
+
+const (
+	lookupBucketSize  = 20
+	lookupConcurrency = 3
+)
+
+// TestLookupQuality compares the lookup quality of the k-bucket table with that of the trie routing table under
+// the same simulated workloads. Both tables are offered the same nodes in the same order.
+func TestLookupQuality(t *testing.T) {
+	workloads := []lookupWorkload{
+		{name: "static", nodes: 500, known: 100, offline: 0, lookups: 200},
+		{name: "sparse", nodes: 500, known: 30, offline: 0, lookups: 200},
+		{name: "churn", nodes: 500, known: 100, offline: 0.2, lookups: 200},
+	}
+
+	for _, w := range workloads {
+		t.Run(w.name, func(t *testing.T) {
+			trie := simulateLookups(t, w, func(self kadt.PeerID) lookupTable {
+				rt, err := triert.New[kadt.Key, kadt.PeerID](self, nil)
+				require.NoError(t, err)
+				return rt
+			})
+
+			kb := simulateLookups(t, w, func(self kadt.PeerID) lookupTable {
+				rt, err := New[kadt.Key, kadt.PeerID](self, &Config{BucketSize: lookupBucketSize})
+				require.NoError(t, err)
+				return rt
+			})
+
+			t.Logf("trie:    table size %6.1f, recall %.3f, requests %5.1f", trie.size, trie.recall, trie.requests)
+			t.Logf("kbucket: table size %6.1f, recall %.3f, requests %5.1f", kb.size, kb.recall, kb.requests)
+
+			// the k-bucket table holds fewer nodes but its lookups should find nearly as many of the closest nodes
+			require.LessOrEqual(t, kb.size, trie.size)
+			require.GreaterOrEqual(t, kb.recall, trie.recall-0.05)
+		})
+	}
+}
+
+func simulateLookups(t *testing.T, w lookupWorkload, newRT func(kadt.PeerID) lookupTable) lookupResult {
+	t.Helper()
+
+	// the same seed yields the same network and lookups for every routing table
+	rng := rand.New(rand.NewSource(1))
+
+	ids := make([]kadt.PeerID, w.nodes)
+	for i := range ids {
+		b := make([]byte, 32)
+		rng.Read(b)
+		ids[i] = kadt.PeerID(peer.ID(b))
+	}
+
+	online := make(map[kadt.PeerID]bool, w.nodes)
+	for _, id := range ids {
+		online[id] = rng.Float64() >= w.offline
+	}
+
+	tables := make(map[kadt.PeerID]lookupTable, w.nodes)
+	var res lookupResult
+	for _, id := range ids {
+		rt := newRT(id)
+		for _, i := range rng.Perm(w.nodes)[:w.known] {
+			rt.AddNode(ids[i])
+		}
+		tables[id] = rt
+		res.size += float64(rt.Size())
+	}
+	res.size /= float64(w.nodes)
+
+	for l := 0; l < w.lookups; l++ {
+		var start kadt.PeerID
+		for {
+			start = ids[rng.Intn(w.nodes)]
+			if online[start] {
+				break
+			}
+		}
+
+		b := make([]byte, 32)
+		rng.Read(b)
+		target := kadt.NewKey(b)
+
+		found, requests := simulateLookup(target, tables[start].NearestNodes(target, lookupBucketSize), tables, online)
+
+		// the closest online nodes in the whole network
+		var want []kadt.PeerID
+		for _, id := range ids {
+			if online[id] {
+				want = append(want, id)
+			}
+		}
+		sortByDistance(target, want)
+		want = want[:lookupBucketSize]
+
+		hits := 0
+		for _, n := range want {
+			if found[n] {
+				hits++
+			}
+		}
+		res.recall += float64(hits) / float64(len(want))
+		res.requests += float64(requests)
+	}
+	res.recall /= float64(w.lookups)
+	res.requests /= float64(w.lookups)
+
+	return res
+}
+
+// simulateLookup performs an iterative lookup for target starting with the seed nodes. It returns the closest
+// nodes that responded and the number of requests sent.
+func simulateLookup(target kadt.Key, seeds []kadt.PeerID, tables map[kadt.PeerID]lookupTable, online map[kadt.PeerID]bool) (map[kadt.PeerID]bool, int) {
+	candidates := append([]kadt.PeerID{}, seeds...)
+	seen := make(map[kadt.PeerID]bool)
+	for _, s := range seeds {
+		seen[s] = true
+	}
+
+	queried := make(map[kadt.PeerID]bool)
+	var responded []kadt.PeerID
+	requests := 0
+
+	for {
+		sortByDistance(target, candidates)
+
+		// the lookup is finished when the closest candidates have all been queried
+		var next []kadt.PeerID
+		for i, c := range candidates {
+			if i >= lookupBucketSize || len(next) >= lookupConcurrency {
+				break
+			}
+			if !queried[c] {
+				next = append(next, c)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+
+		for _, n := range next {
+			queried[n] = true
+			requests++
+
+			// nodes that don't respond are dropped from the candidates
+			if !online[n] {
+				for i, c := range candidates {
+					if key.Equal(c.Key(), n.Key()) {
+						candidates = append(candidates[:i], candidates[i+1:]...)
+						break
+					}
+				}
+				continue
+			}
+
+			responded = append(responded, n)
+			for _, c := range tables[n].NearestNodes(target, lookupBucketSize) {
+				if !seen[c] {
+					seen[c] = true
+					candidates = append(candidates, c)
+				}
+			}
+		}
+	}
+
+	sortByDistance(target, responded)
+	found := make(map[kadt.PeerID]bool)
+	for i, n := range responded {
+		if i >= lookupBucketSize {
+			break
+		}
+		found[n] = true
+	}
+
+	return found, requests
+}
+
+// sortByDistance sorts nodes by their distance to target, closest first.
+func sortByDistance(target kadt.Key, nodes []kadt.PeerID) {
+	dists := make(map[kadt.PeerID]kadt.Key, len(nodes))
+	for _, n := range nodes {
+		dists[n] = target.Xor(n.Key())
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return dists[nodes[i]].Compare(dists[nodes[j]]) < 0
+	})
+}
@@ -0,0 +1,271 @@
+// Package kbucket implements a Kademlia routing table made of k-buckets as described in the original Kademlia paper.
+package kbucket
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/plprobelab/go-libdht/kad"
+	"github.com/plprobelab/go-libdht/kad/key"
+)
+
+// Config holds configuration options for a [Table].
+type Config struct {
+	// BucketSize is the maximum number of nodes a bucket holds, the k in k-bucket.
+	BucketSize int
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.BucketSize < 1 {
+		return fmt.Errorf("bucket size must be greater than zero")
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a [Table].
+func DefaultConfig() *Config {
+	return &Config{
+		BucketSize: 20, // MAGIC
+	}
+}
+
+// Table is a routing table made of k-buckets. Bucket i holds the nodes whose keys share a common prefix of length
+// i with the table's key, except for the last bucket, which holds all nodes whose keys share a longer prefix.
+// The table starts out with a single bucket. When the last bucket is full, it is split in two so that the
+// bucket covering the table's own key is the only one that is ever split.
+//
+// The nodes of a bucket are ordered from least recently seen to most recently seen. Nodes are seen when they are
+// added to the table, when [Table.AddNode] is called for a node that is already present and when [Table.Touch] is
+// called. A node is never evicted to make room for a new node. Instead, the caller is expected to check the
+// connectivity of the bucket's least recently seen node, see [Table.LeastRecentlySeen], and to remove it only if
+// it fails to respond. Long-lived nodes are therefore preferred over new ones.
+//
+// Table is safe for concurrent use.
+type Table[K kad.Key[K], N kad.NodeID[K]] struct {
+	self K
+	cfg  Config
+
+	mu      sync.RWMutex
+	buckets [][]N        // ordered by cpl, the nodes of each bucket are ordered least recently seen first
+	nodes   map[string]N // all nodes in the table by key
+}
+
+// New returns a new [Table] for the given local node. If cfg is nil, the default config is used.
+func New[K kad.Key[K], N kad.NodeID[K]](self N, cfg *Config) (*Table[K, N], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return &Table[K, N]{
+		self:    self.Key(),
+		cfg:     *cfg,
+		buckets: make([][]N, 1),
+		nodes:   make(map[string]N),
+	}, nil
+}
+
+// Self returns the key of the local node.
+func (t *Table[K, N]) Self() K {
+	return t.self
+}
+
+// AddNode adds a node to the table. It returns true if the node was added and false if the node was already
+// present or its bucket is full. A node that was already present is marked as the most recently seen node of its
+// bucket.
+func (t *Table[K, N]) AddNode(n N) bool {
+	kk := n.Key()
+	if key.Equal(kk, t.self) {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.nodes[key.HexString(kk)]; exists {
+		t.touch(kk)
+		return false
+	}
+
+	cpl := t.self.CommonPrefixLength(kk)
+	for {
+		i := t.bucketIndex(cpl)
+		if len(t.buckets[i]) < t.cfg.BucketSize {
+			t.buckets[i] = append(t.buckets[i], n)
+			t.nodes[key.HexString(kk)] = n
+			return true
+		}
+
+		// only the last bucket covers the table's own key and may be split
+		if i != len(t.buckets)-1 || len(t.buckets) >= t.self.BitLen() {
+			return false
+		}
+		t.split()
+	}
+}
+
+// RemoveKey removes the node with the given key from the table. It returns true if the node was present.
+func (t *Table[K, N]) RemoveKey(kk K) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.nodes[key.HexString(kk)]; !exists {
+		return false
+	}
+	delete(t.nodes, key.HexString(kk))
+
+	i := t.bucketIndex(t.self.CommonPrefixLength(kk))
+	for j, n := range t.buckets[i] {
+		if key.Equal(n.Key(), kk) {
+			t.buckets[i] = append(t.buckets[i][:j], t.buckets[i][j+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// NearestNodes returns at most n nodes of the table ordered by distance to the given key, closest first.
+func (t *Table[K, N]) NearestNodes(kk K, n int) []N {
+	type candidate struct {
+		node N
+		dist K
+	}
+
+	t.mu.RLock()
+	candidates := make([]candidate, 0, len(t.nodes))
+	for _, node := range t.nodes {
+		candidates = append(candidates, candidate{node: node, dist: kk.Xor(node.Key())})
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].dist.Compare(candidates[j].dist) < 0
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	nodes := make([]N, len(candidates))
+	for i := range candidates {
+		nodes[i] = candidates[i].node
+	}
+	return nodes
+}
+
+// GetNode returns the node with the given key and true, or false if the node is not present.
+func (t *Table[K, N]) GetNode(kk K) (N, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n, found := t.nodes[key.HexString(kk)]
+	return n, found
+}
+
+// Size returns the number of nodes in the table.
+func (t *Table[K, N]) Size() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.nodes)
+}
+
+// Cpl returns the longest common prefix length the supplied key shares with the table's key.
+func (t *Table[K, N]) Cpl(kk K) int {
+	return t.self.CommonPrefixLength(kk)
+}
+
+// CplSize returns the number of nodes in the table whose longest common prefix with the table's key is of length cpl.
+func (t *Table[K, N]) CplSize(cpl int) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	i := t.bucketIndex(cpl)
+	if i < len(t.buckets)-1 {
+		return len(t.buckets[i])
+	}
+
+	// the last bucket also holds nodes with longer common prefixes
+	count := 0
+	for _, n := range t.buckets[i] {
+		if t.self.CommonPrefixLength(n.Key()) == cpl {
+			count++
+		}
+	}
+	return count
+}
+
+// BucketCount returns the number of buckets in the table.
+func (t *Table[K, N]) BucketCount() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.buckets)
+}
+
+// Touch marks the node with the given key as the most recently seen node of its bucket. It returns false if the
+// node is not present.
+func (t *Table[K, N]) Touch(kk K) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.touch(kk)
+}
+
+// LeastRecentlySeen returns the least recently seen node of the bucket that a node with the given key belongs to
+// and true, or false if that bucket is empty.
+func (t *Table[K, N]) LeastRecentlySeen(kk K) (N, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	b := t.buckets[t.bucketIndex(t.self.CommonPrefixLength(kk))]
+	if len(b) == 0 {
+		var v N
+		return v, false
+	}
+	return b[0], true
+}
+
+// touch moves the node with the given key to the end of its bucket. It must only be called while t.mu is locked.
+func (t *Table[K, N]) touch(kk K) bool {
+	if _, exists := t.nodes[key.HexString(kk)]; !exists {
+		return false
+	}
+
+	i := t.bucketIndex(t.self.CommonPrefixLength(kk))
+	b := t.buckets[i]
+	for j, n := range b {
+		if key.Equal(n.Key(), kk) {
+			copy(b[j:], b[j+1:])
+			b[len(b)-1] = n
+			break
+		}
+	}
+	return true
+}
+
+// bucketIndex returns the index of the bucket that holds nodes with the given cpl.
+func (t *Table[K, N]) bucketIndex(cpl int) int {
+	if cpl >= len(t.buckets) {
+		return len(t.buckets) - 1
+	}
+	return cpl
+}
+
+// split splits the last bucket in two. Nodes that share a longer prefix with the table's key than the bucket's
+// cpl are moved to a new last bucket, keeping their order. It must only be called while t.mu is locked.
+func (t *Table[K, N]) split() {
+	last := len(t.buckets) - 1
+
+	var keep, move []N
+	for _, n := range t.buckets[last] {
+		if t.self.CommonPrefixLength(n.Key()) == last {
+			keep = append(keep, n)
+		} else {
+			move = append(move, n)
+		}
+	}
+
+	t.buckets[last] = keep
+	t.buckets = append(t.buckets, move)
+}
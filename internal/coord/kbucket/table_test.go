@@ -0,0 +1,126 @@
+package kbucket
+
+import (
+	"testing"
+
+	"github.com/plprobelab/go-libdht/kad/key"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/tiny"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("bucket size positive", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.BucketSize = 0
+		require.Error(t, cfg.Validate())
+		cfg.BucketSize = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	_, err := New[tiny.Key, tiny.Node](tiny.NewNode(0), &Config{BucketSize: 0})
+	require.Error(t, err)
+}
+
+func TestTableAddNode(t *testing.T) {
+	rt, err := New[tiny.Key, tiny.Node](tiny.NewNode(0), &Config{BucketSize: 2})
+	require.NoError(t, err)
+
+	// the local node is never added
+	require.False(t, rt.AddNode(tiny.NewNode(0)))
+
+	// the single initial bucket holds nodes of any cpl
+	require.True(t, rt.AddNode(tiny.NewNode(0b10000000)))
+	require.True(t, rt.AddNode(tiny.NewNode(0b10000001)))
+	require.Equal(t, 1, rt.BucketCount())
+
+	// adding a node that is already present fails
+	require.False(t, rt.AddNode(tiny.NewNode(0b10000000)))
+
+	// the full bucket is split but the node's bucket is still full
+	require.False(t, rt.AddNode(tiny.NewNode(0b10000010)))
+	require.Equal(t, 2, rt.BucketCount())
+
+	// the bucket covering the local node takes further nodes until it is full and split again
+	require.True(t, rt.AddNode(tiny.NewNode(0b01000000)))
+	require.True(t, rt.AddNode(tiny.NewNode(0b01000001)))
+	require.True(t, rt.AddNode(tiny.NewNode(0b00000001)))
+	require.Equal(t, 3, rt.BucketCount())
+	require.Equal(t, 5, rt.Size())
+
+	// buckets for cpls other than the last one are never split
+	require.False(t, rt.AddNode(tiny.NewNode(0b01000010)))
+	require.Equal(t, 3, rt.BucketCount())
+
+	require.Equal(t, 2, rt.CplSize(0))
+	require.Equal(t, 2, rt.CplSize(1))
+	require.Equal(t, 0, rt.CplSize(2))
+	require.Equal(t, 1, rt.CplSize(7))
+
+	n, found := rt.GetNode(tiny.Key(0b01000001))
+	require.True(t, found)
+	require.True(t, key.Equal(tiny.Key(0b01000001), n.Key()))
+
+	_, found = rt.GetNode(tiny.Key(0b10000010))
+	require.False(t, found)
+}
+
+func TestTableLeastRecentlySeen(t *testing.T) {
+	rt, err := New[tiny.Key, tiny.Node](tiny.NewNode(0), &Config{BucketSize: 3})
+	require.NoError(t, err)
+
+	_, found := rt.LeastRecentlySeen(tiny.Key(0b10000000))
+	require.False(t, found)
+
+	require.True(t, rt.AddNode(tiny.NewNode(0b10000000)))
+	require.True(t, rt.AddNode(tiny.NewNode(0b10000001)))
+	require.True(t, rt.AddNode(tiny.NewNode(0b10000010)))
+
+	// nodes are ordered by the time they were added
+	oldest, found := rt.LeastRecentlySeen(tiny.Key(0b10000011))
+	require.True(t, found)
+	require.True(t, key.Equal(tiny.Key(0b10000000), oldest.Key()))
+
+	// adding a node again marks it as seen
+	require.False(t, rt.AddNode(tiny.NewNode(0b10000000)))
+	oldest, _ = rt.LeastRecentlySeen(tiny.Key(0b10000011))
+	require.True(t, key.Equal(tiny.Key(0b10000001), oldest.Key()))
+
+	// touching a node marks it as seen
+	require.True(t, rt.Touch(tiny.Key(0b10000001)))
+	oldest, _ = rt.LeastRecentlySeen(tiny.Key(0b10000011))
+	require.True(t, key.Equal(tiny.Key(0b10000010), oldest.Key()))
+
+	require.False(t, rt.Touch(tiny.Key(0b10000011)))
+
+	// removing the least recently seen node makes room for a new one
+	require.True(t, rt.RemoveKey(tiny.Key(0b10000010)))
+	require.False(t, rt.RemoveKey(tiny.Key(0b10000010)))
+	oldest, _ = rt.LeastRecentlySeen(tiny.Key(0b10000011))
+	require.True(t, key.Equal(tiny.Key(0b10000000), oldest.Key()))
+	require.True(t, rt.AddNode(tiny.NewNode(0b10000011)))
+}
+
+func TestTableNearestNodes(t *testing.T) {
+	rt, err := New[tiny.Key, tiny.Node](tiny.NewNode(0), &Config{BucketSize: 2})
+	require.NoError(t, err)
+
+	for _, kk := range []tiny.Key{0b10000000, 0b11000000, 0b01000000, 0b00100000, 0b00000001} {
+		require.True(t, rt.AddNode(tiny.NewNode(kk)))
+	}
+
+	nodes := rt.NearestNodes(tiny.Key(0b00000011), 3)
+	require.Len(t, nodes, 3)
+	require.True(t, key.Equal(tiny.Key(0b00000001), nodes[0].Key()))
+	require.True(t, key.Equal(tiny.Key(0b00100000), nodes[1].Key()))
+	require.True(t, key.Equal(tiny.Key(0b01000000), nodes[2].Key()))
+
+	require.Len(t, rt.NearestNodes(tiny.Key(0), 10), 5)
+}
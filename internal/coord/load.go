@@ -0,0 +1,51 @@
+package coord
+
+import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// loadReportInterval is the length of the interval over which the utilization of the coordinator's event
+// loop is measured.
+const loadReportInterval = time.Second // MAGIC
+
+// loadMonitor measures the utilization of the coordinator's event loop, i.e. the fraction of time that the
+// event loop spends performing work rather than waiting for behaviours to become ready.
+type loadMonitor struct {
+	clk clock.Clock
+
+	// start is the time the current measurement interval started.
+	start time.Time
+
+	// busy is the time the event loop has been busy in the current measurement interval.
+	busy time.Duration
+}
+
+func newLoadMonitor(clk clock.Clock) *loadMonitor {
+	return &loadMonitor{
+		clk:   clk,
+		start: clk.Now(),
+	}
+}
+
+// record adds the given duration of work to the current measurement interval. Once the interval has
+// elapsed, it returns the utilization of the event loop in that interval and true, and starts a new interval.
+func (m *loadMonitor) record(busy time.Duration) (float64, bool) {
+	m.busy += busy
+
+	elapsed := m.clk.Since(m.start)
+	if elapsed < loadReportInterval {
+		return 0, false
+	}
+
+	utilization := float64(m.busy) / float64(elapsed)
+	if utilization > 1 {
+		utilization = 1
+	}
+
+	m.start = m.clk.Now()
+	m.busy = 0
+
+	return utilization, true
+}
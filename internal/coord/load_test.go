@@ -0,0 +1,35 @@
+package coord
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMonitor(t *testing.T) {
+	clk := clock.NewMock()
+	m := newLoadMonitor(clk)
+
+	// no report before the interval elapsed
+	clk.Add(loadReportInterval / 2)
+	_, ok := m.record(loadReportInterval / 4)
+	require.False(t, ok)
+
+	clk.Add(loadReportInterval / 2)
+	utilization, ok := m.record(loadReportInterval / 4)
+	require.True(t, ok)
+	require.InDelta(t, 0.5, utilization, 0.001)
+
+	// a new interval starts after each report
+	clk.Add(loadReportInterval)
+	utilization, ok = m.record(0)
+	require.True(t, ok)
+	require.Zero(t, utilization)
+
+	// utilization is capped
+	clk.Add(loadReportInterval)
+	utilization, ok = m.record(2 * loadReportInterval)
+	require.True(t, ok)
+	require.Equal(t, 1.0, utilization)
+}
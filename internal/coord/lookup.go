@@ -0,0 +1,235 @@
+package coord
+
+import (
+	"sync"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/coord/query"
+	"github.com/plprobelab/zikade/pb"
+)
+
+// lookupKey identifies a message query by the type and the key of the message
+// that the query sends to remote nodes. Queries with different options that
+// change how the lookup is performed, or that look for a different number of
+// closest nodes, are never shared.
+type lookupKey struct {
+	msgType       pb.Message_MessageType
+	key           string
+	numResults    int
+	disjointPaths int
+	priority      query.Priority
+}
+
+// newLookupKey returns the lookup key for the given message, number of
+// results and query options.
+func newLookupKey(msg *pb.Message, numResults int, qopts *QueryOptions) lookupKey {
+	return lookupKey{
+		msgType:       msg.GetType(),
+		key:           string(msg.GetKey()),
+		numResults:    numResults,
+		disjointPaths: qopts.DisjointPaths,
+		priority:      qopts.Priority,
+	}
+}
+
+// lookupRegistry keeps track of all message queries that are shared between
+// callers because they requested a lookup for the same [lookupKey]
+// while a query for it was in flight.
+type lookupRegistry struct {
+	mu sync.Mutex
+
+	// byKey holds the shared lookups that new callers can attach to. A lookup
+	// is removed from this map as soon as it finished or was stopped.
+	byKey map[lookupKey]*sharedLookup
+
+	// byID holds all shared lookups that still have callers attached to them.
+	byID map[coordt.QueryID]*sharedLookup
+}
+
+func newLookupRegistry() *lookupRegistry {
+	return &lookupRegistry{
+		byKey: make(map[lookupKey]*sharedLookup),
+		byID:  make(map[coordt.QueryID]*sharedLookup),
+	}
+}
+
+// attach attaches the given waiter to the in-flight lookup for the given key.
+// If there is no such lookup, a new one with the given query id is registered
+// and returned together with a true value. The caller is then responsible for
+// starting the query and running the lookup with [sharedLookup.run].
+func (r *lookupRegistry) attach(key lookupKey, queryID coordt.QueryID, waiter *QueryWaiter) (*sharedLookup, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, found := r.byKey[key]
+	if !found {
+		l = &sharedLookup{
+			queryID:     queryID,
+			key:         key,
+			changed:     make(chan struct{}),
+			subscribers: make(map[*QueryWaiter]chan struct{}),
+		}
+		r.byKey[key] = l
+		r.byID[queryID] = l
+	}
+
+	detached := make(chan struct{})
+	l.mu.Lock()
+	l.subscribers[waiter] = detached
+	l.mu.Unlock()
+
+	go l.forward(waiter, detached)
+
+	return l, !found
+}
+
+// detach detaches the given waiter from the shared lookup with the given query
+// id. It returns true if the query should be stopped. This is the case if the
+// query isn't a shared lookup or if the waiter was the last one attached to
+// a lookup that hasn't finished yet.
+func (r *lookupRegistry) detach(queryID coordt.QueryID, waiter *QueryWaiter) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, found := r.byID[queryID]
+	if !found {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	detached, found := l.subscribers[waiter]
+	if !found {
+		// already detached
+		return false
+	}
+	close(detached)
+	delete(l.subscribers, waiter)
+
+	if len(l.subscribers) > 0 {
+		return false
+	}
+
+	delete(r.byID, queryID)
+	if r.byKey[l.key] == l {
+		delete(r.byKey, l.key)
+	}
+
+	return !l.done
+}
+
+// finish removes the given lookup from the registry so that new callers
+// start a new query instead of attaching to the finished one.
+func (r *lookupRegistry) finish(l *sharedLookup) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byKey[l.key] == l {
+		delete(r.byKey, l.key)
+	}
+}
+
+// sharedLookup is a message query whose events are fanned out to the
+// [QueryWaiter] of every caller that is attached to it. All progress events
+// are recorded so that callers that attach to an already running query still
+// receive the same stream of results as the first caller.
+type sharedLookup struct {
+	queryID coordt.QueryID
+	key     lookupKey
+
+	// mu guards all fields below
+	mu sync.Mutex
+
+	// history holds all progress events that the query emitted so far
+	history []CtxEvent[*EventQueryProgressed]
+
+	// finished holds the event that the query emitted when it finished. It is
+	// nil if the query hasn't finished yet or didn't emit a finished event.
+	finished *CtxEvent[*EventQueryFinished]
+
+	// done is true when the query won't emit any more events.
+	done bool
+
+	// changed is closed and replaced whenever a new event was recorded.
+	changed chan struct{}
+
+	// subscribers maps the waiters of all attached callers to a channel that
+	// is closed when the caller detaches.
+	subscribers map[*QueryWaiter]chan struct{}
+}
+
+// run records all events that the query emits to the given source waiter
+// until the query has finished.
+func (l *sharedLookup) run(r *lookupRegistry, source *QueryWaiter) {
+	for ev := range source.Progressed() {
+		l.mu.Lock()
+		l.history = append(l.history, ev)
+		l.notifyChanged()
+		l.mu.Unlock()
+	}
+
+	ev, ok := <-source.Finished()
+
+	r.finish(l)
+
+	l.mu.Lock()
+	if ok {
+		l.finished = &ev
+	}
+	l.done = true
+	l.notifyChanged()
+	l.mu.Unlock()
+}
+
+// notifyChanged wakes up all forwarders. It must be called while l.mu is held.
+func (l *sharedLookup) notifyChanged() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// forward sends all recorded and future events of the lookup to the given
+// waiter until the lookup is done or the waiter was detached. Like a
+// [queryNotifier], it closes the progress channel before sending the finished
+// event.
+func (l *sharedLookup) forward(waiter *QueryWaiter, detached <-chan struct{}) {
+	next := 0
+	for {
+		l.mu.Lock()
+		for next < len(l.history) {
+			ev := l.history[next]
+			l.mu.Unlock()
+
+			select {
+			case <-detached:
+				return
+			case waiter.NotifyProgressed() <- ev:
+			}
+			next++
+
+			l.mu.Lock()
+		}
+
+		if l.done {
+			finished := l.finished
+			l.mu.Unlock()
+
+			close(waiter.NotifyProgressed())
+			if finished != nil {
+				waiter.NotifyFinished() <- *finished
+			}
+			close(waiter.NotifyFinished())
+
+			return
+		}
+
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-detached:
+			return
+		case <-changed:
+		}
+	}
+}
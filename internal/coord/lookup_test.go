@@ -0,0 +1,135 @@
+package coord
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/coord/query"
+	"github.com/plprobelab/zikade/internal/kadtest"
+	"github.com/plprobelab/zikade/internal/nettest"
+	"github.com/plprobelab/zikade/kadt"
+	"github.com/plprobelab/zikade/pb"
+)
+
+func progressEvent(ctx context.Context, id coordt.QueryID, node kadt.PeerID) CtxEvent[*EventQueryProgressed] {
+	return CtxEvent[*EventQueryProgressed]{Ctx: ctx, Event: &EventQueryProgressed{QueryID: id, NodeID: node}}
+}
+
+func TestLookupRegistry_fan_out(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	r := newLookupRegistry()
+	key := newLookupKey(&pb.Message{Type: pb.Message_GET_PROVIDERS, Key: []byte("key")}, 20, &QueryOptions{})
+
+	w1 := NewQueryWaiter(10)
+	l1, created := r.attach(key, "query-1", w1)
+	require.True(t, created)
+
+	source := NewQueryWaiter(10)
+	go l1.run(r, source)
+
+	node1, err := nettest.NewPeerID()
+	require.NoError(t, err)
+	node2, err := nettest.NewPeerID()
+	require.NoError(t, err)
+
+	source.NotifyProgressed() <- progressEvent(ctx, l1.queryID, node1)
+
+	// the first subscriber receives the first event
+	ev := kadtest.ReadItem(t, ctx, w1.Progressed())
+	assert.Equal(t, node1, ev.Event.NodeID)
+
+	// a second caller attaches to the in-flight query
+	w2 := NewQueryWaiter(10)
+	l2, created := r.attach(key, "query-2", w2)
+	require.False(t, created)
+	require.Equal(t, l1.queryID, l2.queryID)
+
+	// the second subscriber receives the replayed first event
+	ev = kadtest.ReadItem(t, ctx, w2.Progressed())
+	assert.Equal(t, node1, ev.Event.NodeID)
+
+	source.NotifyProgressed() <- progressEvent(ctx, l1.queryID, node2)
+
+	// both subscribers receive the second event
+	for _, w := range []*QueryWaiter{w1, w2} {
+		ev = kadtest.ReadItem(t, ctx, w.Progressed())
+		assert.Equal(t, node2, ev.Event.NodeID)
+	}
+
+	close(source.NotifyProgressed())
+	source.NotifyFinished() <- CtxEvent[*EventQueryFinished]{Ctx: ctx, Event: &EventQueryFinished{QueryID: l1.queryID}}
+	close(source.NotifyFinished())
+
+	// both subscribers receive the finished event
+	for _, w := range []*QueryWaiter{w1, w2} {
+		kadtest.AssertClosed(t, ctx, w.Progressed())
+		fev := kadtest.ReadItem(t, ctx, w.Finished())
+		assert.Equal(t, l1.queryID, fev.Event.QueryID)
+		kadtest.AssertClosed(t, ctx, w.Finished())
+	}
+
+	// a query for the same key starts a new lookup after the first finished
+	w3 := NewQueryWaiter(10)
+	l3, created := r.attach(key, "query-3", w3)
+	require.True(t, created)
+	assert.Equal(t, coordt.QueryID("query-3"), l3.queryID)
+
+	// the finished lookup isn't stopped when its callers detach
+	assert.False(t, r.detach(l1.queryID, w1))
+	assert.False(t, r.detach(l1.queryID, w2))
+}
+
+func TestLookupRegistry_detach(t *testing.T) {
+	r := newLookupRegistry()
+	key := newLookupKey(&pb.Message{Type: pb.Message_GET_VALUE, Key: []byte("key")}, 20, &QueryOptions{})
+
+	w1 := NewQueryWaiter(10)
+	l, _ := r.attach(key, "query-1", w1)
+
+	w2 := NewQueryWaiter(10)
+	r.attach(key, "query-2", w2)
+
+	// the first caller goes away, but the query continues for the second one
+	assert.False(t, r.detach(l.queryID, w1))
+
+	// detaching twice has no effect
+	assert.False(t, r.detach(l.queryID, w1))
+
+	// the last caller goes away, so the query should be stopped
+	assert.True(t, r.detach(l.queryID, w2))
+
+	// new callers don't attach to the stopped query
+	w3 := NewQueryWaiter(10)
+	_, created := r.attach(key, "query-3", w3)
+	assert.True(t, created)
+
+	// queries that aren't shared are always stopped
+	assert.True(t, r.detach("unknown", NewQueryWaiter(10)))
+}
+
+func TestLookupRegistry_different_keys(t *testing.T) {
+	r := newLookupRegistry()
+
+	_, created := r.attach(newLookupKey(&pb.Message{Type: pb.Message_GET_VALUE, Key: []byte("key")}, 20, &QueryOptions{}), "query-1", NewQueryWaiter(10))
+	assert.True(t, created)
+
+	_, created = r.attach(newLookupKey(&pb.Message{Type: pb.Message_GET_PROVIDERS, Key: []byte("key")}, 20, &QueryOptions{}), "query-2", NewQueryWaiter(10))
+	assert.True(t, created)
+
+	_, created = r.attach(newLookupKey(&pb.Message{Type: pb.Message_GET_VALUE, Key: []byte("other")}, 20, &QueryOptions{}), "query-3", NewQueryWaiter(10))
+	assert.True(t, created)
+
+	_, created = r.attach(newLookupKey(&pb.Message{Type: pb.Message_GET_VALUE, Key: []byte("key")}, 20, &QueryOptions{DisjointPaths: 2}), "query-4", NewQueryWaiter(10))
+	assert.True(t, created)
+
+	_, created = r.attach(newLookupKey(&pb.Message{Type: pb.Message_GET_VALUE, Key: []byte("key")}, 20, &QueryOptions{Priority: query.PriorityBackground}), "query-5", NewQueryWaiter(10))
+	assert.True(t, created)
+
+	_, created = r.attach(newLookupKey(&pb.Message{Type: pb.Message_GET_VALUE, Key: []byte("key")}, 10, &QueryOptions{}), "query-6", NewQueryWaiter(10))
+	assert.True(t, created)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/benbjohnson/clock"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 
@@ -17,6 +18,9 @@ type NetworkBehaviour struct {
 	// rtr is the message router used to send messages
 	rtr coordt.Router[kadt.Key, kadt.PeerID, *pb.Message]
 
+	// clk is used to measure the round-trip times of requests
+	clk clock.Clock
+
 	nodeHandlersMu sync.Mutex
 	nodeHandlers   map[kadt.PeerID]*NodeHandler // TODO: garbage collect node handlers
 
@@ -28,9 +32,10 @@ type NetworkBehaviour struct {
 	tracer trace.Tracer
 }
 
-func NewNetworkBehaviour(rtr coordt.Router[kadt.Key, kadt.PeerID, *pb.Message], logger *slog.Logger, tracer trace.Tracer) *NetworkBehaviour {
+func NewNetworkBehaviour(rtr coordt.Router[kadt.Key, kadt.PeerID, *pb.Message], clk clock.Clock, logger *slog.Logger, tracer trace.Tracer) *NetworkBehaviour {
 	b := &NetworkBehaviour{
 		rtr:          rtr,
+		clk:          clk,
 		nodeHandlers: make(map[kadt.PeerID]*NodeHandler),
 		ready:        make(chan struct{}, 1),
 		logger:       logger.With("behaviour", "network"),
@@ -52,7 +57,7 @@ func (b *NetworkBehaviour) Notify(ctx context.Context, ev BehaviourEvent) {
 		b.nodeHandlersMu.Lock()
 		nh, ok := b.nodeHandlers[ev.To]
 		if !ok {
-			nh = NewNodeHandler(ev.To, b.rtr, b.logger, b.tracer)
+			nh = NewNodeHandler(ev.To, b.rtr, b.clk, b.logger, b.tracer)
 			b.nodeHandlers[ev.To] = nh
 		}
 		b.nodeHandlersMu.Unlock()
@@ -61,7 +66,7 @@ func (b *NetworkBehaviour) Notify(ctx context.Context, ev BehaviourEvent) {
 		b.nodeHandlersMu.Lock()
 		nh, ok := b.nodeHandlers[ev.To]
 		if !ok {
-			nh = NewNodeHandler(ev.To, b.rtr, b.logger, b.tracer)
+			nh = NewNodeHandler(ev.To, b.rtr, b.clk, b.logger, b.tracer)
 			b.nodeHandlers[ev.To] = nh
 		}
 		b.nodeHandlersMu.Unlock()
@@ -109,15 +114,17 @@ func (b *NetworkBehaviour) Perform(ctx context.Context) (BehaviourEvent, bool) {
 type NodeHandler struct {
 	self   kadt.PeerID
 	rtr    coordt.Router[kadt.Key, kadt.PeerID, *pb.Message]
+	clk    clock.Clock
 	queue  *WorkQueue[NodeHandlerRequest]
 	logger *slog.Logger
 	tracer trace.Tracer
 }
 
-func NewNodeHandler(self kadt.PeerID, rtr coordt.Router[kadt.Key, kadt.PeerID, *pb.Message], logger *slog.Logger, tracer trace.Tracer) *NodeHandler {
+func NewNodeHandler(self kadt.PeerID, rtr coordt.Router[kadt.Key, kadt.PeerID, *pb.Message], clk clock.Clock, logger *slog.Logger, tracer trace.Tracer) *NodeHandler {
 	h := &NodeHandler{
 		self:   self,
 		rtr:    rtr,
+		clk:    clk,
 		logger: logger,
 		tracer: tracer,
 	}
@@ -139,6 +146,7 @@ func (h *NodeHandler) send(ctx context.Context, ev NodeHandlerRequest) bool {
 		if cmd.Notify == nil {
 			break
 		}
+		start := h.clk.Now()
 		nodes, err := h.rtr.GetClosestNodes(ctx, h.self, cmd.Target)
 		if err != nil {
 			cmd.Notify.Notify(ctx, &EventGetCloserNodesFailure{
@@ -155,11 +163,13 @@ func (h *NodeHandler) send(ctx context.Context, ev NodeHandlerRequest) bool {
 			To:          h.self,
 			Target:      cmd.Target,
 			CloserNodes: nodes,
+			RTT:         h.clk.Since(start),
 		})
 	case *EventOutboundSendMessage:
 		if cmd.Notify == nil {
 			break
 		}
+		start := h.clk.Now()
 		resp, err := h.rtr.SendMessage(ctx, h.self, cmd.Message)
 		if err != nil {
 			cmd.Notify.Notify(ctx, &EventSendMessageFailure{
@@ -177,6 +187,7 @@ func (h *NodeHandler) send(ctx context.Context, ev NodeHandlerRequest) bool {
 			Request:     cmd.Message,
 			Response:    resp,
 			CloserNodes: resp.CloserNodes(),
+			RTT:         h.clk.Since(start),
 		})
 	default:
 		panic(fmt.Sprintf("unexpected command type: %T", cmd))
@@ -0,0 +1,332 @@
+package coord
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/kadt"
+	"github.com/plprobelab/zikade/pb"
+)
+
+// OperationType is the kind of work an [Operation] performs.
+type OperationType string
+
+const (
+	// OperationFindCloser is a query that searches for the closest nodes to a key.
+	OperationFindCloser OperationType = "find_closer"
+
+	// OperationMessage is a query that sends a message to the closest nodes to a key.
+	OperationMessage OperationType = "message"
+
+	// OperationBroadcast is a broadcast that stores a record with the closest nodes to a key.
+	OperationBroadcast OperationType = "broadcast"
+)
+
+// An Operation is a snapshot of a query or broadcast that is running on a [Coordinator].
+type Operation struct {
+	ID          coordt.QueryID
+	Type        OperationType
+	MessageType pb.Message_MessageType // the type of the message sent to remote nodes, unset for find closer queries
+	Target      kadt.Key
+	Stats       coordt.QueryStats // the requests made so far, Stats.Start is the time the operation was started
+	InFlight    []kadt.PeerID     // the nodes that were sent a request that has not been answered yet
+}
+
+// operation is the state of a running operation held by an [operationRegistry].
+type operation struct {
+	info Operation
+
+	// inFlight maps the string form of the ids of the nodes with outstanding requests to the number of requests
+	// that are outstanding.
+	inFlight map[string]int
+	peers    map[string]kadt.PeerID
+
+	// stop is the behaviour that is notified with an [EventStopQuery] when the operation is cancelled.
+	stop Notify[BehaviourEvent]
+
+	// cancels holds the cancel functions of the contexts of all callers waiting for the operation, keyed by
+	// a handle unique to the caller. Shared lookups may have more than one caller.
+	cancels map[uint64]context.CancelFunc
+
+	// path records the path of the operation through the network. It is nil unless a caller asked for it.
+	path *pathRecorder
+}
+
+// operationRegistry keeps track of the queries and broadcasts started by the callers of a [Coordinator] so
+// that they can be inspected and cancelled while they are running.
+type operationRegistry struct {
+	clk clock.Clock
+
+	mu  sync.Mutex
+	ops map[coordt.QueryID]*operation
+
+	// lastHandle holds the last handle given to a caller that registered an operation.
+	lastHandle uint64
+}
+
+func newOperationRegistry(clk clock.Clock) *operationRegistry {
+	return &operationRegistry{
+		clk: clk,
+		ops: make(map[coordt.QueryID]*operation),
+	}
+}
+
+// register records that a caller is waiting for the operation described by info. The behaviour stop is
+// notified when the operation is cancelled, and so is the cancel function of the caller. If the operation is
+// already registered, for example because the caller attached to a shared lookup, only the cancel function
+// is added to it. The returned function must be called when the caller stopped waiting for the operation.
+// The operation is removed once all of its callers have stopped waiting.
+func (r *operationRegistry) register(info Operation, stop Notify[BehaviourEvent], cancel context.CancelFunc) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, found := r.ops[info.ID]
+	if !found {
+		op = &operation{
+			info:     info,
+			inFlight: make(map[string]int),
+			peers:    make(map[string]kadt.PeerID),
+			stop:     stop,
+			cancels:  make(map[uint64]context.CancelFunc),
+		}
+		r.ops[info.ID] = op
+	}
+
+	r.lastHandle++
+	handle := r.lastHandle
+	op.cancels[handle] = cancel
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(op.cancels, handle)
+		if len(op.cancels) == 0 && r.ops[info.ID] == op {
+			delete(r.ops, info.ID)
+		}
+	}
+}
+
+// list returns a snapshot of all registered operations, ordered by their start time.
+func (r *operationRegistry) list() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ops := make([]Operation, 0, len(r.ops))
+	for _, op := range r.ops {
+		ops = append(ops, op.snapshot())
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Stats.Start.Equal(ops[j].Stats.Start) {
+			return ops[i].ID < ops[j].ID
+		}
+		return ops[i].Stats.Start.Before(ops[j].Stats.Start)
+	})
+
+	return ops
+}
+
+// get returns a snapshot of the operation with the given id and whether it was found.
+func (r *operationRegistry) get(id coordt.QueryID) (Operation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, found := r.ops[id]
+	if !found {
+		return Operation{}, false
+	}
+	return op.snapshot(), true
+}
+
+// cancel stops the operation with the given id and cancels the contexts of all callers waiting for it. It
+// returns false if there is no such operation.
+func (r *operationRegistry) cancel(ctx context.Context, id coordt.QueryID) bool {
+	r.mu.Lock()
+	op, found := r.ops[id]
+	if !found {
+		r.mu.Unlock()
+		return false
+	}
+	cancels := make([]context.CancelFunc, 0, len(op.cancels))
+	for _, cancel := range op.cancels {
+		cancels = append(cancels, cancel)
+	}
+	stop := op.stop
+	r.mu.Unlock()
+
+	stop.Notify(ctx, &EventStopQuery{QueryID: id})
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	return true
+}
+
+// recordPath starts recording the path of the operation with the given id through the network. It must be
+// called before the operation sends its first request.
+func (r *operationRegistry) recordPath(id coordt.QueryID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, found := r.ops[id]
+	if !found || op.path != nil {
+		return
+	}
+	op.path = newPathRecorder()
+}
+
+// path returns the path of the operation with the given id that was recorded so far. It returns false if
+// there is no such operation or its path is not recorded.
+func (r *operationRegistry) path(id coordt.QueryID) (Path, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, found := r.ops[id]
+	if !found || op.path == nil {
+		return Path{}, false
+	}
+	return op.path.path(op.info.Target, op.info.Stats.Start), true
+}
+
+// track records the outbound request of a registered operation and arranges for its response to be
+// recorded too. Requests of operations that are not registered, such as those of the routing behaviour,
+// are ignored.
+func (r *operationRegistry) track(ev NetworkCommand) {
+	switch ev := ev.(type) {
+	case *EventOutboundGetCloserNodes:
+		if ev.Notify != nil && r.requested(ev.QueryID, ev.To) {
+			ev.Notify = &operationNotifier{ops: r, next: ev.Notify}
+		}
+	case *EventOutboundSendMessage:
+		if ev.Notify != nil && r.requested(ev.QueryID, ev.To) {
+			ev.Notify = &operationNotifier{ops: r, next: ev.Notify}
+		}
+	}
+}
+
+// requested records that a request was sent to a node on behalf of an operation. It returns false if the
+// operation is not registered.
+func (r *operationRegistry) requested(id coordt.QueryID, to kadt.PeerID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, found := r.ops[id]
+	if !found {
+		return false
+	}
+
+	op.info.Stats.Requests++
+	k := to.String()
+	op.inFlight[k]++
+	op.peers[k] = to
+
+	if op.path != nil {
+		op.path.requested(to, r.clk.Now())
+	}
+
+	return true
+}
+
+// escalated records the number of times the query of an operation escalated so far. The registry can't
+// observe escalations in the requests of the operation so they are taken from the progress of the query.
+func (r *operationRegistry) escalated(id coordt.QueryID, escalations int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, found := r.ops[id]
+	if !found {
+		return
+	}
+
+	if escalations > op.info.Stats.Escalations {
+		op.info.Stats.Escalations = escalations
+	}
+}
+
+// response describes the outcome of a request that was sent on behalf of an operation.
+type response struct {
+	ok     bool          // whether the request succeeded
+	err    error         // the error if the request failed
+	rtt    time.Duration // the time it took to receive the response
+	size   int           // the size of the response in bytes, zero if it is unknown
+	closer []kadt.PeerID // the closer nodes that were returned
+}
+
+// responded records that a request that was sent to a node on behalf of an operation has completed.
+func (r *operationRegistry) responded(id coordt.QueryID, from kadt.PeerID, res response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, found := r.ops[id]
+	if !found {
+		return
+	}
+
+	if res.ok {
+		op.info.Stats.Success++
+	} else {
+		op.info.Stats.Failure++
+	}
+
+	if op.path != nil {
+		op.path.responded(from, res)
+	}
+
+	k := from.String()
+	op.inFlight[k]--
+	if op.inFlight[k] <= 0 {
+		delete(op.inFlight, k)
+		delete(op.peers, k)
+	}
+}
+
+// snapshot returns a copy of the operation's information. It must be called while the registry's lock is held.
+func (op *operation) snapshot() Operation {
+	info := op.info
+	info.InFlight = make([]kadt.PeerID, 0, len(op.peers))
+	for _, p := range op.peers {
+		info.InFlight = append(info.InFlight, p)
+	}
+	sort.Slice(info.InFlight, func(i, j int) bool {
+		return info.InFlight[i].String() < info.InFlight[j].String()
+	})
+	return info
+}
+
+// operationNotifier records the responses to the requests of a registered operation before passing them on
+// to the behaviour that made the request.
+type operationNotifier struct {
+	ops  *operationRegistry
+	next Notify[BehaviourEvent]
+}
+
+func (n *operationNotifier) Notify(ctx context.Context, ev BehaviourEvent) {
+	switch ev := ev.(type) {
+	case *EventGetCloserNodesSuccess:
+		n.ops.responded(ev.QueryID, ev.To, response{ok: true, rtt: ev.RTT, closer: ev.CloserNodes})
+	case *EventGetCloserNodesFailure:
+		n.ops.responded(ev.QueryID, ev.To, response{err: ev.Err})
+	case *EventSendMessageSuccess:
+		n.ops.responded(ev.QueryID, ev.To, response{ok: true, rtt: ev.RTT, size: ev.Response.Size(), closer: ev.CloserNodes})
+	case *EventSendMessageFailure:
+		n.ops.responded(ev.QueryID, ev.To, response{err: ev.Err})
+	}
+	n.next.Notify(ctx, ev)
+}
+
+// newOperation returns the description of a new operation that starts now.
+func newOperation(id coordt.QueryID, typ OperationType, msgType pb.Message_MessageType, target kadt.Key, start time.Time) Operation {
+	return Operation{
+		ID:          id,
+		Type:        typ,
+		MessageType: msgType,
+		Target:      target,
+		Stats:       coordt.QueryStats{Start: start},
+	}
+}
@@ -0,0 +1,152 @@
+package coord
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/kadtest"
+	"github.com/plprobelab/zikade/internal/nettest"
+	"github.com/plprobelab/zikade/kadt"
+	"github.com/plprobelab/zikade/pb"
+)
+
+func TestOperationRegistry_track(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	r := newOperationRegistry(clock.New())
+
+	node1, err := nettest.NewPeerID()
+	require.NoError(t, err)
+	node2, err := nettest.NewPeerID()
+	require.NoError(t, err)
+
+	start := time.Unix(1000, 0)
+	release := r.register(newOperation("op-1", OperationFindCloser, 0, node1.Key(), start), NotifyFunc[BehaviourEvent](func(context.Context, BehaviourEvent) {}), func() {})
+
+	var responses []BehaviourEvent
+	requester := NotifyFunc[BehaviourEvent](func(ctx context.Context, ev BehaviourEvent) {
+		responses = append(responses, ev)
+	})
+
+	ev1 := &EventOutboundGetCloserNodes{QueryID: "op-1", To: node1, Notify: requester}
+	r.track(ev1)
+	ev2 := &EventOutboundGetCloserNodes{QueryID: "op-1", To: node2, Notify: requester}
+	r.track(ev2)
+
+	op, found := r.get("op-1")
+	require.True(t, found)
+	assert.Equal(t, OperationFindCloser, op.Type)
+	assert.Equal(t, start, op.Stats.Start)
+	assert.Equal(t, 2, op.Stats.Requests)
+	assert.ElementsMatch(t, []kadt.PeerID{node1, node2}, op.InFlight)
+
+	// responses are recorded and passed on to the requester
+	ev1.Notify.Notify(ctx, &EventGetCloserNodesSuccess{QueryID: "op-1", To: node1})
+	ev2.Notify.Notify(ctx, &EventGetCloserNodesFailure{QueryID: "op-1", To: node2})
+	require.Len(t, responses, 2)
+
+	op, found = r.get("op-1")
+	require.True(t, found)
+	assert.Equal(t, 1, op.Stats.Success)
+	assert.Equal(t, 1, op.Stats.Failure)
+	assert.Empty(t, op.InFlight)
+
+	// escalations are taken from the progress of the query and never decrease
+	r.escalated("op-1", 1)
+	r.escalated("op-1", 0)
+	op, found = r.get("op-1")
+	require.True(t, found)
+	assert.Equal(t, 1, op.Stats.Escalations)
+
+	release()
+	_, found = r.get("op-1")
+	require.False(t, found)
+}
+
+func TestOperationRegistry_untracked(t *testing.T) {
+	r := newOperationRegistry(clock.New())
+
+	node, err := nettest.NewPeerID()
+	require.NoError(t, err)
+
+	// requests of queries that are not registered are left untouched
+	notify := NotifyFunc[BehaviourEvent](func(context.Context, BehaviourEvent) {})
+	ev := &EventOutboundSendMessage{QueryID: "bootstrap", To: node, Notify: notify}
+	r.track(ev)
+	require.IsType(t, notify, ev.Notify)
+	require.Empty(t, r.list())
+}
+
+func TestOperationRegistry_shared(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	r := newOperationRegistry(clock.New())
+
+	var stopped []coordt.QueryID
+	stop := NotifyFunc[BehaviourEvent](func(ctx context.Context, ev BehaviourEvent) {
+		stopped = append(stopped, ev.(*EventStopQuery).QueryID)
+	})
+
+	cancelled := 0
+	info := newOperation("op-1", OperationMessage, pb.Message_GET_PROVIDERS, kadt.Key{}, time.Unix(1000, 0))
+	release1 := r.register(info, stop, func() { cancelled++ })
+	release2 := r.register(info, stop, func() { cancelled++ })
+	require.Len(t, r.list(), 1)
+
+	// the operation is listed until the last caller stopped waiting
+	release1()
+	require.Len(t, r.list(), 1)
+
+	// cancelling stops the query and cancels the remaining caller
+	require.True(t, r.cancel(ctx, "op-1"))
+	require.Equal(t, []coordt.QueryID{"op-1"}, stopped)
+	require.Equal(t, 1, cancelled)
+
+	release2()
+	require.Empty(t, r.list())
+	require.False(t, r.cancel(ctx, "op-1"))
+}
+
+func TestCoordinatorOperations(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+	ccfg := DefaultCoordinatorConfig()
+	ccfg.Clock = clk
+
+	c, err := NewCoordinator(nodes[0].NodeID, nodes[0].Router, nodes[0].RoutingTable, ccfg)
+	require.NoError(t, err)
+
+	target := nodes[3].NodeID.Key()
+
+	var listed []Operation
+	qfn := func(ctx context.Context, id kadt.PeerID, msg *pb.Message, stats coordt.QueryStats) error {
+		if listed == nil {
+			listed = c.Operations()
+			require.True(t, c.CancelOperation(ctx, listed[0].ID))
+		}
+		return nil
+	}
+
+	_, _, err = c.QueryClosest(ctx, target, qfn, 20)
+	require.True(t, errors.Is(err, context.Canceled))
+
+	require.Len(t, listed, 1)
+	assert.Equal(t, OperationFindCloser, listed[0].Type)
+	assert.Equal(t, 0, target.Compare(listed[0].Target))
+	assert.GreaterOrEqual(t, listed[0].Stats.Requests, 1)
+
+	// the operation is removed once the caller returned
+	require.Empty(t, c.Operations())
+	_, found := c.Operation(listed[0].ID)
+	require.False(t, found)
+}
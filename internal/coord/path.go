@@ -0,0 +1,101 @@
+package coord
+
+import (
+	"time"
+
+	"github.com/plprobelab/zikade/kadt"
+)
+
+// A Path records how a query travelled through the network. Every node that was sent a request is a hop of
+// the path. A hop is referred to by the nodes that returned it as a closer node before it was contacted, so
+// the hops and their referrers form a directed acyclic graph that starts at the seeds of the query.
+type Path struct {
+	Target kadt.Key
+	Start  time.Time // the time the query was started
+	Hops   []PathHop // the contacted nodes in the order the requests were sent
+}
+
+// A PathHop is a node that was contacted by a query.
+type PathHop struct {
+	Node         kadt.PeerID
+	ReferredBy   []kadt.PeerID // the nodes that returned Node as a closer node before it was contacted, empty for seeds
+	Sent         time.Time     // the time the request was sent to Node
+	Responded    bool          // whether Node responded or the request failed
+	RTT          time.Duration // the time it took to receive the response, zero if Node has not responded
+	ResponseSize int           // the size of the response in bytes, zero if it is unknown
+	CloserNodes  []kadt.PeerID // the closer nodes that Node returned
+	Err          error         // the error of the request if it failed
+}
+
+// pathRecorder builds the [Path] of an operation from its requests and their responses.
+type pathRecorder struct {
+	hops []PathHop
+
+	// index maps the string form of the ids of the contacted nodes to their position in hops.
+	index map[string]int
+
+	// referrers maps the string form of the ids of the nodes that were returned as closer nodes to the
+	// nodes that returned them.
+	referrers map[string][]kadt.PeerID
+}
+
+func newPathRecorder() *pathRecorder {
+	return &pathRecorder{
+		index:     make(map[string]int),
+		referrers: make(map[string][]kadt.PeerID),
+	}
+}
+
+// requested records that a request was sent to a node. Only the first request to a node is recorded.
+func (p *pathRecorder) requested(to kadt.PeerID, now time.Time) {
+	k := to.String()
+	if _, found := p.index[k]; found {
+		return
+	}
+
+	p.index[k] = len(p.hops)
+	p.hops = append(p.hops, PathHop{
+		Node:       to,
+		ReferredBy: append([]kadt.PeerID(nil), p.referrers[k]...),
+		Sent:       now,
+	})
+}
+
+// responded records the response of a node or the failure of the request that was sent to it.
+func (p *pathRecorder) responded(from kadt.PeerID, res response) {
+	i, found := p.index[from.String()]
+	if !found {
+		return
+	}
+
+	hop := &p.hops[i]
+	if !res.ok {
+		hop.Err = res.err
+		return
+	}
+
+	hop.Responded = true
+	hop.RTT = res.rtt
+	hop.ResponseSize = res.size
+	hop.CloserNodes = res.closer
+
+	for _, n := range res.closer {
+		k := n.String()
+		if _, contacted := p.index[k]; contacted {
+			// the node was reached through other nodes already
+			continue
+		}
+		p.referrers[k] = append(p.referrers[k], from)
+	}
+}
+
+// path returns a copy of the recorded path.
+func (p *pathRecorder) path(target kadt.Key, start time.Time) Path {
+	hops := make([]PathHop, len(p.hops))
+	copy(hops, p.hops)
+	return Path{
+		Target: target,
+		Start:  start,
+		Hops:   hops,
+	}
+}
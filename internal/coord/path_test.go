@@ -0,0 +1,113 @@
+package coord
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/kadtest"
+	"github.com/plprobelab/zikade/internal/nettest"
+	"github.com/plprobelab/zikade/kadt"
+	"github.com/plprobelab/zikade/pb"
+)
+
+func TestPathRecorder(t *testing.T) {
+	node1, err := nettest.NewPeerID()
+	require.NoError(t, err)
+	node2, err := nettest.NewPeerID()
+	require.NoError(t, err)
+	node3, err := nettest.NewPeerID()
+	require.NoError(t, err)
+
+	start := time.Unix(1000, 0)
+	p := newPathRecorder()
+
+	// node1 and node2 are seeds, both return node3
+	p.requested(node1, start)
+	p.requested(node2, start)
+	p.responded(node1, response{ok: true, rtt: time.Second, size: 10, closer: []kadt.PeerID{node2, node3}})
+	p.responded(node2, response{ok: true, rtt: 2 * time.Second, closer: []kadt.PeerID{node3}})
+
+	// node3 fails, a second request to node1 is not recorded again
+	p.requested(node3, start.Add(2*time.Second))
+	p.requested(node1, start.Add(2*time.Second))
+	errTimeout := errors.New("timeout")
+	p.responded(node3, response{err: errTimeout})
+
+	path := p.path(node1.Key(), start)
+	assert.Equal(t, start, path.Start)
+	require.Len(t, path.Hops, 3)
+
+	assert.Equal(t, node1, path.Hops[0].Node)
+	assert.Empty(t, path.Hops[0].ReferredBy)
+	assert.True(t, path.Hops[0].Responded)
+	assert.Equal(t, time.Second, path.Hops[0].RTT)
+	assert.Equal(t, 10, path.Hops[0].ResponseSize)
+	assert.Equal(t, []kadt.PeerID{node2, node3}, path.Hops[0].CloserNodes)
+
+	// node2 was contacted before node1 returned it
+	assert.Equal(t, node2, path.Hops[1].Node)
+	assert.Empty(t, path.Hops[1].ReferredBy)
+
+	assert.Equal(t, node3, path.Hops[2].Node)
+	assert.Equal(t, []kadt.PeerID{node1, node2}, path.Hops[2].ReferredBy)
+	assert.Equal(t, start.Add(2*time.Second), path.Hops[2].Sent)
+	assert.False(t, path.Hops[2].Responded)
+	assert.Equal(t, errTimeout, path.Hops[2].Err)
+}
+
+func TestCoordinatorQueryPath(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+	ccfg := DefaultCoordinatorConfig()
+	ccfg.Clock = clk
+
+	c, err := NewCoordinator(nodes[0].NodeID, nodes[0].Router, nodes[0].RoutingTable, ccfg)
+	require.NoError(t, err)
+
+	target := nodes[3].NodeID.Key()
+
+	qfn := func(ctx context.Context, id kadt.PeerID, msg *pb.Message, stats coordt.QueryStats) error {
+		return nil
+	}
+
+	var paths []Path
+	onPath := func(ctx context.Context, p Path) {
+		paths = append(paths, p)
+	}
+
+	_, _, err = c.QueryClosest(ctx, target, qfn, 20, WithPath(onPath))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	// A asks B, which refers it to C, which refers it to D
+	path := paths[0]
+	assert.Equal(t, 0, target.Compare(path.Target))
+	require.Len(t, path.Hops, 3)
+	for i, hop := range path.Hops {
+		assert.Equal(t, nodes[i+1].NodeID, hop.Node)
+		assert.True(t, hop.Responded)
+		if i == 0 {
+			assert.Empty(t, hop.ReferredBy)
+		} else {
+			assert.Equal(t, []kadt.PeerID{nodes[i].NodeID}, hop.ReferredBy)
+		}
+	}
+
+	// the path of message queries includes the size of the responses
+	msg := &pb.Message{Type: pb.Message_GET_VALUE, Key: []byte("key")}
+	_, _, err = c.QueryMessage(ctx, msg, qfn, 20, WithPath(onPath))
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	require.NotEmpty(t, paths[1].Hops)
+	assert.Greater(t, paths[1].Hops[0].ResponseSize, 0)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 
@@ -28,6 +29,9 @@ type QueryConfig struct {
 	// Tracer is the tracer that should be used to trace execution.
 	Tracer trace.Tracer
 
+	// Meter is the meter that should be used to record metrics.
+	Meter metric.Meter
+
 	// Concurrency is the maximum number of queries that may be waiting for message responses at any one time.
 	Concurrency int
 
@@ -39,6 +43,42 @@ type QueryConfig struct {
 
 	// RequestTimeout is the timeout queries should use for contacting a single node
 	RequestTimeout time.Duration
+
+	// AdaptiveRequestTimeout specifies whether queries should derive the timeout for contacting a single node
+	// from the observed round-trip times of earlier requests. RequestTimeout is then the upper bound of the timeout.
+	AdaptiveRequestTimeout bool
+
+	// RequestTimeoutMin is the lower bound of adaptive request timeouts.
+	RequestTimeoutMin time.Duration
+
+	// RequestTimeoutPercentile is the percentile of observed round-trip times that is used as the request
+	// timeout for nodes that have not been contacted before.
+	RequestTimeoutPercentile float64
+
+	// Interactive, Background and Maintenance configure how queries of each priority class are scheduled.
+	// The query pool takes turns between the classes by weighted round-robin and limits the number of queries
+	// of each class that may be waiting for message responses at any one time.
+	Interactive query.PriorityConfig
+	Background  query.PriorityConfig
+	Maintenance query.PriorityConfig
+
+	// AdaptiveConcurrency specifies whether the number of queries in flight should be adjusted based on the
+	// utilization of the coordinator's event loop, and whether each query should adjust its number of concurrent
+	// requests based on the success rate and latency of its requests. Concurrency and RequestConcurrency are then
+	// the initial values.
+	AdaptiveConcurrency bool
+
+	// MaxConcurrency is the upper bound of the number of queries in flight if AdaptiveConcurrency is true.
+	MaxConcurrency int
+
+	// MaxRequestConcurrency is the upper bound of the number of concurrent requests of each query if
+	// AdaptiveConcurrency is true.
+	MaxRequestConcurrency int
+
+	// PreferScoredNodes specifies whether queries that do not use disjoint paths should contact the nodes that
+	// the routing behaviour scored highly before other nodes of similar distance. The scores only affect the
+	// order in which nodes are contacted, the results of queries remain the closest nodes to their target.
+	PreferScoredNodes bool
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -64,6 +104,13 @@ func (cfg *QueryConfig) Validate() error {
 		}
 	}
 
+	if cfg.Meter == nil {
+		return &errs.ConfigurationError{
+			Component: "PooledQueryConfig",
+			Err:       fmt.Errorf("meter must not be nil"),
+		}
+	}
+
 	if cfg.Concurrency < 1 {
 		return &errs.ConfigurationError{
 			Component: "PooledQueryConfig",
@@ -91,6 +138,64 @@ func (cfg *QueryConfig) Validate() error {
 		}
 	}
 
+	if err := cfg.Interactive.Validate(); err != nil {
+		return &errs.ConfigurationError{
+			Component: "PooledQueryConfig",
+			Err:       fmt.Errorf("interactive priority: %w", err),
+		}
+	}
+
+	if err := cfg.Background.Validate(); err != nil {
+		return &errs.ConfigurationError{
+			Component: "PooledQueryConfig",
+			Err:       fmt.Errorf("background priority: %w", err),
+		}
+	}
+
+	if err := cfg.Maintenance.Validate(); err != nil {
+		return &errs.ConfigurationError{
+			Component: "PooledQueryConfig",
+			Err:       fmt.Errorf("maintenance priority: %w", err),
+		}
+	}
+
+	if cfg.AdaptiveConcurrency && cfg.MaxConcurrency < cfg.Concurrency {
+		return &errs.ConfigurationError{
+			Component: "PooledQueryConfig",
+			Err:       fmt.Errorf("max concurrency must not be less than concurrency"),
+		}
+	}
+
+	if cfg.AdaptiveConcurrency && cfg.MaxRequestConcurrency < cfg.RequestConcurrency {
+		return &errs.ConfigurationError{
+			Component: "PooledQueryConfig",
+			Err:       fmt.Errorf("max request concurrency must not be less than request concurrency"),
+		}
+	}
+
+	if cfg.AdaptiveRequestTimeout {
+		if cfg.RequestTimeoutMin < 1 {
+			return &errs.ConfigurationError{
+				Component: "PooledQueryConfig",
+				Err:       fmt.Errorf("request timeout min must be greater than zero"),
+			}
+		}
+
+		if cfg.RequestTimeoutMin > cfg.RequestTimeout {
+			return &errs.ConfigurationError{
+				Component: "PooledQueryConfig",
+				Err:       fmt.Errorf("request timeout min must not be greater than request timeout"),
+			}
+		}
+
+		if cfg.RequestTimeoutPercentile <= 0 || cfg.RequestTimeoutPercentile > 1 {
+			return &errs.ConfigurationError{
+				Component: "PooledQueryConfig",
+				Err:       fmt.Errorf("request timeout percentile must be greater than zero and not greater than one"),
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -99,11 +204,25 @@ func DefaultQueryConfig() *QueryConfig {
 		Clock:              clock.New(),
 		Logger:             tele.DefaultLogger("coord"),
 		Tracer:             tele.NoopTracer(),
+		Meter:              tele.NoopMeter(),
 		Concurrency:        3,               // MAGIC
 		Timeout:            5 * time.Minute, // MAGIC
 		RequestConcurrency: 3,               // MAGIC
 		RequestTimeout:     time.Minute,     // MAGIC
 
+		Interactive: query.PriorityConfig{Weight: 4, Concurrency: 3}, // MAGIC
+		Background:  query.PriorityConfig{Weight: 2, Concurrency: 2}, // MAGIC
+		Maintenance: query.PriorityConfig{Weight: 1, Concurrency: 1}, // MAGIC
+
+		AdaptiveRequestTimeout:   false,
+		RequestTimeoutMin:        time.Second, // MAGIC
+		RequestTimeoutPercentile: 0.99,        // MAGIC
+
+		AdaptiveConcurrency:   false,
+		MaxConcurrency:        10, // MAGIC
+		MaxRequestConcurrency: 10, // MAGIC
+
+		PreferScoredNodes: false,
 	}
 }
 
@@ -152,6 +271,16 @@ func NewQueryBehaviour(self kadt.PeerID, cfg *QueryConfig) (*QueryBehaviour, err
 	qpCfg.Timeout = cfg.Timeout
 	qpCfg.QueryConcurrency = cfg.RequestConcurrency
 	qpCfg.RequestTimeout = cfg.RequestTimeout
+	qpCfg.AdaptiveRequestTimeout = cfg.AdaptiveRequestTimeout
+	qpCfg.RequestTimeoutMin = cfg.RequestTimeoutMin
+	qpCfg.RequestTimeoutPercentile = cfg.RequestTimeoutPercentile
+	qpCfg.Interactive = cfg.Interactive
+	qpCfg.Background = cfg.Background
+	qpCfg.Maintenance = cfg.Maintenance
+	qpCfg.Meter = cfg.Meter
+	qpCfg.AdaptiveConcurrency = cfg.AdaptiveConcurrency
+	qpCfg.MaxConcurrency = cfg.MaxConcurrency
+	qpCfg.MaxQueryConcurrency = cfg.MaxRequestConcurrency
 
 	pool, err := query.NewPool[kadt.Key, kadt.PeerID, *pb.Message](self, qpCfg)
 	if err != nil {
@@ -263,19 +392,31 @@ func (p *QueryBehaviour) perfomNextInbound(ctx context.Context) (BehaviourEvent,
 	switch ev := pev.Event.(type) {
 	case *EventStartFindCloserQuery:
 		cmd = &query.EventPoolAddFindCloserQuery[kadt.Key, kadt.PeerID]{
-			QueryID: ev.QueryID,
-			Target:  ev.Target,
-			Seed:    ev.KnownClosestNodes,
+			QueryID:        ev.QueryID,
+			Target:         ev.Target,
+			Seed:           ev.KnownClosestNodes,
+			NumResults:     ev.NumResults,
+			DisjointPaths:  ev.DisjointPaths,
+			Priority:       ev.Priority,
+			Termination:    ev.Termination,
+			Concurrency:    ev.Concurrency,
+			RequestTimeout: ev.RequestTimeout,
 		}
 		if ev.Notify != nil {
 			p.notifiers[ev.QueryID] = &queryNotifier[*EventQueryFinished]{monitor: ev.Notify}
 		}
 	case *EventStartMessageQuery:
 		cmd = &query.EventPoolAddQuery[kadt.Key, kadt.PeerID, *pb.Message]{
-			QueryID: ev.QueryID,
-			Target:  ev.Target,
-			Message: ev.Message,
-			Seed:    ev.KnownClosestNodes,
+			QueryID:        ev.QueryID,
+			Target:         ev.Target,
+			Message:        ev.Message,
+			Seed:           ev.KnownClosestNodes,
+			NumResults:     ev.NumResults,
+			DisjointPaths:  ev.DisjointPaths,
+			Priority:       ev.Priority,
+			Termination:    ev.Termination,
+			Concurrency:    ev.Concurrency,
+			RequestTimeout: ev.RequestTimeout,
 		}
 		if ev.Notify != nil {
 			p.notifiers[ev.QueryID] = &queryNotifier[*EventQueryFinished]{monitor: ev.Notify}
@@ -284,8 +425,13 @@ func (p *QueryBehaviour) perfomNextInbound(ctx context.Context) (BehaviourEvent,
 		cmd = &query.EventPoolStopQuery{
 			QueryID: ev.QueryID,
 		}
+	case *EventNotifyLoad:
+		cmd = &query.EventPoolLoad{
+			Utilization: ev.Utilization,
+		}
 	case *EventGetCloserNodesSuccess:
 		p.queueAddNodeEvents(ev.CloserNodes)
+		p.queueResponseEvent(ev.To, ev.RTT)
 		waiter, ok := p.notifiers[ev.QueryID]
 		if ok {
 			waiter.TryNotifyProgressed(ctx, &EventQueryProgressed{
@@ -299,6 +445,7 @@ func (p *QueryBehaviour) perfomNextInbound(ctx context.Context) (BehaviourEvent,
 			NodeID:      ev.To,
 			QueryID:     ev.QueryID,
 			CloserNodes: ev.CloserNodes,
+			RTT:         ev.RTT,
 		}
 	case *EventGetCloserNodesFailure:
 		// queue an event that will notify the routing behaviour of a failed node
@@ -312,6 +459,7 @@ func (p *QueryBehaviour) perfomNextInbound(ctx context.Context) (BehaviourEvent,
 		}
 	case *EventSendMessageSuccess:
 		p.queueAddNodeEvents(ev.CloserNodes)
+		p.queueResponseEvent(ev.To, ev.RTT)
 		waiter, ok := p.notifiers[ev.QueryID]
 		if ok {
 			waiter.TryNotifyProgressed(ctx, &EventQueryProgressed{
@@ -324,6 +472,7 @@ func (p *QueryBehaviour) perfomNextInbound(ctx context.Context) (BehaviourEvent,
 			NodeID:      ev.To,
 			QueryID:     ev.QueryID,
 			CloserNodes: ev.CloserNodes,
+			RTT:         ev.RTT,
 		}
 	case *EventSendMessageFailure:
 		// queue an event that will notify the routing behaviour of a failed node
@@ -430,6 +579,21 @@ func (p *QueryBehaviour) queueNonConnectivityEvent(nid kadt.PeerID) {
 	})
 }
 
+func (p *QueryBehaviour) queueResponseEvent(nid kadt.PeerID, rtt time.Duration) {
+	p.pendingOutbound = append(p.pendingOutbound, &EventNotifyResponse{
+		NodeID: nid,
+		RTT:    rtt,
+	})
+}
+
+// SetNodeScorer sets the scorer that queries started afterwards use to contact more useful nodes first among
+// nodes of similar distance from their target. It is only called if [QueryConfig.PreferScoredNodes] is true.
+func (p *QueryBehaviour) SetNodeScorer(s query.NodeScorer[kadt.Key, kadt.PeerID]) {
+	p.performMu.Lock()
+	defer p.performMu.Unlock()
+	p.pool.SetNodeScorer(s)
+}
+
 type queryNotifier[E TerminalQueryEvent] struct {
 	monitor  QueryMonitor[E]
 	pending  []CtxEvent[*EventQueryProgressed]
@@ -0,0 +1,92 @@
+package query
+
+import (
+	"time"
+)
+
+// The parameters of the concurrency limit controller.
+const (
+	// concurrencyBackoff is the factor the limit is multiplied with when a request fails or the system is overloaded.
+	concurrencyBackoff = 0.5
+
+	// concurrencyLatencyFactor is the multiple of the lowest observed round-trip time above which a response is
+	// considered slow. Slow responses indicate that more concurrent requests would only queue up.
+	concurrencyLatencyFactor = 2
+)
+
+// concurrencyLimit adjusts a concurrency limit in the same way that TCP congestion control adjusts its
+// congestion window: the limit grows by one after a full window of requests succeeded quickly and is halved
+// when a request fails. A full window of responses that took much longer than the fastest response seen so
+// far shrinks the limit by one, so the limit settles at the point where more concurrency stops paying off.
+type concurrencyLimit struct {
+	min    int
+	max    int
+	window int
+
+	// credit counts the fast responses, minus the slow responses, since the window last changed.
+	credit int
+
+	// baseRTT is the lowest round-trip time observed so far, zero if none was observed.
+	baseRTT time.Duration
+}
+
+func newConcurrencyLimit(initial, min, max int) *concurrencyLimit {
+	l := &concurrencyLimit{
+		min:    min,
+		max:    max,
+		window: initial,
+	}
+	l.clamp()
+	return l
+}
+
+// limit returns the current concurrency limit.
+func (l *concurrencyLimit) limit() int {
+	return l.window
+}
+
+// onSuccess adjusts the limit after a request succeeded with the given round-trip time. A round-trip time
+// of zero means that it is unknown and the response is considered fast.
+func (l *concurrencyLimit) onSuccess(rtt time.Duration) {
+	if rtt > 0 && (l.baseRTT == 0 || rtt < l.baseRTT) {
+		l.baseRTT = rtt
+	}
+
+	if rtt > concurrencyLatencyFactor*l.baseRTT {
+		l.credit--
+		if l.credit <= -l.window {
+			l.resize(l.window - 1)
+		}
+		return
+	}
+
+	l.credit++
+	if l.credit >= l.window {
+		l.resize(l.window + 1)
+	}
+}
+
+// onFailure adjusts the limit after a request failed or timed out.
+func (l *concurrencyLimit) onFailure() {
+	l.resize(int(float64(l.window) * concurrencyBackoff))
+}
+
+// grow raises the limit by one.
+func (l *concurrencyLimit) grow() {
+	l.resize(l.window + 1)
+}
+
+func (l *concurrencyLimit) resize(window int) {
+	l.window = window
+	l.credit = 0
+	l.clamp()
+}
+
+func (l *concurrencyLimit) clamp() {
+	if l.window < l.min {
+		l.window = l.min
+	}
+	if l.window > l.max {
+		l.window = l.max
+	}
+}
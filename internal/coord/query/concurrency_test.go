@@ -0,0 +1,55 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	l := newConcurrencyLimit(2, 1, 4)
+	require.Equal(t, 2, l.limit())
+
+	// a full window of fast responses raises the limit by one
+	l.onSuccess(10 * time.Millisecond)
+	l.onSuccess(10 * time.Millisecond)
+	require.Equal(t, 3, l.limit())
+
+	// a failure halves the limit
+	l.onFailure()
+	require.Equal(t, 1, l.limit())
+
+	// the limit never drops below the minimum
+	l.onFailure()
+	require.Equal(t, 1, l.limit())
+}
+
+func TestConcurrencyLimitSlowResponses(t *testing.T) {
+	l := newConcurrencyLimit(3, 1, 4)
+
+	l.onSuccess(10 * time.Millisecond)
+	require.Equal(t, 3, l.limit())
+
+	// a full window of responses that take much longer than the fastest response shrinks the limit
+	for i := 0; i < 4; i++ {
+		l.onSuccess(50 * time.Millisecond)
+	}
+	require.Equal(t, 2, l.limit())
+
+	// responses with an unknown round-trip time count as fast
+	l.onSuccess(0)
+	l.onSuccess(0)
+	require.Equal(t, 3, l.limit())
+}
+
+func TestConcurrencyLimitBounds(t *testing.T) {
+	l := newConcurrencyLimit(8, 1, 4)
+	require.Equal(t, 4, l.limit())
+
+	l.grow()
+	require.Equal(t, 4, l.limit())
+
+	l = newConcurrencyLimit(0, 1, 4)
+	require.Equal(t, 1, l.limit())
+}
@@ -0,0 +1,313 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plprobelab/go-libdht/kad"
+	"github.com/plprobelab/go-libdht/kad/key"
+	"github.com/plprobelab/go-libdht/kad/trie"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/tele"
+)
+
+// A DisjointQuery performs an S/Kademlia style lookup over a number of disjoint
+// paths. The seed nodes are distributed across the paths, and each path runs
+// its own iterative [Query] with its own [NodeIter]. A node is only ever
+// contacted by a single path: closer nodes that are returned to a path are
+// discarded if another path already knows about them. Once all paths have
+// finished, the closest nodes found across all paths form the result.
+//
+// This limits the influence that a single malicious node can have on the
+// outcome of a lookup to the path that it was discovered on.
+//
+// Each path consults the [QueryConfig.Termination] policy with the combined
+// statistics of all paths, so that a budget applies to the query as a whole.
+type DisjointQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message] struct {
+	self N
+	id   coordt.QueryID
+
+	// cfg is a copy of the optional configuration supplied to the query
+	cfg QueryConfig
+
+	target K
+
+	// paths holds the independent queries, one per disjoint path.
+	paths []*Query[K, N, M]
+
+	// owners maps the key of every node that any path knows about to the
+	// index of that path in paths.
+	owners *trie.Trie[K, int]
+
+	// next is the index of the path that is polled first on the next advance.
+	next int
+
+	// finished indicates that the query has completed its work or has been stopped.
+	finished bool
+
+	// stats holds the statistics of all paths combined. It is only valid
+	// once the query has been marked as finished.
+	stats QueryStats
+
+	// targetNodes is the set of responsive nodes thought to be closest to the target
+	// across all paths. It is populated once the query has been marked as finished.
+	// This will contain up to [QueryConfig.NumResults] nodes.
+	targetNodes []N
+}
+
+// NewDisjointFindCloserQuery creates a new [DisjointQuery] that finds closer nodes to the target
+// over the given number of disjoint paths.
+func NewDisjointFindCloserQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, id coordt.QueryID, target K, paths int, knownClosestNodes []N, cfg *QueryConfig) (*DisjointQuery[K, N, M], error) {
+	var empty M
+	return newDisjointQuery[K, N, M](self, id, target, empty, true, paths, knownClosestNodes, cfg)
+}
+
+// NewDisjointQuery creates a new [DisjointQuery] that sends the message to the nodes that it visits
+// over the given number of disjoint paths.
+func NewDisjointQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, id coordt.QueryID, target K, msg M, paths int, knownClosestNodes []N, cfg *QueryConfig) (*DisjointQuery[K, N, M], error) {
+	return newDisjointQuery[K, N, M](self, id, target, msg, false, paths, knownClosestNodes, cfg)
+}
+
+func newDisjointQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, id coordt.QueryID, target K, msg M, findCloser bool, paths int, knownClosestNodes []N, cfg *QueryConfig) (*DisjointQuery[K, N, M], error) {
+	if cfg == nil {
+		cfg = DefaultQueryConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if paths < 1 {
+		return nil, fmt.Errorf("number of paths must be greater than zero")
+	}
+
+	q := &DisjointQuery[K, N, M]{
+		self:   self,
+		id:     id,
+		cfg:    *cfg,
+		target: target,
+		paths:  make([]*Query[K, N, M], paths),
+		owners: trie.New[K, int](),
+	}
+
+	// distribute the seed nodes in order of their distance to the target
+	// across all paths so that every path starts with nodes that are about as
+	// close to the target as the seed nodes of every other path.
+	seeds := trie.New[K, N]()
+	for _, node := range knownClosestNodes {
+		// exclude self from closest nodes
+		if key.Equal(node.Key(), self.Key()) {
+			continue
+		}
+		seeds.Add(node.Key(), node)
+	}
+
+	pathSeeds := make([][]N, paths)
+	for i, e := range trie.Closest(seeds, target, seeds.Size()) {
+		idx := i % paths
+		pathSeeds[idx] = append(pathSeeds[idx], e.Data)
+		q.owners.Add(e.Key, idx)
+	}
+
+	for i := range q.paths {
+		path, err := NewQuery[K, N, M](self, id, target, msg, NewClosestNodesIter[K, N](target), pathSeeds[i], cfg)
+		if err != nil {
+			return nil, fmt.Errorf("new path query: %w", err)
+		}
+		path.findCloser = findCloser
+		path.termStats = q.combinedStats
+		q.paths[i] = path
+	}
+
+	return q, nil
+}
+
+func (q *DisjointQuery[K, N, M]) Advance(ctx context.Context, ev QueryEvent) (out QueryState) {
+	ctx, span := tele.StartSpan(ctx, "DisjointQuery.Advance", trace.WithAttributes(tele.AttrInEvent(ev)))
+	defer func() {
+		span.SetAttributes(tele.AttrOutEvent(out))
+		span.End()
+	}()
+
+	if q.finished {
+		return &StateQueryFinished[K, N]{
+			QueryID:      q.id,
+			Stats:        q.stats,
+			ClosestNodes: q.targetNodes,
+		}
+	}
+
+	switch tev := ev.(type) {
+	case *EventQueryCancel:
+		for _, path := range q.paths {
+			path.Advance(ctx, tev)
+		}
+		q.markFinished()
+		return &StateQueryFinished[K, N]{
+			QueryID:      q.id,
+			Stats:        q.stats,
+			ClosestNodes: q.targetNodes,
+		}
+	case *EventQueryNodeResponse[K, N]:
+		found, idx := trie.Find(q.owners, tev.NodeID.Key())
+		if !found {
+			// got a rogue message
+			break
+		}
+		state := q.paths[idx].Advance(ctx, &EventQueryNodeResponse[K, N]{
+			NodeID:      tev.NodeID,
+			CloserNodes: q.claimCloserNodes(idx, tev.CloserNodes),
+			RTT:         tev.RTT,
+		})
+		if q.isRequest(state) {
+			return state
+		}
+	case *EventQueryNodeFailure[K, N]:
+		span.RecordError(tev.Error)
+		found, idx := trie.Find(q.owners, tev.NodeID.Key())
+		if !found {
+			// got a rogue message
+			break
+		}
+		state := q.paths[idx].Advance(ctx, tev)
+		if q.isRequest(state) {
+			return state
+		}
+	case *EventQueryPoll:
+		// no event to process
+	default:
+		panic(fmt.Sprintf("unexpected event: %T", tev))
+	}
+
+	// poll all paths, starting with a different path each time so that no path
+	// can starve the others.
+	finished := 0
+	atCapacity := 0
+	for i := 0; i < len(q.paths); i++ {
+		idx := (q.next + i) % len(q.paths)
+		state := q.paths[idx].Advance(ctx, &EventQueryPoll{})
+		if q.isRequest(state) {
+			q.next = (idx + 1) % len(q.paths)
+			return state
+		}
+
+		switch state.(type) {
+		case *StateQueryFinished[K, N]:
+			finished++
+		case *StateQueryWaitingAtCapacity:
+			atCapacity++
+		}
+	}
+
+	if finished == len(q.paths) {
+		q.markFinished()
+		return &StateQueryFinished[K, N]{
+			QueryID:      q.id,
+			Stats:        q.stats,
+			ClosestNodes: q.targetNodes,
+		}
+	}
+
+	if finished+atCapacity == len(q.paths) {
+		return &StateQueryWaitingAtCapacity{
+			QueryID: q.id,
+			Stats:   q.combinedStats(),
+		}
+	}
+
+	return &StateQueryWaitingWithCapacity{
+		QueryID: q.id,
+		Stats:   q.combinedStats(),
+	}
+}
+
+// claimCloserNodes returns the closer nodes that the path with the given index
+// may use and records that path as their owner. Nodes that are already owned
+// by another path are discarded.
+func (q *DisjointQuery[K, N, M]) claimCloserNodes(idx int, closer []N) []N {
+	claimed := make([]N, 0, len(closer))
+	for _, node := range closer {
+		found, owner := trie.Find(q.owners, node.Key())
+		if found && owner != idx {
+			continue
+		}
+		q.owners.Add(node.Key(), idx)
+		claimed = append(claimed, node)
+	}
+	return claimed
+}
+
+// isRequest reports whether the given path state asks to contact a node. If
+// so, it replaces the stats of the path in the state with the combined stats
+// of all paths.
+func (q *DisjointQuery[K, N, M]) isRequest(state QueryState) bool {
+	switch st := state.(type) {
+	case *StateQueryFindCloser[K, N]:
+		st.Stats = q.combinedStats()
+		return true
+	case *StateQuerySendMessage[K, N, M]:
+		st.Stats = q.combinedStats()
+		return true
+	default:
+		return false
+	}
+}
+
+// combinedStats returns the sum of the statistics of all paths.
+func (q *DisjointQuery[K, N, M]) combinedStats() QueryStats {
+	var stats QueryStats
+	for _, path := range q.paths {
+		if !path.stats.Start.IsZero() && (stats.Start.IsZero() || path.stats.Start.Before(stats.Start)) {
+			stats.Start = path.stats.Start
+		}
+		stats.Requests += path.stats.Requests
+		stats.Success += path.stats.Success
+		stats.Failure += path.stats.Failure
+		stats.Escalations += path.stats.Escalations
+	}
+	return stats
+}
+
+func (q *DisjointQuery[K, N, M]) markFinished() {
+	q.finished = true
+	q.stats = q.combinedStats()
+	q.stats.End = q.cfg.Clock.Now()
+
+	// merge the closest nodes of all paths
+	closest := trie.New[K, N]()
+	for _, path := range q.paths {
+		for _, node := range path.targetNodes {
+			closest.Add(node.Key(), node)
+		}
+	}
+
+	q.targetNodes = make([]N, 0, q.cfg.NumResults)
+	for _, e := range trie.Closest(closest, q.target, q.cfg.NumResults) {
+		q.targetNodes = append(q.targetNodes, e.Data)
+	}
+}
+
+func (q *DisjointQuery[K, N, M]) setRTTEstimator(rtt *RTTEstimator[K]) {
+	for _, path := range q.paths {
+		path.setRTTEstimator(rtt)
+	}
+}
+
+func (q *DisjointQuery[K, N, M]) queryID() coordt.QueryID {
+	return q.id
+}
+
+func (q *DisjointQuery[K, N, M]) startTime() time.Time {
+	return q.combinedStats().Start
+}
+
+// waiting reports whether any path is waiting for message responses.
+func (q *DisjointQuery[K, N, M]) waiting() bool {
+	for _, path := range q.paths {
+		if path.waiting() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/tiny"
+)
+
+func TestDisjointQuery_paths(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000001) // 1
+	b := tiny.NewNode(0b00000010) // 2
+	c := tiny.NewNode(0b00000100) // 4
+	d := tiny.NewNode(0b00001000) // 8
+	e := tiny.NewNode(0b00000011) // 3
+
+	// known nodes are in "random" order
+	knownNodes := []tiny.Node{d, b, c, a}
+
+	clk := clock.NewMock()
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.NumResults = 2
+
+	queryID := coordt.QueryID("test")
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewDisjointFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, queryID, target, 2, knownNodes, cfg)
+	require.NoError(t, err)
+
+	// the seeds are distributed by distance: the first path starts with a and c
+	// while the second path starts with b and d.
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateQueryFindCloser[tiny.Key, tiny.Node])
+	require.Equal(t, b, st.NodeID)
+	require.Equal(t, queryID, st.QueryID)
+	require.Equal(t, 2, st.Stats.Requests) // stats are combined across paths
+
+	// both paths have a request in flight
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+
+	// a returns b, which belongs to the other path, and the new node e
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{
+		NodeID:      a,
+		CloserNodes: []tiny.Node{b, e},
+	})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, e, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// b returns e, which now belongs to the first path, so the second path
+	// continues with its own seed d
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{
+		NodeID:      b,
+		CloserNodes: []tiny.Node{e},
+	})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, d, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the first path has found enough nodes and finishes, the second path is still waiting.
+	// Since b didn't return a closer node, the second path stalled and raised its capacity.
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{
+		NodeID: e,
+	})
+	require.IsType(t, &StateQueryWaitingWithCapacity{}, state)
+
+	// the second path finishes, so the query finishes
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{
+		NodeID: d,
+	})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	stf := state.(*StateQueryFinished[tiny.Key, tiny.Node])
+	require.Equal(t, []tiny.Node{a, b}, stf.ClosestNodes) // merged across paths
+	require.Equal(t, 4, stf.Stats.Requests)
+	require.Equal(t, 4, stf.Stats.Success)
+	require.Equal(t, 0, stf.Stats.Failure)
+}
+
+func TestDisjointQuery_failure(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000001)
+	b := tiny.NewNode(0b00000010)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.NumResults = 1
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewDisjointFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, 2, []tiny.Node{a, b}, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.Equal(t, b, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// a failure only affects the path of the failed node
+	state = qry.Advance(ctx, &EventQueryNodeFailure[tiny.Key, tiny.Node]{NodeID: a})
+	require.IsType(t, &StateQueryWaitingWithCapacity{}, state)
+
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: b})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	stf := state.(*StateQueryFinished[tiny.Key, tiny.Node])
+	require.Equal(t, []tiny.Node{b}, stf.ClosestNodes)
+	require.Equal(t, 1, stf.Stats.Failure)
+	require.Equal(t, 1, stf.Stats.Success)
+}
+
+func TestDisjointQuery_cancel(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000001)
+	b := tiny.NewNode(0b00000010)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clock.NewMock()
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewDisjointQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, tiny.Message{}, 2, []tiny.Node{a, b}, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQuerySendMessage[tiny.Key, tiny.Node, tiny.Message]{}, state)
+
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: a})
+	require.IsType(t, &StateQuerySendMessage[tiny.Key, tiny.Node, tiny.Message]{}, state)
+	require.Equal(t, b, state.(*StateQuerySendMessage[tiny.Key, tiny.Node, tiny.Message]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryCancel{})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, []tiny.Node{a}, state.(*StateQueryFinished[tiny.Key, tiny.Node]).ClosestNodes)
+
+	// once finished, the query remains finished
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+}
+
+func TestDisjointQuery_requestBudget(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000001) // 1
+	b := tiny.NewNode(0b00000010) // 2
+	c := tiny.NewNode(0b00000100) // 4
+	d := tiny.NewNode(0b00001000) // 8
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 1
+	cfg.Termination = RequestBudgetPolicy{Requests: 3}
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewDisjointFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, 2, []tiny.Node{a, b, c, d}, cfg)
+	require.NoError(t, err)
+
+	// each path contacts its first seed
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, b, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the first path uses the last request of the budget
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: a})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, c, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the second path doesn't contact d since the budget is spent across both paths
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: b})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, 3, state.(*StateQueryFinished[tiny.Key, tiny.Node]).Stats.Requests)
+}
+
+func TestDisjointQuery_combinedStats(t *testing.T) {
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000001)
+	b := tiny.NewNode(0b00000010)
+	c := tiny.NewNode(0b00000100)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clock.NewMock()
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewDisjointQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, tiny.Message{}, 3, []tiny.Node{a, b, c}, cfg)
+	require.NoError(t, err)
+	require.Len(t, qry.paths, 3)
+
+	start := time.Unix(1000, 0)
+	pathStats := []QueryStats{
+		{Start: start.Add(2 * time.Second), Requests: 4, Success: 3, Failure: 1, Escalations: 1},
+		{Start: start, Requests: 5, Success: 2, Failure: 2, Escalations: 0},
+		{Requests: 0, Success: 0, Failure: 0, Escalations: 0}, // a path that hasn't started yet
+	}
+	for i, st := range pathStats {
+		qry.paths[i].stats = st
+	}
+
+	stats := qry.combinedStats()
+
+	// the combined query started when its first path started
+	require.Equal(t, start, stats.Start)
+
+	// the end is recorded when the combined query finishes, see TestDisjointQuery_cancel
+	require.True(t, stats.End.IsZero())
+
+	// counters are summed over all paths
+	require.Equal(t, 9, stats.Requests)
+	require.Equal(t, 5, stats.Success)
+	require.Equal(t, 3, stats.Failure)
+	require.Equal(t, 1, stats.Escalations)
+}
+
+func TestDisjointQuery_invalid_paths(t *testing.T) {
+	self := tiny.NewNode(0)
+	_, err := NewDisjointFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", tiny.Key(0), 0, nil, nil)
+	require.Error(t, err)
+}
@@ -5,7 +5,6 @@ import (
 
 	"github.com/plprobelab/go-libdht/kad"
 	"github.com/plprobelab/go-libdht/kad/key"
-	"github.com/plprobelab/go-libdht/kad/trie"
 )
 
 // A NodeIter iterates nodes according to some strategy.
@@ -21,45 +20,146 @@ type NodeIter[K kad.Key[K], N kad.NodeID[K]] interface {
 	Each(ctx context.Context, fn func(context.Context, *NodeStatus[K, N]) bool) bool
 }
 
-// A ClosestNodesIter iterates nodes in order of ascending distance from a key.
+// nodePreferrer is implemented by iterators that may want a query to contact a different node than the
+// closest node that has not been contacted yet. See [ClosestNodesIter.Prefer].
+type nodePreferrer[K kad.Key[K], N kad.NodeID[K]] interface {
+	Prefer(*NodeStatus[K, N]) *NodeStatus[K, N]
+}
+
+// A NodeScorer scores the utility of nodes. Nodes with a higher score have proven to be more useful, for
+// example because they respond quickly and reliably.
+type NodeScorer[K kad.Key[K], N kad.NodeID[K]] interface {
+	// Score returns the score of the node and whether the node is known to the scorer.
+	Score(N) (float64, bool)
+}
+
+// A ClosestNodesIter iterates nodes in order of ascending distance from a key. The nodes are kept sorted by
+// their distance as they are added, so iterating over them neither sorts nor allocates.
+//
+// An iterator created with [NewScoredClosestNodesIter] additionally records the score of each node. The
+// scores never change the order of iteration, they are only consulted by [ClosestNodesIter.Prefer] to choose
+// which of several nodes of similar distance should be contacted next.
 type ClosestNodesIter[K kad.Key[K], N kad.NodeID[K]] struct {
 	// target is the key whose distance to a node determines the position of that node in the iterator.
 	target K
 
-	// nodelist holds the nodes discovered so far, ordered by increasing distance from the target.
-	nodes *trie.Trie[K, *NodeStatus[K, N]]
+	// nodes holds the nodes discovered so far, ordered by increasing distance from the target.
+	nodes []closestNode[K, N]
+
+	// scorer is the optional scorer used to choose between nodes of similar distance. If it is nil, nodes are
+	// contacted strictly in order of distance.
+	scorer NodeScorer[K, N]
+}
+
+// closestNode is a node held by a [ClosestNodesIter] together with its distance from the target.
+type closestNode[K kad.Key[K], N kad.NodeID[K]] struct {
+	distance K
+	cpl      int     // the common prefix length of the node's key and the target
+	score    float64 // the score of the node when it was added, zero if there is no scorer
+	status   *NodeStatus[K, N]
 }
 
 // NewClosestNodesIter creates a new ClosestNodesIter
 func NewClosestNodesIter[K kad.Key[K], N kad.NodeID[K]](target K) *ClosestNodesIter[K, N] {
 	return &ClosestNodesIter[K, N]{
 		target: target,
-		nodes:  trie.New[K, *NodeStatus[K, N]](),
 	}
 }
 
+// NewScoredClosestNodesIter creates a new ClosestNodesIter that uses scorer to choose which of several nodes
+// of similar distance should be contacted next. Nodes unknown to the scorer have a score of zero.
+func NewScoredClosestNodesIter[K kad.Key[K], N kad.NodeID[K]](target K, scorer NodeScorer[K, N]) *ClosestNodesIter[K, N] {
+	return &ClosestNodesIter[K, N]{
+		target: target,
+		scorer: scorer,
+	}
+}
+
+// Add adds node information to the iterator. It is ignored if the iterator already holds information for
+// a node with the same key.
 func (iter *ClosestNodesIter[K, N]) Add(ni *NodeStatus[K, N]) {
-	iter.nodes.Add(ni.NodeID.Key(), ni)
+	cn := closestNode[K, N]{
+		distance: iter.target.Xor(ni.NodeID.Key()),
+		cpl:      iter.target.CommonPrefixLength(ni.NodeID.Key()),
+		status:   ni,
+	}
+
+	i, found := iter.search(cn.distance)
+	if found {
+		return
+	}
+
+	if iter.scorer != nil {
+		cn.score, _ = iter.scorer.Score(ni.NodeID)
+	}
+
+	// insert the node at its position, shifting the nodes that are further away
+	var zero closestNode[K, N]
+	iter.nodes = append(iter.nodes, zero)
+	copy(iter.nodes[i+1:], iter.nodes[i:])
+	iter.nodes[i] = cn
 }
 
 func (iter *ClosestNodesIter[K, N]) Find(k K) (*NodeStatus[K, N], bool) {
-	found, ni := trie.Find(iter.nodes, k)
-	return ni, found
+	i, found := iter.search(iter.target.Xor(k))
+	if !found {
+		return nil, false
+	}
+	return iter.nodes[i].status, true
 }
 
 func (iter *ClosestNodesIter[K, N]) Each(ctx context.Context, fn func(context.Context, *NodeStatus[K, N]) bool) bool {
-	// get all the nodes in order of distance from the target
-	// TODO: turn this into a walk or iterator on trie.Trie
-	entries := trie.Closest(iter.nodes, iter.target, iter.nodes.Size())
-	for _, e := range entries {
-		ni := e.Data
-		if fn(ctx, ni) {
+	for i := range iter.nodes {
+		if fn(ctx, iter.nodes[i].status) {
 			return true
 		}
 	}
 	return false
 }
 
+// Prefer returns the node that should be contacted instead of ni, which must be the closest node held by the
+// iterator that has not been contacted yet. Without a scorer Prefer returns ni. Otherwise it returns the
+// not yet contacted node with the highest score among the nodes whose keys share as many leading bits with
+// the target as the key of ni. Nodes with equal scores are preferred by distance.
+func (iter *ClosestNodesIter[K, N]) Prefer(ni *NodeStatus[K, N]) *NodeStatus[K, N] {
+	if iter.scorer == nil {
+		return ni
+	}
+
+	i, found := iter.search(iter.target.Xor(ni.NodeID.Key()))
+	if !found {
+		return ni
+	}
+
+	best := i
+	for j := i + 1; j < len(iter.nodes) && iter.nodes[j].cpl == iter.nodes[i].cpl; j++ {
+		if _, ok := iter.nodes[j].status.State.(*StateNodeNotContacted); !ok {
+			continue
+		}
+		if iter.nodes[j].score > iter.nodes[best].score {
+			best = j
+		}
+	}
+
+	return iter.nodes[best].status
+}
+
+// search returns the position of the first node that is not closer to the target than the given distance,
+// and whether that node has the same distance. Since the distance uniquely identifies a key, a node with an
+// equal distance has the same key as the one searched for.
+func (iter *ClosestNodesIter[K, N]) search(distance K) (int, bool) {
+	lo, hi := 0, len(iter.nodes)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if iter.nodes[mid].distance.Compare(distance) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(iter.nodes) && iter.nodes[lo].distance.Compare(distance) == 0
+}
+
 // A SequentialIter iterates nodes in the order they were added to the iterator.
 type SequentialIter[K kad.Key[K], N kad.NodeID[K]] struct {
 	// nodelist holds the nodes discovered so far, ordered by increasing distance from the target.
@@ -2,12 +2,15 @@ package query
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/plprobelab/go-libdht/kad/key"
+	"github.com/plprobelab/go-libdht/kad/trie"
 	"github.com/stretchr/testify/require"
 
 	"github.com/plprobelab/zikade/internal/tiny"
+	"github.com/plprobelab/zikade/kadt"
 )
 
 var (
@@ -76,3 +79,207 @@ func TestSequentialIter(t *testing.T) {
 	require.True(t, key.Equal(order[2], a.Key()))
 	require.True(t, key.Equal(order[3], c.Key()))
 }
+
+func TestClosestNodesIterFind(t *testing.T) {
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100)
+	b := tiny.NewNode(0b00001000)
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	nsa := &NodeStatus[tiny.Key, tiny.Node]{NodeID: a}
+	iter.Add(nsa)
+
+	found, ok := iter.Find(a.Key())
+	require.True(t, ok)
+	require.Same(t, nsa, found)
+
+	_, ok = iter.Find(b.Key())
+	require.False(t, ok)
+
+	// adding a node with the same key keeps the original node information
+	iter.Add(&NodeStatus[tiny.Key, tiny.Node]{NodeID: a})
+	found, ok = iter.Find(a.Key())
+	require.True(t, ok)
+	require.Same(t, nsa, found)
+
+	count := 0
+	iter.Each(context.Background(), func(ctx context.Context, ns *NodeStatus[tiny.Key, tiny.Node]) bool {
+		count++
+		return false
+	})
+	require.Equal(t, 1, count)
+}
+
+func TestClosestNodesIterEachStops(t *testing.T) {
+	target := tiny.Key(0b00000001)
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+	for _, k := range []tiny.Key{0b00100000, 0b00000100, 0b00010000, 0b00001000} {
+		iter.Add(&NodeStatus[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(k)})
+	}
+
+	var visited []tiny.Key
+	stopped := iter.Each(context.Background(), func(ctx context.Context, ns *NodeStatus[tiny.Key, tiny.Node]) bool {
+		visited = append(visited, ns.NodeID.Key())
+		return len(visited) == 2
+	})
+	require.True(t, stopped)
+	require.Equal(t, []tiny.Key{0b00000100, 0b00001000}, visited)
+}
+
+func TestClosestNodesIterEachDoesNotAllocate(t *testing.T) {
+	iter, _ := newBenchmarkIter(500)
+
+	ctx := context.Background()
+	visited := 0
+	fn := func(ctx context.Context, ns *NodeStatus[kadt.Key, kadt.PeerID]) bool {
+		visited++
+		return false
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		iter.Each(ctx, fn)
+	})
+	require.Zero(t, allocs)
+	require.Equal(t, 101*500, visited)
+}
+
+// mapScorer is a [NodeScorer] that looks up the scores of nodes in a map.
+type mapScorer map[tiny.Key]float64
+
+func (m mapScorer) Score(n tiny.Node) (float64, bool) {
+	score, found := m[n.Key()]
+	return score, found
+}
+
+func TestScoredClosestNodesIter(t *testing.T) {
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00000101) // 5
+	c := tiny.NewNode(0b00000110) // 6
+	d := tiny.NewNode(0b00010000) // 16
+
+	// a is unknown to the scorer
+	scorer := mapScorer{
+		b.Key(): 0.5,
+		c.Key(): 0.9,
+		d.Key(): 1.0,
+	}
+
+	iter := NewScoredClosestNodesIter[tiny.Key, tiny.Node](target, scorer)
+	nodes := make(map[tiny.Key]*NodeStatus[tiny.Key, tiny.Node])
+	for _, n := range []tiny.Node{d, a, b, c} {
+		nodes[n.Key()] = &NodeStatus[tiny.Key, tiny.Node]{NodeID: n, State: &StateNodeNotContacted{}}
+		iter.Add(nodes[n.Key()])
+	}
+	iter.Add(&NodeStatus[tiny.Key, tiny.Node]{NodeID: b, State: &StateNodeNotContacted{}})
+
+	// the scores don't change the order of iteration, nodes are always ordered by distance
+	order := make([]tiny.Key, 0, 4)
+	iter.Each(context.Background(), func(ctx context.Context, ns *NodeStatus[tiny.Key, tiny.Node]) bool {
+		order = append(order, ns.NodeID.Key())
+		return false
+	})
+	require.Equal(t, []tiny.Key{a.Key(), b.Key(), c.Key(), d.Key()}, order)
+
+	// a, b and c are roughly as far from the target as each other, c has the highest score among them and is
+	// preferred, d is further away and not considered despite its higher score
+	require.Equal(t, c.Key(), iter.Prefer(nodes[a.Key()]).NodeID.Key())
+
+	// once c has been contacted, b is preferred over a
+	nodes[c.Key()].State = &StateNodeWaiting{}
+	require.Equal(t, b.Key(), iter.Prefer(nodes[a.Key()]).NodeID.Key())
+
+	// d is the only node with its common prefix length
+	require.Equal(t, d.Key(), iter.Prefer(nodes[d.Key()]).NodeID.Key())
+
+	// without a scorer the closest node is contacted
+	plain := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+	plain.Add(nodes[a.Key()])
+	plain.Add(nodes[c.Key()])
+	require.Equal(t, a.Key(), plain.Prefer(nodes[a.Key()]).NodeID.Key())
+
+	for _, n := range []tiny.Node{a, b, c, d} {
+		ns, found := iter.Find(n.Key())
+		require.True(t, found)
+		require.True(t, key.Equal(n.Key(), ns.NodeID.Key()))
+	}
+
+	_, found := iter.Find(tiny.Key(0b00000111))
+	require.False(t, found)
+}
+
+// newBenchmarkIter returns an iterator that holds n nodes with random keys and the nodes themselves.
+func newBenchmarkIter(n int) (*ClosestNodesIter[kadt.Key, kadt.PeerID], []*NodeStatus[kadt.Key, kadt.PeerID]) {
+	target := kadt.NewKey([]byte("target"))
+	iter := NewClosestNodesIter[kadt.Key, kadt.PeerID](target)
+	nodes := make([]*NodeStatus[kadt.Key, kadt.PeerID], n)
+	for i := range nodes {
+		nodes[i] = &NodeStatus[kadt.Key, kadt.PeerID]{NodeID: kadt.PeerID(fmt.Sprintf("node-%d", i))}
+		iter.Add(nodes[i])
+	}
+	return iter, nodes
+}
+
+// BenchmarkClosestNodesIter measures visiting all nodes of a large query in order of their distance from
+// the target, which a query does on every advance. The "trie" case measures the previous approach that
+// sorted the nodes held in a trie on each iteration.
+func BenchmarkClosestNodesIter(b *testing.B) {
+	for _, n := range []int{20, 100, 500, 2000} {
+		iter, nodes := newBenchmarkIter(n)
+		ctx := context.Background()
+		fn := func(ctx context.Context, ns *NodeStatus[kadt.Key, kadt.PeerID]) bool {
+			return false
+		}
+
+		b.Run(fmt.Sprintf("iter/n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				iter.Each(ctx, fn)
+			}
+		})
+
+		tr := trie.New[kadt.Key, *NodeStatus[kadt.Key, kadt.PeerID]]()
+		for _, ns := range nodes {
+			tr.Add(ns.NodeID.Key(), ns)
+		}
+		b.Run(fmt.Sprintf("trie/n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, e := range trie.Closest(tr, iter.target, tr.Size()) {
+					if fn(ctx, e.Data) {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkClosestNodesIterAdd measures adding the nodes of a large query one at a time.
+func BenchmarkClosestNodesIterAdd(b *testing.B) {
+	for _, n := range []int{100, 2000} {
+		_, nodes := newBenchmarkIter(n)
+		target := kadt.NewKey([]byte("target"))
+
+		b.Run(fmt.Sprintf("iter/n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				iter := NewClosestNodesIter[kadt.Key, kadt.PeerID](target)
+				for _, ns := range nodes {
+					iter.Add(ns)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("trie/n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tr := trie.New[kadt.Key, *NodeStatus[kadt.Key, kadt.PeerID]]()
+				for _, ns := range nodes {
+					tr.Add(ns.NodeID.Key(), ns)
+				}
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/plprobelab/go-libdht/kad"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/plprobelab/zikade/errs"
 	"github.com/plprobelab/zikade/internal/coord/coordt"
@@ -16,16 +18,50 @@ import (
 type Pool[K kad.Key[K], N kad.NodeID[K], M coordt.Message] struct {
 	// self is the node id of the system the pool is running on
 	self       N
-	queries    []*Query[K, N, M]
-	queryIndex map[coordt.QueryID]*Query[K, N, M]
+	queryIndex map[coordt.QueryID]poolQuery[K]
+
+	// classes holds the queries of the pool by their priority class, indexed by [Priority].
+	classes [numPriorities]*poolClass[K]
+
+	// queryPriority holds the priority class of each query in the pool.
+	queryPriority map[coordt.QueryID]Priority
 
 	// cfg is a copy of the optional configuration supplied to the pool
 	cfg PoolConfig
 
-	// queriesInFlight is number of queries that are waiting for message responses
+	// queriesInFlight is number of queries that are waiting for message responses. It is recalculated
+	// each time the pool is advanced.
 	queriesInFlight int
+
+	// rtt estimates the round-trip times of requests to derive per-node request timeouts.
+	// It is nil if [PoolConfig.AdaptiveRequestTimeout] is false.
+	rtt *RTTEstimator[K]
+
+	// concurrency adjusts the number of queries that may be waiting for message responses at any one time.
+	// It is nil if [PoolConfig.AdaptiveConcurrency] is false.
+	concurrency *concurrencyLimit
+
+	// scorer is used by queries that do not use disjoint paths to choose between nodes of similar distance by
+	// their utility. It is nil unless set with [Pool.SetNodeScorer].
+	scorer NodeScorer[K, N]
+
+	// saturated indicates that the pool was at capacity when it was last advanced.
+	saturated bool
+
+	// counterRequests is a counter that tracks the number of requests sent by queries of each priority class.
+	counterRequests metric.Int64Counter
+
+	// counterClassAtCapacity is a counter that tracks how often a priority class reached its concurrency limit.
+	counterClassAtCapacity metric.Int64Counter
+
+	// gaugeQueries is a gauge that tracks the number of queries of each priority class.
+	gaugeQueries metric.Int64ObservableGauge
 }
 
+// poolHighLoad is the utilization of the system above which an adaptive pool reduces the number of
+// queries it advances in parallel.
+const poolHighLoad = 0.8
+
 // PoolConfig specifies optional configuration for a Pool
 type PoolConfig struct {
 	Concurrency      int           // the maximum number of queries that may be waiting for message responses at any one time
@@ -33,7 +69,31 @@ type PoolConfig struct {
 	Replication      int           // the 'k' parameter defined by Kademlia
 	QueryConcurrency int           // the maximum number of concurrent requests that each query may have in flight
 	RequestTimeout   time.Duration // the timeout queries should use for contacting a single node
+	DisjointPaths    int           // the number of disjoint paths queries use if not specified per query, 1 disables disjoint path lookups
 	Clock            clock.Clock   // a clock that may replaced by a mock when testing
+
+	// AdaptiveRequestTimeout specifies whether queries should derive the timeout for contacting a single node
+	// from the observed round-trip times of earlier requests. RequestTimeout is then the upper bound of the timeout.
+	AdaptiveRequestTimeout   bool
+	RequestTimeoutMin        time.Duration // the lower bound of adaptive request timeouts
+	RequestTimeoutPercentile float64       // the percentile of observed round-trip times used as the timeout for nodes without history
+
+	// Interactive, Background and Maintenance configure the scheduling of the queries of each priority class.
+	// The pool takes turns between the classes by weighted round-robin.
+	Interactive PriorityConfig
+	Background  PriorityConfig
+	Maintenance PriorityConfig
+
+	// Meter is the meter that should be used to record metrics.
+	Meter metric.Meter
+
+	// AdaptiveConcurrency specifies whether the pool should adjust the number of queries it advances in parallel
+	// based on the reported load of the system, and whether queries should adjust their number of concurrent requests
+	// based on the success rate and latency of their requests. Concurrency and QueryConcurrency are then the initial
+	// values.
+	AdaptiveConcurrency bool
+	MaxConcurrency      int // the upper bound of the number of queries in flight if AdaptiveConcurrency is true
+	MaxQueryConcurrency int // the upper bound of the number of concurrent requests per query if AdaptiveConcurrency is true
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -77,6 +137,53 @@ func (cfg *PoolConfig) Validate() error {
 		}
 	}
 
+	if cfg.DisjointPaths < 1 {
+		return &errs.ConfigurationError{
+			Component: "PoolConfig",
+			Err:       fmt.Errorf("disjoint paths must be greater than zero"),
+		}
+	}
+
+	for p := Priority(0); p < numPriorities; p++ {
+		pcfg := cfg.priorityConfig(p)
+		if err := pcfg.Validate(); err != nil {
+			return &errs.ConfigurationError{
+				Component: "PoolConfig",
+				Err:       fmt.Errorf("%s priority: %w", p, err),
+			}
+		}
+	}
+
+	if cfg.Meter == nil {
+		return &errs.ConfigurationError{
+			Component: "PoolConfig",
+			Err:       fmt.Errorf("meter must not be nil"),
+		}
+	}
+
+	if cfg.AdaptiveConcurrency && cfg.MaxConcurrency < cfg.Concurrency {
+		return &errs.ConfigurationError{
+			Component: "PoolConfig",
+			Err:       fmt.Errorf("max concurrency must not be less than concurrency"),
+		}
+	}
+
+	if cfg.AdaptiveConcurrency && cfg.MaxQueryConcurrency < cfg.QueryConcurrency {
+		return &errs.ConfigurationError{
+			Component: "PoolConfig",
+			Err:       fmt.Errorf("max query concurrency must not be less than query concurrency"),
+		}
+	}
+
+	if cfg.AdaptiveRequestTimeout {
+		if err := cfg.rttConfig().Validate(); err != nil {
+			return &errs.ConfigurationError{
+				Component: "PoolConfig",
+				Err:       fmt.Errorf("adaptive request timeout: %w", err),
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -90,9 +197,45 @@ func DefaultPoolConfig() *PoolConfig {
 		Replication:      20,
 		QueryConcurrency: 3,
 		RequestTimeout:   time.Minute,
+		DisjointPaths:    1,
+
+		Interactive: PriorityConfig{Weight: 4, Concurrency: 3},
+		Background:  PriorityConfig{Weight: 2, Concurrency: 2},
+		Maintenance: PriorityConfig{Weight: 1, Concurrency: 1},
+
+		Meter: tele.NoopMeter(),
+
+		AdaptiveRequestTimeout:   false,
+		RequestTimeoutMin:        time.Second,
+		RequestTimeoutPercentile: 0.99,
+
+		AdaptiveConcurrency: false,
+		MaxConcurrency:      10,
+		MaxQueryConcurrency: 10,
 	}
 }
 
+// priorityConfig returns the configuration of the given priority class.
+func (cfg *PoolConfig) priorityConfig(p Priority) PriorityConfig {
+	switch p {
+	case PriorityBackground:
+		return cfg.Background
+	case PriorityMaintenance:
+		return cfg.Maintenance
+	default:
+		return cfg.Interactive
+	}
+}
+
+// rttConfig returns the configuration of the round-trip time estimator that derives adaptive request timeouts.
+func (cfg *PoolConfig) rttConfig() *RTTConfig {
+	rttCfg := DefaultRTTConfig()
+	rttCfg.MinTimeout = cfg.RequestTimeoutMin
+	rttCfg.MaxTimeout = cfg.RequestTimeout
+	rttCfg.Percentile = cfg.RequestTimeoutPercentile
+	return rttCfg
+}
+
 func NewPool[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, cfg *PoolConfig) (*Pool[K, N, M], error) {
 	if cfg == nil {
 		cfg = DefaultPoolConfig()
@@ -100,12 +243,80 @@ func NewPool[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, cfg *PoolC
 		return nil, err
 	}
 
-	return &Pool[K, N, M]{
-		self:       self,
-		cfg:        *cfg,
-		queries:    make([]*Query[K, N, M], 0),
-		queryIndex: make(map[coordt.QueryID]*Query[K, N, M]),
-	}, nil
+	p := &Pool[K, N, M]{
+		self:          self,
+		cfg:           *cfg,
+		queryIndex:    make(map[coordt.QueryID]poolQuery[K]),
+		queryPriority: make(map[coordt.QueryID]Priority),
+	}
+
+	for i := range p.classes {
+		p.classes[i] = &poolClass[K]{
+			cfg:     cfg.priorityConfig(Priority(i)),
+			queries: make([]poolQuery[K], 0),
+		}
+	}
+
+	var err error
+	p.counterRequests, err = cfg.Meter.Int64Counter(
+		"query_pool_requests",
+		metric.WithDescription("Total number of requests sent by queries of the query pool per priority class"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create query_pool_requests counter: %w", err)
+	}
+
+	p.counterClassAtCapacity, err = cfg.Meter.Int64Counter(
+		"query_pool_class_at_capacity",
+		metric.WithDescription("Total number of times a priority class of the query pool reached its concurrency limit"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create query_pool_class_at_capacity counter: %w", err)
+	}
+
+	p.gaugeQueries, err = cfg.Meter.Int64ObservableGauge(
+		"query_pool_queries",
+		metric.WithDescription("Number of queries in the query pool per priority class"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			for i, c := range p.classes {
+				o.Observe(c.size.Load(), metric.WithAttributes(priorityAttr(Priority(i))))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create query_pool_queries gauge: %w", err)
+	}
+
+	if cfg.AdaptiveRequestTimeout {
+		rtt, err := NewRTTEstimator[K](cfg.rttConfig())
+		if err != nil {
+			return nil, fmt.Errorf("rtt estimator: %w", err)
+		}
+		p.rtt = rtt
+	}
+
+	if cfg.AdaptiveConcurrency {
+		p.concurrency = newConcurrencyLimit(cfg.Concurrency, 1, cfg.MaxConcurrency)
+	}
+
+	return p, nil
+}
+
+// SetNodeScorer sets the scorer that queries added to the pool afterwards use to choose which of several nodes
+// of similar distance from their target to contact next. Queries still finish and report their results by
+// distance alone. Queries that use disjoint paths ignore the scorer so that the paths do not converge on the
+// same highly scored nodes. A nil scorer disables scoring.
+func (p *Pool[K, N, M]) SetNodeScorer(s NodeScorer[K, N]) {
+	p.scorer = s
+}
+
+// newIter returns the iterator for a new query of the pool that does not use disjoint paths.
+func (p *Pool[K, N, M]) newIter(target K) NodeIter[K, N] {
+	if p.scorer == nil {
+		return NewClosestNodesIter[K, N](target)
+	}
+	return NewScoredClosestNodesIter[K, N](target, p.scorer)
 }
 
 // Advance advances the state of the pool by attempting to advance one of its queries
@@ -113,18 +324,29 @@ func (p *Pool[K, N, M]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 	ctx, span := tele.StartSpan(ctx, "Pool.Advance")
 	defer span.End()
 
-	// reset the in flight counter so it can be calculated as the queries are advanced
-	p.queriesInFlight = 0
-
 	// eventQueryID keeps track of a query that was advanced via a specific event, to avoid it
 	// being advanced twice
 	eventQueryID := coordt.InvalidQueryID
 
 	switch tev := ev.(type) {
 	case *EventPoolAddFindCloserQuery[K, N]:
-		p.addFindCloserQuery(ctx, tev.QueryID, tev.Target, tev.Seed, tev.NumResults)
+		p.addFindCloserQuery(ctx, tev.QueryID, tev.Target, tev.Seed, queryParams{
+			numResults:     tev.NumResults,
+			disjointPaths:  tev.DisjointPaths,
+			priority:       tev.Priority,
+			termination:    tev.Termination,
+			concurrency:    tev.Concurrency,
+			requestTimeout: tev.RequestTimeout,
+		})
 	case *EventPoolAddQuery[K, N, M]:
-		p.addQuery(ctx, tev.QueryID, tev.Target, tev.Message, tev.Seed, tev.NumResults)
+		p.addQuery(ctx, tev.QueryID, tev.Target, tev.Message, tev.Seed, queryParams{
+			numResults:     tev.NumResults,
+			disjointPaths:  tev.DisjointPaths,
+			priority:       tev.Priority,
+			termination:    tev.Termination,
+			concurrency:    tev.Concurrency,
+			requestTimeout: tev.RequestTimeout,
+		})
 		// TODO: return error as state
 	case *EventPoolStopQuery:
 		if qry, ok := p.queryIndex[tev.QueryID]; ok {
@@ -132,18 +354,22 @@ func (p *Pool[K, N, M]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 			if terminal {
 				return state
 			}
-			eventQueryID = qry.id
+			eventQueryID = qry.queryID()
 		}
 	case *EventPoolNodeResponse[K, N]:
+		if p.rtt != nil {
+			p.rtt.Observe(tev.NodeID.Key(), tev.RTT)
+		}
 		if qry, ok := p.queryIndex[tev.QueryID]; ok {
 			state, terminal := p.advanceQuery(ctx, qry, &EventQueryNodeResponse[K, N]{
 				NodeID:      tev.NodeID,
 				CloserNodes: tev.CloserNodes,
+				RTT:         tev.RTT,
 			})
 			if terminal {
 				return state
 			}
-			eventQueryID = qry.id
+			eventQueryID = qry.queryID()
 		}
 	case *EventPoolNodeFailure[K, N]:
 		if qry, ok := p.queryIndex[tev.QueryID]; ok {
@@ -154,7 +380,15 @@ func (p *Pool[K, N, M]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 			if terminal {
 				return state
 			}
-			eventQueryID = qry.id
+			eventQueryID = qry.queryID()
+		}
+	case *EventPoolLoad:
+		if p.concurrency != nil {
+			if tev.Utilization >= poolHighLoad {
+				p.concurrency.onFailure()
+			} else if p.saturated {
+				p.concurrency.grow()
+			}
 		}
 	case *EventPoolPoll:
 		// no event to process
@@ -162,28 +396,77 @@ func (p *Pool[K, N, M]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 		panic(fmt.Sprintf("unexpected event: %T", tev))
 	}
 
-	if len(p.queries) == 0 {
+	p.saturated = false
+
+	if len(p.queryIndex) == 0 {
 		return &StatePoolIdle{}
 	}
 
-	// Attempt to advance another query
-	for _, qry := range p.queries {
-		if eventQueryID == qry.id {
-			// avoid advancing query twice
-			continue
+	// count the queries that are waiting for message responses
+	p.queriesInFlight = 0
+	for _, cls := range p.classes {
+		cls.queriesInFlight = 0
+		for _, qry := range cls.queries {
+			if qry.waiting() {
+				cls.queriesInFlight++
+			}
 		}
+		p.queriesInFlight += cls.queriesInFlight
+	}
 
-		state, terminal := p.advanceQuery(ctx, qry, &EventQueryPoll{})
-		if terminal {
-			return state
+	// Attempt to advance another query, taking turns between the priority classes
+	for _, c := range p.classOrder() {
+		cls := p.classes[c]
+		classLimited := false
+		n := len(cls.queries)
+		for i := 0; i < n; i++ {
+			idx := (cls.next + i) % n
+			qry := cls.queries[idx]
+			if eventQueryID == qry.queryID() {
+				// avoid advancing query twice
+				continue
+			}
+
+			// a query that is not waiting for responses would start a new request, so it may
+			// only proceed if neither the pool nor its class have the maximum number of queries in flight
+			waiting := qry.waiting()
+			if !waiting {
+				if p.queriesInFlight >= p.maxInFlight() {
+					p.saturated = true
+					continue
+				}
+				if cls.atCapacity() {
+					classLimited = true
+					continue
+				}
+			}
+
+			state, terminal := p.advanceQuery(ctx, qry, &EventQueryPoll{})
+			if terminal {
+				if p.isRequest(state) {
+					if !waiting {
+						p.queriesInFlight++
+						cls.queriesInFlight++
+					}
+					// The query that follows the one that sent the request is polled first next time so
+					// that the queries of a class take turns in sending requests.
+					cls.next = (idx + 1) % n
+				}
+				return state
+			}
 		}
 
-		// check if we have the maximum number of queries in flight
-		if p.queriesInFlight >= p.cfg.Concurrency {
-			return &StatePoolWaitingAtCapacity{}
+		if classLimited {
+			p.counterClassAtCapacity.Add(ctx, 1, metric.WithAttributes(priorityAttr(c)))
 		}
 	}
 
+	// check if we have the maximum number of queries in flight
+	if p.queriesInFlight >= p.maxInFlight() {
+		p.saturated = true
+		return &StatePoolWaitingAtCapacity{}
+	}
+
 	if p.queriesInFlight > 0 {
 		return &StatePoolWaitingWithCapacity{}
 	}
@@ -191,11 +474,45 @@ func (p *Pool[K, N, M]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 	return &StatePoolIdle{}
 }
 
-func (p *Pool[K, N, M]) advanceQuery(ctx context.Context, qry *Query[K, N, M], qev QueryEvent) (PoolState, bool) {
+// classOrder returns the priority classes in the order their queries should be advanced. The class that
+// goes first is chosen by smooth weighted round-robin among the classes that have queries, so that every
+// class gets a share of turns proportional to its weight.
+func (p *Pool[K, N, M]) classOrder() []Priority {
+	first := Priority(-1)
+	total := 0
+	for i, c := range p.classes {
+		if len(c.queries) == 0 {
+			continue
+		}
+		c.currentWeight += c.cfg.Weight
+		total += c.cfg.Weight
+		if first < 0 || c.currentWeight > p.classes[first].currentWeight {
+			first = Priority(i)
+		}
+	}
+
+	order := make([]Priority, 0, numPriorities)
+	if first < 0 {
+		return order
+	}
+	p.classes[first].currentWeight -= total
+
+	order = append(order, first)
+	for i := Priority(0); i < numPriorities; i++ {
+		if i != first && len(p.classes[i].queries) > 0 {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+func (p *Pool[K, N, M]) advanceQuery(ctx context.Context, qry poolQuery[K], qev QueryEvent) (PoolState, bool) {
+	priority := p.queryPriority[qry.queryID()]
+
 	state := qry.Advance(ctx, qev)
 	switch st := state.(type) {
 	case *StateQueryFindCloser[K, N]:
-		p.queriesInFlight++
+		p.counterRequests.Add(ctx, 1, metric.WithAttributes(priorityAttr(priority)))
 		return &StatePoolFindCloser[K, N]{
 			QueryID: st.QueryID,
 			Stats:   st.Stats,
@@ -203,7 +520,7 @@ func (p *Pool[K, N, M]) advanceQuery(ctx context.Context, qry *Query[K, N, M], q
 			Target:  st.Target,
 		}, true
 	case *StateQuerySendMessage[K, N, M]:
-		p.queriesInFlight++
+		p.counterRequests.Add(ctx, 1, metric.WithAttributes(priorityAttr(priority)))
 		return &StatePoolSendMessage[K, N, M]{
 			QueryID: st.QueryID,
 			Stats:   st.Stats,
@@ -211,103 +528,197 @@ func (p *Pool[K, N, M]) advanceQuery(ctx context.Context, qry *Query[K, N, M], q
 			Message: st.Message,
 		}, true
 	case *StateQueryFinished[K, N]:
-		p.removeQuery(qry.id)
+		p.removeQuery(qry.queryID())
 		return &StatePoolQueryFinished[K, N]{
 			QueryID:      st.QueryID,
 			Stats:        st.Stats,
 			ClosestNodes: st.ClosestNodes,
 		}, true
 	case *StateQueryWaitingAtCapacity:
-		elapsed := p.cfg.Clock.Since(qry.stats.Start)
+		elapsed := p.cfg.Clock.Since(qry.startTime())
 		if elapsed > p.cfg.Timeout {
-			p.removeQuery(qry.id)
+			p.removeQuery(qry.queryID())
 			return &StatePoolQueryTimeout{
 				QueryID: st.QueryID,
 				Stats:   st.Stats,
 			}, true
 		}
-		p.queriesInFlight++
 	case *StateQueryWaitingWithCapacity:
-		elapsed := p.cfg.Clock.Since(qry.stats.Start)
+		elapsed := p.cfg.Clock.Since(qry.startTime())
 		if elapsed > p.cfg.Timeout {
-			p.removeQuery(qry.id)
+			p.removeQuery(qry.queryID())
 			return &StatePoolQueryTimeout{
 				QueryID: st.QueryID,
 				Stats:   st.Stats,
 			}, true
 		}
-		p.queriesInFlight++
 	}
 	return nil, false
 }
 
 func (p *Pool[K, N, M]) removeQuery(queryID coordt.QueryID) {
-	for i := range p.queries {
-		if p.queries[i].id != queryID {
+	cls := p.classes[p.queryPriority[queryID]]
+	for i := range cls.queries {
+		if cls.queries[i].queryID() != queryID {
 			continue
 		}
-		// remove from slice
-		copy(p.queries[i:], p.queries[i+1:])
-		p.queries[len(p.queries)-1] = nil
-		p.queries = p.queries[:len(p.queries)-1]
+		cls.remove(i)
 		break
 	}
 	delete(p.queryIndex, queryID)
+	delete(p.queryPriority, queryID)
 }
 
 // addQuery adds a query to the pool, returning the new query id
 // TODO: remove target argument and use msg.Target
-func (p *Pool[K, N, M]) addQuery(ctx context.Context, queryID coordt.QueryID, target K, msg M, knownClosestNodes []N, numResults int) error {
+func (p *Pool[K, N, M]) addQuery(ctx context.Context, queryID coordt.QueryID, target K, msg M, knownClosestNodes []N, params queryParams) error {
 	if _, exists := p.queryIndex[queryID]; exists {
 		return fmt.Errorf("query id already in use")
 	}
-	iter := NewClosestNodesIter[K, N](target)
+	if !params.priority.valid() {
+		return fmt.Errorf("unknown priority: %s", params.priority)
+	}
 
-	qryCfg := DefaultQueryConfig()
-	qryCfg.Clock = p.cfg.Clock
-	qryCfg.Concurrency = p.cfg.QueryConcurrency
-	qryCfg.RequestTimeout = p.cfg.RequestTimeout
+	qryCfg := p.newQueryConfig(params)
 
-	if numResults > 0 {
-		qryCfg.NumResults = numResults
+	var qry poolQuery[K]
+	var err error
+	if paths := p.disjointPaths(params.disjointPaths); paths > 1 {
+		qry, err = NewDisjointQuery[K, N, M](p.self, queryID, target, msg, paths, knownClosestNodes, qryCfg)
+	} else {
+		iter := p.newIter(target)
+		qry, err = NewQuery[K, N, M](p.self, queryID, target, msg, iter, knownClosestNodes, qryCfg)
 	}
-
-	qry, err := NewQuery[K, N, M](p.self, queryID, target, msg, iter, knownClosestNodes, qryCfg)
 	if err != nil {
 		return fmt.Errorf("new query: %w", err)
 	}
 
-	p.queries = append(p.queries, qry)
+	if p.rtt != nil && params.requestTimeout == 0 {
+		// a request timeout that was given for the query takes precedence over adaptive timeouts
+		qry.setRTTEstimator(p.rtt)
+	}
+
+	p.classes[params.priority].add(qry)
 	p.queryIndex[queryID] = qry
+	p.queryPriority[queryID] = params.priority
 
 	return nil
 }
 
 // addQuery adds a find closer query to the pool, returning the new query id
-func (p *Pool[K, N, M]) addFindCloserQuery(ctx context.Context, queryID coordt.QueryID, target K, knownClosestNodes []N, numResults int) error {
+func (p *Pool[K, N, M]) addFindCloserQuery(ctx context.Context, queryID coordt.QueryID, target K, knownClosestNodes []N, params queryParams) error {
 	if _, exists := p.queryIndex[queryID]; exists {
 		return fmt.Errorf("query id already in use")
 	}
-	iter := NewClosestNodesIter[K, N](target)
+	if !params.priority.valid() {
+		return fmt.Errorf("unknown priority: %s", params.priority)
+	}
+
+	qryCfg := p.newQueryConfig(params)
+
+	var qry poolQuery[K]
+	var err error
+	if paths := p.disjointPaths(params.disjointPaths); paths > 1 {
+		qry, err = NewDisjointFindCloserQuery[K, N, M](p.self, queryID, target, paths, knownClosestNodes, qryCfg)
+	} else {
+		iter := p.newIter(target)
+		qry, err = NewFindCloserQuery[K, N, M](p.self, queryID, target, iter, knownClosestNodes, qryCfg)
+	}
+	if err != nil {
+		return fmt.Errorf("new query: %w", err)
+	}
 
+	if p.rtt != nil && params.requestTimeout == 0 {
+		// a request timeout that was given for the query takes precedence over adaptive timeouts
+		qry.setRTTEstimator(p.rtt)
+	}
+
+	p.classes[params.priority].add(qry)
+	p.queryIndex[queryID] = qry
+	p.queryPriority[queryID] = params.priority
+
+	return nil
+}
+
+// queryParams holds the parameters of a single query that is added to a [Pool]. Zero values use the
+// configuration of the pool.
+type queryParams struct {
+	numResults     int
+	disjointPaths  int
+	priority       Priority
+	termination    TerminationPolicy
+	concurrency    int
+	requestTimeout time.Duration
+}
+
+// newQueryConfig returns the configuration for a new query of the pool.
+func (p *Pool[K, N, M]) newQueryConfig(params queryParams) *QueryConfig {
 	qryCfg := DefaultQueryConfig()
 	qryCfg.Clock = p.cfg.Clock
 	qryCfg.Concurrency = p.cfg.QueryConcurrency
 	qryCfg.RequestTimeout = p.cfg.RequestTimeout
+	qryCfg.AdaptiveConcurrency = p.cfg.AdaptiveConcurrency
+	qryCfg.MaxConcurrency = p.cfg.MaxQueryConcurrency
 
-	if numResults > 0 {
-		qryCfg.NumResults = numResults
+	if params.numResults > 0 {
+		qryCfg.NumResults = params.numResults
+	}
+	if params.termination != nil {
+		qryCfg.Termination = params.termination
+	}
+	if params.concurrency > 0 {
+		qryCfg.Concurrency = params.concurrency
+		if qryCfg.MaxConcurrency < params.concurrency {
+			qryCfg.MaxConcurrency = params.concurrency
+		}
+	}
+	if params.requestTimeout > 0 {
+		qryCfg.RequestTimeout = params.requestTimeout
 	}
 
-	qry, err := NewFindCloserQuery[K, N, M](p.self, queryID, target, iter, knownClosestNodes, qryCfg)
-	if err != nil {
-		return fmt.Errorf("new query: %w", err)
+	return qryCfg
+}
+
+// maxInFlight returns the maximum number of queries that may currently be waiting for message responses.
+func (p *Pool[K, N, M]) maxInFlight() int {
+	if p.concurrency == nil {
+		return p.cfg.Concurrency
 	}
+	return p.concurrency.limit()
+}
 
-	p.queries = append(p.queries, qry)
-	p.queryIndex[queryID] = qry
+// disjointPaths returns the number of disjoint paths a new query should use.
+// It falls back to the pool's configuration if the number wasn't specified.
+func (p *Pool[K, N, M]) disjointPaths(paths int) int {
+	if paths > 0 {
+		return paths
+	}
+	return p.cfg.DisjointPaths
+}
 
-	return nil
+// isRequest reports whether the pool state asks to contact a node.
+func (p *Pool[K, N, M]) isRequest(state PoolState) bool {
+	switch state.(type) {
+	case *StatePoolFindCloser[K, N], *StatePoolSendMessage[K, N, M]:
+		return true
+	default:
+		return false
+	}
+}
+
+// priorityAttr returns the metric attribute that identifies a priority class.
+func priorityAttr(p Priority) attribute.KeyValue {
+	return attribute.String("priority", p.String())
+}
+
+// poolQuery is a query that is managed by a [Pool]. It is implemented by
+// [Query] and [DisjointQuery].
+type poolQuery[K kad.Key[K]] interface {
+	Advance(ctx context.Context, ev QueryEvent) QueryState
+	queryID() coordt.QueryID
+	startTime() time.Time
+	waiting() bool
+	setRTTEstimator(rtt *RTTEstimator[K])
 }
 
 // States
@@ -372,19 +783,31 @@ type PoolEvent interface {
 
 // EventPoolAddQuery is an event that attempts to add a new query that finds closer nodes to a target key.
 type EventPoolAddFindCloserQuery[K kad.Key[K], N kad.NodeID[K]] struct {
-	QueryID    coordt.QueryID // the id to use for the new query
-	Target     K              // the target key for the query
-	Seed       []N            // an initial set of close nodes the query should use
-	NumResults int            // the minimum number of nodes to successfully contact before considering iteration complete
+	QueryID       coordt.QueryID    // the id to use for the new query
+	Target        K                 // the target key for the query
+	Seed          []N               // an initial set of close nodes the query should use
+	NumResults    int               // the minimum number of nodes to successfully contact before considering iteration complete
+	DisjointPaths int               // the number of disjoint paths the query should use, zero uses the pool's default
+	Priority      Priority          // the priority class of the query
+	Termination   TerminationPolicy // decides whether the query should finish early, nil uses the default policy
+
+	Concurrency    int           // the maximum number of concurrent requests of the query, zero uses the pool's default
+	RequestTimeout time.Duration // the timeout for contacting a single node, zero uses the pool's default
 }
 
 // EventPoolAddQuery is an event that attempts to add a new query that sends a message.
 type EventPoolAddQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message] struct {
-	QueryID    coordt.QueryID // the id to use for the new query
-	Target     K              // the target key for the query
-	Message    M              // message to be sent to each node
-	Seed       []N            // an initial set of close nodes the query should use
-	NumResults int            // the minimum number of nodes to successfully contact before considering iteration complete
+	QueryID       coordt.QueryID    // the id to use for the new query
+	Target        K                 // the target key for the query
+	Message       M                 // message to be sent to each node
+	Seed          []N               // an initial set of close nodes the query should use
+	NumResults    int               // the minimum number of nodes to successfully contact before considering iteration complete
+	DisjointPaths int               // the number of disjoint paths the query should use, zero uses the pool's default
+	Priority      Priority          // the priority class of the query
+	Termination   TerminationPolicy // decides whether the query should finish early, nil uses the default policy
+
+	Concurrency    int           // the maximum number of concurrent requests of the query, zero uses the pool's default
+	RequestTimeout time.Duration // the timeout for contacting a single node, zero uses the pool's default
 }
 
 // EventPoolStopQuery notifies a [Pool] to stop a query.
@@ -397,6 +820,7 @@ type EventPoolNodeResponse[K kad.Key[K], N kad.NodeID[K]] struct {
 	QueryID     coordt.QueryID // the id of the query that sent the message
 	NodeID      N              // the node the message was sent to
 	CloserNodes []N            // the closer nodes sent by the node
+	RTT         time.Duration  // the round-trip time of the request, zero if unknown
 }
 
 // EventPoolNodeFailure notifies a [Pool] that an attempt to contact a node has failed.
@@ -406,6 +830,12 @@ type EventPoolNodeFailure[K kad.Key[K], N kad.NodeID[K]] struct {
 	Error   error          // the error that caused the failure, if any
 }
 
+// EventPoolLoad notifies a [Pool] of the current load of the system it is running on. A pool with
+// [PoolConfig.AdaptiveConcurrency] enabled uses it to adjust the number of queries it advances in parallel.
+type EventPoolLoad struct {
+	Utilization float64 // the fraction of time the system was busy in the last measurement interval, between 0 and 1
+}
+
 // EventPoolPoll is an event that signals the pool that it can perform housekeeping work such as time out queries.
 type EventPoolPoll struct{}
 
@@ -415,4 +845,5 @@ func (*EventPoolAddFindCloserQuery[K, N]) poolEvent() {}
 func (*EventPoolStopQuery) poolEvent()                {}
 func (*EventPoolNodeResponse[K, N]) poolEvent()       {}
 func (*EventPoolNodeFailure[K, N]) poolEvent()        {}
+func (*EventPoolLoad) poolEvent()                     {}
 func (*EventPoolPoll) poolEvent()                     {}
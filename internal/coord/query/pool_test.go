@@ -3,6 +3,7 @@ package query
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/plprobelab/go-libdht/kad/key"
@@ -63,6 +64,75 @@ func TestPoolConfigValidate(t *testing.T) {
 		cfg.RequestTimeout = -1
 		require.Error(t, cfg.Validate())
 	})
+
+	t.Run("disjoint paths positive", func(t *testing.T) {
+		cfg := DefaultPoolConfig()
+		cfg.DisjointPaths = 0
+		require.Error(t, cfg.Validate())
+		cfg.DisjointPaths = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("priority classes valid", func(t *testing.T) {
+		cfg := DefaultPoolConfig()
+		cfg.Interactive.Weight = 0
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultPoolConfig()
+		cfg.Background.Concurrency = 0
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultPoolConfig()
+		cfg.Maintenance.Weight = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("meter is not nil", func(t *testing.T) {
+		cfg := DefaultPoolConfig()
+		cfg.Meter = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("adaptive concurrency max not less than initial", func(t *testing.T) {
+		cfg := DefaultPoolConfig()
+		cfg.MaxConcurrency = cfg.Concurrency - 1
+		require.NoError(t, cfg.Validate()) // only validated when enabled
+
+		cfg.AdaptiveConcurrency = true
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultPoolConfig()
+		cfg.AdaptiveConcurrency = true
+		cfg.MaxQueryConcurrency = cfg.QueryConcurrency - 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("adaptive request timeout min positive", func(t *testing.T) {
+		cfg := DefaultPoolConfig()
+		cfg.RequestTimeoutMin = 0
+		require.NoError(t, cfg.Validate()) // only validated when enabled
+
+		cfg.AdaptiveRequestTimeout = true
+		require.Error(t, cfg.Validate())
+		cfg.RequestTimeoutMin = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("adaptive request timeout min not greater than request timeout", func(t *testing.T) {
+		cfg := DefaultPoolConfig()
+		cfg.AdaptiveRequestTimeout = true
+		cfg.RequestTimeoutMin = cfg.RequestTimeout + 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("adaptive request timeout percentile in range", func(t *testing.T) {
+		cfg := DefaultPoolConfig()
+		cfg.AdaptiveRequestTimeout = true
+		cfg.RequestTimeoutPercentile = 0
+		require.Error(t, cfg.Validate())
+		cfg.RequestTimeoutPercentile = 1.1
+		require.Error(t, cfg.Validate())
+	})
 }
 
 func TestPoolStartsIdle(t *testing.T) {
@@ -174,6 +244,161 @@ func TestPoolAddQueryStartsIfCapacity(t *testing.T) {
 	require.IsType(t, &StatePoolWaitingWithCapacity{}, state)
 }
 
+func TestPoolAddQueryDisjointPaths(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clk
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00001000) // 8
+
+	queryID := coordt.QueryID("test")
+	state := p.Advance(ctx, &EventPoolAddQuery[tiny.Key, tiny.Node, tiny.Message]{
+		QueryID:       queryID,
+		Target:        target,
+		Message:       tiny.Message{Content: "msg"},
+		Seed:          []tiny.Node{a, b},
+		DisjointPaths: 2,
+	})
+	require.IsType(t, &StatePoolSendMessage[tiny.Key, tiny.Node, tiny.Message]{}, state)
+	require.Equal(t, a, state.(*StatePoolSendMessage[tiny.Key, tiny.Node, tiny.Message]).NodeID)
+
+	// the query was added as a disjoint path query
+	require.IsType(t, &DisjointQuery[tiny.Key, tiny.Node, tiny.Message]{}, p.queryIndex[queryID])
+
+	// the second path contacts its own seed
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolSendMessage[tiny.Key, tiny.Node, tiny.Message]{}, state)
+	require.Equal(t, b, state.(*StatePoolSendMessage[tiny.Key, tiny.Node, tiny.Message]).NodeID)
+}
+
+func TestPoolAdaptiveRequestTimeout(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clk
+	cfg.AdaptiveRequestTimeout = true
+	cfg.RequestTimeoutMin = 10 * time.Millisecond
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100) // 4
+
+	// the first query contacts a which responds after 100ms
+	state := p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID: "first",
+		Target:  target,
+		Seed:    []tiny.Node{a},
+	})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+
+	state = p.Advance(ctx, &EventPoolNodeResponse[tiny.Key, tiny.Node]{
+		QueryID: "first",
+		NodeID:  a,
+		RTT:     100 * time.Millisecond,
+	})
+	require.IsType(t, &StatePoolQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	// the second query contacts a again
+	state = p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID: "second",
+		Target:  target,
+		Seed:    []tiny.Node{a},
+	})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+
+	// the request is still in flight before the estimated timeout of 100ms + 4*50ms elapsed
+	clk.Add(300 * time.Millisecond)
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolWaitingWithCapacity{}, state)
+
+	// a is marked as unresponsive long before the configured request timeout and the query finishes
+	clk.Add(time.Millisecond)
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolQueryFinished[tiny.Key, tiny.Node]{}, state)
+	stf := state.(*StatePoolQueryFinished[tiny.Key, tiny.Node])
+	require.Equal(t, coordt.QueryID("second"), stf.QueryID)
+	require.Equal(t, 1, stf.Stats.Failure)
+
+	// the timeout doubled so that a can respond to the third query even though it became slower
+	state = p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID: "third",
+		Target:  target,
+		Seed:    []tiny.Node{a},
+	})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+
+	clk.Add(600 * time.Millisecond)
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolWaitingWithCapacity{}, state)
+
+	state = p.Advance(ctx, &EventPoolNodeResponse[tiny.Key, tiny.Node]{
+		QueryID: "third",
+		NodeID:  a,
+		RTT:     600 * time.Millisecond,
+	})
+	require.IsType(t, &StatePoolQueryFinished[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, 1, state.(*StatePoolQueryFinished[tiny.Key, tiny.Node]).Stats.Success)
+}
+
+func TestPoolAdaptiveConcurrency(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 1
+	cfg.AdaptiveConcurrency = true
+	cfg.MaxConcurrency = 2
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00001000) // 8
+
+	state := p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID: "first",
+		Target:  target,
+		Seed:    []tiny.Node{a},
+	})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+
+	state = p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID: "second",
+		Target:  target,
+		Seed:    []tiny.Node{b},
+	})
+	require.IsType(t, &StatePoolWaitingAtCapacity{}, state)
+
+	// a high load does not allow more queries in flight
+	state = p.Advance(ctx, &EventPoolLoad{Utilization: 0.9})
+	require.IsType(t, &StatePoolWaitingAtCapacity{}, state)
+
+	// a low load while at capacity allows another query to make progress
+	state = p.Advance(ctx, &EventPoolLoad{Utilization: 0.1})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, coordt.QueryID("second"), state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).QueryID)
+
+	// the concurrency is bounded by the configured maximum
+	state = p.Advance(ctx, &EventPoolLoad{Utilization: 0.1})
+	require.IsType(t, &StatePoolWaitingAtCapacity{}, state)
+	require.Equal(t, 2, p.maxInFlight())
+
+	// a high load reduces the number of queries in flight
+	p.Advance(ctx, &EventPoolLoad{Utilization: 0.9})
+	require.Equal(t, 1, p.maxInFlight())
+}
+
 func TestPoolNodeResponse(t *testing.T) {
 	ctx := context.Background()
 	clk := clock.NewMock()
@@ -217,7 +442,85 @@ func TestPoolNodeResponse(t *testing.T) {
 	require.Equal(t, 1, stf.Stats.Success)
 }
 
-func TestPoolPrefersRunningQueriesOverNewOnes(t *testing.T) {
+func TestPoolQueryTermination(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clk
+	cfg.QueryConcurrency = 1
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00001000) // 8
+
+	queryID := coordt.QueryID("test")
+
+	state := p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:     queryID,
+		Target:      target,
+		Seed:        []tiny.Node{a, b},
+		Termination: RequestBudgetPolicy{Requests: 1},
+	})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, a, state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the query spent its budget of a single request, so it finishes without contacting b
+	state = p.Advance(ctx, &EventPoolNodeResponse[tiny.Key, tiny.Node]{
+		QueryID: queryID,
+		NodeID:  a,
+	})
+	require.IsType(t, &StatePoolQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	stf := state.(*StatePoolQueryFinished[tiny.Key, tiny.Node])
+	require.Equal(t, queryID, stf.QueryID)
+	require.Equal(t, 1, stf.Stats.Requests)
+}
+
+func TestPoolQueryParameters(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clk
+	cfg.QueryConcurrency = 1
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00001000) // 8
+	c := tiny.NewNode(0b00010000) // 16
+
+	queryID := coordt.QueryID("test")
+
+	// the query may have two requests in flight although the pool's default is one
+	state := p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:        queryID,
+		Target:         target,
+		Seed:           []tiny.Node{a, b, c},
+		Concurrency:    2,
+		RequestTimeout: time.Second,
+	})
+	require.Equal(t, a, state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.Equal(t, b, state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolWaitingWithCapacity{}, state)
+
+	// the requests time out after the query's request timeout instead of the pool's default
+	clk.Add(2 * time.Second)
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.Equal(t, c, state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).NodeID)
+}
+
+func TestPoolQueriesTakeTurns(t *testing.T) {
 	ctx := context.Background()
 	clk := clock.NewMock()
 	cfg := DefaultPoolConfig()
@@ -256,25 +559,31 @@ func TestPoolPrefersRunningQueriesOverNewOnes(t *testing.T) {
 		Seed:    []tiny.Node{a, b, c, d},
 	})
 
-	// the first query should continue its operation in preference to starting the new query
+	// the first query is the only one in the class so it is still polled first
 	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
 	st = state.(*StatePoolFindCloser[tiny.Key, tiny.Node])
 	require.Equal(t, queryID1, st.QueryID)
 	require.Equal(t, b, st.NodeID)
 
-	// advance the pool again, the first query should continue its operation in preference to starting the new query
+	// advance the pool again, the second query takes its turn although the first query has capacity
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+	st = state.(*StatePoolFindCloser[tiny.Key, tiny.Node])
+	require.Equal(t, queryID2, st.QueryID)
+	require.Equal(t, a, st.NodeID)
+
+	// advance the pool again, the queries alternate
 	state = p.Advance(ctx, &EventPoolPoll{})
 	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
 	st = state.(*StatePoolFindCloser[tiny.Key, tiny.Node])
 	require.Equal(t, queryID1, st.QueryID)
 	require.Equal(t, c, st.NodeID)
 
-	// advance the pool again, the first query is at capacity so the second query can start
 	state = p.Advance(ctx, &EventPoolPoll{})
 	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
 	st = state.(*StatePoolFindCloser[tiny.Key, tiny.Node])
 	require.Equal(t, queryID2, st.QueryID)
-	require.Equal(t, a, st.NodeID)
+	require.Equal(t, b, st.NodeID)
 
 	// notify first query that node was contacted successfully, but no closer nodes
 	state = p.Advance(ctx, &EventPoolNodeResponse[tiny.Key, tiny.Node]{
@@ -298,7 +607,7 @@ func TestPoolPrefersRunningQueriesOverNewOnes(t *testing.T) {
 	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
 	st = state.(*StatePoolFindCloser[tiny.Key, tiny.Node])
 	require.Equal(t, queryID2, st.QueryID)
-	require.Equal(t, b, st.NodeID)
+	require.Equal(t, c, st.NodeID)
 }
 
 func TestPoolRespectsConcurrency(t *testing.T) {
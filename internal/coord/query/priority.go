@@ -0,0 +1,127 @@
+package query
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/plprobelab/go-libdht/kad"
+
+	"github.com/plprobelab/zikade/errs"
+)
+
+// Priority is the priority class of a query that is managed by a [Pool]. A pool divides its capacity between
+// the priority classes so that long-running queries of one class cannot starve the queries of another.
+type Priority int
+
+const (
+	// PriorityInteractive is the priority class of queries that a user is waiting for. It is the default.
+	PriorityInteractive Priority = iota
+
+	// PriorityBackground is the priority class of long-running queries that nobody is waiting for, such as
+	// bulk reprovides.
+	PriorityBackground
+
+	// PriorityMaintenance is the priority class of queries that maintain the state of the system, such as
+	// explore-style lookups that refresh the routing table.
+	PriorityMaintenance
+
+	// numPriorities is the number of priority classes.
+	numPriorities = 3
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	case PriorityMaintenance:
+		return "maintenance"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+// valid reports whether p is a known priority class.
+func (p Priority) valid() bool {
+	return p >= 0 && p < numPriorities
+}
+
+// PriorityConfig specifies how a [Pool] schedules the queries of a single priority class.
+type PriorityConfig struct {
+	// Weight is the share of turns the priority class gets relative to the weights of the other classes
+	// when queries of several classes compete for the capacity of the pool.
+	Weight int
+
+	// Concurrency is the maximum number of queries of the priority class that may be waiting for message
+	// responses at any one time. It applies in addition to the concurrency of the pool.
+	Concurrency int
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *PriorityConfig) Validate() error {
+	if cfg.Weight < 1 {
+		return &errs.ConfigurationError{
+			Component: "PriorityConfig",
+			Err:       fmt.Errorf("weight must be greater than zero"),
+		}
+	}
+
+	if cfg.Concurrency < 1 {
+		return &errs.ConfigurationError{
+			Component: "PriorityConfig",
+			Err:       fmt.Errorf("concurrency must be greater than zero"),
+		}
+	}
+
+	return nil
+}
+
+// poolClass holds the queries of a single priority class of a [Pool].
+type poolClass[K kad.Key[K]] struct {
+	// cfg is a copy of the configuration of the priority class
+	cfg PriorityConfig
+
+	// queries holds the queries of the class in the order they were added.
+	queries []poolQuery[K]
+
+	// next is the index in queries of the query that is polled first on the next advance of the pool.
+	next int
+
+	// queriesInFlight is number of queries of the class that are waiting for message responses. Like the
+	// counter of the pool, it is recalculated each time the pool is advanced.
+	queriesInFlight int
+
+	// currentWeight is the current weight of the class used by the smooth weighted round-robin scheduler.
+	currentWeight int
+
+	// size records the number of queries in the class so that it can be read asynchronously by metrics.
+	size atomic.Int64
+}
+
+// add appends the query to the class.
+func (c *poolClass[K]) add(qry poolQuery[K]) {
+	c.queries = append(c.queries, qry)
+	c.size.Store(int64(len(c.queries)))
+}
+
+// remove removes the query at index i from the class and keeps the rotation pointing at the query that
+// followed it.
+func (c *poolClass[K]) remove(i int) {
+	copy(c.queries[i:], c.queries[i+1:])
+	c.queries[len(c.queries)-1] = nil
+	c.queries = c.queries[:len(c.queries)-1]
+	c.size.Store(int64(len(c.queries)))
+
+	if c.next > i {
+		c.next--
+	}
+	if c.next >= len(c.queries) {
+		c.next = 0
+	}
+}
+
+// atCapacity reports whether the class has the maximum number of queries in flight.
+func (c *poolClass[K]) atCapacity() bool {
+	return c.queriesInFlight >= c.cfg.Concurrency
+}
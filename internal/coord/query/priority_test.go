@@ -0,0 +1,145 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/tiny"
+)
+
+func TestPriorityConfigValidate(t *testing.T) {
+	t.Run("weight positive", func(t *testing.T) {
+		cfg := PriorityConfig{Weight: 0, Concurrency: 1}
+		require.Error(t, cfg.Validate())
+		cfg.Weight = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("concurrency positive", func(t *testing.T) {
+		cfg := PriorityConfig{Weight: 1, Concurrency: 0}
+		require.Error(t, cfg.Validate())
+		cfg.Concurrency = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestPoolPriorityWeightedRoundRobin(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 10
+	cfg.QueryConcurrency = 10
+	cfg.Interactive = PriorityConfig{Weight: 2, Concurrency: 10}
+	cfg.Background = PriorityConfig{Weight: 1, Concurrency: 10}
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	seeds := make([]tiny.Node, 0, 8)
+	for i := 1; i <= 8; i++ {
+		seeds = append(seeds, tiny.NewNode(tiny.Key(i)))
+	}
+
+	// the background query is added first so that it would be advanced first without priorities
+	p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:  "background",
+		Target:   tiny.Key(0),
+		Seed:     seeds,
+		Priority: PriorityBackground,
+	})
+	p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:  "interactive",
+		Target:   tiny.Key(0),
+		Seed:     seeds,
+		Priority: PriorityInteractive,
+	})
+
+	// both classes take turns according to their weights
+	counts := map[coordt.QueryID]int{}
+	for i := 0; i < 6; i++ {
+		state := p.Advance(ctx, &EventPoolPoll{})
+		require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+		counts[state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).QueryID]++
+	}
+	require.Equal(t, 4, counts["interactive"])
+	require.Equal(t, 2, counts["background"])
+}
+
+func TestPoolPriorityConcurrency(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 3
+	cfg.QueryConcurrency = 1
+	cfg.Background = PriorityConfig{Weight: 1, Concurrency: 1}
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	target := tiny.Key(0b00000001)
+	a := tiny.NewNode(0b00000100) // 4
+
+	state := p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:  "bg1",
+		Target:   target,
+		Seed:     []tiny.Node{a},
+		Priority: PriorityBackground,
+	})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, coordt.QueryID("bg1"), state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).QueryID)
+
+	// the background class has reached its limit, so the second background query must wait
+	// even though the pool has capacity
+	state = p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:  "bg2",
+		Target:   target,
+		Seed:     []tiny.Node{a},
+		Priority: PriorityBackground,
+	})
+	require.IsType(t, &StatePoolWaitingWithCapacity{}, state)
+
+	// an interactive query can still start
+	state = p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:  "interactive",
+		Target:   target,
+		Seed:     []tiny.Node{a},
+		Priority: PriorityInteractive,
+	})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, coordt.QueryID("interactive"), state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).QueryID)
+
+	// once the first background query finishes, the second one starts
+	state = p.Advance(ctx, &EventPoolNodeResponse[tiny.Key, tiny.Node]{
+		QueryID: "bg1",
+		NodeID:  a,
+	})
+	require.IsType(t, &StatePoolQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, coordt.QueryID("bg2"), state.(*StatePoolFindCloser[tiny.Key, tiny.Node]).QueryID)
+}
+
+func TestPoolPriorityUnknown(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clock.NewMock()
+
+	self := tiny.NewNode(0)
+	p, err := NewPool[tiny.Key, tiny.Node, tiny.Message](self, cfg)
+	require.NoError(t, err)
+
+	state := p.Advance(ctx, &EventPoolAddFindCloserQuery[tiny.Key, tiny.Node]{
+		QueryID:  "test",
+		Target:   tiny.Key(0),
+		Seed:     []tiny.Node{tiny.NewNode(1)},
+		Priority: Priority(numPriorities),
+	})
+	require.IsType(t, &StatePoolIdle{}, state)
+}
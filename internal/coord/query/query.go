@@ -21,6 +21,10 @@ type QueryStats struct {
 	Requests int
 	Success  int
 	Failure  int
+
+	// Escalations is the number of times the query stalled and raised its
+	// concurrency to contact all remaining nodes among the closest nodes.
+	Escalations int
 }
 
 // QueryConfig specifies optional configuration for a Query
@@ -29,6 +33,14 @@ type QueryConfig struct {
 	NumResults     int           // the minimum number of nodes to successfully contact before considering iteration complete
 	RequestTimeout time.Duration // the timeout for contacting a single node
 	Clock          clock.Clock   // a clock that may replaced by a mock when testing
+
+	// AdaptiveConcurrency specifies whether the query should adjust the number of concurrent requests based on
+	// the success rate and latency of earlier requests. Concurrency is then the initial number of concurrent requests.
+	AdaptiveConcurrency bool
+	MaxConcurrency      int // the upper bound of the number of concurrent requests if AdaptiveConcurrency is true
+
+	// Termination decides whether the query should finish before it ran to completion.
+	Termination TerminationPolicy
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -57,6 +69,24 @@ func (cfg *QueryConfig) Validate() error {
 			Err:       fmt.Errorf("request timeout must be greater than zero"),
 		}
 	}
+	if cfg.AdaptiveConcurrency && cfg.MaxConcurrency < cfg.Concurrency {
+		return &errs.ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("max concurrency must not be less than concurrency"),
+		}
+	}
+	if cfg.Termination == nil {
+		return &errs.ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("termination policy must not be nil"),
+		}
+	}
+	if err := cfg.Termination.Validate(); err != nil {
+		return &errs.ConfigurationError{
+			Component: "QueryConfig",
+			Err:       fmt.Errorf("termination policy: %w", err),
+		}
+	}
 	return nil
 }
 
@@ -68,6 +98,11 @@ func DefaultQueryConfig() *QueryConfig {
 		NumResults:     20,
 		RequestTimeout: time.Minute,
 		Clock:          clock.New(), // use standard time
+
+		AdaptiveConcurrency: false,
+		MaxConcurrency:      10,
+
+		Termination: KClosestPolicy{},
 	}
 }
 
@@ -92,8 +127,50 @@ type Query[K kad.Key[K], N kad.NodeID[K], M coordt.Message] struct {
 	// This will contain up to [QueryConfig.NumResults] nodes.
 	targetNodes []N
 
-	// inFlight is number of requests in flight, will be <= concurrency
+	// inFlight is number of requests in flight, will be <= concurrency unless the query is stalled
 	inFlight int
+
+	// closest is the key of the closest node to the target that the query knows about.
+	closest K
+
+	// hasClosest indicates whether closest holds a valid key.
+	hasClosest bool
+
+	// roundResponses is the number of responses, failures or timeouts received in the current round.
+	// A round is complete when [QueryConfig.Concurrency] responses have been received.
+	roundResponses int
+
+	// roundProgress indicates whether a response in the current round returned a node closer to the target
+	// than any node the query knew about before.
+	roundProgress bool
+
+	// rounds is the number of rounds the query has completed.
+	rounds int
+
+	// checkedRounds is the number of completed rounds after which the closest nodes were last compared.
+	checkedRounds int
+
+	// stableRounds is the number of consecutive rounds after which the closest nodes did not change.
+	stableRounds int
+
+	// roundClosest holds the keys of the closest nodes the query knew about when it last compared them.
+	roundClosest []K
+
+	// stalled indicates that the last round did not bring the query closer to the target. While stalled, the
+	// query contacts all remaining nodes among the [QueryConfig.NumResults] closest nodes at once.
+	stalled bool
+
+	// rtt is an optional estimator that provides per-node request timeouts. If it is nil, all requests use
+	// [QueryConfig.RequestTimeout].
+	rtt *RTTEstimator[K]
+
+	// termStats returns the statistics that the termination policy is consulted with. A [DisjointQuery] sets
+	// it to the combined statistics of all its paths. If it is nil, the statistics of the query are used.
+	termStats func() QueryStats
+
+	// concurrency adjusts the number of concurrent requests. It is nil if [QueryConfig.AdaptiveConcurrency]
+	// is false.
+	concurrency *concurrencyLimit
 }
 
 func NewFindCloserQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, id coordt.QueryID, target K, iter NodeIter[K, N], knownClosestNodes []N, cfg *QueryConfig) (*Query[K, N, M], error) {
@@ -113,6 +190,19 @@ func NewQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, id coordt
 		return nil, err
 	}
 
+	q := &Query[K, N, M]{
+		self:   self,
+		id:     id,
+		cfg:    *cfg,
+		msg:    msg,
+		iter:   iter,
+		target: target,
+	}
+
+	if cfg.AdaptiveConcurrency {
+		q.concurrency = newConcurrencyLimit(cfg.Concurrency, 1, cfg.MaxConcurrency)
+	}
+
 	for _, node := range knownClosestNodes {
 		// exclude self from closest nodes
 		if key.Equal(node.Key(), self.Key()) {
@@ -122,16 +212,10 @@ func NewQuery[K kad.Key[K], N kad.NodeID[K], M coordt.Message](self N, id coordt
 			NodeID: node,
 			State:  &StateNodeNotContacted{},
 		})
+		q.updateClosest(node.Key())
 	}
 
-	return &Query[K, N, M]{
-		self:   self,
-		id:     id,
-		cfg:    *cfg,
-		msg:    msg,
-		iter:   iter,
-		target: target,
-	}, nil
+	return q, nil
 }
 
 func (q *Query[K, N, M]) Advance(ctx context.Context, ev QueryEvent) (out QueryState) {
@@ -158,7 +242,7 @@ func (q *Query[K, N, M]) Advance(ctx context.Context, ev QueryEvent) (out QueryS
 			ClosestNodes: q.targetNodes,
 		}
 	case *EventQueryNodeResponse[K, N]:
-		q.onNodeResponse(ctx, tev.NodeID, tev.CloserNodes)
+		q.onNodeResponse(ctx, tev.NodeID, tev.CloserNodes, tev.RTT)
 	case *EventQueryNodeFailure[K, N]:
 		span.RecordError(tev.Error)
 		q.onNodeFailure(ctx, tev.NodeID)
@@ -169,23 +253,41 @@ func (q *Query[K, N, M]) Advance(ctx context.Context, ev QueryEvent) (out QueryS
 		panic(fmt.Sprintf("unexpected event: %T", tev))
 	}
 
+	if q.terminate(ctx) {
+		q.markFinished(ctx)
+		return &StateQueryFinished[K, N]{
+			QueryID:      q.id,
+			Stats:        q.stats,
+			ClosestNodes: q.targetNodes,
+		}
+	}
+
 	// count number of successes in the order of the iteration
 	successes := 0
 
 	// progressing is set to true if any node is still awaiting contact
 	progressing := false
 
-	// TODO: if stalled then we should contact all remaining nodes that have not already been queried
+	// candidates counts the nodes that may still be part of the result in the order of the iteration
+	candidates := 0
+
+	// If the query is stalled it contacts all remaining nodes among the closest nodes at once.
 	atCapacity := func() bool {
-		return q.inFlight >= q.cfg.Concurrency
+		if q.stalled {
+			return q.inFlight >= q.cfg.NumResults
+		}
+		return q.inFlight >= q.maxInFlight()
 	}
 
-	// get all the nodes in order of distance from the target
-	// TODO: turn this into a walk or iterator on trie.Trie
-
 	var returnState QueryState
 
+	// visit all the nodes in order of distance from the target
 	q.iter.Each(ctx, func(ctx context.Context, ni *NodeStatus[K, N]) bool {
+		switch ni.State.(type) {
+		case *StateNodeWaiting, *StateNodeSucceeded, *StateNodeNotContacted:
+			candidates++
+		}
+
 		switch st := ni.State.(type) {
 		case *StateNodeWaiting:
 			if q.cfg.Clock.Now().After(st.Deadline) {
@@ -193,6 +295,13 @@ func (q *Query[K, N, M]) Advance(ctx context.Context, ev QueryEvent) (out QueryS
 				ni.State = &StateNodeUnresponsive{}
 				q.inFlight--
 				q.stats.Failure++
+				if q.concurrency != nil {
+					q.concurrency.onFailure()
+				}
+				if q.rtt != nil {
+					q.rtt.ObserveTimeout(ni.NodeID.Key())
+				}
+				q.onRoundResponse()
 			} else if atCapacity() {
 				returnState = &StateQueryWaitingAtCapacity{
 					QueryID: q.id,
@@ -219,8 +328,19 @@ func (q *Query[K, N, M]) Advance(ctx context.Context, ev QueryEvent) (out QueryS
 			}
 
 		case *StateNodeNotContacted:
+			if q.stalled && candidates > q.cfg.NumResults {
+				// All closest nodes have been contacted. Stop here and wait for the outstanding
+				// responses to bring the query closer to the target.
+				return true
+			}
+
 			if !atCapacity() {
-				deadline := q.cfg.Clock.Now().Add(q.cfg.RequestTimeout)
+				// let the iterator choose between nodes of similar distance, the order of the results is unaffected
+				if np, ok := q.iter.(nodePreferrer[K, N]); ok {
+					ni = np.Prefer(ni)
+				}
+
+				deadline := q.cfg.Clock.Now().Add(q.requestTimeout(ni.NodeID))
 				ni.State = &StateNodeWaiting{Deadline: deadline}
 				q.inFlight++
 				q.stats.Requests++
@@ -305,8 +425,90 @@ func (q *Query[K, N, M]) markFinished(ctx context.Context) {
 	})
 }
 
+// terminate consults the termination policy of the query and reports whether the query should finish.
+func (q *Query[K, N, M]) terminate(ctx context.Context) bool {
+	if q.rounds > q.checkedRounds {
+		q.updateStableRounds(ctx)
+	}
+
+	stats := q.stats
+	if q.termStats != nil {
+		stats = q.termStats()
+	}
+
+	st := &TerminationState{
+		Stats:        stats,
+		NumResults:   q.cfg.NumResults,
+		StableRounds: q.stableRounds,
+	}
+	if !stats.Start.IsZero() {
+		st.Elapsed = q.cfg.Clock.Since(stats.Start)
+	}
+
+	return q.cfg.Termination.Terminate(st)
+}
+
+// updateStableRounds compares the closest nodes that may still be part of the result with those after the
+// rounds that were checked before and counts the completed rounds as stable if they are the same.
+func (q *Query[K, N, M]) updateStableRounds(ctx context.Context) {
+	closest := make([]K, 0, q.cfg.NumResults)
+	q.iter.Each(ctx, func(ctx context.Context, ni *NodeStatus[K, N]) bool {
+		switch ni.State.(type) {
+		case *StateNodeWaiting, *StateNodeSucceeded, *StateNodeNotContacted:
+			closest = append(closest, ni.NodeID.Key())
+		}
+		return len(closest) >= q.cfg.NumResults
+	})
+
+	stable := len(closest) == len(q.roundClosest)
+	for i := 0; stable && i < len(closest); i++ {
+		stable = key.Equal(closest[i], q.roundClosest[i])
+	}
+
+	if stable {
+		q.stableRounds += q.rounds - q.checkedRounds
+	} else {
+		q.stableRounds = 0
+	}
+	q.roundClosest = closest
+	q.checkedRounds = q.rounds
+}
+
+// requestTimeout returns the timeout for a request to the given node.
+func (q *Query[K, N, M]) requestTimeout(node N) time.Duration {
+	if q.rtt == nil {
+		return q.cfg.RequestTimeout
+	}
+	return q.rtt.RequestTimeout(node.Key())
+}
+
+// maxInFlight returns the maximum number of concurrent requests the query currently permits.
+func (q *Query[K, N, M]) maxInFlight() int {
+	if q.concurrency == nil {
+		return q.cfg.Concurrency
+	}
+	return q.concurrency.limit()
+}
+
+func (q *Query[K, N, M]) setRTTEstimator(rtt *RTTEstimator[K]) {
+	q.rtt = rtt
+}
+
+func (q *Query[K, N, M]) queryID() coordt.QueryID {
+	return q.id
+}
+
+func (q *Query[K, N, M]) startTime() time.Time {
+	return q.stats.Start
+}
+
+// waiting reports whether the query is waiting for message responses.
+func (q *Query[K, N, M]) waiting() bool {
+	return q.inFlight > 0
+}
+
 // onNodeResponse processes the result of a successful response received from a node.
-func (q *Query[K, N, M]) onNodeResponse(ctx context.Context, node N, closer []N) {
+func (q *Query[K, N, M]) onNodeResponse(ctx context.Context, node N, closer []N, rtt time.Duration) {
 	ni, found := q.iter.Find(node.Key())
 	if !found {
 		// got a rogue message
@@ -316,6 +518,10 @@ func (q *Query[K, N, M]) onNodeResponse(ctx context.Context, node N, closer []N)
 	case *StateNodeWaiting:
 		q.inFlight--
 		q.stats.Success++
+		if q.concurrency != nil {
+			q.concurrency.onSuccess(rtt)
+		}
+		defer q.onRoundResponse()
 	case *StateNodeUnresponsive:
 		q.stats.Success++
 
@@ -342,10 +548,43 @@ func (q *Query[K, N, M]) onNodeResponse(ctx context.Context, node N, closer []N)
 			NodeID: id,
 			State:  &StateNodeNotContacted{},
 		})
+		if q.updateClosest(id.Key()) {
+			q.roundProgress = true
+		}
 	}
 	ni.State = &StateNodeSucceeded{}
 }
 
+// updateClosest records the given key as the key of the closest node if it is closer to the
+// target than the closest node known so far. It reports whether the closest node changed.
+func (q *Query[K, N, M]) updateClosest(k K) bool {
+	if q.hasClosest && q.target.Xor(k).Compare(q.target.Xor(q.closest)) >= 0 {
+		return false
+	}
+	q.closest = k
+	q.hasClosest = true
+	return true
+}
+
+// onRoundResponse records that a request that was in flight completed. After a round of as many
+// completed requests as the query currently permits to be in flight, the query is marked as stalled
+// if none of them returned a node that is closer to the target than the closest node known before.
+func (q *Query[K, N, M]) onRoundResponse() {
+	q.roundResponses++
+	if q.roundResponses < q.maxInFlight() {
+		return
+	}
+
+	q.rounds++
+	if !q.roundProgress && !q.stalled {
+		q.stats.Escalations++
+	}
+	q.stalled = !q.roundProgress
+
+	q.roundResponses = 0
+	q.roundProgress = false
+}
+
 // onNodeFailure processes the result of a failed attempt to contact a node.
 func (q *Query[K, N, M]) onNodeFailure(ctx context.Context, node N) {
 	ni, found := q.iter.Find(node.Key())
@@ -357,6 +596,10 @@ func (q *Query[K, N, M]) onNodeFailure(ctx context.Context, node N) {
 	case *StateNodeWaiting:
 		q.inFlight--
 		q.stats.Failure++
+		if q.concurrency != nil {
+			q.concurrency.onFailure()
+		}
+		q.onRoundResponse()
 	case *StateNodeUnresponsive:
 		// update node state to failed
 		break
@@ -431,8 +674,9 @@ type EventQueryCancel struct{}
 
 // EventQueryNodeResponse notifies a [Query] that an attempt to contact a node has received a successful response.
 type EventQueryNodeResponse[K kad.Key[K], N kad.NodeID[K]] struct {
-	NodeID      N   // the node the message was sent to
-	CloserNodes []N // the closer nodes sent by the node
+	NodeID      N             // the node the message was sent to
+	CloserNodes []N           // the closer nodes sent by the node
+	RTT         time.Duration // the round-trip time of the request, zero if unknown
 }
 
 // EventQueryNodeFailure notifies a [Query] that an attempt to to contact a node has failed.
@@ -48,6 +48,27 @@ func TestQueryConfigValidate(t *testing.T) {
 		cfg.NumResults = -1
 		require.Error(t, cfg.Validate())
 	})
+
+	t.Run("max concurrency not less than concurrency", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.MaxConcurrency = cfg.Concurrency - 1
+		require.NoError(t, cfg.Validate()) // only validated when enabled
+
+		cfg.AdaptiveConcurrency = true
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("termination policy not nil", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.Termination = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("termination policy valid", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.Termination = RequestBudgetPolicy{Requests: 0}
+		require.Error(t, cfg.Validate())
+	})
 }
 
 func TestQueryMessagesNode(t *testing.T) {
@@ -773,6 +794,48 @@ func TestQueryWithCloserIterFinishesWhenNumResultsReached(t *testing.T) {
 	require.Equal(t, 2, len(stf.ClosestNodes))
 }
 
+func TestQueryScoredIterFinishesWithClosestNodes(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00000101) // 5
+	c := tiny.NewNode(0b00000110) // 6
+	d := tiny.NewNode(0b00010000) // 16
+
+	// c is the most useful node among the nodes of similar distance
+	iter := NewScoredClosestNodesIter[tiny.Key, tiny.Node](target, mapScorer{c.Key(): 1.0, d.Key(): 2.0})
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 1
+	cfg.NumResults = 2
+
+	self := tiny.NewNode(0xff)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, coordt.QueryID("test"), target, iter, []tiny.Node{a, b, c, d}, cfg)
+	require.NoError(t, err)
+
+	// the query contacts c first because of its score
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, c, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the remaining nodes of similar distance are contacted by distance
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: c})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// c and a succeeded but b is closer to the target than c, so the query must contact it before finishing
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: a})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, b, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the query finishes with the closest nodes by distance, not by score
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: b})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, []tiny.Node{a, b}, state.(*StateQueryFinished[tiny.Key, tiny.Node]).ClosestNodes)
+}
+
 func TestQueryWithCloserIterContinuesUntilNumResultsReached(t *testing.T) {
 	ctx := context.Background()
 
@@ -843,6 +906,115 @@ func TestQueryWithCloserIterContinuesUntilNumResultsReached(t *testing.T) {
 	require.Equal(t, 3, stf.Stats.Success)
 }
 
+func TestQueryStalledContactsClosestNodes(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000010) // 2
+	b := tiny.NewNode(0b00000100) // 4
+	c := tiny.NewNode(0b00001000) // 8
+	d := tiny.NewNode(0b00010000) // 16
+	e := tiny.NewNode(0b00000001) // 1
+
+	knownNodes := []tiny.Node{a, b, c, d}
+
+	clk := clock.NewMock()
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.NumResults = 3
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, iter, knownNodes, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+
+	// a completes the round without returning a closer node, so the query is stalled
+	// and contacts the remaining nodes among the closest nodes at once
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: a})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateQueryFindCloser[tiny.Key, tiny.Node])
+	require.Equal(t, b, st.NodeID)
+	require.Equal(t, 1, st.Stats.Escalations)
+
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, c, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// d is not among the closest nodes so the query waits for the outstanding responses
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryWaitingWithCapacity{}, state)
+
+	// b returns a closer node so the query is no longer stalled and returns to its configured concurrency
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{
+		NodeID:      b,
+		CloserNodes: []tiny.Node{e},
+	})
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+	require.Equal(t, 1, state.(*StateQueryWaitingAtCapacity).Stats.Escalations)
+
+	// c completes the next round and e is contacted next
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: c})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, e, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+}
+
+func TestQueryStalledFinishesWhenClosestNodesContacted(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000010) // 2
+	b := tiny.NewNode(0b00000100) // 4
+	c := tiny.NewNode(0b00001000) // 8
+
+	knownNodes := []tiny.Node{a, b, c}
+
+	clk := clock.NewMock()
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.NumResults = 2
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, iter, knownNodes, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// a fails, which completes the round without progress
+	state = qry.Advance(ctx, &EventQueryNodeFailure[tiny.Key, tiny.Node]{NodeID: a})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, b, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the failed node is not counted among the closest nodes, so c is contacted too
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, c, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: b})
+	require.IsType(t, &StateQueryWaitingWithCapacity{}, state)
+
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: c})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	stf := state.(*StateQueryFinished[tiny.Key, tiny.Node])
+	require.Equal(t, []tiny.Node{b, c}, stf.ClosestNodes)
+	require.Equal(t, 1, stf.Stats.Escalations)
+}
+
 func TestQueryNotContactedMakesCapacity(t *testing.T) {
 	ctx := context.Background()
 
@@ -1307,3 +1479,211 @@ func TestFindCloserQueryIncludesPartialClosestNodesWhenCancelled(t *testing.T) {
 	stf := state.(*StateQueryFinished[tiny.Key, tiny.Node])
 	require.Equal(t, 1, len(stf.ClosestNodes))
 }
+
+func TestQueryAdaptiveConcurrency(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00001000) // 8
+	b := tiny.NewNode(0b00010000) // 16
+	c := tiny.NewNode(0b00100000) // 32
+	e := tiny.NewNode(0b00000001) // 1
+
+	clk := clock.NewMock()
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.NumResults = 3
+	cfg.AdaptiveConcurrency = true
+	cfg.MaxConcurrency = 3
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, iter, []tiny.Node{a, b, c}, cfg)
+	require.NoError(t, err)
+
+	// the query starts with a single request in flight
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+
+	// a fast response raises the number of concurrent requests
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{
+		NodeID:      a,
+		CloserNodes: []tiny.Node{e},
+		RTT:         10 * time.Millisecond,
+	})
+	require.Equal(t, 2, qry.maxInFlight())
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, e, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+	require.Equal(t, b, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+
+	// a failure halves the number of concurrent requests
+	qry.Advance(ctx, &EventQueryNodeFailure[tiny.Key, tiny.Node]{NodeID: e})
+	require.Equal(t, 1, qry.maxInFlight())
+}
+
+func TestQueryRoundsFollowAdaptiveConcurrency(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000100) // 4
+	b := tiny.NewNode(0b00001000) // 8
+	c := tiny.NewNode(0b00010000) // 16
+	d := tiny.NewNode(0b00100000) // 32
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 4
+	cfg.NumResults = 4
+	cfg.AdaptiveConcurrency = true
+	cfg.MaxConcurrency = 4
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, iter, []tiny.Node{a, b, c, d}, cfg)
+	require.NoError(t, err)
+
+	for _, n := range []tiny.Node{a, b, c, d} {
+		state := qry.Advance(ctx, &EventQueryPoll{})
+		require.IsType(t, &StateQueryFindCloser[tiny.Key, tiny.Node]{}, state)
+		require.Equal(t, n, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+	}
+
+	// the first failure halves the number of concurrent requests to two
+	qry.Advance(ctx, &EventQueryNodeFailure[tiny.Key, tiny.Node]{NodeID: a})
+	require.Equal(t, 2, qry.maxInFlight())
+	require.Equal(t, 0, qry.rounds)
+
+	// the second failure halves it to one, so two responses complete a round without progress
+	qry.Advance(ctx, &EventQueryNodeFailure[tiny.Key, tiny.Node]{NodeID: b})
+	require.Equal(t, 1, qry.maxInFlight())
+	require.Equal(t, 1, qry.rounds)
+	require.Equal(t, 1, qry.stats.Escalations)
+}
+
+func TestQueryRequestBudget(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000010) // 2
+	b := tiny.NewNode(0b00000100) // 4
+	c := tiny.NewNode(0b00001000) // 8
+
+	clk := clock.NewMock()
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.Termination = RequestBudgetPolicy{Requests: 2}
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, iter, []tiny.Node{a, b, c}, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: a})
+	require.Equal(t, b, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the budget of two requests is spent, so the query finishes without contacting c
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: b})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	stf := state.(*StateQueryFinished[tiny.Key, tiny.Node])
+	require.Equal(t, []tiny.Node{a, b}, stf.ClosestNodes)
+	require.Equal(t, 2, stf.Stats.Requests)
+}
+
+func TestQueryTimeBudget(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000010) // 2
+	b := tiny.NewNode(0b00000100) // 4
+
+	clk := clock.NewMock()
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.Termination = TimeBudgetPolicy{Budget: time.Second}
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, iter, []tiny.Node{a, b}, cfg)
+	require.NoError(t, err)
+
+	// the budget only starts with the first request
+	clk.Add(time.Minute)
+
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	clk.Add(500 * time.Millisecond)
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+
+	// the query finishes once the budget is spent even though a request is in flight
+	clk.Add(500 * time.Millisecond)
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+	require.Empty(t, state.(*StateQueryFinished[tiny.Key, tiny.Node]).ClosestNodes)
+}
+
+func TestQueryStableTermination(t *testing.T) {
+	ctx := context.Background()
+
+	target := tiny.Key(0b00000000)
+	a := tiny.NewNode(0b00000010) // 2
+	b := tiny.NewNode(0b00000100) // 4
+	c := tiny.NewNode(0b00001000) // 8
+	d := tiny.NewNode(0b00010000) // 16
+
+	clk := clock.NewMock()
+
+	iter := NewClosestNodesIter[tiny.Key, tiny.Node](target)
+
+	cfg := DefaultQueryConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.NumResults = 3
+	cfg.Termination = StablePolicy{Rounds: 1}
+
+	self := tiny.NewNode(0b10000000)
+	qry, err := NewFindCloserQuery[tiny.Key, tiny.Node, tiny.Message](self, "test", target, iter, []tiny.Node{a, b, c, d}, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, &EventQueryPoll{})
+	require.Equal(t, a, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the first round establishes the closest nodes, and the stalled query contacts the remaining ones
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: a})
+	require.Equal(t, b, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+	state = qry.Advance(ctx, &EventQueryPoll{})
+	require.Equal(t, c, state.(*StateQueryFindCloser[tiny.Key, tiny.Node]).NodeID)
+
+	// the second round doesn't change the closest nodes, so the query finishes without waiting for c
+	state = qry.Advance(ctx, &EventQueryNodeResponse[tiny.Key, tiny.Node]{NodeID: b})
+	require.IsType(t, &StateQueryFinished[tiny.Key, tiny.Node]{}, state)
+
+	stf := state.(*StateQueryFinished[tiny.Key, tiny.Node])
+	require.Equal(t, []tiny.Node{a, b}, stf.ClosestNodes)
+	require.Equal(t, 3, stf.Stats.Requests)
+}
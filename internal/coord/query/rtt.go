@@ -0,0 +1,225 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/plprobelab/go-libdht/kad"
+	"github.com/plprobelab/go-libdht/kad/key"
+
+	"github.com/plprobelab/zikade/errs"
+)
+
+// RTTConfig specifies optional configuration for an [RTTEstimator].
+type RTTConfig struct {
+	MinTimeout time.Duration // the lower bound for request timeouts
+	MaxTimeout time.Duration // the upper bound for request timeouts, also used when no round-trip times have been observed yet
+	Percentile float64       // the percentile of recently observed round-trip times that is used as the timeout for nodes without history
+	Nodes      int           // the maximum number of nodes for which round-trip time estimates are kept
+	Samples    int           // the number of recently observed round-trip times across all nodes that the percentile is derived from
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *RTTConfig) Validate() error {
+	if cfg.MinTimeout < 1 {
+		return &errs.ConfigurationError{
+			Component: "RTTConfig",
+			Err:       fmt.Errorf("min timeout must be greater than zero"),
+		}
+	}
+
+	if cfg.MaxTimeout < cfg.MinTimeout {
+		return &errs.ConfigurationError{
+			Component: "RTTConfig",
+			Err:       fmt.Errorf("max timeout must not be less than min timeout"),
+		}
+	}
+
+	if cfg.Percentile <= 0 || cfg.Percentile > 1 {
+		return &errs.ConfigurationError{
+			Component: "RTTConfig",
+			Err:       fmt.Errorf("percentile must be greater than zero and not greater than one"),
+		}
+	}
+
+	if cfg.Nodes < 1 {
+		return &errs.ConfigurationError{
+			Component: "RTTConfig",
+			Err:       fmt.Errorf("nodes must be greater than zero"),
+		}
+	}
+
+	if cfg.Samples < 1 {
+		return &errs.ConfigurationError{
+			Component: "RTTConfig",
+			Err:       fmt.Errorf("samples must be greater than zero"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultRTTConfig returns the default configuration options for an RTTEstimator.
+// Options may be overridden before passing to NewRTTEstimator
+func DefaultRTTConfig() *RTTConfig {
+	return &RTTConfig{
+		MinTimeout: time.Second, // MAGIC
+		MaxTimeout: time.Minute, // MAGIC
+		Percentile: 0.99,        // MAGIC
+		Nodes:      1024,        // MAGIC
+		Samples:    256,         // MAGIC
+	}
+}
+
+// The gains and the variance factor of the round-trip time estimator as defined in RFC 6298.
+const (
+	rttAlpha     = 0.125
+	rttBeta      = 0.25
+	rttVarFactor = 4
+)
+
+// rttEstimate is the smoothed round-trip time and its variation for a single node.
+type rttEstimate struct {
+	srtt   time.Duration
+	rttvar time.Duration
+}
+
+// An RTTEstimator estimates the round-trip times of requests to nodes and derives request timeouts from them.
+// It keeps a smoothed round-trip time and its variation for each node in the same way that TCP derives its
+// retransmission timeout (RFC 6298). The timeout for nodes without history is a percentile of the round-trip
+// times recently observed across all nodes.
+//
+// An RTTEstimator is not safe for concurrent use.
+type RTTEstimator[K kad.Key[K]] struct {
+	// cfg is a copy of the optional configuration supplied to the estimator
+	cfg RTTConfig
+
+	// estimates holds the round-trip time estimates of recently contacted nodes, keyed by the hex string of
+	// their Kademlia key.
+	estimates *lru.Cache[string, rttEstimate]
+
+	// samples is a ring buffer of the most recently observed round-trip times across all nodes.
+	samples []time.Duration
+
+	// next is the index in samples that the next observed round-trip time is written to.
+	next int
+
+	// fallback caches the timeout for nodes without history. It is invalidated when a new round-trip time is
+	// observed.
+	fallback      time.Duration
+	fallbackValid bool
+}
+
+// NewRTTEstimator creates a new RTTEstimator
+func NewRTTEstimator[K kad.Key[K]](cfg *RTTConfig) (*RTTEstimator[K], error) {
+	if cfg == nil {
+		cfg = DefaultRTTConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	estimates, err := lru.New[string, rttEstimate](cfg.Nodes)
+	if err != nil {
+		return nil, fmt.Errorf("new lru cache: %w", err)
+	}
+
+	return &RTTEstimator[K]{
+		cfg:       *cfg,
+		estimates: estimates,
+		samples:   make([]time.Duration, 0, cfg.Samples),
+	}, nil
+}
+
+// Observe records the round-trip time of a request to the node with the given key.
+func (e *RTTEstimator[K]) Observe(k K, rtt time.Duration) {
+	if rtt <= 0 {
+		return
+	}
+
+	hk := key.HexString(k)
+	est, found := e.estimates.Get(hk)
+	if !found {
+		est = rttEstimate{
+			srtt:   rtt,
+			rttvar: rtt / 2,
+		}
+	} else {
+		est.rttvar = time.Duration((1-rttBeta)*float64(est.rttvar) + rttBeta*math.Abs(float64(est.srtt-rtt)))
+		est.srtt = time.Duration((1-rttAlpha)*float64(est.srtt) + rttAlpha*float64(rtt))
+	}
+	e.estimates.Add(hk, est)
+
+	if len(e.samples) < cap(e.samples) {
+		e.samples = append(e.samples, rtt)
+	} else {
+		e.samples[e.next] = rtt
+	}
+	e.next = (e.next + 1) % cap(e.samples)
+	e.fallbackValid = false
+}
+
+// ObserveTimeout records that a request to the node with the given key timed out. Like the retransmission timer
+// of TCP (RFC 6298, section 5.5), the timeout for the node is doubled, up to the configured maximum, by raising
+// the variation of its estimate. A node that became slower than its history suggests can therefore still respond
+// to later requests, whose round-trip times then bring the estimate back in line.
+func (e *RTTEstimator[K]) ObserveTimeout(k K) {
+	timeout := e.RequestTimeout(k)
+
+	hk := key.HexString(k)
+	est, found := e.estimates.Get(hk)
+	if !found {
+		est.srtt = timeout
+	}
+
+	est.rttvar = (e.clamp(2*timeout) - est.srtt) / rttVarFactor
+	if est.rttvar < 0 {
+		est.rttvar = 0
+	}
+	e.estimates.Add(hk, est)
+}
+
+// RequestTimeout returns the timeout for a request to the node with the given key. The timeout is always
+// between the configured minimum and maximum timeouts.
+func (e *RTTEstimator[K]) RequestTimeout(k K) time.Duration {
+	est, found := e.estimates.Get(key.HexString(k))
+	if !found {
+		return e.fallbackTimeout()
+	}
+
+	return e.clamp(est.srtt + rttVarFactor*est.rttvar)
+}
+
+// fallbackTimeout returns the timeout for nodes without history.
+func (e *RTTEstimator[K]) fallbackTimeout() time.Duration {
+	if len(e.samples) == 0 {
+		return e.cfg.MaxTimeout
+	}
+
+	if !e.fallbackValid {
+		sorted := make([]time.Duration, len(e.samples))
+		copy(sorted, e.samples)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		idx := int(math.Ceil(e.cfg.Percentile*float64(len(sorted)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		e.fallback = e.clamp(sorted[idx])
+		e.fallbackValid = true
+	}
+
+	return e.fallback
+}
+
+func (e *RTTEstimator[K]) clamp(d time.Duration) time.Duration {
+	if d < e.cfg.MinTimeout {
+		return e.cfg.MinTimeout
+	}
+	if d > e.cfg.MaxTimeout {
+		return e.cfg.MaxTimeout
+	}
+	return d
+}
@@ -0,0 +1,160 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/tiny"
+)
+
+func TestRTTConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultRTTConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("min timeout positive", func(t *testing.T) {
+		cfg := DefaultRTTConfig()
+		cfg.MinTimeout = 0
+		require.Error(t, cfg.Validate())
+		cfg.MinTimeout = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max timeout not less than min timeout", func(t *testing.T) {
+		cfg := DefaultRTTConfig()
+		cfg.MaxTimeout = cfg.MinTimeout - 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("percentile in range", func(t *testing.T) {
+		cfg := DefaultRTTConfig()
+		cfg.Percentile = 0
+		require.Error(t, cfg.Validate())
+		cfg.Percentile = 1.01
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("nodes positive", func(t *testing.T) {
+		cfg := DefaultRTTConfig()
+		cfg.Nodes = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("samples positive", func(t *testing.T) {
+		cfg := DefaultRTTConfig()
+		cfg.Samples = 0
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestRTTEstimator(t *testing.T) {
+	cfg := DefaultRTTConfig()
+	cfg.MinTimeout = 10 * time.Millisecond
+	cfg.MaxTimeout = time.Second
+
+	e, err := NewRTTEstimator[tiny.Key](cfg)
+	require.NoError(t, err)
+
+	a := tiny.Key(0b00000001)
+	b := tiny.Key(0b00000010)
+
+	// without any observations the maximum timeout is used
+	require.Equal(t, cfg.MaxTimeout, e.RequestTimeout(a))
+
+	// the first observation sets the smoothed rtt to the sample and its variation to half the sample
+	e.Observe(a, 100*time.Millisecond)
+	require.Equal(t, 300*time.Millisecond, e.RequestTimeout(a))
+
+	// a sample equal to the smoothed rtt reduces the variation
+	e.Observe(a, 100*time.Millisecond)
+	require.Equal(t, 100*time.Millisecond+4*37500*time.Microsecond, e.RequestTimeout(a))
+
+	// nodes without history use the percentile of all recent observations
+	require.Equal(t, 100*time.Millisecond, e.RequestTimeout(b))
+
+	// non-positive round-trip times are ignored
+	e.Observe(b, 0)
+	require.Equal(t, 100*time.Millisecond, e.RequestTimeout(b))
+}
+
+func TestRTTEstimatorClamps(t *testing.T) {
+	cfg := DefaultRTTConfig()
+	cfg.MinTimeout = 50 * time.Millisecond
+	cfg.MaxTimeout = 200 * time.Millisecond
+
+	e, err := NewRTTEstimator[tiny.Key](cfg)
+	require.NoError(t, err)
+
+	fast := tiny.Key(0b00000001)
+	slow := tiny.Key(0b00000010)
+
+	e.Observe(fast, time.Millisecond)
+	require.Equal(t, cfg.MinTimeout, e.RequestTimeout(fast))
+
+	e.Observe(slow, time.Second)
+	require.Equal(t, cfg.MaxTimeout, e.RequestTimeout(slow))
+}
+
+func TestRTTEstimatorTimeoutBacksOff(t *testing.T) {
+	cfg := DefaultRTTConfig()
+	cfg.MinTimeout = 10 * time.Millisecond
+	cfg.MaxTimeout = time.Second
+
+	e, err := NewRTTEstimator[tiny.Key](cfg)
+	require.NoError(t, err)
+
+	a := tiny.Key(0b00000001)
+	b := tiny.Key(0b00000010)
+
+	// a fast node has a short timeout
+	for i := 0; i < 20; i++ {
+		e.Observe(a, time.Millisecond)
+	}
+	require.Equal(t, cfg.MinTimeout, e.RequestTimeout(a))
+
+	// when the node becomes slower than its timeout, every timeout doubles the next one
+	e.ObserveTimeout(a)
+	require.Equal(t, 20*time.Millisecond, e.RequestTimeout(a))
+	e.ObserveTimeout(a)
+	require.Equal(t, 40*time.Millisecond, e.RequestTimeout(a))
+	e.ObserveTimeout(a)
+	require.Equal(t, 80*time.Millisecond, e.RequestTimeout(a))
+
+	// the node can respond within the longer timeout and its estimate follows the slower round-trip times
+	e.Observe(a, 70*time.Millisecond)
+	require.Greater(t, e.RequestTimeout(a), 70*time.Millisecond)
+
+	// the timeout doesn't grow beyond the maximum
+	for i := 0; i < 10; i++ {
+		e.ObserveTimeout(a)
+	}
+	require.Equal(t, cfg.MaxTimeout, e.RequestTimeout(a))
+
+	// nodes without history start from the timeout for unknown nodes
+	fallback := e.RequestTimeout(b)
+	e.ObserveTimeout(b)
+	require.Equal(t, 2*fallback, e.RequestTimeout(b))
+}
+
+func TestRTTEstimatorPercentile(t *testing.T) {
+	cfg := DefaultRTTConfig()
+	cfg.MinTimeout = time.Millisecond
+	cfg.Percentile = 0.5
+	cfg.Samples = 4
+
+	e, err := NewRTTEstimator[tiny.Key](cfg)
+	require.NoError(t, err)
+
+	for i := 1; i <= 4; i++ {
+		e.Observe(tiny.Key(i), time.Duration(i)*10*time.Millisecond)
+	}
+	require.Equal(t, 20*time.Millisecond, e.RequestTimeout(tiny.Key(100)))
+
+	// older samples are replaced once the buffer is full
+	e.Observe(tiny.Key(5), 50*time.Millisecond)
+	e.Observe(tiny.Key(6), 60*time.Millisecond)
+	require.Equal(t, 40*time.Millisecond, e.RequestTimeout(tiny.Key(100)))
+}
@@ -0,0 +1,152 @@
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/plprobelab/zikade/errs"
+)
+
+// A TerminationPolicy decides whether a [Query] should finish before it ran to completion. A query always
+// finishes once it received successful responses from the [QueryConfig.NumResults] closest nodes that it knows
+// about, or when it has no nodes left to contact. The policy is consulted each time the query is advanced and
+// may end the query earlier, trading the accuracy of the result for speed.
+//
+// A single policy may be shared by many queries, so policies must not keep any state of their own. The
+// [TerminationState] holds everything that the query knows about its progress. A [DisjointQuery] consults
+// the policy for each of its paths separately, passing the statistics of all paths combined, so that request
+// and time budgets apply to all paths together while stable rounds are counted per path.
+type TerminationPolicy interface {
+	// Terminate reports whether the query should finish now.
+	Terminate(st *TerminationState) bool
+
+	// Validate checks the parameters of the policy and returns an error if any have invalid values.
+	Validate() error
+}
+
+// TerminationState describes the progress of a [Query] to a [TerminationPolicy].
+type TerminationState struct {
+	// Stats holds the statistics of the query so far.
+	Stats QueryStats
+
+	// Elapsed is the time since the query sent its first request. It is zero if no request was sent yet.
+	Elapsed time.Duration
+
+	// NumResults is the number of closest nodes the query is looking for.
+	NumResults int
+
+	// StableRounds is the number of consecutive rounds after which the set of the NumResults closest nodes
+	// that the query knows about was the same as after the round before. A round is complete when the query
+	// received as many responses, failures or timeouts as it may have requests in flight.
+	StableRounds int
+}
+
+// KClosestPolicy is the classic Kademlia termination policy. It never ends a query early, so the query runs
+// until it received successful responses from the closest nodes or ran out of nodes to contact. It is the
+// default policy.
+type KClosestPolicy struct{}
+
+var _ TerminationPolicy = KClosestPolicy{}
+
+func (KClosestPolicy) Terminate(*TerminationState) bool { return false }
+
+func (KClosestPolicy) Validate() error { return nil }
+
+// StablePolicy ends a query once the set of the closest nodes that it knows about did not change for a
+// number of rounds. This is the "beta" policy: further rounds are unlikely to find closer nodes, so the
+// query returns the closest nodes that responded so far.
+type StablePolicy struct {
+	// Rounds is the number of consecutive rounds that the closest nodes must remain the same.
+	Rounds int
+}
+
+var _ TerminationPolicy = StablePolicy{}
+
+func (p StablePolicy) Terminate(st *TerminationState) bool {
+	return st.StableRounds >= p.Rounds
+}
+
+func (p StablePolicy) Validate() error {
+	if p.Rounds < 1 {
+		return &errs.ConfigurationError{
+			Component: "StablePolicy",
+			Err:       fmt.Errorf("rounds must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// RequestBudgetPolicy ends a query once it sent a number of requests. The responses to the requests that
+// are in flight at that moment are not awaited.
+type RequestBudgetPolicy struct {
+	// Requests is the maximum number of requests the query may send.
+	Requests int
+}
+
+var _ TerminationPolicy = RequestBudgetPolicy{}
+
+func (p RequestBudgetPolicy) Terminate(st *TerminationState) bool {
+	return st.Stats.Requests >= p.Requests
+}
+
+func (p RequestBudgetPolicy) Validate() error {
+	if p.Requests < 1 {
+		return &errs.ConfigurationError{
+			Component: "RequestBudgetPolicy",
+			Err:       fmt.Errorf("requests must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// TimeBudgetPolicy ends a query once a period of time has passed since it sent its first request.
+type TimeBudgetPolicy struct {
+	// Budget is the maximum time the query may run for.
+	Budget time.Duration
+}
+
+var _ TerminationPolicy = TimeBudgetPolicy{}
+
+func (p TimeBudgetPolicy) Terminate(st *TerminationState) bool {
+	return st.Elapsed >= p.Budget
+}
+
+func (p TimeBudgetPolicy) Validate() error {
+	if p.Budget <= 0 {
+		return &errs.ConfigurationError{
+			Component: "TimeBudgetPolicy",
+			Err:       fmt.Errorf("budget must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// AnyPolicy ends a query as soon as any of its policies would end it. It allows, for example, a request
+// budget to be combined with a time budget.
+type AnyPolicy []TerminationPolicy
+
+var _ TerminationPolicy = AnyPolicy{}
+
+func (p AnyPolicy) Terminate(st *TerminationState) bool {
+	for _, policy := range p {
+		if policy.Terminate(st) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p AnyPolicy) Validate() error {
+	for _, policy := range p {
+		if policy == nil {
+			return &errs.ConfigurationError{
+				Component: "AnyPolicy",
+				Err:       fmt.Errorf("policy must not be nil"),
+			}
+		}
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminationPolicies(t *testing.T) {
+	st := &TerminationState{
+		Stats:        QueryStats{Requests: 5},
+		Elapsed:      2 * time.Second,
+		NumResults:   20,
+		StableRounds: 2,
+	}
+
+	require.False(t, KClosestPolicy{}.Terminate(st))
+
+	require.True(t, StablePolicy{Rounds: 2}.Terminate(st))
+	require.False(t, StablePolicy{Rounds: 3}.Terminate(st))
+
+	require.True(t, RequestBudgetPolicy{Requests: 5}.Terminate(st))
+	require.False(t, RequestBudgetPolicy{Requests: 6}.Terminate(st))
+
+	require.True(t, TimeBudgetPolicy{Budget: 2 * time.Second}.Terminate(st))
+	require.False(t, TimeBudgetPolicy{Budget: 3 * time.Second}.Terminate(st))
+
+	require.False(t, AnyPolicy{}.Terminate(st))
+	require.False(t, AnyPolicy{StablePolicy{Rounds: 3}, RequestBudgetPolicy{Requests: 6}}.Terminate(st))
+	require.True(t, AnyPolicy{StablePolicy{Rounds: 3}, RequestBudgetPolicy{Requests: 5}}.Terminate(st))
+}
+
+func TestTerminationPoliciesValidate(t *testing.T) {
+	require.NoError(t, KClosestPolicy{}.Validate())
+
+	require.NoError(t, StablePolicy{Rounds: 1}.Validate())
+	require.Error(t, StablePolicy{Rounds: 0}.Validate())
+
+	require.NoError(t, RequestBudgetPolicy{Requests: 1}.Validate())
+	require.Error(t, RequestBudgetPolicy{Requests: 0}.Validate())
+
+	require.NoError(t, TimeBudgetPolicy{Budget: time.Second}.Validate())
+	require.Error(t, TimeBudgetPolicy{Budget: 0}.Validate())
+
+	require.NoError(t, AnyPolicy{StablePolicy{Rounds: 1}}.Validate())
+	require.Error(t, AnyPolicy{StablePolicy{Rounds: 0}}.Validate())
+	require.Error(t, AnyPolicy{nil}.Validate())
+}
@@ -77,6 +77,60 @@ func TestQueryConfigValidate(t *testing.T) {
 		cfg.RequestTimeout = -1
 		require.Error(t, cfg.Validate())
 	})
+
+	t.Run("meter not nil", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.Meter = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("priority classes valid", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.Interactive.Weight = 0
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultQueryConfig()
+		cfg.Background.Concurrency = 0
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultQueryConfig()
+		cfg.Maintenance.Concurrency = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("adaptive max concurrency not less than initial", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.MaxConcurrency = cfg.Concurrency - 1
+		require.NoError(t, cfg.Validate()) // only validated when enabled
+
+		cfg.AdaptiveConcurrency = true
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultQueryConfig()
+		cfg.AdaptiveConcurrency = true
+		cfg.MaxRequestConcurrency = cfg.RequestConcurrency - 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("adaptive request timeout min positive", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.RequestTimeoutMin = 0
+		require.NoError(t, cfg.Validate()) // only validated when enabled
+
+		cfg.AdaptiveRequestTimeout = true
+		require.Error(t, cfg.Validate())
+		cfg.RequestTimeoutMin = cfg.RequestTimeout + 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("adaptive request timeout percentile in range", func(t *testing.T) {
+		cfg := DefaultQueryConfig()
+		cfg.AdaptiveRequestTimeout = true
+		cfg.RequestTimeoutPercentile = 0
+		require.Error(t, cfg.Validate())
+		cfg.RequestTimeoutPercentile = 1.5
+		require.Error(t, cfg.Validate())
+	})
 }
 
 func TestQueryBehaviourBase(t *testing.T) {
@@ -2,6 +2,7 @@ package coord
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"github.com/plprobelab/zikade/errs"
 	"github.com/plprobelab/zikade/internal/coord/coordt"
 	"github.com/plprobelab/zikade/internal/coord/cplutil"
+	"github.com/plprobelab/zikade/internal/coord/query"
 	"github.com/plprobelab/zikade/internal/coord/routing"
 	"github.com/plprobelab/zikade/kadt"
 	"github.com/plprobelab/zikade/tele"
@@ -62,6 +64,25 @@ type RoutingConfig struct {
 	// ProbeCheckInterval is the time interval the behaviour should use between connectivity checks for the same node in the routing table.
 	ProbeCheckInterval time.Duration
 
+	// ProbeMaxCheckInterval is the time interval the behaviour should use between connectivity checks for nodes that have
+	// proven to be consistently useful. Nodes are checked less often the higher their score.
+	ProbeMaxCheckInterval time.Duration
+
+	// ProbeMaxFailures is the number of consecutive connectivity checks a node in the routing table may fail before it is removed.
+	ProbeMaxFailures int
+
+	// ProbeFailureBackoff is the time the behaviour should wait before checking a node again after its first failed connectivity check.
+	// The time doubles with every further failure.
+	ProbeFailureBackoff time.Duration
+
+	// ProbeOfflineWindow is the number of most recent connectivity checks the behaviour should use to detect that local connectivity
+	// was lost. Zero disables the detection.
+	ProbeOfflineWindow int
+
+	// ProbeOfflineThreshold is the share of failed checks among the most recent ProbeOfflineWindow checks at or above which the behaviour
+	// considers local connectivity lost and stops removing nodes from the routing table.
+	ProbeOfflineThreshold float64
+
 	// IncludeQueueCapacity is the maximum number of nodes the behaviour should keep queued as candidates for inclusion in the routing table.
 	IncludeQueueCapacity int
 
@@ -69,6 +90,17 @@ type RoutingConfig struct {
 	// connectivity checks for nodes in the inclusion candidate queue.
 	IncludeRequestConcurrency int
 
+	// IncludeReplacementCapacity is the maximum number of verified nodes the behaviour should keep for each bucket of the routing table
+	// to replace nodes that are removed from it. Zero disables the replacement of removed nodes.
+	IncludeReplacementCapacity int
+
+	// IncludeCandidateTTL is the time a candidate may wait in the inclusion candidate queue before the behaviour drops it.
+	IncludeCandidateTTL time.Duration
+
+	// IncludeBucketSize is the number of nodes at which a bucket of the routing table is considered full. Replacements
+	// that the routing table rejects while their bucket is not full are dropped.
+	IncludeBucketSize int
+
 	// ExploreTimeout is the time the behaviour should wait before terminating an exploration of a routing table bucket if it is not making progress.
 	ExploreTimeout time.Duration
 
@@ -81,7 +113,10 @@ type RoutingConfig struct {
 	ExploreRequestTimeout time.Duration
 
 	// ExploreMaximumCpl is the maximum CPL (common prefix length) the behaviour should explore to increase routing table occupancy.
-	// All CPLs from this value to zero will be explored on a repeating schedule.
+	// All CPLs from this value to zero will be explored on a repeating schedule. Targets for CPLs greater than 15 have
+	// to be searched for, which takes twice as long for every additional bit, see [cplutil.PeerIDGenerator]. The search
+	// is spread across several polls of the behaviour. CPLs greater than [cplutil.MaxSearchCpl] are explored by looking
+	// up the local node's own key, see [exploreNodeIDFunc]. It must not be greater than [cplutil.MaxCpl].
 	ExploreMaximumCpl int
 
 	// ExploreInterval is the base time interval the behaviour should leave between explorations of the same CPL.
@@ -97,6 +132,24 @@ type RoutingConfig struct {
 	// It must be between 0 and 0.05. When zero, no jitter is applied.
 	// See the documentation for [routing.DynamicExploreSchedule] for the precise formula used to calculate explore intervals.
 	ExploreIntervalJitter float64
+
+	// ExploreAdaptiveSchedule specifies whether the behaviour should schedule explorations according to the occupancy of
+	// the routing table's buckets instead of using fixed intervals. Buckets that are under-filled or whose size recently
+	// changed are explored sooner and full, stable buckets later, within ExploreMinInterval and ExploreMaxInterval.
+	// See the documentation for [routing.AdaptiveExploreSchedule] for the precise formula used to calculate explore intervals.
+	ExploreAdaptiveSchedule bool
+
+	// ExploreMinInterval is the time interval the behaviour should leave between explorations of the most under-filled CPLs.
+	// This setting is only considered if ExploreAdaptiveSchedule is true.
+	ExploreMinInterval time.Duration
+
+	// ExploreMaxInterval is the time interval the behaviour should leave between explorations of full and stable CPLs.
+	// This setting is only considered if ExploreAdaptiveSchedule is true.
+	ExploreMaxInterval time.Duration
+
+	// ExploreBucketSize is the number of nodes at which a bucket of the routing table is considered full.
+	// This setting is only considered if ExploreAdaptiveSchedule is true.
+	ExploreBucketSize int
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -171,6 +224,41 @@ func (cfg *RoutingConfig) Validate() error {
 		}
 	}
 
+	if cfg.ProbeMaxCheckInterval < cfg.ProbeCheckInterval {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe maximum check interval must not be less than probe check interval"),
+		}
+	}
+
+	if cfg.ProbeMaxFailures < 1 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe maximum failures must be greater than zero"),
+		}
+	}
+
+	if cfg.ProbeFailureBackoff < 1 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe failure backoff must be greater than zero"),
+		}
+	}
+
+	if cfg.ProbeOfflineWindow < 0 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe offline window must not be negative"),
+		}
+	}
+
+	if cfg.ProbeOfflineThreshold <= 0 || cfg.ProbeOfflineThreshold > 1 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("probe offline threshold must be greater than zero and at most one"),
+		}
+	}
+
 	if cfg.IncludeQueueCapacity < 1 {
 		return &errs.ConfigurationError{
 			Component: "RoutingConfig",
@@ -185,6 +273,27 @@ func (cfg *RoutingConfig) Validate() error {
 		}
 	}
 
+	if cfg.IncludeReplacementCapacity < 0 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("include replacement capacity must not be negative"),
+		}
+	}
+
+	if cfg.IncludeBucketSize < 1 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("include bucket size must be greater than zero"),
+		}
+	}
+
+	if cfg.IncludeCandidateTTL < 1 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("include candidate ttl must be greater than zero"),
+		}
+	}
+
 	if cfg.ExploreTimeout < 1 {
 		return &errs.ConfigurationError{
 			Component: "RoutingConfig",
@@ -213,11 +322,10 @@ func (cfg *RoutingConfig) Validate() error {
 		}
 	}
 
-	// This limit exists because we can only generate 15 bit prefixes [cplutil.GenRandPeerID].
-	if cfg.ExploreMaximumCpl > 15 {
+	if cfg.ExploreMaximumCpl > cplutil.MaxCpl {
 		return &errs.ConfigurationError{
 			Component: "RoutingConfig",
-			Err:       fmt.Errorf("explore maximum cpl must be 15 or less"),
+			Err:       fmt.Errorf("explore maximum cpl must be %d or less", cplutil.MaxCpl),
 		}
 	}
 
@@ -249,6 +357,27 @@ func (cfg *RoutingConfig) Validate() error {
 		}
 	}
 
+	if cfg.ExploreMinInterval < 1 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore minimum interval must be greater than zero"),
+		}
+	}
+
+	if cfg.ExploreMaxInterval < cfg.ExploreMinInterval {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore maximum interval must not be less than explore minimum interval"),
+		}
+	}
+
+	if cfg.ExploreBucketSize < 1 {
+		return &errs.ConfigurationError{
+			Component: "RoutingConfig",
+			Err:       fmt.Errorf("explore bucket size must be greater than zero"),
+		}
+	}
+
 	return nil
 }
 
@@ -265,11 +394,19 @@ func DefaultRoutingConfig() *RoutingConfig {
 
 		ConnectivityCheckTimeout: time.Minute, // MAGIC
 
-		ProbeRequestConcurrency: 3,             // MAGIC
-		ProbeCheckInterval:      6 * time.Hour, // MAGIC
+		ProbeRequestConcurrency: 3,              // MAGIC
+		ProbeCheckInterval:      6 * time.Hour,  // MAGIC
+		ProbeMaxCheckInterval:   24 * time.Hour, // MAGIC
+		ProbeMaxFailures:        3,              // MAGIC
+		ProbeFailureBackoff:     time.Minute,    // MAGIC
+		ProbeOfflineWindow:      10,             // MAGIC
+		ProbeOfflineThreshold:   0.8,            // MAGIC
 
-		IncludeRequestConcurrency: 3,   // MAGIC
-		IncludeQueueCapacity:      128, // MAGIC
+		IncludeRequestConcurrency:  3,                // MAGIC
+		IncludeQueueCapacity:       128,              // MAGIC
+		IncludeReplacementCapacity: 8,                // MAGIC
+		IncludeCandidateTTL:        10 * time.Minute, // MAGIC
+		IncludeBucketSize:          20,               // MAGIC
 
 		ExploreTimeout:            5 * time.Minute, // MAGIC
 		ExploreRequestConcurrency: 3,               // MAGIC
@@ -278,7 +415,10 @@ func DefaultRoutingConfig() *RoutingConfig {
 		ExploreInterval:           time.Hour, // MAGIC
 		ExploreIntervalMultiplier: 1,         // MAGIC
 		ExploreIntervalJitter:     0,         // MAGIC
-
+		ExploreAdaptiveSchedule:   false,
+		ExploreMinInterval:        10 * time.Minute, // MAGIC
+		ExploreMaxInterval:        12 * time.Hour,   // MAGIC
+		ExploreBucketSize:         20,               // MAGIC
 	}
 }
 
@@ -319,6 +459,11 @@ type RoutingBehaviour struct {
 	// pendingInbound is a queue of inbound events that are awaiting processing
 	pendingInbound []CtxEvent[BehaviourEvent]
 
+	// exploreSearching records whether the explore is searching for a target and needs to be polled again to
+	// continue the search.
+	// it must only be accessed while performMu is held
+	exploreSearching bool
+
 	ready chan struct{}
 }
 
@@ -349,6 +494,9 @@ func NewRoutingBehaviour(self kadt.PeerID, rt routing.RoutingTableCpl[kadt.Key,
 	includeCfg.Timeout = cfg.ConnectivityCheckTimeout
 	includeCfg.QueueCapacity = cfg.IncludeQueueCapacity
 	includeCfg.Concurrency = cfg.IncludeRequestConcurrency
+	includeCfg.ReplacementCapacity = cfg.IncludeReplacementCapacity
+	includeCfg.CandidateTTL = cfg.IncludeCandidateTTL
+	includeCfg.BucketSize = cfg.IncludeBucketSize
 
 	include, err := routing.NewInclude[kadt.Key, kadt.PeerID](rt, includeCfg)
 	if err != nil {
@@ -362,6 +510,11 @@ func NewRoutingBehaviour(self kadt.PeerID, rt routing.RoutingTableCpl[kadt.Key,
 	probeCfg.Timeout = cfg.ConnectivityCheckTimeout
 	probeCfg.Concurrency = cfg.ProbeRequestConcurrency
 	probeCfg.CheckInterval = cfg.ProbeCheckInterval
+	probeCfg.MaxCheckInterval = cfg.ProbeMaxCheckInterval
+	probeCfg.MaxFailures = cfg.ProbeMaxFailures
+	probeCfg.FailureBackoff = cfg.ProbeFailureBackoff
+	probeCfg.OfflineWindow = cfg.ProbeOfflineWindow
+	probeCfg.OfflineThreshold = cfg.ProbeOfflineThreshold
 
 	probe, err := routing.NewProbe[kadt.Key](rt, probeCfg)
 	if err != nil {
@@ -376,12 +529,17 @@ func NewRoutingBehaviour(self kadt.PeerID, rt routing.RoutingTableCpl[kadt.Key,
 	exploreCfg.RequestConcurrency = cfg.ExploreRequestConcurrency
 	exploreCfg.RequestTimeout = cfg.ExploreRequestTimeout
 
-	schedule, err := routing.NewDynamicExploreSchedule(cfg.ExploreMaximumCpl, cfg.Clock.Now(), cfg.ExploreInterval, cfg.ExploreIntervalMultiplier, cfg.ExploreIntervalJitter)
+	var schedule routing.ExploreSchedule
+	if cfg.ExploreAdaptiveSchedule {
+		schedule, err = routing.NewAdaptiveExploreSchedule[kadt.Key, kadt.PeerID](rt, cfg.ExploreMaximumCpl, cfg.Clock.Now(), cfg.ExploreBucketSize, cfg.ExploreMinInterval, cfg.ExploreMaxInterval)
+	} else {
+		schedule, err = routing.NewDynamicExploreSchedule(cfg.ExploreMaximumCpl, cfg.Clock.Now(), cfg.ExploreInterval, cfg.ExploreIntervalMultiplier, cfg.ExploreIntervalJitter)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("explore schedule: %w", err)
 	}
 
-	explore, err := routing.NewExplore[kadt.Key](self, rt, cplutil.GenRandPeerID, schedule, exploreCfg)
+	explore, err := routing.NewExplore[kadt.Key](self, rt, exploreNodeIDFunc(self, cplutil.NewPeerIDGenerator(cplutil.DefaultSearchBudget)), schedule, exploreCfg)
 	if err != nil {
 		return nil, fmt.Errorf("explore: %w", err)
 	}
@@ -389,6 +547,29 @@ func NewRoutingBehaviour(self kadt.PeerID, rt routing.RoutingTableCpl[kadt.Key,
 	return ComposeRoutingBehaviour(self, bootstrap, include, probe, explore, cfg)
 }
 
+// exploreNodeIDFunc returns a function that generates explore targets with g. A search that exhausted the budget
+// of g is reported to the explore as incomplete so that it continues on the next poll.
+//
+// The target of an explore can't simply be a random key with the required prefix. Remote peers hash the key of a
+// FIND_NODE request to find the closest nodes, so the request has to carry a preimage of the target key and
+// finding one takes as long as finding a peer ID. For CPLs greater than [cplutil.MaxSearchCpl] the function
+// therefore returns self instead. The nodes closest to self fill the buckets with the longest common prefixes, so
+// looking them up refreshes every bucket whose nodes are among the closest nodes to self, which covers all buckets
+// beyond [cplutil.MaxSearchCpl] in networks of up to several millions of peers.
+func exploreNodeIDFunc(self kadt.PeerID, g *cplutil.PeerIDGenerator) routing.NodeIDForCplFunc[kadt.Key, kadt.PeerID] {
+	return func(k kadt.Key, cpl int) (kadt.PeerID, error) {
+		if cpl > cplutil.MaxSearchCpl && cpl <= cplutil.MaxCpl {
+			return self, nil
+		}
+
+		id, err := g.GenRandPeerID(k, cpl)
+		if errors.Is(err, cplutil.ErrSearchIncomplete) {
+			return "", fmt.Errorf("%w: %w", routing.ErrNodeIDSearchIncomplete, err)
+		}
+		return id, err
+	}
+}
+
 // ComposeRoutingBehaviour creates a [RoutingBehaviour] composed of the supplied state machines.
 // The state machines are assumed to pre-configured so any [RoutingConfig] values relating to the state machines will not be applied.
 func ComposeRoutingBehaviour(
@@ -432,6 +613,16 @@ func (r *RoutingBehaviour) Notify(ctx context.Context, ev BehaviourEvent) {
 	}
 }
 
+// Score returns the score the probe state machine assigned to the node and whether the node is known to it.
+// Nodes with a higher score have proven to be more useful. It is safe to call Score concurrently with Perform.
+func (r *RoutingBehaviour) Score(id kadt.PeerID) (float64, bool) {
+	scorer, ok := r.probe.(query.NodeScorer[kadt.Key, kadt.PeerID])
+	if !ok {
+		return 0, false
+	}
+	return scorer.Score(id)
+}
+
 func (r *RoutingBehaviour) Ready() <-chan struct{} {
 	return r.ready
 }
@@ -475,7 +666,7 @@ func (r *RoutingBehaviour) nextPendingOutbound() (BehaviourEvent, bool) {
 }
 
 func (r *RoutingBehaviour) updateReadyStatus() {
-	if len(r.pendingOutbound) != 0 {
+	if len(r.pendingOutbound) != 0 || r.exploreSearching {
 		select {
 		case r.ready <- struct{}{}:
 		default:
@@ -584,6 +775,7 @@ func (r *RoutingBehaviour) perfomNextInbound() (BehaviourEvent, bool) {
 			if len(ev.CloserNodes) > 0 {
 				cmd = &routing.EventProbeConnectivityCheckSuccess[kadt.Key, kadt.PeerID]{
 					NodeID: ev.To,
+					RTT:    ev.RTT,
 				}
 			} else {
 				cmd = &routing.EventProbeConnectivityCheckFailure[kadt.Key, kadt.PeerID]{
@@ -658,7 +850,8 @@ func (r *RoutingBehaviour) perfomNextInbound() (BehaviourEvent, bool) {
 
 		// tell the include state machine in case this is a new peer that could be added to the routing table
 		cmd := &routing.EventIncludeAddCandidate[kadt.Key, kadt.PeerID]{
-			NodeID: ev.NodeID,
+			NodeID:    ev.NodeID,
+			Connected: true,
 		}
 		next, ok := r.advanceInclude(ctx, cmd)
 		if ok {
@@ -670,6 +863,16 @@ func (r *RoutingBehaviour) perfomNextInbound() (BehaviourEvent, bool) {
 			NodeID: ev.NodeID,
 		}
 		return r.advanceProbe(ctx, cmdProbe)
+	case *EventNotifyResponse:
+		span.SetAttributes(attribute.String("event", "EventNotifyResponse"), attribute.String("nodeid", ev.NodeID.String()))
+
+		// tell the probe state machine so it can update the node's score
+		cmdProbe := &routing.EventProbeNotifyQueryResult[kadt.Key, kadt.PeerID]{
+			NodeID:  ev.NodeID,
+			Success: true,
+			RTT:     ev.RTT,
+		}
+		return r.advanceProbe(ctx, cmdProbe)
 	case *EventNotifyNonConnectivity:
 		span.SetAttributes(attribute.String("event", "EventNotifyConnectivity"), attribute.String("nodeid", ev.NodeID.String()))
 
@@ -772,6 +975,12 @@ func (r *RoutingBehaviour) advanceInclude(ctx context.Context, ev routing.Includ
 		return &EventRoutingUpdated{
 			NodeID: st.NodeID,
 		}, true
+	case *routing.StateIncludeBucketFull[kadt.Key, kadt.PeerID]:
+		// a node could not be added because its bucket is full, check whether the bucket's oldest node is still alive
+		r.cfg.Logger.Debug("bucket full, checking least recently seen peer", tele.LogAttrPeerID(st.NodeID), slog.String("oldest", st.Oldest.String()))
+		return r.advanceProbe(ctx, &routing.EventProbeCheckEvictable[kadt.Key, kadt.PeerID]{
+			NodeID: st.Oldest,
+		})
 	case *routing.StateIncludeWaitingAtCapacity:
 		// nothing to do except wait for message response or timeout
 	case *routing.StateIncludeWaitingWithCapacity:
@@ -810,6 +1019,14 @@ func (r *RoutingBehaviour) advanceProbe(ctx context.Context, ev routing.ProbeEve
 			NodeID: st.NodeID,
 		})
 
+		// let the include state machine fill the gap from its replacement cache
+		next, ok := r.advanceInclude(ctx, &routing.EventIncludeNodeRemoved[kadt.Key, kadt.PeerID]{
+			NodeID: st.NodeID,
+		})
+		if ok {
+			r.pendingOutbound = append(r.pendingOutbound, next)
+		}
+
 		// add the node to the inclusion list for a second chance
 		r.Notify(ctx, &EventAddNode{
 			NodeID: st.NodeID,
@@ -835,6 +1052,7 @@ func (r *RoutingBehaviour) advanceExplore(ctx context.Context, ev routing.Explor
 	ctx, span := r.cfg.Tracer.Start(ctx, "RoutingBehaviour.advanceExplore")
 	defer span.End()
 	bstate := r.explore.Advance(ctx, ev)
+	_, r.exploreSearching = bstate.(*routing.StateExploreSearching)
 	switch st := bstate.(type) {
 
 	case *routing.StateExploreFindCloser[kadt.Key, kadt.PeerID]:
@@ -846,6 +1064,8 @@ func (r *RoutingBehaviour) advanceExplore(ctx context.Context, ev routing.Explor
 			Notify:  r,
 		}, true
 
+	case *routing.StateExploreSearching:
+		// explore searching for a target, the search continues when the behaviour is polled again
 	case *routing.StateExploreWaiting:
 		// explore waiting for a message response, nothing to do
 	case *routing.StateExploreQueryFinished:
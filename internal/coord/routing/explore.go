@@ -3,7 +3,9 @@ package routing
 import (
 	"container/heap"
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"sync/atomic"
 	"time"
@@ -49,6 +51,12 @@ type Explore[K kad.Key[K], N kad.NodeID[K]] struct {
 	// qryCpl is the cpl the current query is exploring for
 	qryCpl int
 
+	// searchCpl is the cpl that cplFn is searching a node id for across several advances, -1 if there is no search
+	searchCpl int
+
+	// searchStart is the time the search for a node id for searchCpl started
+	searchStart time.Time
+
 	// cfg is a copy of the optional configuration supplied to the Explore
 	cfg ExploreConfig
 
@@ -75,9 +83,21 @@ type Explore[K kad.Key[K], N kad.NodeID[K]] struct {
 
 // NodeIDForCplFunc is a function that given a cpl generates a [kad.NodeID] with a key that has
 // a common prefix length with k of length cpl.
-// Invariant: CommonPrefixLength(k, node.Key()) = cpl
+// Invariant: CommonPrefixLength(k, node.Key()) >= cpl
+//
+// A function that cannot generate a node id for a long cpl in reasonable time may return a node id whose key
+// has a longer common prefix with k, such as the local node's own id. The nodes closest to it also fill the
+// bucket for cpl once the bucket only holds nodes that are among the closest to the local node.
+//
+// A function that has to search for a node id may stop after a small amount of work and return an error
+// wrapping [ErrNodeIDSearchIncomplete]. The [Explore] then calls it again with the same arguments on its next
+// advance so that a long search does not hold up other work.
 type NodeIDForCplFunc[K kad.Key[K], N kad.NodeID[K]] func(k K, cpl int) (N, error)
 
+// ErrNodeIDSearchIncomplete is returned by a [NodeIDForCplFunc] that has not found a node id yet but continues
+// its search when it is called again.
+var ErrNodeIDSearchIncomplete = errors.New("node id search incomplete")
+
 // An ExploreSchedule provides an ordering for explorations of each cpl in a routing table.
 type ExploreSchedule interface {
 	// NextCpl returns the first cpl to be explored whose due time is before or equal to the given time.
@@ -86,6 +106,14 @@ type ExploreSchedule interface {
 	NextCpl(ts time.Time) (int, bool)
 }
 
+// An ExploreResultObserver is an [ExploreSchedule] that adapts to the outcome of explorations. If the schedule
+// supplied to an [Explore] implements ExploreResultObserver it is notified whenever the exploration of a cpl ends.
+type ExploreResultObserver interface {
+	// ExploreResult is called when the exploration of cpl ended at the given time. success is false if the explore
+	// query timed out or could not be started.
+	ExploreResult(cpl int, ts time.Time, success bool)
+}
+
 // ExploreConfig specifies optional configuration for an [Explore]
 type ExploreConfig struct {
 	// Clock is  a clock that may replaced by a mock when testing
@@ -173,12 +201,13 @@ func NewExplore[K kad.Key[K], N kad.NodeID[K]](self N, rt RoutingTableCpl[K, N],
 	}
 
 	e := &Explore[K, N]{
-		self:     self,
-		cplFn:    cplFn,
-		rt:       rt,
-		cfg:      *cfg,
-		qryCpl:   -1,
-		schedule: schedule,
+		self:      self,
+		cplFn:     cplFn,
+		rt:        rt,
+		cfg:       *cfg,
+		qryCpl:    -1,
+		searchCpl: -1,
+		schedule:  schedule,
 	}
 	e.cplAttributeSet.Store(attribute.NewSet())
 
@@ -260,15 +289,26 @@ func (e *Explore[K, N]) Advance(ctx context.Context, ev ExploreEvent) (out Explo
 		return e.advanceQuery(ctx, &query.EventQueryPoll{})
 	}
 
-	// is an explore due yet?
-	cpl, ok := e.schedule.NextCpl(e.cfg.Clock.Now())
-	if !ok {
-		return &StateExploreIdle{}
+	// continue searching for a node id or check whether an explore is due yet
+	cpl := e.searchCpl
+	if cpl < 0 {
+		var ok bool
+		cpl, ok = e.schedule.NextCpl(e.cfg.Clock.Now())
+		if !ok {
+			return &StateExploreIdle{}
+		}
+		e.searchStart = e.cfg.Clock.Now()
 	}
 
 	// start an explore query by synthesizing a node whose key has the appropriate cpl
 	node, err := e.cplFn(e.self.Key(), cpl)
+	if errors.Is(err, ErrNodeIDSearchIncomplete) && e.cfg.Clock.Since(e.searchStart) <= e.cfg.Timeout {
+		e.searchCpl = cpl
+		return &StateExploreSearching{Cpl: cpl}
+	}
+	e.searchCpl = -1
 	if err != nil {
+		e.observeResult(cpl, false)
 		return &StateExploreFailure{
 			Cpl:   cpl,
 			Error: fmt.Errorf("synthesize random node for cpl %d: %w", cpl, err),
@@ -285,6 +325,7 @@ func (e *Explore[K, N]) Advance(ctx context.Context, ev ExploreEvent) (out Explo
 
 	qry, err := query.NewFindCloserQuery[K, N, any](e.self, ExploreQueryID, node.Key(), iter, seeds, qryCfg)
 	if err != nil {
+		e.observeResult(cpl, false)
 		return &StateExploreFailure{
 			Cpl:   cpl,
 			Error: fmt.Errorf("start explore query for cpl %d: %w", cpl, err),
@@ -314,9 +355,11 @@ func (e *Explore[K, N]) advanceQuery(ctx context.Context, qev query.QueryEvent)
 		}
 	case *query.StateQueryFinished[K, N]:
 		span.SetAttributes(attribute.String("out_state", "StateExploreFinished"))
+		cpl := e.qryCpl
 		e.clearQuery()
+		e.observeResult(cpl, true)
 		return &StateExploreQueryFinished{
-			Cpl:   e.qryCpl,
+			Cpl:   cpl,
 			Stats: st.Stats,
 		}
 	case *query.StateQueryWaitingAtCapacity:
@@ -324,9 +367,11 @@ func (e *Explore[K, N]) advanceQuery(ctx context.Context, qev query.QueryEvent)
 		if elapsed > e.cfg.Timeout {
 			e.counterFindFailed.Add(ctx, 1, metric.WithAttributeSet(e.cplAttributeSet.Load().(attribute.Set)))
 			span.SetAttributes(attribute.String("out_state", "StateExploreTimeout"))
+			cpl := e.qryCpl
 			e.clearQuery()
+			e.observeResult(cpl, false)
 			return &StateExploreQueryTimeout{
-				Cpl:   e.qryCpl,
+				Cpl:   cpl,
 				Stats: st.Stats,
 			}
 		}
@@ -340,9 +385,11 @@ func (e *Explore[K, N]) advanceQuery(ctx context.Context, qev query.QueryEvent)
 		if elapsed > e.cfg.Timeout {
 			e.counterFindFailed.Add(ctx, 1, metric.WithAttributeSet(e.cplAttributeSet.Load().(attribute.Set)))
 			span.SetAttributes(attribute.String("out_state", "StateExploreTimeout"))
+			cpl := e.qryCpl
 			e.clearQuery()
+			e.observeResult(cpl, false)
 			return &StateExploreQueryTimeout{
-				Cpl:   e.qryCpl,
+				Cpl:   cpl,
 				Stats: st.Stats,
 			}
 		}
@@ -356,6 +403,13 @@ func (e *Explore[K, N]) advanceQuery(ctx context.Context, qev query.QueryEvent)
 	}
 }
 
+// observeResult notifies the schedule of the outcome of the exploration of cpl if it adapts to outcomes.
+func (e *Explore[K, N]) observeResult(cpl int, success bool) {
+	if o, ok := e.schedule.(ExploreResultObserver); ok {
+		o.ExploreResult(cpl, e.cfg.Clock.Now(), success)
+	}
+}
+
 func (e *Explore[K, N]) clearQuery() {
 	e.qry = nil
 	e.qryCpl = -1
@@ -379,6 +433,12 @@ type StateExploreFindCloser[K kad.Key[K], N kad.NodeID[K]] struct {
 	Stats   query.QueryStats
 }
 
+// StateExploreSearching indicates that the explore is searching for a node id to explore a cpl with. The
+// search continues with the next advance of the explore.
+type StateExploreSearching struct {
+	Cpl int // the cpl being explored
+}
+
 // StateExploreWaiting indicates that the explore query is waiting for a response.
 type StateExploreWaiting struct {
 	Cpl   int // the cpl being explored
@@ -407,6 +467,7 @@ type StateExploreFailure struct {
 // exploreState() ensures that only [Explore] states can be assigned to an [ExploreState].
 func (*StateExploreIdle) exploreState()             {}
 func (*StateExploreFindCloser[K, N]) exploreState() {}
+func (*StateExploreSearching) exploreState()        {}
 func (*StateExploreWaiting) exploreState()          {}
 func (*StateExploreQueryFinished) exploreState()    {}
 func (*StateExploreQueryTimeout) exploreState()     {}
@@ -587,6 +648,144 @@ func (s *DynamicExploreSchedule) cplInterval(cpl int) time.Duration {
 	return time.Duration(interval)
 }
 
+// An AdaptiveExploreSchedule calculates an explore schedule from the occupancy of the routing table's buckets and
+// the outcome of earlier explorations.
+//
+// The interval until the next exploration of a cpl is derived from the urgency of the bucket, which is the larger
+// of how empty the bucket is and how much its size changed since the cpl was last explored:
+//
+//	urgency  = max(1 - size / bucketSize, |size - previous size| / bucketSize)
+//	interval = maxInterval - urgency x (maxInterval - minInterval)
+//
+// Empty or churning buckets are therefore explored every minInterval while full buckets whose size is stable are
+// explored every maxInterval. Since the urgency is calculated whenever the schedule is consulted, a bucket that
+// loses nodes becomes due sooner. Each consecutive exploration of a cpl that fails doubles its interval, up to
+// maxInterval.
+type AdaptiveExploreSchedule[K kad.Key[K], N kad.NodeID[K]] struct {
+	// rt is the routing table whose buckets are explored
+	rt RoutingTableCpl[K, N]
+
+	// bucketSize is the number of nodes at which a bucket is considered full
+	bucketSize int
+
+	// minInterval is the time interval to leave between explorations of the most urgent CPLs.
+	minInterval time.Duration
+
+	// maxInterval is the time interval to leave between explorations of full and stable CPLs.
+	maxInterval time.Duration
+
+	// cpls holds the exploration history of each cpl, indexed by cpl
+	cpls []adaptiveExploreEntry
+}
+
+type adaptiveExploreEntry struct {
+	Last     time.Time // the time the cpl was last explored
+	Size     int       // the size of the bucket when the cpl was last explored
+	Failures int       // the number of consecutive explorations of the cpl that failed
+}
+
+// NewAdaptiveExploreSchedule creates a new adaptive explore schedule for the buckets of rt.
+//
+// maxCpl is the maximum CPL (common prefix length) that will be scheduled.
+// bucketSize is the number of nodes at which a bucket is considered full.
+// minInterval and maxInterval are the bounds of the time interval left between explorations of the same CPL.
+func NewAdaptiveExploreSchedule[K kad.Key[K], N kad.NodeID[K]](rt RoutingTableCpl[K, N], maxCpl int, start time.Time, bucketSize int, minInterval, maxInterval time.Duration) (*AdaptiveExploreSchedule[K, N], error) {
+	if maxCpl < 1 {
+		return nil, fmt.Errorf("maximum cpl must be greater than zero")
+	}
+
+	if bucketSize < 1 {
+		return nil, fmt.Errorf("bucket size must be greater than zero")
+	}
+
+	if minInterval < 1 {
+		return nil, fmt.Errorf("minimum interval must be greater than zero")
+	}
+
+	if maxInterval < minInterval {
+		return nil, fmt.Errorf("maximum interval must not be less than minimum interval")
+	}
+
+	s := &AdaptiveExploreSchedule[K, N]{
+		rt:          rt,
+		bucketSize:  bucketSize,
+		minInterval: minInterval,
+		maxInterval: maxInterval,
+		cpls:        make([]adaptiveExploreEntry, maxCpl+1),
+	}
+
+	for cpl := range s.cpls {
+		s.cpls[cpl] = adaptiveExploreEntry{
+			Last: start,
+			Size: rt.CplSize(cpl),
+		}
+	}
+
+	return s, nil
+}
+
+// NextCpl returns the cpl whose exploration is most overdue at the given time. If several cpls are due at the same
+// time the lowest is returned.
+func (s *AdaptiveExploreSchedule[K, N]) NextCpl(ts time.Time) (int, bool) {
+	next := -1
+	var nextDue time.Time
+	for cpl := range s.cpls {
+		due := s.cpls[cpl].Last.Add(s.cplInterval(cpl))
+		if due.After(ts) {
+			continue
+		}
+		if next == -1 || due.Before(nextDue) {
+			next, nextDue = cpl, due
+		}
+	}
+
+	if next == -1 {
+		return -1, false
+	}
+
+	s.cpls[next].Last = ts
+	s.cpls[next].Size = s.rt.CplSize(next)
+	return next, true
+}
+
+// ExploreResult records the outcome of the exploration of cpl. The next exploration of the cpl is scheduled
+// relative to the time the exploration ended.
+func (s *AdaptiveExploreSchedule[K, N]) ExploreResult(cpl int, ts time.Time, success bool) {
+	if cpl < 0 || cpl >= len(s.cpls) {
+		return
+	}
+
+	s.cpls[cpl].Last = ts
+	if success {
+		s.cpls[cpl].Failures = 0
+	} else {
+		s.cpls[cpl].Failures++
+	}
+}
+
+// cplInterval calculates the current explore interval for a given cpl
+func (s *AdaptiveExploreSchedule[K, N]) cplInterval(cpl int) time.Duration {
+	e := s.cpls[cpl]
+	size := s.rt.CplSize(cpl)
+
+	urgency := 1 - float64(size)/float64(s.bucketSize)
+	churn := size - e.Size
+	if churn < 0 {
+		churn = -churn
+	}
+	urgency = math.Max(urgency, float64(churn)/float64(s.bucketSize))
+	urgency = math.Max(0, math.Min(1, urgency))
+
+	interval := s.maxInterval - time.Duration(urgency*float64(s.maxInterval-s.minInterval))
+	for i := 0; i < e.Failures && interval < s.maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > s.maxInterval {
+		interval = s.maxInterval
+	}
+	return interval
+}
+
 // A NoWaitExploreSchedule implements an explore schedule that cycles through each cpl without delays
 type NoWaitExploreSchedule struct {
 	maxCpl  int
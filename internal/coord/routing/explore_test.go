@@ -2,6 +2,7 @@ package routing
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -119,6 +120,141 @@ func TestDynamicExploreSchedule(t *testing.T) {
 	}
 }
 
+func TestAdaptiveExploreSchedule(t *testing.T) {
+	self := tiny.NewNode(128)
+
+	minInterval := time.Minute
+	maxInterval := time.Hour
+
+	newSchedule := func(t *testing.T, clk clock.Clock, rt RoutingTableCpl[tiny.Key, tiny.Node]) *AdaptiveExploreSchedule[tiny.Key, tiny.Node] {
+		t.Helper()
+		s, err := NewAdaptiveExploreSchedule[tiny.Key, tiny.Node](rt, 3, clk.Now(), 2, minInterval, maxInterval)
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("empty buckets explored after minimum interval", func(t *testing.T) {
+		clk := clock.NewMock()
+		rt, err := triert.New[tiny.Key, tiny.Node](self, nil)
+		require.NoError(t, err)
+
+		s := newSchedule(t, clk, rt)
+
+		_, ok := s.NextCpl(clk.Now())
+		require.False(t, ok)
+
+		clk.Add(minInterval)
+		for cpl := 0; cpl <= 3; cpl++ {
+			next, ok := s.NextCpl(clk.Now())
+			require.True(t, ok)
+			require.Equal(t, cpl, next)
+		}
+
+		_, ok = s.NextCpl(clk.Now())
+		require.False(t, ok)
+	})
+
+	t.Run("full stable bucket explored after maximum interval", func(t *testing.T) {
+		clk := clock.NewMock()
+		rt, err := triert.New[tiny.Key, tiny.Node](self, nil)
+		require.NoError(t, err)
+
+		// fill the bucket for cpl 0
+		rt.AddNode(tiny.NewNode(4))
+		rt.AddNode(tiny.NewNode(8))
+
+		s := newSchedule(t, clk, rt)
+		require.Equal(t, maxInterval, s.cplInterval(0))
+		require.Equal(t, minInterval, s.cplInterval(1))
+
+		// a half full bucket is explored halfway between the bounds
+		rt.AddNode(tiny.NewNode(192))
+		require.Equal(t, minInterval+(maxInterval-minInterval)/2, s.cplInterval(1))
+	})
+
+	t.Run("churned bucket explored sooner", func(t *testing.T) {
+		clk := clock.NewMock()
+		rt, err := triert.New[tiny.Key, tiny.Node](self, nil)
+		require.NoError(t, err)
+
+		rt.AddNode(tiny.NewNode(4))
+		rt.AddNode(tiny.NewNode(8))
+		s := newSchedule(t, clk, rt)
+
+		// replace one of the nodes in the full bucket
+		rt.RemoveKey(tiny.NewNode(4).Key())
+		rt.AddNode(tiny.NewNode(16))
+		require.Equal(t, maxInterval, s.cplInterval(0))
+
+		// losing a node makes the bucket both emptier and churned
+		rt.RemoveKey(tiny.NewNode(8).Key())
+		require.Equal(t, minInterval+(maxInterval-minInterval)/2, s.cplInterval(0))
+	})
+
+	t.Run("failures back off", func(t *testing.T) {
+		clk := clock.NewMock()
+		rt, err := triert.New[tiny.Key, tiny.Node](self, nil)
+		require.NoError(t, err)
+
+		s := newSchedule(t, clk, rt)
+
+		clk.Add(minInterval)
+		next, ok := s.NextCpl(clk.Now())
+		require.True(t, ok)
+		require.Equal(t, 0, next)
+
+		s.ExploreResult(0, clk.Now(), false)
+		require.Equal(t, 2*minInterval, s.cplInterval(0))
+		s.ExploreResult(0, clk.Now(), false)
+		require.Equal(t, 4*minInterval, s.cplInterval(0))
+
+		// success resets the backoff
+		s.ExploreResult(0, clk.Now(), true)
+		require.Equal(t, minInterval, s.cplInterval(0))
+	})
+}
+
+func TestExploreNotifiesScheduleOfResult(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	cfg := DefaultExploreConfig()
+	cfg.Clock = clk
+
+	self := tiny.NewNode(128)
+	rt, err := triert.New[tiny.Key, tiny.Node](self, nil)
+	require.NoError(t, err)
+
+	// populate the routing table with at least one node
+	a := tiny.NewNode(4)
+	rt.AddNode(a)
+
+	schedule, err := NewAdaptiveExploreSchedule[tiny.Key, tiny.Node](rt, maxCplTinyKeys, clk.Now(), 2, time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	ex, err := NewExplore[tiny.Key, tiny.Node](self, rt, tiny.NodeWithCpl, schedule, cfg)
+	require.NoError(t, err)
+
+	clk.Add(time.Minute)
+
+	// the empty buckets are due after the minimum interval
+	state := ex.Advance(ctx, &EventExplorePoll{})
+	require.IsType(t, &StateExploreFindCloser[tiny.Key, tiny.Node]{}, state)
+	cpl := state.(*StateExploreFindCloser[tiny.Key, tiny.Node]).Cpl
+	require.NotEqual(t, self.Key().CommonPrefixLength(a.Key()), cpl)
+
+	// the query finishes once the only known node has been contacted
+	clk.Add(time.Second)
+	state = ex.Advance(ctx, &EventExploreFindCloserFailure[tiny.Key, tiny.Node]{
+		NodeID: a,
+	})
+	require.IsType(t, &StateExploreQueryFinished{}, state)
+
+	// the schedule recorded when the exploration ended
+	require.Equal(t, clk.Now(), schedule.cpls[cpl].Last)
+	require.Equal(t, 0, schedule.cpls[cpl].Failures)
+}
+
 func TestExploreStartsIdle(t *testing.T) {
 	ctx := context.Background()
 	clk := clock.NewMock()
@@ -185,6 +321,83 @@ func TestExploreFirstQueriesForMaximumCpl(t *testing.T) {
 	require.IsType(t, &StateExploreWaiting{}, state)
 }
 
+func TestExploreContinuesIncompleteSearch(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultExploreConfig()
+	cfg.Clock = clk
+
+	self := tiny.NewNode(128)
+	rt, err := triert.New[tiny.Key, tiny.Node](self, nil)
+	require.NoError(t, err)
+
+	a := tiny.NewNode(4)
+	rt.AddNode(a)
+
+	// the node id function needs three calls to find a node id
+	calls := 0
+	cplFn := func(k tiny.Key, cpl int) (tiny.Node, error) {
+		calls++
+		if calls%3 != 0 {
+			return tiny.Node{}, fmt.Errorf("still searching: %w", ErrNodeIDSearchIncomplete)
+		}
+		return tiny.NodeWithCpl(k, cpl)
+	}
+
+	schedule := DefaultDynamicSchedule(t, clk)
+	ex, err := NewExplore[tiny.Key, tiny.Node](self, rt, cplFn, schedule, cfg)
+	require.NoError(t, err)
+
+	clk.Add(schedule.cplInterval(schedule.maxCpl))
+
+	// the explore reports that it is searching until the node id has been found
+	state := ex.Advance(ctx, &EventExplorePoll{})
+	require.IsType(t, &StateExploreSearching{}, state)
+	require.Equal(t, schedule.maxCpl, state.(*StateExploreSearching).Cpl)
+
+	state = ex.Advance(ctx, &EventExplorePoll{})
+	require.IsType(t, &StateExploreSearching{}, state)
+
+	// the search finishes for the same cpl and the query starts
+	state = ex.Advance(ctx, &EventExplorePoll{})
+	require.IsType(t, &StateExploreFindCloser[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateExploreFindCloser[tiny.Key, tiny.Node])
+	require.Equal(t, schedule.maxCpl, st.Cpl)
+	require.Equal(t, schedule.maxCpl, self.Key().CommonPrefixLength(st.Target))
+}
+
+func TestExploreIncompleteSearchTimesOut(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultExploreConfig()
+	cfg.Clock = clk
+
+	self := tiny.NewNode(128)
+	rt, err := triert.New[tiny.Key, tiny.Node](self, nil)
+	require.NoError(t, err)
+
+	// the node id function never finds a node id
+	cplFn := func(k tiny.Key, cpl int) (tiny.Node, error) {
+		return tiny.Node{}, ErrNodeIDSearchIncomplete
+	}
+
+	schedule := DefaultDynamicSchedule(t, clk)
+	ex, err := NewExplore[tiny.Key, tiny.Node](self, rt, cplFn, schedule, cfg)
+	require.NoError(t, err)
+
+	clk.Add(schedule.cplInterval(schedule.maxCpl))
+
+	state := ex.Advance(ctx, &EventExplorePoll{})
+	require.IsType(t, &StateExploreSearching{}, state)
+
+	// the search is abandoned once it took longer than the explore timeout
+	clk.Add(cfg.Timeout + time.Second)
+	state = ex.Advance(ctx, &EventExplorePoll{})
+	require.IsType(t, &StateExploreFailure{}, state)
+	require.Equal(t, schedule.maxCpl, state.(*StateExploreFailure).Cpl)
+	require.ErrorIs(t, state.(*StateExploreFailure).Error, ErrNodeIDSearchIncomplete)
+}
+
 func TestExploreFindCloserResponse(t *testing.T) {
 	ctx := context.Background()
 	clk := clock.NewMock()
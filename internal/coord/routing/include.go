@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"container/heap"
 	"context"
 	"fmt"
 	"sync/atomic"
@@ -9,6 +10,7 @@ import (
 	"github.com/benbjohnson/clock"
 	"github.com/plprobelab/go-libdht/kad"
 	"github.com/plprobelab/go-libdht/kad/key"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 
@@ -21,14 +23,36 @@ type check[K kad.Key[K], N kad.NodeID[K]] struct {
 	Started time.Time
 }
 
+// The Include state machine performs connectivity checks for candidate nodes and adds the nodes that pass to the
+// routing table.
+//
+// Candidates wait for their check in a bounded priority queue. Nodes that are known to be connected come first,
+// followed by nodes for the buckets of the routing table that hold the fewest nodes, and otherwise nodes are checked
+// in the order they were added. Candidates that have been queued for longer than [IncludeConfig.CandidateTTL] are
+// dropped. When the queue is full, a new candidate replaces the queued candidate with the lowest priority if it has
+// a higher priority and is dropped otherwise. Checks that take longer than [IncludeConfig.Timeout] are cancelled to
+// make room for further checks, a late response to a cancelled check is ignored.
+//
+// Candidates that pass their check but cannot be added because their bucket of the routing table is full are kept
+// in a bounded replacement cache for the bucket, identified by the length of the common prefix of the node and the
+// routing table's key. When the state machine is notified with the [EventIncludeNodeRemoved] event that a node was
+// removed from the routing table, the most recently verified candidate from the cache of the node's bucket is added
+// to the routing table at once.
+//
+// If the routing table implements [RoutingTableRecency], a candidate that is added to the replacement cache also
+// causes the state machine to return [StateIncludeBucketFull] with the least recently seen node of the bucket, which
+// should be checked and removed from the routing table if it fails to respond, making room for the candidate.
 type Include[K kad.Key[K], N kad.NodeID[K]] struct {
-	rt kad.RoutingTable[K, N]
+	rt RoutingTableCpl[K, N]
 
 	// checks is an index of checks in progress
 	checks map[string]check[K, N]
 
-	// candidates is a list of nodes that are candidates for adding to the routing table
-	candidates *nodeQueue[K, N]
+	// candidates is a queue of nodes that are candidates for adding to the routing table
+	candidates *candidateQueue[K, N]
+
+	// replacements holds nodes that passed their check while their bucket of the routing table was full
+	replacements *replacementCache[K, N]
 
 	// cfg is a copy of the optional configuration supplied to the Include
 	cfg IncludeConfig
@@ -42,11 +66,15 @@ type Include[K kad.Key[K], N kad.NodeID[K]] struct {
 	// counterChecksFailed is a counter that tracks the number of connectivity checks that have failed.
 	counterChecksFailed metric.Int64Counter
 
-	// counterCandidatesDroppedCapacity is a counter that tracks the number of nodes that were not added to the candidate
-	// queue because it was already at maximum capacity. If this rises or remains high then it could indicate that
-	// the include state machine cannot keep up with the rate of new nodes being added. This could be affected by
-	// the configured maximum number of concurrent checks and the timeout used for terminating slow checks.
-	counterCandidatesDroppedCapacity metric.Int64Counter
+	// counterCandidatesDropped is a counter that tracks the number of candidates that were dropped before their check
+	// completed, with the reason as an attribute. A high rate of drops because of capacity could indicate that the
+	// include state machine cannot keep up with the rate of new nodes being added. This could be affected by the
+	// configured maximum number of concurrent checks and the timeout used for terminating slow checks.
+	counterCandidatesDropped metric.Int64Counter
+
+	// counterReplacementsPromoted is a counter that tracks the number of nodes that were added to the routing table
+	// from the replacement cache after a node of the same bucket was removed.
+	counterReplacementsPromoted metric.Int64Counter
 
 	// gaugeCandidateCount is a gauge that tracks the number of nodes in the probe's pending queue of scheduled checks.
 	gaugeCandidateCount metric.Int64ObservableGauge
@@ -60,8 +88,18 @@ type IncludeConfig struct {
 	QueueCapacity int           // the maximum number of nodes that can be in the candidate queue
 	Concurrency   int           // the maximum number of include checks that may be in progress at any one time
 	Timeout       time.Duration // the time to wait before terminating a check that is not making progress
+	CandidateTTL  time.Duration // the time a candidate may wait in the queue before it is dropped
 	Clock         clock.Clock   // a clock that may replaced by a mock when testing
 
+	// ReplacementCapacity is the maximum number of verified nodes kept for each bucket of the routing table to replace
+	// nodes that are removed from it. Zero disables the replacement cache.
+	ReplacementCapacity int
+
+	// BucketSize is the number of nodes at which a bucket of the routing table is considered full. A replacement
+	// that the routing table rejects while its bucket holds fewer nodes, for example because of a diversity filter,
+	// is dropped instead of being kept for the next removal.
+	BucketSize int
+
 	// Tracer is the tracer that should be used to trace execution.
 	Tracer trace.Tracer
 
@@ -99,6 +137,27 @@ func (cfg *IncludeConfig) Validate() error {
 		}
 	}
 
+	if cfg.CandidateTTL < 1 {
+		return &errs.ConfigurationError{
+			Component: "IncludeConfig",
+			Err:       fmt.Errorf("candidate ttl must be greater than zero"),
+		}
+	}
+
+	if cfg.ReplacementCapacity < 0 {
+		return &errs.ConfigurationError{
+			Component: "IncludeConfig",
+			Err:       fmt.Errorf("replacement capacity must not be negative"),
+		}
+	}
+
+	if cfg.BucketSize < 1 {
+		return &errs.ConfigurationError{
+			Component: "IncludeConfig",
+			Err:       fmt.Errorf("bucket size must be greater than zero"),
+		}
+	}
+
 	if cfg.Tracer == nil {
 		return &errs.ConfigurationError{
 			Component: "IncludeConfig",
@@ -124,13 +183,16 @@ func DefaultIncludeConfig() *IncludeConfig {
 		Tracer: tele.NoopTracer(),
 		Meter:  tele.NoopMeter(),
 
-		Concurrency:   3,
-		Timeout:       time.Minute,
-		QueueCapacity: 128,
+		Concurrency:         3,
+		Timeout:             time.Minute,
+		QueueCapacity:       128,
+		CandidateTTL:        10 * time.Minute,
+		ReplacementCapacity: 8,
+		BucketSize:          20,
 	}
 }
 
-func NewInclude[K kad.Key[K], N kad.NodeID[K]](rt kad.RoutingTable[K, N], cfg *IncludeConfig) (*Include[K, N], error) {
+func NewInclude[K kad.Key[K], N kad.NodeID[K]](rt RoutingTableCpl[K, N], cfg *IncludeConfig) (*Include[K, N], error) {
 	if cfg == nil {
 		cfg = DefaultIncludeConfig()
 	} else if err := cfg.Validate(); err != nil {
@@ -138,10 +200,11 @@ func NewInclude[K kad.Key[K], N kad.NodeID[K]](rt kad.RoutingTable[K, N], cfg *I
 	}
 
 	in := &Include[K, N]{
-		candidates: newNodeQueue[K, N](cfg.QueueCapacity),
-		cfg:        *cfg,
-		rt:         rt,
-		checks:     make(map[string]check[K, N], cfg.Concurrency),
+		candidates:   newCandidateQueue[K, N](cfg.QueueCapacity),
+		replacements: newReplacementCache[K, N](cfg.ReplacementCapacity),
+		cfg:          *cfg,
+		rt:           rt,
+		checks:       make(map[string]check[K, N], cfg.Concurrency),
 	}
 
 	// initialise metrics
@@ -170,12 +233,20 @@ func NewInclude[K kad.Key[K], N kad.NodeID[K]](rt kad.RoutingTable[K, N], cfg *I
 		return nil, fmt.Errorf("create include_checks_failed counter: %w", err)
 	}
 
-	in.counterCandidatesDroppedCapacity, err = cfg.Meter.Int64Counter(
-		"include_candidates_dropped_capacity",
-		metric.WithDescription("Total number of nodes that were not added to the candidate queue because it was already at maximum capacity"),
+	in.counterCandidatesDropped, err = cfg.Meter.Int64Counter(
+		"include_candidates_dropped",
+		metric.WithDescription("Total number of candidate nodes that were dropped before their connectivity check completed, by reason"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create include_candidates_dropped counter: %w", err)
+	}
+
+	in.counterReplacementsPromoted, err = cfg.Meter.Int64Counter(
+		"include_replacements_promoted",
+		metric.WithDescription("Total number of nodes that were added to the routing table from the replacement cache"),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("create include_candidates_dropped_capacity counter: %w", err)
+		return nil, fmt.Errorf("create include_replacements_promoted counter: %w", err)
 	}
 
 	in.gaugeCandidateCount, err = cfg.Meter.Int64ObservableGauge(
@@ -215,116 +286,399 @@ func (in *Include[K, N]) Advance(ctx context.Context, ev IncludeEvent) (out Incl
 			break
 		}
 
-		// TODO: potentially time out a check and make room in the queue
+		now := in.cfg.Clock.Now()
+		c := &candidate[K, N]{
+			NodeID:    tev.NodeID,
+			Connected: tev.Connected,
+			Fill:      in.rt.CplSize(in.rt.Cpl(tev.NodeID.Key())),
+			Queued:    now,
+		}
+
+		if in.candidates.Contains(tev.NodeID) {
+			// a connected node may be queued with a lower priority already
+			if tev.Connected {
+				in.candidates.Update(c)
+			}
+			break
+		}
+
 		if !in.candidates.HasCapacity() {
-			in.counterCandidatesDroppedCapacity.Add(ctx, 1)
-			return &StateIncludeWaitingFull{}
+			in.dropStale(ctx, now)
 		}
-		in.candidates.Enqueue(ctx, tev.NodeID)
+
+		if !in.candidates.HasCapacity() {
+			worst, _ := in.candidates.Lowest()
+			if !c.outranks(worst) {
+				in.counterCandidatesDropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", includeDropCapacity)))
+				return &StateIncludeWaitingFull{}
+			}
+			// make room for the candidate with the higher priority
+			in.candidates.Remove(worst.NodeID)
+			in.counterCandidatesDropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", includeDropEvicted)))
+		}
+		in.candidates.Enqueue(c)
 
 	case *EventIncludeConnectivityCheckSuccess[K, N]:
 		in.counterChecksPassed.Add(ctx, 1)
 		ch, ok := in.checks[key.HexString(tev.NodeID.Key())]
 		if ok {
 			delete(in.checks, key.HexString(tev.NodeID.Key()))
+			cpl := in.rt.Cpl(tev.NodeID.Key())
 			if in.rt.AddNode(tev.NodeID) {
+				in.replacements.Remove(cpl, tev.NodeID)
 				return &StateIncludeRoutingUpdated[K, N]{
 					NodeID: ch.NodeID,
 				}
 			}
+			if _, exists := in.rt.GetNode(tev.NodeID.Key()); !exists {
+				// the node's bucket is full, keep it to replace a node that is removed later
+				in.replacements.Add(cpl, tev.NodeID)
+
+				// ask for the least recently seen node of the bucket to be checked so that it can be replaced
+				if rrt, ok := in.rt.(RoutingTableRecency[K, N]); ok && in.cfg.ReplacementCapacity > 0 {
+					if oldest, ok := rrt.LeastRecentlySeen(tev.NodeID.Key()); ok {
+						return &StateIncludeBucketFull[K, N]{
+							NodeID: tev.NodeID,
+							Oldest: oldest,
+						}
+					}
+				}
+			}
 		}
 	case *EventIncludeConnectivityCheckFailure[K, N]:
 		in.counterChecksFailed.Add(ctx, 1)
 		span.RecordError(tev.Error)
 		delete(in.checks, key.HexString(tev.NodeID.Key()))
 
+	case *EventIncludeNodeRemoved[K, N]:
+		cpl := in.rt.Cpl(tev.NodeID.Key())
+		for {
+			candidate, ok := in.replacements.Pop(cpl)
+			if !ok {
+				break
+			}
+			if _, exists := in.rt.GetNode(candidate.Key()); exists {
+				// the candidate was added to the routing table in the meantime
+				continue
+			}
+			if !in.rt.AddNode(candidate) {
+				if in.rt.CplSize(cpl) >= in.cfg.BucketSize {
+					// the bucket is still full, keep the candidate for the next removal
+					in.replacements.Add(cpl, candidate)
+					break
+				}
+				// the routing table rejected the candidate although the bucket has room, try the next one
+				continue
+			}
+			in.counterReplacementsPromoted.Add(ctx, 1)
+			return &StateIncludeRoutingUpdated[K, N]{
+				NodeID: candidate,
+			}
+		}
+
 	case *EventIncludePoll:
 		// ignore, nothing to do
 	default:
 		panic(fmt.Sprintf("unexpected event: %T", tev))
 	}
 
+	now := in.cfg.Clock.Now()
+
+	// cancel checks that are not making progress to make room for further checks
+	for mk, ch := range in.checks {
+		if now.Sub(ch.Started) >= in.cfg.Timeout {
+			delete(in.checks, mk)
+			in.counterCandidatesDropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", includeDropTimeout)))
+		}
+	}
+
 	if len(in.checks) == in.cfg.Concurrency {
 		if !in.candidates.HasCapacity() {
-			in.counterCandidatesDroppedCapacity.Add(ctx, 1)
 			return &StateIncludeWaitingFull{}
 		}
 		return &StateIncludeWaitingAtCapacity{}
 	}
 
-	candidate, ok := in.candidates.Dequeue(ctx)
-	if !ok {
-		// No candidate in queue
-		if len(in.checks) > 0 {
-			return &StateIncludeWaitingWithCapacity{}
+	var next *candidate[K, N]
+	for {
+		c, ok := in.candidates.Dequeue()
+		if !ok {
+			// No candidate in queue
+			if len(in.checks) > 0 {
+				return &StateIncludeWaitingWithCapacity{}
+			}
+			return &StateIncludeIdle{}
 		}
-		return &StateIncludeIdle{}
+		if in.stale(c, now) {
+			in.counterCandidatesDropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", includeDropStale)))
+			continue
+		}
+		next = c
+		break
 	}
 
-	in.checks[key.HexString(candidate.Key())] = check[K, N]{
-		NodeID:  candidate,
-		Started: in.cfg.Clock.Now(),
+	in.checks[key.HexString(next.NodeID.Key())] = check[K, N]{
+		NodeID:  next.NodeID,
+		Started: now,
 	}
 
 	// Ask the node to find itself
 	in.counterChecksSent.Add(ctx, 1)
 	return &StateIncludeConnectivityCheck[K, N]{
-		NodeID: candidate,
+		NodeID: next.NodeID,
+	}
+}
+
+// Reasons for dropping a candidate that are recorded by the include_candidates_dropped counter.
+const (
+	includeDropCapacity = "capacity" // the queue was full of candidates with the same or a higher priority
+	includeDropEvicted  = "evicted"  // the candidate was removed from the queue to make room for one with a higher priority
+	includeDropStale    = "stale"    // the candidate was queued for longer than the candidate ttl
+	includeDropTimeout  = "timeout"  // the check of the candidate was cancelled because it took too long
+)
+
+// stale reports whether the candidate has been queued for too long.
+func (in *Include[K, N]) stale(c *candidate[K, N], now time.Time) bool {
+	return now.Sub(c.Queued) >= in.cfg.CandidateTTL
+}
+
+// dropStale removes all stale candidates from the queue.
+func (in *Include[K, N]) dropStale(ctx context.Context, now time.Time) {
+	for _, c := range in.candidates.Filter(func(c *candidate[K, N]) bool { return in.stale(c, now) }) {
+		in.candidates.Remove(c.NodeID)
+		in.counterCandidatesDropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", includeDropStale)))
+	}
+}
+
+// candidate is a node queued for a connectivity check.
+type candidate[K kad.Key[K], N kad.NodeID[K]] struct {
+	NodeID    N
+	Connected bool      // whether the node is known to be connected
+	Fill      int       // the number of nodes in the node's bucket of the routing table when it was queued
+	Queued    time.Time // the time the node was queued
+	seq       uint64    // the order in which the node was queued
+	index     int       // the index of the candidate in the heap
+}
+
+// before reports whether c has a higher priority than other. Candidates with the same priority are ordered by
+// their arrival.
+func (c *candidate[K, N]) before(other *candidate[K, N]) bool {
+	if c.outranks(other) {
+		return true
 	}
+	if other.outranks(c) {
+		return false
+	}
+	return c.seq < other.seq
+}
+
+// outranks reports whether c has a strictly higher priority than other, regardless of their arrival.
+func (c *candidate[K, N]) outranks(other *candidate[K, N]) bool {
+	if c.Connected != other.Connected {
+		return c.Connected
+	}
+	return c.Fill < other.Fill
 }
 
-// nodeQueue is a bounded queue of unique NodeIDs
-type nodeQueue[K kad.Key[K], N kad.NodeID[K]] struct {
+// candidateQueue is a bounded priority queue of unique candidates.
+type candidateQueue[K kad.Key[K], N kad.NodeID[K]] struct {
 	capacity int
-	nodes    []N
-	keys     map[string]struct{}
+	heap     candidateHeap[K, N]
+	keys     map[string]*candidate[K, N]
+	seq      uint64
 }
 
-func newNodeQueue[K kad.Key[K], N kad.NodeID[K]](capacity int) *nodeQueue[K, N] {
-	return &nodeQueue[K, N]{
+func newCandidateQueue[K kad.Key[K], N kad.NodeID[K]](capacity int) *candidateQueue[K, N] {
+	return &candidateQueue[K, N]{
 		capacity: capacity,
-		nodes:    make([]N, 0, capacity),
-		keys:     make(map[string]struct{}, capacity),
+		heap:     make(candidateHeap[K, N], 0, capacity),
+		keys:     make(map[string]*candidate[K, N], capacity),
 	}
 }
 
-// Enqueue adds a node to the queue. It returns true if the node was
-// added and false otherwise.
-func (q *nodeQueue[K, N]) Enqueue(ctx context.Context, id N) bool {
-	if len(q.nodes) == q.capacity {
+// Enqueue adds a candidate to the queue. It returns true if the candidate was added and false if the queue is
+// full or already holds the node.
+func (q *candidateQueue[K, N]) Enqueue(c *candidate[K, N]) bool {
+	if len(q.heap) == q.capacity {
 		return false
 	}
 
-	if _, exists := q.keys[key.HexString(id.Key())]; exists {
+	mk := key.HexString(c.NodeID.Key())
+	if _, exists := q.keys[mk]; exists {
 		return false
 	}
 
-	q.nodes = append(q.nodes, id)
-	q.keys[key.HexString(id.Key())] = struct{}{}
+	q.seq++
+	c.seq = q.seq
+	q.keys[mk] = c
+	heap.Push(&q.heap, c)
 	return true
 }
 
-// Dequeue reads an node from the queue. It returns the node and a true value
-// if a node was read or nil and false if no node was read.
-func (q *nodeQueue[K, N]) Dequeue(ctx context.Context) (N, bool) {
-	if len(q.nodes) == 0 {
-		var v N
-		return v, false
+// Update replaces the queued candidate for the same node, keeping its position in the order of arrival.
+func (q *candidateQueue[K, N]) Update(c *candidate[K, N]) {
+	prev, exists := q.keys[key.HexString(c.NodeID.Key())]
+	if !exists {
+		return
 	}
+	prev.Connected = c.Connected
+	prev.Fill = c.Fill
+	heap.Fix(&q.heap, prev.index)
+}
 
-	var id N
-	id, q.nodes = q.nodes[0], q.nodes[1:]
-	delete(q.keys, key.HexString(id.Key()))
+// Dequeue removes the candidate with the highest priority from the queue and returns it. It returns false if
+// the queue is empty.
+func (q *candidateQueue[K, N]) Dequeue() (*candidate[K, N], bool) {
+	if len(q.heap) == 0 {
+		return nil, false
+	}
 
-	return id, true
+	c := heap.Pop(&q.heap).(*candidate[K, N])
+	delete(q.keys, key.HexString(c.NodeID.Key()))
+	return c, true
 }
 
-func (q *nodeQueue[K, N]) HasCapacity() bool {
-	return len(q.nodes) < q.capacity
+// Lowest returns the candidate with the lowest priority without removing it. It uses a linear search which is
+// acceptable since the queue is expected to be small.
+func (q *candidateQueue[K, N]) Lowest() (*candidate[K, N], bool) {
+	if len(q.heap) == 0 {
+		return nil, false
+	}
+
+	lowest := q.heap[0]
+	for _, c := range q.heap[1:] {
+		if lowest.before(c) {
+			lowest = c
+		}
+	}
+	return lowest, true
 }
 
-func (q *nodeQueue[K, N]) Len() int {
-	return len(q.nodes)
+// Filter returns the queued candidates for which fn returns true.
+func (q *candidateQueue[K, N]) Filter(fn func(*candidate[K, N]) bool) []*candidate[K, N] {
+	var out []*candidate[K, N]
+	for _, c := range q.heap {
+		if fn(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Remove removes the candidate for the node from the queue.
+func (q *candidateQueue[K, N]) Remove(n N) {
+	mk := key.HexString(n.Key())
+	c, exists := q.keys[mk]
+	if !exists {
+		return
+	}
+	delete(q.keys, mk)
+	heap.Remove(&q.heap, c.index)
+}
+
+func (q *candidateQueue[K, N]) Contains(n N) bool {
+	_, exists := q.keys[key.HexString(n.Key())]
+	return exists
+}
+
+func (q *candidateQueue[K, N]) HasCapacity() bool {
+	return len(q.heap) < q.capacity
+}
+
+func (q *candidateQueue[K, N]) Len() int {
+	return len(q.heap)
+}
+
+// candidateHeap is a heap of candidates ordered by priority, highest first.
+type candidateHeap[K kad.Key[K], N kad.NodeID[K]] []*candidate[K, N]
+
+func (h candidateHeap[K, N]) Len() int           { return len(h) }
+func (h candidateHeap[K, N]) Less(i, j int) bool { return h[i].before(h[j]) }
+
+func (h candidateHeap[K, N]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *candidateHeap[K, N]) Push(x any) {
+	c := x.(*candidate[K, N])
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *candidateHeap[K, N]) Pop() any {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	old[n-1] = nil
+	c.index = -1
+	*h = old[:n-1]
+	return c
+}
+
+// replacementCache holds nodes that passed a connectivity check while their bucket of the routing table was full,
+// grouped by the length of the common prefix they share with the routing table's key.
+type replacementCache[K kad.Key[K], N kad.NodeID[K]] struct {
+	// capacity is the maximum number of nodes held for each bucket
+	capacity int
+
+	// buckets holds the nodes of each bucket, ordered from least to most recently verified
+	buckets map[int][]N
+}
+
+func newReplacementCache[K kad.Key[K], N kad.NodeID[K]](capacity int) *replacementCache[K, N] {
+	return &replacementCache[K, N]{
+		capacity: capacity,
+		buckets:  make(map[int][]N),
+	}
+}
+
+// Add adds a node to the bucket as the most recently verified one. The least recently verified node of the bucket
+// is dropped if the bucket is at capacity.
+func (c *replacementCache[K, N]) Add(cpl int, n N) {
+	if c.capacity == 0 {
+		return
+	}
+	c.Remove(cpl, n)
+
+	nodes := c.buckets[cpl]
+	if len(nodes) == c.capacity {
+		nodes = append(nodes[:0], nodes[1:]...)
+	}
+	c.buckets[cpl] = append(nodes, n)
+}
+
+// Remove removes a node from the bucket.
+func (c *replacementCache[K, N]) Remove(cpl int, n N) {
+	nodes := c.buckets[cpl]
+	for i := range nodes {
+		if key.Equal(n.Key(), nodes[i].Key()) {
+			c.buckets[cpl] = append(nodes[:i], nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Pop removes the most recently verified node from the bucket and returns it. It returns false if the bucket is
+// empty.
+func (c *replacementCache[K, N]) Pop(cpl int) (N, bool) {
+	nodes := c.buckets[cpl]
+	if len(nodes) == 0 {
+		var v N
+		return v, false
+	}
+
+	n := nodes[len(nodes)-1]
+	var v N
+	nodes[len(nodes)-1] = v
+	c.buckets[cpl] = nodes[:len(nodes)-1]
+	return n, true
+}
+
+// Len returns the number of nodes held for the bucket.
+func (c *replacementCache[K, N]) Len(cpl int) int {
+	return len(c.buckets[cpl])
 }
 
 // IncludeState is the state of a include.
@@ -358,6 +712,14 @@ type StateIncludeRoutingUpdated[K kad.Key[K], N kad.NodeID[K]] struct {
 	NodeID N
 }
 
+// StateIncludeBucketFull indicates that a node passed its connectivity check but could not be added to the routing
+// table because its bucket is full. The node is kept in the replacement cache. The least recently seen node of the
+// bucket should be checked and removed from the routing table if it fails to respond.
+type StateIncludeBucketFull[K kad.Key[K], N kad.NodeID[K]] struct {
+	NodeID N // the node that could not be added
+	Oldest N // the least recently seen node of the bucket
+}
+
 // includeState() ensures that only Include states can be assigned to an IncludeState.
 func (*StateIncludeConnectivityCheck[K, N]) includeState() {}
 func (*StateIncludeIdle) includeState()                    {}
@@ -365,6 +727,7 @@ func (*StateIncludeWaitingAtCapacity) includeState()       {}
 func (*StateIncludeWaitingWithCapacity) includeState()     {}
 func (*StateIncludeWaitingFull) includeState()             {}
 func (*StateIncludeRoutingUpdated[K, N]) includeState()    {}
+func (*StateIncludeBucketFull[K, N]) includeState()        {}
 
 // IncludeEvent is an event intended to advance the state of an [Include].
 type IncludeEvent interface {
@@ -376,7 +739,8 @@ type EventIncludePoll struct{}
 
 // EventIncludeAddCandidate notifies an [Include] that a node should be added to the candidate list.
 type EventIncludeAddCandidate[K kad.Key[K], N kad.NodeID[K]] struct {
-	NodeID N // the candidate node
+	NodeID    N    // the candidate node
+	Connected bool // whether the node is known to be connected, which gives it priority over other candidates
 }
 
 // EventIncludeConnectivityCheckSuccess notifies an [Include] that a requested connectivity check has received a successful response.
@@ -390,8 +754,15 @@ type EventIncludeConnectivityCheckFailure[K kad.Key[K], N kad.NodeID[K]] struct
 	Error  error // the error that caused the failure, if any
 }
 
+// EventIncludeNodeRemoved notifies an [Include] that a node was removed from the routing table, so that a node from
+// the replacement cache of the node's bucket can take its place.
+type EventIncludeNodeRemoved[K kad.Key[K], N kad.NodeID[K]] struct {
+	NodeID N // the node that was removed
+}
+
 // includeEvent() ensures that only events accepted by an [Include] can be assigned to the [IncludeEvent] interface.
 func (*EventIncludePoll) includeEvent()                           {}
 func (*EventIncludeAddCandidate[K, N]) includeEvent()             {}
 func (*EventIncludeConnectivityCheckSuccess[K, N]) includeEvent() {}
 func (*EventIncludeConnectivityCheckFailure[K, N]) includeEvent() {}
+func (*EventIncludeNodeRemoved[K, N]) includeEvent()              {}
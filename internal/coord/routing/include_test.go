@@ -3,12 +3,14 @@ package routing
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/plprobelab/go-libdht/kad/key"
 	"github.com/plprobelab/go-libdht/kad/triert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/plprobelab/zikade/internal/coord/kbucket"
 	"github.com/plprobelab/zikade/internal/tiny"
 )
 
@@ -18,6 +20,30 @@ func TestIncludeConfigValidate(t *testing.T) {
 		require.NoError(t, cfg.Validate())
 	})
 
+	t.Run("candidate ttl positive", func(t *testing.T) {
+		cfg := DefaultIncludeConfig()
+		cfg.CandidateTTL = 0
+		require.Error(t, cfg.Validate())
+		cfg.CandidateTTL = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("replacement capacity not negative", func(t *testing.T) {
+		cfg := DefaultIncludeConfig()
+		cfg.ReplacementCapacity = -1
+		require.Error(t, cfg.Validate())
+		cfg.ReplacementCapacity = 0
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("bucket size positive", func(t *testing.T) {
+		cfg := DefaultIncludeConfig()
+		cfg.BucketSize = 0
+		require.Error(t, cfg.Validate())
+		cfg.BucketSize = -1
+		require.Error(t, cfg.Validate())
+	})
+
 	t.Run("clock is not nil", func(t *testing.T) {
 		cfg := DefaultIncludeConfig()
 		cfg.Clock = nil
@@ -288,3 +314,348 @@ func TestIncludeConnectivityCheckFailure(t *testing.T) {
 	require.False(t, found)
 	require.Zero(t, foundNode)
 }
+
+func TestIncludeReplacementCache(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+	cfg.BucketSize = 1
+
+	// a routing table that only holds a single node in each bucket
+	rtCfg := triert.DefaultConfig[tiny.Key, tiny.Node]()
+	rtCfg.KeyFilter = func(rt *triert.TrieRT[tiny.Key, tiny.Node], kk tiny.Key) bool {
+		return rt.CplSize(rt.Cpl(kk)) < 1
+	}
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), rtCfg)
+	require.NoError(t, err)
+
+	// fill the bucket of nodes with a common prefix length of zero
+	require.True(t, rt.AddNode(tiny.NewNode(4)))
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	// candidates in the same bucket pass their checks but can't be added to the routing table
+	for _, n := range []tiny.Node{tiny.NewNode(3), tiny.NewNode(2)} {
+		state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: n})
+		require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+		state = p.Advance(ctx, &EventIncludeConnectivityCheckSuccess[tiny.Key, tiny.Node]{NodeID: n})
+		require.IsType(t, &StateIncludeIdle{}, state)
+
+		_, found := rt.GetNode(n.Key())
+		require.False(t, found)
+	}
+
+	// the bucket is still full so no candidate is promoted
+	state := p.Advance(ctx, &EventIncludeNodeRemoved[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeIdle{}, state)
+
+	// the most recently verified candidate takes the place of a removed node
+	rt.RemoveKey(tiny.Key(4))
+	state = p.Advance(ctx, &EventIncludeNodeRemoved[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeRoutingUpdated[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateIncludeRoutingUpdated[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(2), st.NodeID.Key()))
+
+	_, found := rt.GetNode(tiny.Key(2))
+	require.True(t, found)
+
+	// followed by the next one
+	rt.RemoveKey(tiny.Key(2))
+	state = p.Advance(ctx, &EventIncludeNodeRemoved[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(2)})
+	require.IsType(t, &StateIncludeRoutingUpdated[tiny.Key, tiny.Node]{}, state)
+	st = state.(*StateIncludeRoutingUpdated[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(3), st.NodeID.Key()))
+
+	// the cache of the bucket is empty now
+	rt.RemoveKey(tiny.Key(3))
+	state = p.Advance(ctx, &EventIncludeNodeRemoved[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeIdle{}, state)
+}
+
+func TestIncludeReplacementCacheSkipsRejectedCandidates(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+	cfg.BucketSize = 2
+
+	// a routing table that holds two nodes in each bucket and never accepts node 3, like a diversity filter would
+	rtCfg := triert.DefaultConfig[tiny.Key, tiny.Node]()
+	rtCfg.KeyFilter = func(rt *triert.TrieRT[tiny.Key, tiny.Node], kk tiny.Key) bool {
+		return kk != tiny.Key(3) && rt.CplSize(rt.Cpl(kk)) < 2
+	}
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), rtCfg)
+	require.NoError(t, err)
+
+	// fill the bucket of nodes with a common prefix length of zero
+	require.True(t, rt.AddNode(tiny.NewNode(4)))
+	require.True(t, rt.AddNode(tiny.NewNode(5)))
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	// candidates in the same bucket are kept in the replacement cache, node 3 is the most recently verified
+	for _, n := range []tiny.Node{tiny.NewNode(2), tiny.NewNode(3)} {
+		state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: n})
+		require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+		state = p.Advance(ctx, &EventIncludeConnectivityCheckSuccess[tiny.Key, tiny.Node]{NodeID: n})
+		require.IsType(t, &StateIncludeIdle{}, state)
+	}
+
+	// node 3 is rejected although the bucket has room, so it is dropped and node 2 takes the place of the removed node
+	rt.RemoveKey(tiny.Key(4))
+	state := p.Advance(ctx, &EventIncludeNodeRemoved[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeRoutingUpdated[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateIncludeRoutingUpdated[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(2), st.NodeID.Key()))
+
+	// the rejected candidate isn't kept
+	rt.RemoveKey(tiny.Key(2))
+	state = p.Advance(ctx, &EventIncludeNodeRemoved[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(2)})
+	require.IsType(t, &StateIncludeIdle{}, state)
+}
+
+func TestIncludeBucketFullRequestsEvictionCheck(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+
+	// a routing table that orders nodes by the time they were last seen
+	rt, err := kbucket.New[tiny.Key, tiny.Node](tiny.NewNode(128), &kbucket.Config{BucketSize: 2})
+	require.NoError(t, err)
+	require.True(t, rt.AddNode(tiny.NewNode(4)))
+	require.True(t, rt.AddNode(tiny.NewNode(5)))
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	// the candidate can't be added, so the least recently seen node of its bucket should be checked
+	state = p.Advance(ctx, &EventIncludeConnectivityCheckSuccess[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeBucketFull[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateIncludeBucketFull[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(3), st.NodeID.Key()))
+	require.True(t, key.Equal(tiny.Key(4), st.Oldest.Key()))
+
+	// the candidate replaces the least recently seen node once it is removed
+	rt.RemoveKey(tiny.Key(4))
+	state = p.Advance(ctx, &EventIncludeNodeRemoved[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeRoutingUpdated[tiny.Key, tiny.Node]{}, state)
+
+	_, found := rt.GetNode(tiny.Key(3))
+	require.True(t, found)
+}
+
+func TestReplacementCache(t *testing.T) {
+	c := newReplacementCache[tiny.Key, tiny.Node](2)
+
+	c.Add(0, tiny.NewNode(1))
+	c.Add(0, tiny.NewNode(2))
+	c.Add(1, tiny.NewNode(64))
+	require.Equal(t, 2, c.Len(0))
+	require.Equal(t, 1, c.Len(1))
+
+	// adding to a full bucket drops the least recently verified node
+	c.Add(0, tiny.NewNode(3))
+	require.Equal(t, 2, c.Len(0))
+
+	// adding a node again makes it the most recently verified one
+	c.Add(0, tiny.NewNode(2))
+	require.Equal(t, 2, c.Len(0))
+
+	n, ok := c.Pop(0)
+	require.True(t, ok)
+	require.True(t, key.Equal(tiny.Key(2), n.Key()))
+
+	c.Remove(0, tiny.NewNode(3))
+	_, ok = c.Pop(0)
+	require.False(t, ok)
+
+	// a cache without capacity holds no nodes
+	c = newReplacementCache[tiny.Key, tiny.Node](0)
+	c.Add(0, tiny.NewNode(1))
+	_, ok = c.Pop(0)
+	require.False(t, ok)
+}
+
+func TestIncludeCandidatePriority(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1 // one check at a time so candidates are queued
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+
+	// the bucket of nodes with a common prefix length of zero holds two nodes, the next bucket is empty
+	rt.AddNode(tiny.NewNode(4))
+	rt.AddNode(tiny.NewNode(5))
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	// the first candidate is checked at once
+	state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	// queue a candidate for the fuller bucket, one for the empty bucket and a connected one for the fuller bucket
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(6)})
+	require.IsType(t, &StateIncludeWaitingAtCapacity{}, state)
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(192)})
+	require.IsType(t, &StateIncludeWaitingAtCapacity{}, state)
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(7), Connected: true})
+	require.IsType(t, &StateIncludeWaitingAtCapacity{}, state)
+
+	// connected candidates are checked first, then candidates for the emptier bucket
+	checking := tiny.NewNode(3)
+	for _, want := range []tiny.Key{7, 192, 6} {
+		state = p.Advance(ctx, &EventIncludeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: checking})
+		require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+		st := state.(*StateIncludeConnectivityCheck[tiny.Key, tiny.Node])
+		require.True(t, key.Equal(want, st.NodeID.Key()))
+		checking = st.NodeID
+	}
+}
+
+func TestIncludeQueueFullEvictsLowerPriority(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.QueueCapacity = 1
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	// the queue is full after the second candidate
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeWaitingFull{}, state)
+
+	// a connected candidate takes the place of the queued one
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(5), Connected: true})
+	require.IsType(t, &StateIncludeWaitingFull{}, state)
+
+	// a candidate with a lower priority is dropped
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(6)})
+	require.IsType(t, &StateIncludeWaitingFull{}, state)
+
+	state = p.Advance(ctx, &EventIncludeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateIncludeConnectivityCheck[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(5), st.NodeID.Key()))
+
+	// no other candidates are queued
+	state = p.Advance(ctx, &EventIncludeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(5)})
+	require.IsType(t, &StateIncludeIdle{}, state)
+}
+
+func TestIncludeQueueFullDropsEqualPriority(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.QueueCapacity = 1
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	// the queue is full after the second candidate
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeWaitingFull{}, state)
+
+	// a candidate with the same priority doesn't take the place of the queued one
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(5)})
+	require.IsType(t, &StateIncludeWaitingFull{}, state)
+
+	state = p.Advance(ctx, &EventIncludeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateIncludeConnectivityCheck[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(4), st.NodeID.Key()))
+
+	// no other candidates are queued
+	state = p.Advance(ctx, &EventIncludeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeIdle{}, state)
+}
+
+func TestIncludeCandidateTTL(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.Timeout = time.Hour
+	cfg.CandidateTTL = 5 * time.Minute
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeWaitingAtCapacity{}, state)
+
+	// the queued candidate ages out while the first check is in progress
+	clk.Add(cfg.CandidateTTL)
+
+	state = p.Advance(ctx, &EventIncludeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeIdle{}, state)
+}
+
+func TestIncludeCheckTimeout(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultIncludeConfig()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.Timeout = time.Minute
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+
+	p, err := NewInclude[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	state := p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	state = p.Advance(ctx, &EventIncludeAddCandidate[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateIncludeWaitingAtCapacity{}, state)
+
+	// the first check is cancelled once it times out, making room for the next candidate
+	clk.Add(cfg.Timeout)
+	state = p.Advance(ctx, &EventIncludePoll{})
+	require.IsType(t, &StateIncludeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateIncludeConnectivityCheck[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(4), st.NodeID.Key()))
+
+	// a late response to the cancelled check is ignored
+	p.Advance(ctx, &EventIncludeConnectivityCheckSuccess[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(3)})
+	_, found := rt.GetNode(tiny.Key(3))
+	require.False(t, found)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -29,6 +30,22 @@ type RoutingTableCpl[K kad.Key[K], N kad.NodeID[K]] interface {
 	CplSize(cpl int) int
 }
 
+// RoutingTableRecency is a [RoutingTableCpl] that orders the nodes of each bucket by the time they were last seen
+// and doesn't evict nodes to make room for new ones, as described in the original Kademlia paper. The [Include]
+// and [Probe] state machines ask for the least recently seen node of a full bucket to be checked and remove it only
+// if it fails to respond.
+type RoutingTableRecency[K kad.Key[K], N kad.NodeID[K]] interface {
+	RoutingTableCpl[K, N]
+
+	// Touch marks the node with the supplied key as the most recently seen node of its bucket. It returns false if
+	// the node is not in the table.
+	Touch(kk K) bool
+
+	// LeastRecentlySeen returns the least recently seen node of the bucket that a node with the supplied key
+	// belongs to, or false if the bucket is empty.
+	LeastRecentlySeen(kk K) (N, bool)
+}
+
 // The Probe state machine performs regular connectivity checks for nodes in a routing table.
 //
 // The state machine is notified of a new entry in the routing table via the [EventProbeAdd] event. This adds the node
@@ -44,11 +61,22 @@ type RoutingTableCpl[K kad.Key[K], N kad.NodeID[K]] interface {
 // [EventProbeConnectivityCheckSuccessFailure] events to determine the outcome of the check. If neither are received
 // within a configurable timeout the node is marked as failed.
 //
-// Nodes that receive a successful response have their next check time updated to the current time plus the configured
-// [ProbeConfig.CheckInterval].
+// Nodes that receive a successful response have their next check time updated to the current time plus a check
+// interval that depends on the node's score. The score combines the time the node has been known, its round-trip time,
+// the outcomes of recent query requests and the outcomes of earlier connectivity checks. Nodes that score at most one
+// half are checked every [ProbeConfig.CheckInterval], better nodes are checked less often, up to the
+// [ProbeConfig.MaxCheckInterval] for a perfect score. The current score of a node may be read with [Probe.Score].
 //
-// Nodes that fail a connectivity check, or are timed out, are removed from the routing table and from the list of nodes
-// to check. The state machine emits the [StateProbeNodeFailure] state to notify callers of this event.
+// Nodes that fail a connectivity check, or are timed out, are checked again after a backoff that starts at
+// [ProbeConfig.FailureBackoff] and doubles with every further failure. Nodes that fail [ProbeConfig.MaxFailures] checks
+// in a row are removed from the routing table and from the list of nodes to check. The state machine emits the
+// [StateProbeNodeFailure] state to notify callers of this event. A node that is the subject of an
+// [EventProbeCheckEvictable] event is checked at once and removed after a single failed check, since another node is
+// waiting to replace it.
+//
+// The state machine keeps the outcomes of the most recent checks. If most of them failed it is more likely that the
+// local node lost connectivity than that all the checked nodes went away at once, so failed checks are then not
+// counted against the nodes and no nodes are removed until checks succeed again.
 //
 // The state machine accepts a [EventProbePoll] event to check for outstanding work such as initiating a new check or
 // timing out an existing one.
@@ -58,13 +86,24 @@ type RoutingTableCpl[K kad.Key[K], N kad.NodeID[K]] interface {
 // The state machine accepts the [EventProbeNotifyConnectivity] event as a notification that an external system has
 // performed a suitable connectivity check, such as when the node responds to a query. The probe state machine treats
 // these events as if a successful response had been received from a check by advancing the time of the next check.
+//
+// The [EventProbeNotifyQueryResult] event notifies the state machine of the outcome of a query request sent to a node.
+// It updates the node's score and, if the request succeeded, also advances the time of the next check.
 type Probe[K kad.Key[K], N kad.NodeID[K]] struct {
 	rt RoutingTableCpl[K, N]
 
-	// nvl is a list of nodes with information about their connectivity checks
-	// TODO: this will be expanded with more general scoring information related to their utility
+	// nvl is a list of nodes with information about their connectivity checks and scores
 	nvl *nodeValueList[K, N]
 
+	// scoresMu guards scores
+	scoresMu sync.RWMutex
+
+	// scores holds the latest score of each node in nvl so that it can be read outside of the state machine
+	scores map[string]float64
+
+	// monitor keeps the outcomes of the most recent checks to detect that local connectivity was lost
+	monitor *connectivityMonitor
+
 	// cfg is a copy of the optional configuration supplied to the Probe
 	cfg ProbeConfig
 
@@ -77,6 +116,10 @@ type Probe[K kad.Key[K], N kad.NodeID[K]] struct {
 	// counterChecksFailed is a counter that tracks the number of connectivity checks that have failed.
 	counterChecksFailed metric.Int64Counter
 
+	// counterEvictionsPaused is a counter that tracks the number of failed checks that were not counted against
+	// the checked node because local connectivity appeared to be lost.
+	counterEvictionsPaused metric.Int64Counter
+
 	// gaugePendingCount is a gauge that tracks the number of nodes in the probe's pending queue of scheduled checks.
 	gaugePendingCount metric.Int64ObservableGauge
 
@@ -86,10 +129,21 @@ type Probe[K kad.Key[K], N kad.NodeID[K]] struct {
 
 // ProbeConfig specifies optional configuration for a Probe
 type ProbeConfig struct {
-	CheckInterval time.Duration // the minimum time interval between checks for a node
-	Concurrency   int           // the maximum number of probe checks that may be in progress at any one time
-	Timeout       time.Duration // the time to wait before terminating a check that is not making progress
-	Clock         clock.Clock   // a clock that may be replaced by a mock when testing
+	CheckInterval    time.Duration // the minimum time interval between checks for a node
+	MaxCheckInterval time.Duration // the time interval between checks for a node with a perfect score
+	Concurrency      int           // the maximum number of probe checks that may be in progress at any one time
+	Timeout          time.Duration // the time to wait before terminating a check that is not making progress
+	MaxFailures      int           // the number of consecutive failed checks after which a node is removed
+	FailureBackoff   time.Duration // the time to wait before checking a node again after its first failed check
+	Clock            clock.Clock   // a clock that may be replaced by a mock when testing
+
+	// OfflineWindow is the number of most recent check outcomes used to detect that local connectivity was lost.
+	// Zero disables the detection.
+	OfflineWindow int
+
+	// OfflineThreshold is the share of failed checks among the most recent OfflineWindow checks at or above which
+	// local connectivity is considered lost.
+	OfflineThreshold float64
 
 	// Tracer is the tracer that should be used to trace execution.
 	Tracer trace.Tracer
@@ -142,6 +196,41 @@ func (cfg *ProbeConfig) Validate() error {
 		}
 	}
 
+	if cfg.MaxCheckInterval < cfg.CheckInterval {
+		return &errs.ConfigurationError{
+			Component: "ProbeConfig",
+			Err:       fmt.Errorf("maximum revisit interval must not be less than revisit interval"),
+		}
+	}
+
+	if cfg.MaxFailures < 1 {
+		return &errs.ConfigurationError{
+			Component: "ProbeConfig",
+			Err:       fmt.Errorf("maximum failures must be greater than zero"),
+		}
+	}
+
+	if cfg.FailureBackoff < 1 {
+		return &errs.ConfigurationError{
+			Component: "ProbeConfig",
+			Err:       fmt.Errorf("failure backoff must be greater than zero"),
+		}
+	}
+
+	if cfg.OfflineWindow < 0 {
+		return &errs.ConfigurationError{
+			Component: "ProbeConfig",
+			Err:       fmt.Errorf("offline window must not be negative"),
+		}
+	}
+
+	if cfg.OfflineThreshold <= 0 || cfg.OfflineThreshold > 1 {
+		return &errs.ConfigurationError{
+			Component: "ProbeConfig",
+			Err:       fmt.Errorf("offline threshold must be greater than zero and at most one"),
+		}
+	}
+
 	return nil
 }
 
@@ -153,9 +242,14 @@ func DefaultProbeConfig() *ProbeConfig {
 		Tracer: tele.NoopTracer(),
 		Meter:  tele.NoopMeter(),
 
-		Concurrency:   3,             // MAGIC
-		Timeout:       time.Minute,   // MAGIC
-		CheckInterval: 6 * time.Hour, // MAGIC
+		Concurrency:      3,              // MAGIC
+		Timeout:          time.Minute,    // MAGIC
+		CheckInterval:    6 * time.Hour,  // MAGIC
+		MaxCheckInterval: 24 * time.Hour, // MAGIC
+		MaxFailures:      3,              // MAGIC
+		FailureBackoff:   time.Minute,    // MAGIC
+		OfflineWindow:    10,             // MAGIC
+		OfflineThreshold: 0.8,            // MAGIC
 	}
 }
 
@@ -167,9 +261,14 @@ func NewProbe[K kad.Key[K], N kad.NodeID[K]](rt RoutingTableCpl[K, N], cfg *Prob
 	}
 
 	p := &Probe[K, N]{
-		cfg: *cfg,
-		rt:  rt,
-		nvl: NewNodeValueList[K, N](),
+		cfg:    *cfg,
+		rt:     rt,
+		nvl:    NewNodeValueList[K, N](),
+		scores: make(map[string]float64),
+		monitor: &connectivityMonitor{
+			outcomes:  make([]bool, cfg.OfflineWindow),
+			threshold: cfg.OfflineThreshold,
+		},
 	}
 
 	// initialise metrics
@@ -198,6 +297,14 @@ func NewProbe[K kad.Key[K], N kad.NodeID[K]](rt RoutingTableCpl[K, N], cfg *Prob
 		return nil, fmt.Errorf("create probe_checks_failed counter: %w", err)
 	}
 
+	p.counterEvictionsPaused, err = cfg.Meter.Int64Counter(
+		"probe_evictions_paused",
+		metric.WithDescription("Total number of failed connectivity checks that did not count against the node because local connectivity appeared to be lost"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create probe_evictions_paused counter: %w", err)
+	}
+
 	p.gaugePendingCount, err = cfg.Meter.Int64ObservableGauge(
 		"probe_pending_count",
 		metric.WithDescription("Total number of nodes being monitored by the probe state machine"),
@@ -236,18 +343,24 @@ func (p *Probe[K, N]) Advance(ctx context.Context, ev ProbeEvent) (out ProbeStat
 		}
 
 		// add a node to the value list
+		now := p.cfg.Clock.Now()
 		nv := &nodeValue[K, N]{
-			NodeID:       tev.NodeID,
-			NextCheckDue: p.cfg.Clock.Now().Add(p.cfg.CheckInterval),
-			Cpl:          p.rt.Cpl(tev.NodeID.Key()),
+			NodeID: tev.NodeID,
+			Cpl:    p.rt.Cpl(tev.NodeID.Key()),
+		}
+		if prev, found := p.nvl.Get(tev.NodeID); found {
+			// keep the history of a node that is added again
+			nv.nodeScore = prev.nodeScore
+		} else {
+			nv.AddedAt = now
 		}
 		// TODO: if node was in ongoing list return a state that can signal the caller to cancel any prior outbound message
-		p.nvl.Put(nv)
+		p.reschedule(nv, now)
 	case *EventProbeRemove[K, N]:
 		span.SetAttributes(attribute.String("nodeid", tev.NodeID.String()))
 
 		p.rt.RemoveKey(tev.NodeID.Key())
-		p.nvl.Remove(tev.NodeID)
+		p.remove(tev.NodeID)
 		return &StateProbeNodeFailure[K, N]{
 			NodeID: tev.NodeID,
 		}
@@ -261,22 +374,23 @@ func (p *Probe[K, N]) Advance(ctx context.Context, ev ProbeEvent) (out ProbeStat
 			span.RecordError(errors.New("node not in node value list"))
 			break
 		}
-		// update next check time
-		nv.NextCheckDue = p.cfg.Clock.Now().Add(p.cfg.CheckInterval)
+		p.monitor.record(false)
+		nv.ChecksPassed++
+		nv.Failures = 0
+		nv.observeRTT(tev.RTT)
+		p.seen(nv)
 
-		// put into list, which will clear any ongoing check too
-		p.nvl.Put(nv)
+		// update next check time and put into list, which will clear any ongoing check too
+		p.reschedule(nv, p.cfg.Clock.Now())
 
 	case *EventProbeConnectivityCheckFailure[K, N]:
-		// probe failed, so remove from routing table and from list
+		// probe failed, so check again later or remove from routing table and from list
 		p.counterChecksFailed.Add(ctx, 1)
 		span.SetAttributes(attribute.String("nodeid", tev.NodeID.String()))
 		span.RecordError(tev.Error)
 
-		p.rt.RemoveKey(tev.NodeID.Key())
-		p.nvl.Remove(tev.NodeID)
-		return &StateProbeNodeFailure[K, N]{
-			NodeID: tev.NodeID,
+		if st := p.checkFailed(ctx, tev.NodeID); st != nil {
+			return st
 		}
 	case *EventProbeNotifyConnectivity[K, N]:
 		span.SetAttributes(attribute.String("nodeid", tev.NodeID.String()))
@@ -285,11 +399,45 @@ func (p *Probe[K, N]) Advance(ctx context.Context, ev ProbeEvent) (out ProbeStat
 			// ignore message for unknown node, which might have been removed
 			break
 		}
-		// update next check time
-		nv.NextCheckDue = p.cfg.Clock.Now().Add(p.cfg.CheckInterval)
+		nv.ChecksPassed++
+		nv.Failures = 0
+		p.seen(nv)
+
+		// update next check time and put into list, which will clear any ongoing check too
+		p.reschedule(nv, p.cfg.Clock.Now())
+	case *EventProbeNotifyQueryResult[K, N]:
+		span.SetAttributes(attribute.String("nodeid", tev.NodeID.String()), attribute.Bool("success", tev.Success))
+		nv, found := p.nvl.Get(tev.NodeID)
+		if !found {
+			// ignore message for unknown node, which might have been removed
+			break
+		}
+		nv.observeQuery(tev.Success)
+		if !tev.Success {
+			// only update the score, the next check is not affected
+			p.setScore(nv, p.cfg.Clock.Now())
+			break
+		}
+		nv.Failures = 0
+		nv.observeRTT(tev.RTT)
+		p.seen(nv)
 
-		// put into list, which will clear any ongoing check too
-		p.nvl.Put(nv)
+		// update next check time and put into list, which will clear any ongoing check too
+		p.reschedule(nv, p.cfg.Clock.Now())
+
+	case *EventProbeCheckEvictable[K, N]:
+		span.SetAttributes(attribute.String("nodeid", tev.NodeID.String()))
+		nv, found := p.nvl.Get(tev.NodeID)
+		if !found {
+			// ignore message for unknown node, which might have been removed
+			break
+		}
+		nv.Evictable = true
+		if !p.nvl.isOngoing(tev.NodeID) {
+			// check the node as soon as possible, an ongoing check will decide the node's fate anyway
+			nv.NextCheckDue = p.cfg.Clock.Now()
+			p.nvl.Put(nv)
+		}
 
 	default:
 		panic(fmt.Sprintf("unexpected event: %T", tev))
@@ -304,13 +452,10 @@ func (p *Probe[K, N]) Advance(ctx context.Context, ev ProbeEvent) (out ProbeStat
 			return &StateProbeWaitingAtCapacity{}
 		}
 
-		// mark the node as failed since it timed out
-		p.rt.RemoveKey(candidate.Key())
-		p.nvl.Remove(candidate)
-		return &StateProbeNodeFailure[K, N]{
-			NodeID: candidate,
+		// mark the check as failed since it timed out
+		if st := p.checkFailed(ctx, candidate); st != nil {
+			return st
 		}
-
 	}
 
 	// there is capacity to start a new check
@@ -333,6 +478,116 @@ func (p *Probe[K, N]) Advance(ctx context.Context, ev ProbeEvent) (out ProbeStat
 	}
 }
 
+// Score returns the current score of a node as a value between zero and one, and whether the node is known to
+// the probe. Nodes with a higher score have proven to be more useful. It is safe to call Score concurrently with
+// Advance.
+func (p *Probe[K, N]) Score(n N) (float64, bool) {
+	p.scoresMu.RLock()
+	defer p.scoresMu.RUnlock()
+	score, found := p.scores[key.HexString(n.Key())]
+	return score, found
+}
+
+// reschedule scores the node, sets the time of its next check based on its score and puts it into the list of
+// pending nodes.
+func (p *Probe[K, N]) reschedule(nv *nodeValue[K, N], now time.Time) {
+	score := p.setScore(nv, now)
+	nv.NextCheckDue = now.Add(p.checkInterval(score))
+	p.nvl.Put(nv)
+}
+
+// seen records that a node responded. The node is no longer evictable and, if the routing table orders nodes by
+// the time they were last seen, becomes the most recently seen node of its bucket.
+func (p *Probe[K, N]) seen(nv *nodeValue[K, N]) {
+	nv.Evictable = false
+	if rrt, ok := p.rt.(RoutingTableRecency[K, N]); ok {
+		rrt.Touch(nv.NodeID.Key())
+	}
+}
+
+// checkFailed handles a failed or timed out connectivity check of a node. The node is removed from the routing
+// table and from the list of nodes once it failed MaxFailures checks in a row or if it is evictable, otherwise it is
+// checked again after a backoff. Failed checks are not counted against the node while local connectivity appears to be lost.
+// checkFailed returns the state to emit if the node was removed and nil otherwise.
+func (p *Probe[K, N]) checkFailed(ctx context.Context, n N) ProbeState {
+	p.monitor.record(true)
+
+	nv, found := p.nvl.Get(n)
+	if !found {
+		// somehow the node doesn't exist so this is an obvious candidate for removal
+		p.rt.RemoveKey(n.Key())
+		p.remove(n)
+		return &StateProbeNodeFailure[K, N]{
+			NodeID: n,
+		}
+	}
+
+	now := p.cfg.Clock.Now()
+	if p.monitor.offline() {
+		// most checks are failing, so it is more likely that the local node lost connectivity
+		p.counterEvictionsPaused.Add(ctx, 1)
+		p.setScore(nv, now)
+		nv.NextCheckDue = now.Add(p.failureBackoff(nv.Failures + 1))
+		p.nvl.Put(nv)
+		return nil
+	}
+
+	nv.ChecksFailed++
+	nv.Failures++
+	if nv.Failures >= p.cfg.MaxFailures || nv.Evictable {
+		p.rt.RemoveKey(n.Key())
+		p.remove(n)
+		return &StateProbeNodeFailure[K, N]{
+			NodeID: n,
+		}
+	}
+
+	p.setScore(nv, now)
+	nv.NextCheckDue = now.Add(p.failureBackoff(nv.Failures))
+	p.nvl.Put(nv)
+	return nil
+}
+
+// failureBackoff returns the time to wait before checking a node again after the given number of consecutive
+// failed checks. It doubles with every failure but never exceeds CheckInterval.
+func (p *Probe[K, N]) failureBackoff(failures int) time.Duration {
+	backoff := p.cfg.FailureBackoff
+	for i := 1; i < failures && backoff < p.cfg.CheckInterval; i++ {
+		backoff *= 2
+	}
+	if backoff > p.cfg.CheckInterval {
+		return p.cfg.CheckInterval
+	}
+	return backoff
+}
+
+// checkInterval returns the time to wait before checking a node with the given score. Nodes that score at most one
+// half are checked every CheckInterval, the interval grows linearly up to MaxCheckInterval for a perfect score.
+func (p *Probe[K, N]) checkInterval(score float64) time.Duration {
+	if score <= 0.5 {
+		return p.cfg.CheckInterval
+	}
+	extra := (score - 0.5) / 0.5 * float64(p.cfg.MaxCheckInterval-p.cfg.CheckInterval)
+	return p.cfg.CheckInterval + time.Duration(extra)
+}
+
+// setScore records the current score of the node so that it can be read by [Probe.Score] and returns it.
+func (p *Probe[K, N]) setScore(nv *nodeValue[K, N], now time.Time) float64 {
+	score := nv.Score(now)
+	p.scoresMu.Lock()
+	p.scores[key.HexString(nv.NodeID.Key())] = score
+	p.scoresMu.Unlock()
+	return score
+}
+
+// remove removes the node from the list of nodes and forgets its score.
+func (p *Probe[K, N]) remove(n N) {
+	p.nvl.Remove(n)
+	p.scoresMu.Lock()
+	delete(p.scores, key.HexString(n.Key()))
+	p.scoresMu.Unlock()
+}
+
 // ProbeState is the state of the [Probe] state machine.
 type ProbeState interface {
 	probeState()
@@ -387,7 +642,8 @@ type EventProbeRemove[K kad.Key[K], N kad.NodeID[K]] struct {
 
 // EventProbeConnectivityCheckSuccess notifies a [Probe] that a requested connectivity check has received a successful response.
 type EventProbeConnectivityCheckSuccess[K kad.Key[K], N kad.NodeID[K]] struct {
-	NodeID N // the node the message was sent to
+	NodeID N             // the node the message was sent to
+	RTT    time.Duration // the round-trip time of the check, zero if unknown
 }
 
 // EventProbeConnectivityCheckFailure notifies a [Probe] that a requested connectivity check has failed.
@@ -401,6 +657,20 @@ type EventProbeNotifyConnectivity[K kad.Key[K], N kad.NodeID[K]] struct {
 	NodeID N
 }
 
+// EventProbeNotifyQueryResult notifies a probe of the outcome of a request that a query sent to a node.
+type EventProbeNotifyQueryResult[K kad.Key[K], N kad.NodeID[K]] struct {
+	NodeID  N             // the node the request was sent to
+	Success bool          // whether the node responded to the request
+	RTT     time.Duration // the round-trip time of the request, zero if unknown or the request failed
+}
+
+// EventProbeCheckEvictable notifies a probe that a node is the least recently seen node of a full bucket and that
+// another node is waiting to take its place. The node is checked as soon as possible and removed from the routing
+// table if the check fails.
+type EventProbeCheckEvictable[K kad.Key[K], N kad.NodeID[K]] struct {
+	NodeID N
+}
+
 // probeEvent() ensures that only events accepted by a [Probe] can be assigned to the [ProbeEvent] interface.
 func (*EventProbePoll) probeEvent()                           {}
 func (*EventProbeAdd[K, N]) probeEvent()                      {}
@@ -408,13 +678,18 @@ func (*EventProbeRemove[K, N]) probeEvent()                   {}
 func (*EventProbeConnectivityCheckSuccess[K, N]) probeEvent() {}
 func (*EventProbeConnectivityCheckFailure[K, N]) probeEvent() {}
 func (*EventProbeNotifyConnectivity[K, N]) probeEvent()       {}
+func (*EventProbeNotifyQueryResult[K, N]) probeEvent()        {}
+func (*EventProbeCheckEvictable[K, N]) probeEvent()           {}
 
 type nodeValue[K kad.Key[K], N kad.NodeID[K]] struct {
 	NodeID        N
 	Cpl           int // the longest common prefix length the node shares with the routing table's key
 	NextCheckDue  time.Time
 	CheckDeadline time.Time
-	Index         int // the index of the item in the ordering
+	Index         int  // the index of the item in the ordering
+	Failures      int  // the number of consecutive connectivity checks the node failed
+	Evictable     bool // whether the node is removed after a single failed check because another node waits to replace it
+	nodeScore
 }
 
 type nodeValueEntry[K kad.Key[K], N kad.NodeID[K]] struct {
@@ -501,6 +776,12 @@ func (l *nodeValueList[K, N]) Remove(n N) {
 	l.removeFromOngoing(n)
 }
 
+// isOngoing reports whether a node has an ongoing connectivity check.
+func (l *nodeValueList[K, N]) isOngoing(n N) bool {
+	nve, ok := l.nodes[key.HexString(n.Key())]
+	return ok && nve.index == -1
+}
+
 // FindCheckPastDeadline looks for the first node in the ongoing list whose deadline is
 // before the supplied timestamp.
 func (l *nodeValueList[K, N]) FindCheckPastDeadline(ts time.Time) (N, bool) {
@@ -606,3 +887,52 @@ func (o *nodeValuePendingList[K, N]) Pop() any {
 	*o = old[0 : n-1]
 	return v
 }
+
+// connectivityMonitor detects that the local node lost connectivity from the outcomes of the most recent
+// connectivity checks.
+type connectivityMonitor struct {
+	// outcomes is a ring buffer of the most recent outcomes, true for a failed check
+	outcomes []bool
+
+	// next is the position in outcomes of the next outcome to record
+	next int
+
+	// count is the number of outcomes recorded in outcomes
+	count int
+
+	// failures is the number of failed checks recorded in outcomes
+	failures int
+
+	// threshold is the share of failed checks at or above which local connectivity is considered lost
+	threshold float64
+}
+
+// record records the outcome of a check, replacing the oldest outcome if the buffer is full.
+func (m *connectivityMonitor) record(failed bool) {
+	if len(m.outcomes) == 0 {
+		return
+	}
+
+	if m.count == len(m.outcomes) {
+		if m.outcomes[m.next] {
+			m.failures--
+		}
+	} else {
+		m.count++
+	}
+
+	m.outcomes[m.next] = failed
+	if failed {
+		m.failures++
+	}
+	m.next = (m.next + 1) % len(m.outcomes)
+}
+
+// offline reports whether enough of the most recent checks failed to consider local connectivity lost. It is
+// false until the buffer is full.
+func (m *connectivityMonitor) offline() bool {
+	if len(m.outcomes) == 0 || m.count < len(m.outcomes) {
+		return false
+	}
+	return float64(m.failures) >= m.threshold*float64(m.count)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/plprobelab/go-libdht/kad/triert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/plprobelab/zikade/internal/coord/kbucket"
 	"github.com/plprobelab/zikade/internal/tiny"
 )
 
@@ -63,6 +64,48 @@ func TestProbeConfigValidate(t *testing.T) {
 		cfg.CheckInterval = -1
 		require.Error(t, cfg.Validate())
 	})
+
+	t.Run("max revisit interval not less than revisit interval", func(t *testing.T) {
+		cfg := DefaultProbeConfig()
+		cfg.MaxCheckInterval = cfg.CheckInterval - 1
+		require.Error(t, cfg.Validate())
+		cfg.MaxCheckInterval = cfg.CheckInterval
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("max failures positive", func(t *testing.T) {
+		cfg := DefaultProbeConfig()
+		cfg.MaxFailures = 0
+		require.Error(t, cfg.Validate())
+		cfg.MaxFailures = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("failure backoff positive", func(t *testing.T) {
+		cfg := DefaultProbeConfig()
+		cfg.FailureBackoff = 0
+		require.Error(t, cfg.Validate())
+		cfg.FailureBackoff = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("offline window not negative", func(t *testing.T) {
+		cfg := DefaultProbeConfig()
+		cfg.OfflineWindow = -1
+		require.Error(t, cfg.Validate())
+		cfg.OfflineWindow = 0
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("offline threshold in range", func(t *testing.T) {
+		cfg := DefaultProbeConfig()
+		cfg.OfflineThreshold = 0
+		require.Error(t, cfg.Validate())
+		cfg.OfflineThreshold = 1.1
+		require.Error(t, cfg.Validate())
+		cfg.OfflineThreshold = 1
+		require.NoError(t, cfg.Validate())
+	})
 }
 
 func TestProbeStartsIdle(t *testing.T) {
@@ -692,6 +735,9 @@ func TestProbeConnectivityCheckFailure(t *testing.T) {
 	// Set concurrency to allow more than one check to run
 	cfg.Concurrency = 2
 
+	// remove nodes after their first failed check
+	cfg.MaxFailures = 1
+
 	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
 	require.NoError(t, err)
 	rt.AddNode(tiny.NewNode(4))
@@ -738,6 +784,257 @@ func TestProbeConnectivityCheckFailure(t *testing.T) {
 	require.IsType(t, &StateProbeIdle{}, state)
 }
 
+func TestProbeCheckEvictable(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	cfg := DefaultProbeConfig()
+	cfg.Clock = clk
+	cfg.CheckInterval = 10 * time.Minute
+	cfg.MaxFailures = 3
+
+	rt, err := kbucket.New[tiny.Key, tiny.Node](tiny.NewNode(128), &kbucket.Config{BucketSize: 2})
+	require.NoError(t, err)
+	rt.AddNode(tiny.NewNode(4))
+	rt.AddNode(tiny.NewNode(5))
+
+	sm, err := NewProbe[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	for _, n := range []tiny.Node{tiny.NewNode(4), tiny.NewNode(5)} {
+		state := sm.Advance(ctx, &EventProbeAdd[tiny.Key, tiny.Node]{NodeID: n})
+		require.IsType(t, &StateProbeIdle{}, state)
+	}
+
+	// the least recently seen node is checked at once
+	state := sm.Advance(ctx, &EventProbeCheckEvictable[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateProbeConnectivityCheck[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(4), st.NodeID.Key()))
+
+	// a successful check marks the node as the most recently seen node of its bucket
+	state = sm.Advance(ctx, &EventProbeConnectivityCheckSuccess[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	oldest, found := rt.LeastRecentlySeen(tiny.Key(6))
+	require.True(t, found)
+	require.True(t, key.Equal(tiny.Key(5), oldest.Key()))
+
+	// a single failed check removes an evictable node even though MaxFailures is not reached
+	state = sm.Advance(ctx, &EventProbeCheckEvictable[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(5)})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	state = sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(5)})
+	require.IsType(t, &StateProbeNodeFailure[tiny.Key, tiny.Node]{}, state)
+	stf := state.(*StateProbeNodeFailure[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(5), stf.NodeID.Key()))
+
+	_, found = rt.GetNode(tiny.Key(5))
+	require.False(t, found)
+
+	// a node that passed its check is no longer evictable and stays after a failed check
+	clk.Add(cfg.CheckInterval)
+	state = sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	state = sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: tiny.NewNode(4)})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	_, found = rt.GetNode(tiny.Key(4))
+	require.True(t, found)
+}
+
+func TestProbeConnectivityCheckFailureBackoff(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	cfg := DefaultProbeConfig()
+	cfg.Clock = clk
+	cfg.CheckInterval = 10 * time.Minute
+	cfg.MaxFailures = 3
+	cfg.FailureBackoff = time.Minute
+	cfg.OfflineWindow = 0 // never consider local connectivity lost
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+	rt.AddNode(tiny.NewNode(4))
+
+	sm, err := NewProbe[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	state := sm.Advance(ctx, &EventProbeAdd[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	// advance time by one revisit interval
+	clk.Add(cfg.CheckInterval)
+	state = sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+
+	// the first two failures are tolerated, the node is checked again after one and then two minutes
+	for _, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		state = sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{
+			NodeID: tiny.NewNode(4),
+		})
+		require.IsType(t, &StateProbeIdle{}, state)
+
+		_, found := rt.GetNode(tiny.Key(4))
+		require.True(t, found)
+
+		clk.Add(backoff - time.Second)
+		state = sm.Advance(ctx, &EventProbePoll{})
+		require.IsType(t, &StateProbeIdle{}, state)
+
+		clk.Add(time.Second)
+		state = sm.Advance(ctx, &EventProbePoll{})
+		require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	}
+
+	// the third failure in a row removes the node
+	state = sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+	require.IsType(t, &StateProbeNodeFailure[tiny.Key, tiny.Node]{}, state)
+
+	_, found := rt.GetNode(tiny.Key(4))
+	require.False(t, found)
+}
+
+func TestProbeConnectivityCheckSuccessResetsFailures(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	cfg := DefaultProbeConfig()
+	cfg.Clock = clk
+	cfg.CheckInterval = 10 * time.Minute
+	cfg.MaxFailures = 2
+	cfg.FailureBackoff = time.Minute
+	cfg.OfflineWindow = 0 // never consider local connectivity lost
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+	rt.AddNode(tiny.NewNode(4))
+
+	sm, err := NewProbe[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	sm.Advance(ctx, &EventProbeAdd[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+
+	// fail, succeed and fail again, the node should be kept since the failures were not consecutive
+	clk.Add(cfg.CheckInterval)
+	state := sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	state = sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	clk.Add(cfg.FailureBackoff)
+	state = sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	state = sm.Advance(ctx, &EventProbeConnectivityCheckSuccess[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	clk.Add(cfg.CheckInterval)
+	state = sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	state = sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	_, found := rt.GetNode(tiny.Key(4))
+	require.True(t, found)
+}
+
+func TestProbeOfflinePausesEvictions(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	cfg := DefaultProbeConfig()
+	cfg.Clock = clk
+	cfg.CheckInterval = 10 * time.Minute
+	cfg.Concurrency = 4
+	cfg.MaxFailures = 1
+	cfg.FailureBackoff = time.Minute
+	cfg.OfflineWindow = 4
+	cfg.OfflineThreshold = 0.75
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+
+	nodes := []tiny.Node{tiny.NewNode(4), tiny.NewNode(3), tiny.NewNode(2), tiny.NewNode(1)}
+	for _, n := range nodes {
+		rt.AddNode(n)
+	}
+
+	sm, err := NewProbe[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	for _, n := range nodes {
+		sm.Advance(ctx, &EventProbeAdd[tiny.Key, tiny.Node]{NodeID: n})
+	}
+
+	// start checks for all nodes
+	clk.Add(cfg.CheckInterval)
+	for range nodes {
+		state := sm.Advance(ctx, &EventProbePoll{})
+		require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	}
+
+	// the first failures remove the nodes since there are too few outcomes to tell whether local
+	// connectivity was lost
+	for _, n := range nodes[:3] {
+		state := sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: n})
+		require.IsType(t, &StateProbeNodeFailure[tiny.Key, tiny.Node]{}, state)
+	}
+
+	// all recent checks failed, so the last node is kept and checked again after the backoff
+	state := sm.Advance(ctx, &EventProbeConnectivityCheckFailure[tiny.Key, tiny.Node]{NodeID: nodes[3]})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	_, found := rt.GetNode(nodes[3].Key())
+	require.True(t, found)
+
+	clk.Add(cfg.FailureBackoff)
+	state = sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateProbeConnectivityCheck[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(nodes[3].Key(), st.NodeID.Key()))
+}
+
+func TestConnectivityMonitor(t *testing.T) {
+	m := &connectivityMonitor{
+		outcomes:  make([]bool, 4),
+		threshold: 0.75,
+	}
+
+	// not offline until the window is full
+	m.record(true)
+	m.record(true)
+	m.record(true)
+	require.False(t, m.offline())
+
+	m.record(false)
+	require.True(t, m.offline())
+
+	// the oldest failure is replaced by a success
+	m.record(false)
+	require.False(t, m.offline())
+
+	// a disabled monitor is never offline
+	m = &connectivityMonitor{threshold: 0.75}
+	for i := 0; i < 10; i++ {
+		m.record(true)
+	}
+	require.False(t, m.offline())
+}
+
 func TestProbeNotifyConnectivity(t *testing.T) {
 	ctx := context.Background()
 	clk := clock.NewMock()
@@ -808,6 +1105,84 @@ func TestProbeNotifyConnectivity(t *testing.T) {
 	require.IsType(t, &StateProbeWaitingWithCapacity{}, state)
 }
 
+func TestProbeNotifyQueryResult(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	cfg := DefaultProbeConfig()
+	cfg.Clock = clk
+	cfg.CheckInterval = 10 * time.Minute
+	cfg.MaxCheckInterval = time.Hour
+	cfg.Concurrency = 2
+
+	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
+	require.NoError(t, err)
+	rt.AddNode(tiny.NewNode(4))
+	rt.AddNode(tiny.NewNode(3))
+
+	sm, err := NewProbe[tiny.Key, tiny.Node](rt, cfg)
+	require.NoError(t, err)
+
+	// unknown nodes have no score
+	_, found := sm.Score(tiny.NewNode(4))
+	require.False(t, found)
+
+	state := sm.Advance(ctx, &EventProbeAdd[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+	require.IsType(t, &StateProbeIdle{}, state)
+	state = sm.Advance(ctx, &EventProbeAdd[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(3),
+	})
+	require.IsType(t, &StateProbeIdle{}, state)
+
+	initial, found := sm.Score(tiny.NewNode(4))
+	require.True(t, found)
+
+	// the node with key 4 responds quickly to many query requests
+	for i := 0; i < 10; i++ {
+		state = sm.Advance(ctx, &EventProbeNotifyQueryResult[tiny.Key, tiny.Node]{
+			NodeID:  tiny.NewNode(4),
+			Success: true,
+			RTT:     10 * time.Millisecond,
+		})
+		require.IsType(t, &StateProbeIdle{}, state)
+	}
+
+	excellent, found := sm.Score(tiny.NewNode(4))
+	require.True(t, found)
+	require.Greater(t, excellent, 0.5)
+	require.Greater(t, excellent, initial)
+
+	// advance time past the base check interval
+	clk.Add(20 * time.Minute)
+
+	// only the node without query history is due a check, the excellent node is checked less often
+	state = sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeConnectivityCheck[tiny.Key, tiny.Node]{}, state)
+	st := state.(*StateProbeConnectivityCheck[tiny.Key, tiny.Node])
+	require.True(t, key.Equal(tiny.Key(3), st.NodeID.Key()))
+
+	state = sm.Advance(ctx, &EventProbePoll{})
+	require.IsType(t, &StateProbeWaitingWithCapacity{}, state)
+
+	// a failed request lowers the score
+	sm.Advance(ctx, &EventProbeNotifyQueryResult[tiny.Key, tiny.Node]{
+		NodeID:  tiny.NewNode(4),
+		Success: false,
+	})
+	worse, found := sm.Score(tiny.NewNode(4))
+	require.True(t, found)
+	require.Less(t, worse, excellent)
+
+	// removed nodes have no score
+	sm.Advance(ctx, &EventProbeRemove[tiny.Key, tiny.Node]{
+		NodeID: tiny.NewNode(4),
+	})
+	_, found = sm.Score(tiny.NewNode(4))
+	require.False(t, found)
+}
+
 func TestProbeTimeout(t *testing.T) {
 	ctx := context.Background()
 	clk := clock.NewMock()
@@ -817,6 +1192,7 @@ func TestProbeTimeout(t *testing.T) {
 	cfg.CheckInterval = 10 * time.Minute
 	cfg.Timeout = 3 * time.Minute
 	cfg.Concurrency = 1 // one probe at a time, timeouts will be used to free capacity if there are more requests
+	cfg.MaxFailures = 1 // remove nodes after their first failed check
 
 	rt, err := triert.New[tiny.Key, tiny.Node](tiny.NewNode(128), nil)
 	require.NoError(t, err)
@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"time"
+)
+
+const (
+	// scoreUptimeHalfLife is the time after which a node's uptime contributes half of its maximum to the node's score.
+	scoreUptimeHalfLife = 24 * time.Hour // MAGIC
+
+	// scoreRTTHalfLife is the round-trip time at which a node's latency contributes half of its maximum to the node's score.
+	scoreRTTHalfLife = 250 * time.Millisecond // MAGIC
+
+	// scoreQueryDecay is the factor by which the weight of earlier query outcomes is reduced with every new outcome.
+	scoreQueryDecay = 0.9 // MAGIC
+
+	// The weights of the components of a node's score, they sum to one.
+	scoreWeightUptime  = 0.2 // MAGIC
+	scoreWeightRTT     = 0.2 // MAGIC
+	scoreWeightQueries = 0.4 // MAGIC
+	scoreWeightChecks  = 0.2 // MAGIC
+)
+
+// nodeScore holds the information used to score the utility of a node.
+type nodeScore struct {
+	AddedAt      time.Time     // the time the node was first added to the probe list
+	RTT          time.Duration // the smoothed round-trip time of requests sent to the node, zero if unknown
+	QuerySuccess float64       // the decayed number of query requests the node responded to
+	QueryFailure float64       // the decayed number of query requests that failed
+	ChecksPassed int           // the number of connectivity checks the node passed
+	ChecksFailed int           // the number of connectivity checks the node failed
+}
+
+// observeRTT folds a round-trip time sample into the smoothed round-trip time in the same way as TCP.
+func (s *nodeScore) observeRTT(rtt time.Duration) {
+	if rtt <= 0 {
+		return
+	}
+	if s.RTT == 0 {
+		s.RTT = rtt
+		return
+	}
+	s.RTT = (7*s.RTT + rtt) / 8
+}
+
+// observeQuery records the outcome of a query request sent to the node. Earlier outcomes are decayed so that
+// recent ones dominate the score.
+func (s *nodeScore) observeQuery(success bool) {
+	s.QuerySuccess *= scoreQueryDecay
+	s.QueryFailure *= scoreQueryDecay
+	if success {
+		s.QuerySuccess++
+	} else {
+		s.QueryFailure++
+	}
+}
+
+// Score returns the utility of the node at the given time as a value between zero and one. It combines the time
+// the node has been known, its round-trip time, the outcomes of recent query requests and the outcomes of its
+// connectivity checks. A node without any history scores 0.4.
+func (s *nodeScore) Score(now time.Time) float64 {
+	var uptime float64
+	if !s.AddedAt.IsZero() && now.After(s.AddedAt) {
+		d := now.Sub(s.AddedAt)
+		uptime = float64(d) / float64(d+scoreUptimeHalfLife)
+	}
+
+	latency := 0.5
+	if s.RTT > 0 {
+		latency = float64(scoreRTTHalfLife) / float64(s.RTT+scoreRTTHalfLife)
+	}
+
+	// the success rates are smoothed so that nodes with little history are close to one half
+	queries := (s.QuerySuccess + 1) / (s.QuerySuccess + s.QueryFailure + 2)
+	checks := float64(s.ChecksPassed+1) / float64(s.ChecksPassed+s.ChecksFailed+2)
+
+	return scoreWeightUptime*uptime + scoreWeightRTT*latency + scoreWeightQueries*queries + scoreWeightChecks*checks
+}
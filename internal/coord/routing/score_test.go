@@ -0,0 +1,64 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeScore(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	t.Run("no history", func(t *testing.T) {
+		s := nodeScore{AddedAt: now}
+		require.InDelta(t, 0.4, s.Score(now), 1e-9)
+	})
+
+	t.Run("uptime", func(t *testing.T) {
+		s := nodeScore{AddedAt: now}
+		require.Greater(t, s.Score(now.Add(scoreUptimeHalfLife)), s.Score(now))
+		require.InDelta(t, 0.4+scoreWeightUptime/2, s.Score(now.Add(scoreUptimeHalfLife)), 1e-9)
+	})
+
+	t.Run("rtt", func(t *testing.T) {
+		fast := nodeScore{AddedAt: now}
+		fast.observeRTT(10 * time.Millisecond)
+		slow := nodeScore{AddedAt: now}
+		slow.observeRTT(2 * time.Second)
+		require.Greater(t, fast.Score(now), slow.Score(now))
+
+		// samples are smoothed
+		slow.observeRTT(10 * time.Millisecond)
+		require.Greater(t, slow.RTT, time.Second)
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		s := nodeScore{AddedAt: now}
+		for i := 0; i < 20; i++ {
+			s.observeQuery(false)
+		}
+		bad := s.Score(now)
+		require.Less(t, bad, 0.4)
+
+		// recent successes outweigh earlier failures
+		for i := 0; i < 20; i++ {
+			s.observeQuery(true)
+		}
+		require.Greater(t, s.Score(now), 0.5)
+	})
+
+	t.Run("checks", func(t *testing.T) {
+		s := nodeScore{AddedAt: now, ChecksPassed: 10}
+		require.Greater(t, s.Score(now), 0.4)
+		s.ChecksFailed = 20
+		require.Less(t, s.Score(now), 0.4)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		s := nodeScore{AddedAt: now, RTT: time.Nanosecond, QuerySuccess: 1e9, ChecksPassed: 1e9}
+		score := s.Score(now.Add(1e6 * time.Hour))
+		require.LessOrEqual(t, score, 1.0)
+		require.Greater(t, score, 0.99)
+	})
+}
@@ -118,6 +118,56 @@ func TestRoutingConfigValidate(t *testing.T) {
 		require.Error(t, cfg.Validate())
 	})
 
+	t.Run("probe max check interval not less than probe check interval", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.ProbeMaxCheckInterval = cfg.ProbeCheckInterval - 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("probe max failures positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.ProbeMaxFailures = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("probe failure backoff positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.ProbeFailureBackoff = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("probe offline window not negative", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.ProbeOfflineWindow = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("probe offline threshold in range", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.ProbeOfflineThreshold = 0
+		require.Error(t, cfg.Validate())
+		cfg.ProbeOfflineThreshold = 1.5
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("include candidate ttl positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.IncludeCandidateTTL = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("include bucket size positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.IncludeBucketSize = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("include replacement capacity not negative", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+		cfg.IncludeReplacementCapacity = -1
+		require.Error(t, cfg.Validate())
+	})
+
 	t.Run("include request concurrency positive", func(t *testing.T) {
 		cfg := DefaultRoutingConfig()
 
@@ -172,10 +222,14 @@ func TestRoutingConfigValidate(t *testing.T) {
 		require.Error(t, cfg.Validate())
 	})
 
-	t.Run("explore maximum 15 or less", func(t *testing.T) {
+	t.Run("explore maximum cpl 255 or less", func(t *testing.T) {
 		cfg := DefaultRoutingConfig()
 
-		cfg.ExploreMaximumCpl = 16
+		cfg.ExploreMaximumCpl = 21
+		require.NoError(t, cfg.Validate())
+		cfg.ExploreMaximumCpl = 255
+		require.NoError(t, cfg.Validate())
+		cfg.ExploreMaximumCpl = 256
 		require.Error(t, cfg.Validate())
 	})
 
@@ -209,6 +263,46 @@ func TestRoutingConfigValidate(t *testing.T) {
 		cfg.ExploreIntervalJitter = -0.1
 		require.Error(t, cfg.Validate())
 	})
+
+	t.Run("explore minimum interval positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.ExploreMinInterval = 0
+		require.Error(t, cfg.Validate())
+		cfg.ExploreMinInterval = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("explore maximum interval not less than minimum interval", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.ExploreMaxInterval = cfg.ExploreMinInterval - 1
+		require.Error(t, cfg.Validate())
+		cfg.ExploreMaxInterval = cfg.ExploreMinInterval
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("explore bucket size positive", func(t *testing.T) {
+		cfg := DefaultRoutingConfig()
+
+		cfg.ExploreBucketSize = 0
+		require.Error(t, cfg.Validate())
+		cfg.ExploreBucketSize = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestNewRoutingBehaviourAdaptiveExploreSchedule(t *testing.T) {
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(1, clk)
+	require.NoError(t, err)
+
+	cfg := DefaultRoutingConfig()
+	cfg.Clock = clk
+	cfg.ExploreAdaptiveSchedule = true
+
+	_, err = NewRoutingBehaviour(nodes[0].NodeID, nodes[0].RoutingTable, cfg)
+	require.NoError(t, err)
 }
 
 func TestRoutingStartBootstrapSendsEvent(t *testing.T) {
@@ -344,6 +438,36 @@ func TestRoutingAddNodeInfoSendsEvent(t *testing.T) {
 	require.Equal(t, expected, include.first())
 }
 
+func TestRoutingNotifyConnectivityAddsConnectedCandidate(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+
+	self := nodes[0].NodeID
+
+	// records the event passed to include
+	include := NewRecordingSM[routing.IncludeEvent, routing.IncludeState](&routing.StateIncludeIdle{})
+
+	cfg := DefaultRoutingConfig()
+	cfg.Clock = clk
+	routingBehaviour, err := ComposeRoutingBehaviour(self, idleBootstrap(), include, idleProbe(), idleExplore(), cfg)
+	require.NoError(t, err)
+
+	routingBehaviour.Notify(ctx, &EventNotifyConnectivity{
+		NodeID: nodes[2].NodeID,
+	})
+	routingBehaviour.Perform(ctx)
+
+	// the node is a connected candidate
+	expected := &routing.EventIncludeAddCandidate[kadt.Key, kadt.PeerID]{
+		NodeID:    nodes[2].NodeID,
+		Connected: true,
+	}
+	require.Equal(t, expected, include.first())
+}
+
 func TestRoutingIncludeGetClosestNodesSuccess(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -498,6 +622,150 @@ func TestRoutingIncludedNodeAddToProbeList(t *testing.T) {
 	require.Equal(t, candidate, oev.To)
 }
 
+func TestRoutingNotifyResponseScoresNode(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+
+	self := nodes[0].NodeID
+	rt := nodes[0].RoutingTable
+
+	t.Run("notifies probe", func(t *testing.T) {
+		// records the event passed to probe
+		probe := NewRecordingSM[routing.ProbeEvent, routing.ProbeState](&routing.StateProbeIdle{})
+
+		cfg := DefaultRoutingConfig()
+		cfg.Clock = clk
+		routingBehaviour, err := ComposeRoutingBehaviour(self, idleBootstrap(), idleInclude(), probe, idleExplore(), cfg)
+		require.NoError(t, err)
+
+		routingBehaviour.Notify(ctx, &EventNotifyResponse{
+			NodeID: nodes[1].NodeID,
+			RTT:    time.Second,
+		})
+		routingBehaviour.Perform(ctx)
+
+		require.IsType(t, &routing.EventProbeNotifyQueryResult[kadt.Key, kadt.PeerID]{}, probe.first())
+		rev := probe.first().(*routing.EventProbeNotifyQueryResult[kadt.Key, kadt.PeerID])
+		require.Equal(t, nodes[1].NodeID, rev.NodeID)
+		require.True(t, rev.Success)
+		require.Equal(t, time.Second, rev.RTT)
+
+		// the recording state machine does not score nodes
+		_, found := routingBehaviour.Score(nodes[1].NodeID)
+		require.False(t, found)
+	})
+
+	t.Run("updates score", func(t *testing.T) {
+		probeCfg := routing.DefaultProbeConfig()
+		probeCfg.Clock = clk
+		probe, err := routing.NewProbe[kadt.Key](rt, probeCfg)
+		require.NoError(t, err)
+
+		cfg := DefaultRoutingConfig()
+		cfg.Clock = clk
+		routingBehaviour, err := ComposeRoutingBehaviour(self, idleBootstrap(), idleInclude(), probe, idleExplore(), cfg)
+		require.NoError(t, err)
+
+		probe.Advance(ctx, &routing.EventProbeAdd[kadt.Key, kadt.PeerID]{NodeID: nodes[1].NodeID})
+		initial, found := routingBehaviour.Score(nodes[1].NodeID)
+		require.True(t, found)
+
+		routingBehaviour.Notify(ctx, &EventNotifyResponse{
+			NodeID: nodes[1].NodeID,
+			RTT:    10 * time.Millisecond,
+		})
+		routingBehaviour.Perform(ctx)
+
+		score, found := routingBehaviour.Score(nodes[1].NodeID)
+		require.True(t, found)
+		require.Greater(t, score, initial)
+	})
+}
+
+func TestRoutingProbeNodeFailurePromotesReplacement(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+
+	self := nodes[0].NodeID
+
+	// records the event passed to include
+	include := NewRecordingSM[routing.IncludeEvent, routing.IncludeState](&routing.StateIncludeIdle{})
+
+	// a probe that reports the node as failed
+	probe := NewRecordingSM[routing.ProbeEvent, routing.ProbeState](&routing.StateProbeNodeFailure[kadt.Key, kadt.PeerID]{
+		NodeID: nodes[1].NodeID,
+	})
+
+	cfg := DefaultRoutingConfig()
+	cfg.Clock = clk
+	routingBehaviour, err := ComposeRoutingBehaviour(self, idleBootstrap(), include, probe, idleExplore(), cfg)
+	require.NoError(t, err)
+
+	routingBehaviour.Notify(ctx, &EventNotifyNonConnectivity{
+		NodeID: nodes[1].NodeID,
+	})
+	dev, ok := routingBehaviour.Perform(ctx)
+	require.True(t, ok)
+	require.IsType(t, &EventRoutingRemoved{}, dev)
+
+	// include should be told about the removal so it can replace the node
+	require.IsType(t, &routing.EventIncludeNodeRemoved[kadt.Key, kadt.PeerID]{}, include.first())
+	rev := include.first().(*routing.EventIncludeNodeRemoved[kadt.Key, kadt.PeerID])
+	require.Equal(t, nodes[1].NodeID, rev.NodeID)
+}
+
+func TestRoutingIncludeBucketFullChecksOldest(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	clk := clock.NewMock()
+	_, nodes, err := nettest.LinearTopology(4, clk)
+	require.NoError(t, err)
+
+	self := nodes[0].NodeID
+
+	// an include that reports a full bucket
+	include := NewRecordingSM[routing.IncludeEvent, routing.IncludeState](&routing.StateIncludeBucketFull[kadt.Key, kadt.PeerID]{
+		NodeID: nodes[2].NodeID,
+		Oldest: nodes[1].NodeID,
+	})
+
+	// records the event passed to probe
+	probe := NewRecordingSM[routing.ProbeEvent, routing.ProbeState](&routing.StateProbeConnectivityCheck[kadt.Key, kadt.PeerID]{
+		NodeID: nodes[1].NodeID,
+	})
+
+	cfg := DefaultRoutingConfig()
+	cfg.Clock = clk
+	routingBehaviour, err := ComposeRoutingBehaviour(self, idleBootstrap(), include, probe, idleExplore(), cfg)
+	require.NoError(t, err)
+
+	routingBehaviour.Notify(ctx, &EventGetCloserNodesSuccess{
+		QueryID:     IncludeQueryID,
+		To:          nodes[2].NodeID,
+		Target:      nodes[2].NodeID.Key(),
+		CloserNodes: []kadt.PeerID{nodes[3].NodeID},
+	})
+
+	dev, ok := routingBehaviour.Perform(ctx)
+	require.True(t, ok)
+
+	// the probe should be asked to check the oldest node of the bucket
+	require.IsType(t, &routing.EventProbeCheckEvictable[kadt.Key, kadt.PeerID]{}, probe.first())
+	pev := probe.first().(*routing.EventProbeCheckEvictable[kadt.Key, kadt.PeerID])
+	require.Equal(t, nodes[1].NodeID, pev.NodeID)
+
+	require.IsType(t, &EventOutboundGetCloserNodes{}, dev)
+	oev := dev.(*EventOutboundGetCloserNodes)
+	require.Equal(t, ProbeQueryID, oev.QueryID)
+	require.Equal(t, nodes[1].NodeID, oev.To)
+}
+
 func TestRoutingExploreSendsEvent(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -607,3 +875,33 @@ func TestRoutingExploreGetClosestNodesFailure(t *testing.T) {
 	require.Equal(t, peer.ID(nodes[1].NodeID), peer.ID(rev.NodeID))
 	require.Equal(t, failure, rev.Error)
 }
+
+func TestExploreNodeIDFuncReportsIncompleteSearch(t *testing.T) {
+	k := kadt.NewKey([]byte("key"))
+	fn := exploreNodeIDFunc(kadt.PeerID("self"), cplutil.NewPeerIDGenerator(1))
+
+	// a single candidate is unlikely to have the cpl so the search continues with the next call
+	_, err := fn(k, cplutil.MaxSearchCpl)
+	require.ErrorIs(t, err, routing.ErrNodeIDSearchIncomplete)
+
+	// cpls from the precomputed table don't need a search
+	id, err := fn(k, 10)
+	require.NoError(t, err)
+	require.Equal(t, 10, k.CommonPrefixLength(id.Key()))
+}
+
+func TestExploreNodeIDFuncLooksUpSelfBeyondMaxSearchCpl(t *testing.T) {
+	self := kadt.PeerID("self")
+	fn := exploreNodeIDFunc(self, cplutil.NewPeerIDGenerator(1))
+
+	id, err := fn(self.Key(), cplutil.MaxSearchCpl+1)
+	require.NoError(t, err)
+	require.Equal(t, self, id)
+
+	id, err = fn(self.Key(), cplutil.MaxCpl)
+	require.NoError(t, err)
+	require.Equal(t, self, id)
+
+	_, err = fn(self.Key(), cplutil.MaxCpl+1)
+	require.Error(t, err)
+}
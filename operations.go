@@ -0,0 +1,100 @@
+package zikade
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/plprobelab/zikade/internal/coord"
+	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/kadt"
+	"github.com/plprobelab/zikade/pb"
+)
+
+// An Operation describes a query or broadcast that is running on a [DHT].
+type Operation struct {
+	// ID identifies the operation. It can be passed to [DHT.Operation] and [DHT.CancelOperation].
+	ID string
+
+	// Type is the kind of the operation, one of "find_closer", "message" or "broadcast".
+	Type string
+
+	// MessageType is the type of the message sent to remote peers. It is unset for find closer queries.
+	MessageType pb.Message_MessageType
+
+	// Target is the key the operation is looking for or storing a record at.
+	Target kadt.Key
+
+	// Start is the time the operation was started.
+	Start time.Time
+
+	// Requests is the number of requests the operation sent to remote peers so far.
+	Requests int
+
+	// Success is the number of requests that received a successful response.
+	Success int
+
+	// Failure is the number of requests that failed.
+	Failure int
+
+	// Escalations is the number of times the operation's lookup stalled and
+	// contacted all remaining closest peers at once.
+	Escalations int
+
+	// InFlight holds the peers that were sent a request that has not been answered yet.
+	InFlight []peer.ID
+}
+
+// Operations returns the queries and broadcasts that are currently running, ordered by the time they were
+// started. Maintenance queries of the routing table, such as the bootstrap, are not included.
+func (d *DHT) Operations() []Operation {
+	ops := d.kad.Operations()
+	out := make([]Operation, len(ops))
+	for i, op := range ops {
+		out[i] = newOperation(op)
+	}
+	return out
+}
+
+// Operation returns the running query or broadcast with the given id and whether it was found.
+func (d *DHT) Operation(id string) (Operation, bool) {
+	op, found := d.kad.Operation(coordt.QueryID(id))
+	if !found {
+		return Operation{}, false
+	}
+	return newOperation(op), true
+}
+
+// CancelOperation stops the running query or broadcast with the given id. The calls that are waiting for the
+// operation return with a [context.Canceled] error. It returns [ErrOperationNotFound] if no operation with
+// the id is running.
+func (d *DHT) CancelOperation(ctx context.Context, id string) error {
+	ctx, span := d.tele.Tracer.Start(ctx, "DHT.CancelOperation")
+	defer span.End()
+
+	if !d.kad.CancelOperation(ctx, coordt.QueryID(id)) {
+		return ErrOperationNotFound
+	}
+	return nil
+}
+
+func newOperation(op coord.Operation) Operation {
+	inFlight := make([]peer.ID, len(op.InFlight))
+	for i, p := range op.InFlight {
+		inFlight[i] = peer.ID(p)
+	}
+
+	return Operation{
+		ID:          string(op.ID),
+		Type:        string(op.Type),
+		MessageType: op.MessageType,
+		Target:      op.Target,
+		Start:       op.Stats.Start,
+		Requests:    op.Stats.Requests,
+		Success:     op.Stats.Success,
+		Failure:     op.Stats.Failure,
+		Escalations: op.Stats.Escalations,
+		InFlight:    inFlight,
+	}
+}
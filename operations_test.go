@@ -0,0 +1,77 @@
+package zikade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/kadtest"
+	"github.com/plprobelab/zikade/pb"
+)
+
+func TestDHT_Operations_cancel(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	c := newRandomContent(t)
+
+	top := NewTopology(t)
+	d1 := top.AddServer(nil)
+	d2 := top.AddServer(nil)
+	d3 := top.AddServer(nil)
+
+	top.ConnectChain(ctx, d1, d2, d3)
+
+	// the providers have addresses so that they are handed out without resolving them first
+	for i := 0; i < 3; i++ {
+		_, err := d3.backends[namespaceProviders].Store(ctx, string(c.Hash()), newAddrInfo(t))
+		require.NoError(t, err)
+	}
+
+	// the query blocks on handing out the providers because the channel isn't read
+	out := d1.FindProvidersAsync(ctx, c, 0)
+
+	var op Operation
+	require.Eventually(t, func() bool {
+		ops := d1.Operations()
+		if len(ops) != 1 {
+			return false
+		}
+		op = ops[0]
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "message", op.Type)
+	assert.Equal(t, pb.Message_GET_PROVIDERS, op.MessageType)
+	assert.Equal(t, []byte(c.Hash()), op.Target.MsgKey())
+	assert.GreaterOrEqual(t, op.Requests, 1)
+
+	found, ok := d1.Operation(op.ID)
+	require.True(t, ok)
+	assert.Equal(t, op.ID, found.ID)
+
+	require.NoError(t, d1.CancelOperation(ctx, op.ID))
+
+	// drain the providers that were found before the operation was cancelled
+	for range out {
+	}
+
+	require.Eventually(t, func() bool {
+		return len(d1.Operations()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	require.ErrorIs(t, d1.CancelOperation(ctx, op.ID), ErrOperationNotFound)
+}
+
+func TestDHT_CancelOperation_unknown(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	require.Empty(t, d.Operations())
+
+	_, found := d.Operation("unknown")
+	require.False(t, found)
+
+	require.ErrorIs(t, d.CancelOperation(ctx, "unknown"), ErrOperationNotFound)
+}
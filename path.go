@@ -0,0 +1,178 @@
+package zikade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/zikade/internal/coord"
+	"github.com/plprobelab/zikade/kadt"
+)
+
+// A LookupPath records how a lookup travelled through the network. Every peer
+// that was sent a request is a hop of the path. A hop is referred to by the
+// peers that returned it as a closer peer before it was contacted, so the hops
+// and their referrers form a directed acyclic graph that starts at the peers
+// taken from the local routing table. See [RoutingRecordPath].
+type LookupPath struct {
+	// Target is the key the lookup was looking for.
+	Target kadt.Key
+
+	// Start is the time the lookup was started.
+	Start time.Time
+
+	// Hops holds the contacted peers in the order the requests were sent.
+	Hops []LookupHop
+}
+
+// A LookupHop is a peer that was contacted during a lookup.
+type LookupHop struct {
+	// Peer is the peer that was contacted.
+	Peer peer.ID `json:"peer"`
+
+	// ReferredBy holds the peers that returned Peer as a closer peer before it
+	// was contacted. It is empty if Peer was taken from the routing table.
+	ReferredBy []peer.ID `json:"referred_by,omitempty"`
+
+	// Sent is the time the request was sent to Peer.
+	Sent time.Time `json:"sent"`
+
+	// Responded is true if Peer responded to the request.
+	Responded bool `json:"responded"`
+
+	// RTT is the time it took to receive the response. It is zero if Peer
+	// did not respond.
+	RTT time.Duration `json:"rtt"`
+
+	// ResponseSize is the size of the response in bytes. It is zero if the
+	// size is unknown, e.g., for lookups of peers.
+	ResponseSize int `json:"response_size"`
+
+	// CloserPeers holds the closer peers that Peer returned.
+	CloserPeers []peer.ID `json:"closer_peers,omitempty"`
+
+	// Error describes why the request failed. It is empty if Peer responded
+	// or the lookup stopped before the request completed.
+	Error string `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the path as a JSON object with the fields "target",
+// "start" and "hops". The target is encoded as a hex string and the round
+// trip times of the hops in nanoseconds.
+func (p LookupPath) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Target string      `json:"target"`
+		Start  time.Time   `json:"start"`
+		Hops   []LookupHop `json:"hops"`
+	}{
+		Target: p.Target.HexString(),
+		Start:  p.Start,
+		Hops:   p.Hops,
+	})
+}
+
+// DOT encodes the path as a directed graph in the Graphviz DOT language. The
+// graph has an edge from every hop to the hops that it referred to and from a
+// node named "routing table" to the hops that weren't referred to by any
+// other. Hops are labelled with their round trip time and failed hops are
+// drawn in red.
+func (p LookupPath) DOT() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph lookup {\n")
+	fmt.Fprintf(&b, "\tlabel=%q;\n", "lookup "+p.Target.HexString())
+	fmt.Fprintf(&b, "\t%q [shape=box];\n", "routing table")
+
+	for _, hop := range p.Hops {
+		var attrs string
+		switch {
+		case hop.Responded:
+			attrs = fmt.Sprintf("label=%q", fmt.Sprintf("%s\n%s", hop.Peer.ShortString(), hop.RTT))
+		case hop.Error != "":
+			attrs = fmt.Sprintf("label=%q, color=red", fmt.Sprintf("%s\nfailed", hop.Peer.ShortString()))
+		default:
+			attrs = fmt.Sprintf("label=%q, style=dashed", fmt.Sprintf("%s\npending", hop.Peer.ShortString()))
+		}
+		fmt.Fprintf(&b, "\t%q [%s];\n", hop.Peer.String(), attrs)
+	}
+
+	for _, hop := range p.Hops {
+		if len(hop.ReferredBy) == 0 {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", "routing table", hop.Peer.String())
+			continue
+		}
+		for _, ref := range hop.ReferredBy {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", ref.String(), hop.Peer.String())
+		}
+	}
+
+	fmt.Fprintf(&b, "}\n")
+
+	return b.String()
+}
+
+// newLookupPath converts the path of a query recorded by the coordinator.
+func newLookupPath(p coord.Path) LookupPath {
+	hops := make([]LookupHop, len(p.Hops))
+	for i, hop := range p.Hops {
+		hops[i] = LookupHop{
+			Peer:         peer.ID(hop.Node),
+			ReferredBy:   peerIDs(hop.ReferredBy),
+			Sent:         hop.Sent,
+			Responded:    hop.Responded,
+			RTT:          hop.RTT,
+			ResponseSize: hop.ResponseSize,
+			CloserPeers:  peerIDs(hop.CloserNodes),
+		}
+		if hop.Err != nil {
+			hops[i].Error = hop.Err.Error()
+		}
+	}
+
+	return LookupPath{
+		Target: p.Target,
+		Start:  p.Start,
+		Hops:   hops,
+	}
+}
+
+// recordPath returns a function that stores the path of a lookup in dst,
+// unless dst is nil, and attaches it in both the DOT and JSON encodings to
+// the trace span in the given context.
+func recordPath(dst *LookupPath) func(context.Context, coord.Path) {
+	return func(ctx context.Context, p coord.Path) {
+		path := newLookupPath(p)
+		if dst != nil {
+			*dst = path
+		}
+
+		attrs := []attribute.KeyValue{
+			attribute.Int("hops", len(path.Hops)),
+			attribute.String("dot", path.DOT()),
+		}
+		if data, err := json.Marshal(path); err == nil {
+			attrs = append(attrs, attribute.String("json", string(data)))
+		}
+
+		trace.SpanFromContext(ctx).AddEvent("lookup path", trace.WithAttributes(attrs...))
+	}
+}
+
+// peerIDs converts the given node ids into peer ids.
+func peerIDs(ids []kadt.PeerID) []peer.ID {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	out := make([]peer.ID, len(ids))
+	for i, id := range ids {
+		out[i] = peer.ID(id)
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package zikade
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/zikade/internal/kadtest"
+	"github.com/plprobelab/zikade/kadt"
+)
+
+func TestLookupPath_encoding(t *testing.T) {
+	p1 := newPeerID(t)
+	p2 := newPeerID(t)
+	p3 := newPeerID(t)
+
+	start := time.Unix(1000, 0).UTC()
+	path := LookupPath{
+		Target: kadt.PeerID(p3).Key(),
+		Start:  start,
+		Hops: []LookupHop{
+			{Peer: p1, Sent: start, Responded: true, RTT: time.Millisecond, ResponseSize: 42, CloserPeers: []peer.ID{p2}},
+			{Peer: p2, ReferredBy: []peer.ID{p1}, Sent: start, Error: "timeout"},
+		},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(path)
+		require.NoError(t, err)
+
+		var decoded struct {
+			Target string      `json:"target"`
+			Start  time.Time   `json:"start"`
+			Hops   []LookupHop `json:"hops"`
+		}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, path.Target.HexString(), decoded.Target)
+		assert.Equal(t, start, decoded.Start)
+		assert.Equal(t, path.Hops, decoded.Hops)
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		dot := path.DOT()
+		assert.True(t, strings.HasPrefix(dot, "digraph lookup {"))
+		assert.Contains(t, dot, `"routing table" -> "`+p1.String()+`";`)
+		assert.Contains(t, dot, `"`+p1.String()+`" -> "`+p2.String()+`";`)
+		assert.Contains(t, dot, "color=red")
+		assert.NotContains(t, dot, p3.String())
+	})
+}
+
+func TestDHT_FindProvidersWithMetadataAsync_record_path(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	c := newRandomContent(t)
+
+	top := NewTopology(t)
+	d1 := top.AddServer(nil)
+	d2 := top.AddServer(nil)
+	d3 := top.AddServer(nil)
+
+	top.ConnectChain(ctx, d1, d2, d3)
+
+	var path LookupPath
+	out := d1.FindProvidersWithMetadataAsync(ctx, c, 0, RoutingRecordPath(&path))
+	kadtest.AssertClosed(t, ctx, out)
+
+	// d1 only knows d2, which refers it to d3
+	require.Len(t, path.Hops, 2)
+	assert.Equal(t, d2.host.ID(), path.Hops[0].Peer)
+	assert.Empty(t, path.Hops[0].ReferredBy)
+	assert.True(t, path.Hops[0].Responded)
+	assert.Greater(t, path.Hops[0].ResponseSize, 0)
+	assert.Contains(t, path.Hops[0].CloserPeers, d3.host.ID())
+
+	assert.Equal(t, d3.host.ID(), path.Hops[1].Peer)
+	assert.Equal(t, []peer.ID{d2.host.ID()}, path.Hops[1].ReferredBy)
+}
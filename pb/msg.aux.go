@@ -187,6 +187,10 @@ func (m *Message_Peer) Size() (n int) {
 	if m.Connection != 0 {
 		n += 1 + sovDht(uint64(m.Connection))
 	}
+	l = len(m.Metadata)
+	if l > 0 {
+		n += 1 + l + sovDht(uint64(l))
+	}
 	return n
 }
 
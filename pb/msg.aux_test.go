@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestMessage_ExpectResponse(t *testing.T) {
@@ -36,3 +37,15 @@ func TestMessage_Peer_invalid_maddr(t *testing.T) {
 		t.Fatal("shouldn't have any multiaddrs")
 	}
 }
+
+func TestMessage_Size(t *testing.T) {
+	msg := &Message{
+		Type: Message_GET_PROVIDERS,
+		Key:  []byte("random-key"),
+		ProviderPeers: []*Message_Peer{
+			{Id: []byte("peer-id"), Addrs: [][]byte{[]byte("addr")}, Metadata: []byte("metadata")},
+		},
+	}
+
+	assert.Equal(t, proto.Size(msg), msg.Size())
+}
@@ -0,0 +1,94 @@
+package zikade
+
+import (
+	"context"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/plprobelab/zikade/tele"
+)
+
+// providerCache is a bounded client-side cache of the providers that recent
+// FindProvidersAsync lookups discovered in the network. It is deliberately
+// separate from the [ProvidersBackend] so that cached lookup results are never
+// served to other peers as if we were authoritative for them.
+type providerCache struct {
+	clk   clock.Clock
+	ttl   time.Duration
+	cache *lru.Cache[string, providerCacheEntry]
+	tele  *Telemetry
+}
+
+// providerCacheEntry holds the result of a single provider lookup.
+type providerCacheEntry struct {
+	// providers holds all providers that the lookup discovered.
+	providers []ProviderInfo
+
+	// complete indicates whether the lookup ran until it exhausted the
+	// keyspace. If false, the lookup was stopped early because it found the
+	// requested number of providers.
+	complete bool
+
+	// expiry is the time after which this entry must not be used anymore.
+	expiry time.Time
+}
+
+// newProviderCache initializes a new provider cache that holds at most size
+// entries and discards entries after the given ttl.
+func newProviderCache(clk clock.Clock, size int, ttl time.Duration, tele *Telemetry) (*providerCache, error) {
+	cache, err := lru.New[string, providerCacheEntry](size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &providerCache{
+		clk:   clk,
+		ttl:   ttl,
+		cache: cache,
+		tele:  tele,
+	}, nil
+}
+
+// Get returns the cached entry for the given key if the cache holds a
+// non-expired entry that can satisfy a lookup for count providers. A count of
+// 0 means that the lookup wants all providers, which can only be satisfied by
+// an entry of a complete lookup. The caller must check whether the entry
+// still holds count providers after skipping the ones it already knows.
+func (c *providerCache) Get(ctx context.Context, key string, count int) (providerCacheEntry, bool) {
+	entry, found := c.cache.Get(key)
+	if found && c.clk.Now().After(entry.expiry) {
+		c.cache.Remove(key)
+		found = false
+	}
+
+	hit := found && (entry.complete || (count != 0 && len(entry.providers) >= count))
+	c.trackCacheQuery(ctx, hit)
+
+	if !hit {
+		return providerCacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+// Add stores the providers that a lookup for the given key discovered.
+// The complete parameter indicates whether the lookup exhausted the keyspace.
+func (c *providerCache) Add(key string, providers []ProviderInfo, complete bool) {
+	c.cache.Add(key, providerCacheEntry{
+		providers: providers,
+		complete:  complete,
+		expiry:    c.clk.Now().Add(c.ttl),
+	})
+}
+
+// trackCacheQuery updates the metrics about cache hit/miss performance
+func (c *providerCache) trackCacheQuery(ctx context.Context, hit bool) {
+	set := tele.FromContext(ctx,
+		tele.AttrCacheHit(hit),
+		tele.AttrRecordType("provider"),
+	)
+	c.tele.ProviderCache.Add(ctx, 1, metric.WithAttributeSet(set))
+}
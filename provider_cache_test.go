@@ -0,0 +1,79 @@
+package zikade
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProviderCache(t testing.TB, clk clock.Clock) *providerCache {
+	tele, err := NewWithGlobalProviders()
+	require.NoError(t, err)
+
+	c, err := newProviderCache(clk, 10, time.Minute, tele)
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestProviderCache_Get(t *testing.T) {
+	ctx := context.Background()
+
+	providers := []ProviderInfo{
+		{AddrInfo: peer.AddrInfo{ID: newPeerID(t)}},
+		{AddrInfo: peer.AddrInfo{ID: newPeerID(t)}},
+	}
+
+	t.Run("miss", func(t *testing.T) {
+		c := newTestProviderCache(t, clock.NewMock())
+
+		_, found := c.Get(ctx, "key", 0)
+		assert.False(t, found)
+	})
+
+	t.Run("complete lookup", func(t *testing.T) {
+		c := newTestProviderCache(t, clock.NewMock())
+		c.Add("key", providers, true)
+
+		for _, count := range []int{0, 1, 2, 3} {
+			cached, found := c.Get(ctx, "key", count)
+			assert.True(t, found)
+			assert.True(t, cached.complete)
+			assert.Equal(t, providers, cached.providers)
+		}
+	})
+
+	t.Run("incomplete lookup", func(t *testing.T) {
+		c := newTestProviderCache(t, clock.NewMock())
+		c.Add("key", providers, false)
+
+		_, found := c.Get(ctx, "key", 0)
+		assert.False(t, found)
+
+		_, found = c.Get(ctx, "key", 2)
+		assert.True(t, found)
+
+		_, found = c.Get(ctx, "key", 3)
+		assert.False(t, found)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		clk := clock.NewMock()
+		c := newTestProviderCache(t, clk)
+		c.Add("key", providers, true)
+
+		clk.Add(c.ttl / 2)
+		_, found := c.Get(ctx, "key", 0)
+		assert.True(t, found)
+
+		clk.Add(c.ttl)
+		_, found = c.Get(ctx, "key", 0)
+		assert.False(t, found)
+		assert.Equal(t, 0, c.cache.Len())
+	})
+}
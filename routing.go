@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ipfs/go-cid"
@@ -15,11 +16,14 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/routing"
+	ma "github.com/multiformats/go-multiaddr"
 	"go.opentelemetry.io/otel/attribute"
 	otel "go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 
+	"github.com/plprobelab/zikade/internal/coord"
 	"github.com/plprobelab/zikade/internal/coord/coordt"
+	"github.com/plprobelab/zikade/internal/coord/query"
 	"github.com/plprobelab/zikade/kadt"
 	"github.com/plprobelab/zikade/pb"
 )
@@ -27,9 +31,21 @@ import (
 var _ routing.Routing = (*DHT)(nil)
 
 func (d *DHT) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	return d.FindPeerWithOptions(ctx, id)
+}
+
+// FindPeerWithOptions is like [DHT.FindPeer] but additionally accepts routing
+// options, e.g., [RoutingTimeout] or [RoutingQueryStats].
+func (d *DHT) FindPeerWithOptions(ctx context.Context, id peer.ID, opts ...routing.Option) (peer.AddrInfo, error) {
 	ctx, span := d.tele.Tracer.Start(ctx, "DHT.FindPeer")
 	defer span.End()
 
+	// first parse the routing options
+	rOpt := &routing.Options{} // routing config
+	if err := rOpt.Apply(opts...); err != nil {
+		return peer.AddrInfo{}, fmt.Errorf("apply routing options: %w", err)
+	}
+
 	// First check locally. If we are or were recently connected to the peer,
 	// return the addresses from our peerstore unless the information doesn't
 	// contain any.
@@ -52,7 +68,8 @@ func (d *DHT) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
 		return nil
 	}
 
-	_, _, err := d.kad.QueryClosest(ctx, kadt.PeerID(id).Key(), fn, 20)
+	_, stats, err := d.kad.QueryClosest(ctx, kadt.PeerID(id).Key(), fn, 20, queryOptions(rOpt)...)
+	reportQueryStats(rOpt, stats)
 	if err != nil {
 		return peer.AddrInfo{}, fmt.Errorf("failed to run query: %w", err)
 	}
@@ -65,9 +82,22 @@ func (d *DHT) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
 }
 
 func (d *DHT) Provide(ctx context.Context, c cid.Cid, brdcst bool) error {
+	return d.ProvideWithOptions(ctx, c, brdcst)
+}
+
+// ProvideWithOptions is like [DHT.Provide] but additionally accepts routing
+// options. Use [ProvideMetadata] to attach metadata to the provider record.
+func (d *DHT) ProvideWithOptions(ctx context.Context, c cid.Cid, brdcst bool, opts ...routing.Option) error {
 	ctx, span := d.tele.Tracer.Start(ctx, "DHT.Provide", otel.WithAttributes(attribute.String("cid", c.String())))
 	defer span.End()
 
+	// first parse the routing options
+	rOpt := routing.Options{} // routing config
+	if err := rOpt.Apply(opts...); err != nil {
+		return fmt.Errorf("apply routing options: %w", err)
+	}
+	metadata := getProvideMetadata(&rOpt)
+
 	// verify if this DHT supports provider records by checking if a "providers"
 	// backend is registered.
 	b, found := d.backends[namespaceProviders]
@@ -80,8 +110,14 @@ func (d *DHT) Provide(ctx context.Context, c cid.Cid, brdcst bool) error {
 		return fmt.Errorf("invalid cid: undefined")
 	}
 
-	// store ourselves as one provider for that CID
-	_, err := b.Store(ctx, string(c.Hash()), peer.AddrInfo{ID: d.host.ID()})
+	// store ourselves as one provider for that CID. Only pass a ProviderInfo
+	// to the backend if we have metadata to keep custom backends working.
+	var self any = peer.AddrInfo{ID: d.host.ID()}
+	if len(metadata) > 0 {
+		self = ProviderInfo{AddrInfo: peer.AddrInfo{ID: d.host.ID()}, Metadata: metadata}
+	}
+
+	_, err := b.Store(ctx, string(c.Hash()), self)
 	if err != nil {
 		return fmt.Errorf("storing own provider record: %w", err)
 	}
@@ -101,21 +137,56 @@ func (d *DHT) Provide(ctx context.Context, c cid.Cid, brdcst bool) error {
 		Type: pb.Message_ADD_PROVIDER,
 		Key:  c.Hash(),
 		ProviderPeers: []*pb.Message_Peer{
-			pb.FromAddrInfo(addrInfo),
+			providerToPB(ProviderInfo{AddrInfo: addrInfo, Metadata: metadata}),
 		},
 	}
 
 	// finally, find the closest peers to the target key.
-	return d.kad.BroadcastRecord(ctx, msg)
+	stats, err := d.kad.BroadcastRecord(ctx, msg, queryOptions(&rOpt)...)
+	reportQueryStats(&rOpt, stats)
+	return err
 }
 
 func (d *DHT) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	provOut := d.FindProvidersWithMetadataAsync(ctx, c, count)
+
 	peerOut := make(chan peer.AddrInfo)
-	go d.findProvidersAsyncRoutine(ctx, c, count, peerOut)
+	go func() {
+		defer close(peerOut)
+		for provider := range provOut {
+			select {
+			case <-ctx.Done():
+				return
+			case peerOut <- provider.AddrInfo:
+			}
+		}
+	}()
+
 	return peerOut
 }
 
-func (d *DHT) findProvidersAsyncRoutine(ctx context.Context, c cid.Cid, count int, out chan<- peer.AddrInfo) {
+// FindProvidersWithMetadataAsync is like [DHT.FindProvidersAsync] but returns
+// [ProviderInfo] values that, in addition to the provider's peer ID and
+// addresses, contain the metadata that the provider has attached to its
+// provider record (see [ProvideMetadata]). It additionally accepts routing
+// options, e.g., [RoutingBypassProviderCache]. If the options are invalid,
+// the returned channel is closed right away.
+func (d *DHT) FindProvidersWithMetadataAsync(ctx context.Context, c cid.Cid, count int, opts ...routing.Option) <-chan ProviderInfo {
+	provOut := make(chan ProviderInfo)
+
+	// first parse the routing options
+	rOpt := &routing.Options{} // routing config
+	if err := rOpt.Apply(opts...); err != nil {
+		d.log.Warn("Invalid routing options", slog.String("err", err.Error()))
+		close(provOut)
+		return provOut
+	}
+
+	go d.findProvidersAsyncRoutine(ctx, c, count, rOpt, provOut)
+	return provOut
+}
+
+func (d *DHT) findProvidersAsyncRoutine(ctx context.Context, c cid.Cid, count int, ropt *routing.Options, out chan<- ProviderInfo) {
 	_, span := d.tele.Tracer.Start(ctx, "DHT.findProvidersAsyncRoutine", otel.WithAttributes(attribute.String("cid", c.String()), attribute.Int("count", count)))
 	defer span.End()
 
@@ -166,16 +237,96 @@ func (d *DHT) findProvidersAsyncRoutine(ctx context.Context, c cid.Cid, count in
 		}
 	}
 
+	// then check if a recent lookup has already discovered providers. If they
+	// don't suffice, we keep them to cache them again with the network results.
+	var cachedProviders []ProviderInfo
+	if d.providerCache != nil && !bypassProviderCache(ctx, ropt) {
+		if cached, ok := d.providerCache.Get(ctx, string(c.Hash()), count); ok {
+			for _, provider := range cached.providers {
+				if _, found := providers[provider.ID]; found {
+					continue
+				}
+				providers[provider.ID] = struct{}{}
+
+				select {
+				case <-ctx.Done():
+					return
+				case out <- provider:
+				}
+
+				if count != 0 && len(providers) == count {
+					return
+				}
+			}
+
+			// A complete lookup found all providers there are. Otherwise, the
+			// cached providers that we already sent from the local store leave
+			// us short of count, so we ask the network for more.
+			if cached.complete {
+				return
+			}
+			cachedProviders = cached.providers
+		}
+	}
+
 	// Craft message to send to other peers
 	msg := &pb.Message{
 		Type: pb.Message_GET_PROVIDERS,
 		Key:  c.Hash(),
 	}
 
+	// discovered keeps track of all providers that we found in the network
+	// so that we can add them to the provider cache after the lookup. The
+	// lookup is complete if we didn't stop it early after finding count
+	// providers.
+	var (
+		discoveredMu sync.Mutex
+		discovered   = append([]ProviderInfo(nil), cachedProviders...)
+		complete     = true
+	)
+	discover := func(provider ProviderInfo) {
+		discoveredMu.Lock()
+		discovered = append(discovered, provider)
+		discoveredMu.Unlock()
+	}
+
+	// resolve looks up the addresses of the given provider in the background
+	// and sends it to the caller afterward. We wait for all lookups to finish
+	// before closing the out channel.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := make(chan struct{}, d.cfg.Query.ResolveProviderAddrsConcurrency)
+	resolve := func(provider ProviderInfo) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case <-ctx.Done():
+				return
+			case sem <- struct{}{}:
+			}
+			provider.Addrs = d.resolveProviderAddrs(ctx, provider.ID)
+			<-sem
+
+			discover(provider)
+
+			select {
+			case <-ctx.Done():
+			case out <- provider:
+			}
+		}()
+	}
+
 	// handle node response
 	fn := func(ctx context.Context, id kadt.PeerID, resp *pb.Message, stats coordt.QueryStats) error {
 		// loop through all providers that the remote peer returned
-		for _, provider := range resp.ProviderAddrInfos() {
+		for i, addrInfo := range resp.ProviderAddrInfos() {
+			provider := ProviderInfo{
+				AddrInfo: addrInfo,
+				Metadata: resp.ProviderPeers[i].GetMetadata(),
+			}
 
 			// if we had already sent that peer on the channel -> do nothing
 			if _, found := providers[provider.ID]; found {
@@ -185,17 +336,26 @@ func (d *DHT) findProvidersAsyncRoutine(ctx context.Context, c cid.Cid, count in
 			// keep track that we will have sent this peer on the channel
 			providers[provider.ID] = struct{}{}
 
-			// actually send the provider information to the user
-			select {
-			case <-ctx.Done():
-				return coordt.ErrSkipRemaining
-			case out <- provider:
+			// if the remote peer didn't know any addresses of the provider,
+			// look them up before sending the provider to the user.
+			if d.cfg.Query.ResolveProviderAddrs && len(provider.Addrs) == 0 {
+				resolve(provider)
+			} else {
+				discover(provider)
+
+				// actually send the provider information to the user
+				select {
+				case <-ctx.Done():
+					return coordt.ErrSkipRemaining
+				case out <- provider:
+				}
 			}
 
 			// if count is 0, we will wait until the query has exhausted the keyspace
 			// if count isn't 0, we will stop if the number of providers we have sent
 			// equals the number that the user has requested.
 			if count != 0 && len(providers) == count {
+				complete = false
 				return coordt.ErrSkipRemaining
 			}
 		}
@@ -203,12 +363,36 @@ func (d *DHT) findProvidersAsyncRoutine(ctx context.Context, c cid.Cid, count in
 		return nil
 	}
 
-	_, _, err = d.kad.QueryMessage(ctx, msg, fn, d.cfg.BucketSize)
+	_, stats, err := d.kad.QueryMessage(ctx, msg, fn, d.cfg.BucketSize, queryOptions(ropt)...)
+	reportQueryStats(ropt, stats)
 	if err != nil {
 		span.RecordError(err)
 		d.log.Warn("Failed querying", slog.String("cid", c.String()), slog.String("err", err.Error()))
 		return
 	}
+
+	// wait for all address lookups before caching the discovered providers
+	wg.Wait()
+
+	if d.providerCache != nil && ctx.Err() == nil && len(discovered) > len(cachedProviders) {
+		d.providerCache.Add(string(c.Hash()), discovered, complete)
+	}
+}
+
+// resolveProviderAddrs looks up the addresses of the provider with the given
+// ID. It gives up after [QueryConfig.ResolveProviderAddrsTimeout] and returns
+// nil if the addresses couldn't be found.
+func (d *DHT) resolveProviderAddrs(ctx context.Context, id peer.ID) []ma.Multiaddr {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Query.ResolveProviderAddrsTimeout)
+	defer cancel()
+
+	addrInfo, err := d.FindPeer(ctx, id)
+	if err != nil {
+		d.log.Debug("Failed resolving provider addresses", slog.String("peer", id.String()), slog.String("err", err.Error()))
+		return nil
+	}
+
+	return addrInfo.Addrs
 }
 
 // PutValue satisfies the [routing.Routing] interface and will add the given
@@ -245,7 +429,8 @@ func (d *DHT) PutValue(ctx context.Context, keyStr string, value []byte, opts ..
 	}
 
 	// finally, find the closest peers to the target key.
-	err := d.kad.BroadcastRecord(ctx, msg)
+	stats, err := d.kad.BroadcastRecord(ctx, msg, queryOptions(&rOpt)...)
+	reportQueryStats(&rOpt, stats)
 	if err != nil {
 		return fmt.Errorf("query error: %w", err)
 	}
@@ -436,7 +621,8 @@ func (d *DHT) searchValueRoutine(ctx context.Context, backend Backend, ns string
 		return nil
 	}
 
-	_, _, err := d.kad.QueryMessage(ctx, req, fn, d.cfg.BucketSize)
+	_, stats, err := d.kad.QueryMessage(ctx, req, fn, d.cfg.BucketSize, queryOptions(ropt)...)
+	reportQueryStats(ropt, stats)
 	if err != nil {
 		d.warnErr(err, "Search value query failed")
 		return
@@ -455,7 +641,7 @@ func (d *DHT) searchValueRoutine(ctx context.Context, backend Backend, ns string
 			Record: record.MakePutRecord(string(routingKey), best),
 		}
 
-		if err := d.kad.BroadcastStatic(ctx, msg, fixupPeers); err != nil {
+		if _, err := d.kad.BroadcastStatic(ctx, msg, fixupPeers); err != nil {
 			d.log.Warn("Failed updating peer")
 		}
 	}()
@@ -496,6 +682,430 @@ func (d *DHT) getQuorum(opts *routing.Options) int {
 	return quorum
 }
 
+// provideMetadataOptionKey is a struct that is used as a routing options key
+// to pass provider record metadata into [DHT.ProvideWithOptions].
+type provideMetadataOptionKey struct{}
+
+// ProvideMetadata accepts an opaque metadata blob that will be attached to the
+// provider record, e.g., the retrieval protocols that this node supports. The
+// metadata must not exceed [MaxProviderMetadataSize] bytes. Peers that don't
+// support provider metadata will ignore it.
+func ProvideMetadata(metadata []byte) routing.Option {
+	return func(opts *routing.Options) error {
+		if len(metadata) > MaxProviderMetadataSize {
+			return fmt.Errorf("provider metadata must not exceed %d bytes", MaxProviderMetadataSize)
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[provideMetadataOptionKey{}] = metadata
+
+		return nil
+	}
+}
+
+// getProvideMetadata extracts the provider record metadata from the given
+// routing options and returns nil if no metadata is present.
+func getProvideMetadata(opts *routing.Options) []byte {
+	metadata, _ := opts.Other[provideMetadataOptionKey{}].([]byte)
+	return metadata
+}
+
+// bypassProviderCacheOptionKey is a struct that is used as a routing options
+// key to bypass the client-side provider cache.
+type bypassProviderCacheOptionKey struct{}
+
+// RoutingBypassProviderCache instructs provider lookups to not consult the
+// client-side provider cache (see [QueryConfig.ProviderCacheSize]) and to
+// always query the network instead. The results of the lookup will still be
+// added to the cache. Use [ContextBypassProviderCache] for
+// [DHT.FindProvidersAsync], which doesn't accept routing options.
+func RoutingBypassProviderCache() routing.Option {
+	return func(opts *routing.Options) error {
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[bypassProviderCacheOptionKey{}] = true
+
+		return nil
+	}
+}
+
+// ContextBypassProviderCache returns a context that instructs provider
+// lookups to bypass the client-side provider cache like
+// [RoutingBypassProviderCache]. It is meant for [DHT.FindProvidersAsync],
+// whose signature is defined by [routing.ContentRouting].
+func ContextBypassProviderCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassProviderCacheOptionKey{}, true)
+}
+
+// bypassProviderCache returns true if the given context or routing options
+// instruct us to bypass the client-side provider cache.
+func bypassProviderCache(ctx context.Context, opts *routing.Options) bool {
+	if bypass, _ := ctx.Value(bypassProviderCacheOptionKey{}).(bool); bypass {
+		return true
+	}
+	bypass, _ := opts.Other[bypassProviderCacheOptionKey{}].(bool)
+	return bypass
+}
+
+// disjointPathsOptionKey is a struct that is used as a routing options key to
+// pass the number of disjoint lookup paths into, e.g., SearchValue or
+// FindProvidersWithMetadataAsync.
+type disjointPathsOptionKey struct{}
+
+// RoutingDisjointPaths instructs lookups to follow the given number of
+// disjoint paths through the network as described by S/Kademlia. The nodes
+// closest to the key are split into n independent lookups, and no node is
+// queried by more than one of them. This limits the influence that a single
+// malicious node can have on the result of the lookup at the cost of more
+// requests. The number of paths must be positive, and a value of 1 performs a
+// regular lookup.
+func RoutingDisjointPaths(n int) routing.Option {
+	return func(opts *routing.Options) error {
+		if n < 1 {
+			return fmt.Errorf("disjoint paths must be greater than zero")
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[disjointPathsOptionKey{}] = n
+
+		return nil
+	}
+}
+
+// terminationOptionKey is a struct that is used as a routing options key to
+// pass the policies that may end a lookup before it ran to completion.
+type terminationOptionKey struct{}
+
+// addTermination returns a routing option that adds the given termination
+// policy to the policies of a lookup. The lookup ends as soon as any of them
+// would end it.
+func addTermination(policy query.TerminationPolicy) routing.Option {
+	return func(opts *routing.Options) error {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		policies, _ := opts.Other[terminationOptionKey{}].(query.AnyPolicy)
+		opts.Other[terminationOptionKey{}] = append(policies, policy)
+
+		return nil
+	}
+}
+
+// RoutingTerminateWhenStable instructs lookups to finish once the closest
+// nodes that they know about did not change for the given number of rounds.
+// A round completes when a lookup received as many responses as it may have
+// requests in flight. This trades the accuracy of the result for a faster
+// lookup. The number of rounds must be positive.
+func RoutingTerminateWhenStable(rounds int) routing.Option {
+	return addTermination(query.StablePolicy{Rounds: rounds})
+}
+
+// RoutingRequestBudget instructs lookups to finish once they sent the given
+// number of requests, even if they haven't reached the closest nodes yet. For
+// lookups over several [RoutingDisjointPaths] the budget covers the requests
+// of all paths together. The number of requests must be positive.
+func RoutingRequestBudget(requests int) routing.Option {
+	return addTermination(query.RequestBudgetPolicy{Requests: requests})
+}
+
+// RoutingTimeBudget instructs lookups to finish once the given time passed
+// since they sent their first request. Unlike a context deadline, the lookup
+// returns the results that it found until then instead of an error. The
+// budget must be positive.
+func RoutingTimeBudget(budget time.Duration) routing.Option {
+	return addTermination(query.TimeBudgetPolicy{Budget: budget})
+}
+
+// numResultsOptionKey is a struct that is used as a routing options key to
+// pass the number of closest nodes that a lookup should find.
+type numResultsOptionKey struct{}
+
+// RoutingNumResults instructs lookups and broadcasts to contact the given
+// number of closest nodes to the key instead of [Config.BucketSize]. The
+// number must be positive.
+func RoutingNumResults(n int) routing.Option {
+	return func(opts *routing.Options) error {
+		if n < 1 {
+			return fmt.Errorf("number of results must be greater than zero")
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[numResultsOptionKey{}] = n
+
+		return nil
+	}
+}
+
+// requestConcurrencyOptionKey is a struct that is used as a routing options
+// key to pass the number of requests that a lookup may have in flight.
+type requestConcurrencyOptionKey struct{}
+
+// RoutingRequestConcurrency instructs lookups and broadcasts to have at most
+// the given number of requests in flight at the same time. The number must be
+// positive.
+func RoutingRequestConcurrency(n int) routing.Option {
+	return func(opts *routing.Options) error {
+		if n < 1 {
+			return fmt.Errorf("request concurrency must be greater than zero")
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[requestConcurrencyOptionKey{}] = n
+
+		return nil
+	}
+}
+
+// requestTimeoutOptionKey is a struct that is used as a routing options key
+// to pass the timeout of a single request of a lookup.
+type requestTimeoutOptionKey struct{}
+
+// RoutingRequestTimeout instructs lookups and broadcasts to give up on a
+// single node if it didn't respond within the given duration. It replaces the
+// timeout that is otherwise derived from the round trip times observed for
+// the node. The timeout must be positive.
+func RoutingRequestTimeout(timeout time.Duration) routing.Option {
+	return func(opts *routing.Options) error {
+		if timeout <= 0 {
+			return fmt.Errorf("request timeout must be greater than zero")
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[requestTimeoutOptionKey{}] = timeout
+
+		return nil
+	}
+}
+
+// timeoutOptionKey is a struct that is used as a routing options key to pass
+// the maximum duration of a lookup.
+type timeoutOptionKey struct{}
+
+// RoutingTimeout bounds the duration of lookups and broadcasts. If the lookup
+// did not finish within the given duration, it is stopped and the operation
+// fails with [context.DeadlineExceeded]. Use [RoutingTimeBudget] to keep the
+// results found until then instead. The timeout must be positive.
+func RoutingTimeout(timeout time.Duration) routing.Option {
+	return func(opts *routing.Options) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be greater than zero")
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[timeoutOptionKey{}] = timeout
+
+		return nil
+	}
+}
+
+// seedCountOptionKey is a struct that is used as a routing options key to
+// pass the number of nodes from the routing table that a lookup starts with.
+type seedCountOptionKey struct{}
+
+// RoutingSeedCount instructs lookups and broadcasts to start with the given
+// number of closest nodes from the local routing table. The number must be
+// positive.
+func RoutingSeedCount(n int) routing.Option {
+	return func(opts *routing.Options) error {
+		if n < 1 {
+			return fmt.Errorf("seed count must be greater than zero")
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[seedCountOptionKey{}] = n
+
+		return nil
+	}
+}
+
+// BroadcastStrategy is the way that [DHT.PutValue] and [DHT.Provide] store a
+// record with the closest nodes to its key.
+type BroadcastStrategy int
+
+const (
+	// BroadcastFollowUp looks up the closest nodes to the key first and then
+	// stores the record with them. It is the default.
+	BroadcastFollowUp BroadcastStrategy = iota
+
+	// BroadcastStatic stores the record with the closest nodes in the local
+	// routing table without looking up closer nodes in the network.
+	BroadcastStatic
+)
+
+// broadcastStrategyOptionKey is a struct that is used as a routing options
+// key to pass the strategy of a broadcast.
+type broadcastStrategyOptionKey struct{}
+
+// RoutingBroadcastStrategy selects the strategy that [DHT.PutValue] and
+// [DHT.Provide] use to store a record with the closest nodes to its key.
+func RoutingBroadcastStrategy(strategy BroadcastStrategy) routing.Option {
+	return func(opts *routing.Options) error {
+		switch strategy {
+		case BroadcastFollowUp, BroadcastStatic:
+		default:
+			return fmt.Errorf("unknown broadcast strategy: %d", strategy)
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[broadcastStrategyOptionKey{}] = strategy
+
+		return nil
+	}
+}
+
+// QueryStats holds the statistics of the requests that a lookup or broadcast
+// made. See [RoutingQueryStats].
+type QueryStats struct {
+	Start       time.Time // the time the operation began
+	End         time.Time // the time the operation stopped
+	Requests    int       // the number of requests that were sent
+	Success     int       // the number of requests that succeeded
+	Failure     int       // the number of requests that failed
+	Escalations int       // the number of times the lookup stalled and contacted all remaining closest peers at once
+	Exhausted   bool      // whether the lookup ended after contacting every node it could
+}
+
+// queryStatsOptionKey is a struct that is used as a routing options key to
+// pass the destination of the statistics of a lookup.
+type queryStatsOptionKey struct{}
+
+// RoutingQueryStats instructs the routing method to record the statistics of
+// the lookup or broadcast that it ran in the given value. The value is written
+// before the method returns or, for methods that return a channel, before the
+// channel is closed. It is left untouched if the method didn't reach out to
+// the network.
+func RoutingQueryStats(stats *QueryStats) routing.Option {
+	return func(opts *routing.Options) error {
+		if stats == nil {
+			return fmt.Errorf("query stats must not be nil")
+		}
+
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[queryStatsOptionKey{}] = stats
+
+		return nil
+	}
+}
+
+// reportQueryStats records the given statistics in the destination that was
+// passed with [RoutingQueryStats], if any.
+func reportQueryStats(opts *routing.Options, stats coordt.QueryStats) {
+	dst, ok := opts.Other[queryStatsOptionKey{}].(*QueryStats)
+	if !ok {
+		return
+	}
+
+	*dst = QueryStats{
+		Start:       stats.Start,
+		End:         stats.End,
+		Requests:    stats.Requests,
+		Success:     stats.Success,
+		Failure:     stats.Failure,
+		Escalations: stats.Escalations,
+		Exhausted:   stats.Exhausted,
+	}
+}
+
+// recordPathOptionKey is a struct that is used as a routing options key to
+// pass the destination of the path of a lookup.
+type recordPathOptionKey struct{}
+
+// RoutingRecordPath instructs lookups and broadcasts to record the path that
+// they take through the network (see [LookupPath]). The path is stored in the
+// given value before the routing method returns or, for methods that return a
+// channel, before the channel is closed. The path is also attached to the
+// trace span of the lookup. If p is nil, the path is only attached to the
+// trace span. Recording the path prevents the lookup from being shared with
+// concurrent lookups for the same key.
+func RoutingRecordPath(p *LookupPath) routing.Option {
+	return func(opts *routing.Options) error {
+		if opts.Other == nil {
+			opts.Other = make(map[interface{}]interface{}, 1)
+		}
+
+		opts.Other[recordPathOptionKey{}] = p
+
+		return nil
+	}
+}
+
+// queryOptions converts the given routing options into the options of the
+// query that the coordinator runs for a lookup.
+func queryOptions(opts *routing.Options) []coord.QueryOption {
+	var qopts []coord.QueryOption
+	if paths, ok := opts.Other[disjointPathsOptionKey{}].(int); ok {
+		qopts = append(qopts, coord.WithDisjointPaths(paths))
+	}
+	if policies, ok := opts.Other[terminationOptionKey{}].(query.AnyPolicy); ok {
+		if len(policies) == 1 {
+			qopts = append(qopts, coord.WithTermination(policies[0]))
+		} else {
+			qopts = append(qopts, coord.WithTermination(policies))
+		}
+	}
+	if n, ok := opts.Other[numResultsOptionKey{}].(int); ok {
+		qopts = append(qopts, coord.WithNumResults(n))
+	}
+	if n, ok := opts.Other[requestConcurrencyOptionKey{}].(int); ok {
+		qopts = append(qopts, coord.WithRequestConcurrency(n))
+	}
+	if timeout, ok := opts.Other[requestTimeoutOptionKey{}].(time.Duration); ok {
+		qopts = append(qopts, coord.WithRequestTimeout(timeout))
+	}
+	if timeout, ok := opts.Other[timeoutOptionKey{}].(time.Duration); ok {
+		qopts = append(qopts, coord.WithTimeout(timeout))
+	}
+	if n, ok := opts.Other[seedCountOptionKey{}].(int); ok {
+		qopts = append(qopts, coord.WithSeedCount(n))
+	}
+	if strategy, ok := opts.Other[broadcastStrategyOptionKey{}].(BroadcastStrategy); ok {
+		switch strategy {
+		case BroadcastStatic:
+			qopts = append(qopts, coord.WithBroadcastStrategy(coord.BroadcastStatic))
+		default:
+			qopts = append(qopts, coord.WithBroadcastStrategy(coord.BroadcastFollowUp))
+		}
+	}
+	if dst, ok := opts.Other[recordPathOptionKey{}].(*LookupPath); ok {
+		qopts = append(qopts, coord.WithPath(recordPath(dst)))
+	}
+	return qopts
+}
+
 func (d *DHT) Bootstrap(ctx context.Context) error {
 	ctx, span := d.tele.Tracer.Start(ctx, "DHT.Bootstrap")
 	defer span.End()
@@ -21,6 +21,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/plprobelab/zikade/internal/coord"
+	"github.com/plprobelab/zikade/internal/coord/query"
 	"github.com/plprobelab/zikade/internal/kadtest"
 	"github.com/plprobelab/zikade/kadt"
 )
@@ -85,6 +87,33 @@ func TestDHT_FindPeer_happy_path(t *testing.T) {
 	assert.Equal(t, d4.host.ID(), addrInfo.ID)
 }
 
+func TestDHT_FindPeerWithOptions_query_stats(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	top := NewTopology(t)
+	d1 := top.AddServer(nil)
+	d2 := top.AddServer(nil)
+	d3 := top.AddServer(nil)
+	top.ConnectChain(ctx, d1, d2, d3)
+
+	var stats QueryStats
+	addrInfo, err := d1.FindPeerWithOptions(ctx, d3.host.ID(), RoutingQueryStats(&stats))
+	require.NoError(t, err)
+	assert.Equal(t, d3.host.ID(), addrInfo.ID)
+	assert.GreaterOrEqual(t, stats.Requests, 1)
+	assert.GreaterOrEqual(t, stats.Success, 1)
+	assert.False(t, stats.Start.IsZero())
+	assert.False(t, stats.End.Before(stats.Start))
+}
+
+func TestDHT_FindPeerWithOptions_invalid_options(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	_, err := d.FindPeerWithOptions(ctx, newPeerID(t), RoutingRequestTimeout(0))
+	assert.Error(t, err)
+}
+
 func TestDHT_FindPeer_not_found(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -151,6 +180,24 @@ func TestDHT_PutValue_happy_path(t *testing.T) {
 	}, time.Until(deadline), 10*time.Millisecond)
 }
 
+func TestDHT_PutValue_query_stats(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	top := NewTopology(t)
+	d1 := top.AddServer(nil)
+	d2 := top.AddServer(nil)
+
+	top.ConnectChain(ctx, d1, d2)
+
+	k, v := makePkKeyValue(t)
+
+	var stats QueryStats
+	err := d1.PutValue(ctx, k, v, RoutingBroadcastStrategy(BroadcastStatic), RoutingQueryStats(&stats))
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Requests)
+	assert.Equal(t, 1, stats.Success)
+}
+
 func TestDHT_PutValue_local_only(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 
@@ -321,6 +368,33 @@ func TestDHT_FindProvidersAsync_providers_stored_locally(t *testing.T) {
 	kadtest.AssertClosed(t, ctx, out)
 }
 
+func TestDHT_FindProvidersWithMetadataAsync_providers_stored_locally(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	c := newRandomContent(t)
+	metadata := []byte("transport-bitswap")
+
+	err := d.ProvideWithOptions(ctx, c, false, ProvideMetadata(metadata))
+	require.NoError(t, err)
+
+	out := d.FindProvidersWithMetadataAsync(ctx, c, 1)
+
+	val := kadtest.ReadItem(t, ctx, out)
+	assert.Equal(t, d.host.ID(), val.ID)
+	assert.Equal(t, metadata, val.Metadata)
+
+	kadtest.AssertClosed(t, ctx, out)
+}
+
+func TestDHT_ProvideWithOptions_metadata_too_large(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	err := d.ProvideWithOptions(ctx, newRandomContent(t), false, ProvideMetadata(make([]byte, MaxProviderMetadataSize+1)))
+	assert.Error(t, err)
+}
+
 func TestDHT_FindProvidersAsync_returns_only_count_from_local_store(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 	d := newTestDHT(t)
@@ -382,6 +456,189 @@ func TestDHT_FindProvidersAsync_queries_other_peers(t *testing.T) {
 	kadtest.AssertClosed(t, ctx, out)
 }
 
+func TestDHT_FindProvidersWithMetadataAsync_disjoint_paths(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	c := newRandomContent(t)
+
+	top := NewTopology(t)
+	d1 := top.AddServer(nil)
+	d2 := top.AddServer(nil)
+	d3 := top.AddServer(nil)
+
+	// d1 knows about d2 and d3, so each path starts with one of them
+	top.ConnectChain(ctx, d2, d1, d3)
+
+	provider := peer.AddrInfo{ID: newPeerID(t)}
+	_, err := d3.backends[namespaceProviders].Store(ctx, string(c.Hash()), provider)
+	require.NoError(t, err)
+
+	out := d1.FindProvidersWithMetadataAsync(ctx, c, 1, RoutingDisjointPaths(2))
+
+	val := kadtest.ReadItem(t, ctx, out)
+	assert.Equal(t, provider.ID, val.ID)
+
+	kadtest.AssertClosed(t, ctx, out)
+}
+
+func TestDHT_FindProvidersWithMetadataAsync_disjoint_paths_invalid(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	out := d.FindProvidersWithMetadataAsync(ctx, newRandomContent(t), 1, RoutingDisjointPaths(0))
+	kadtest.AssertClosed(t, ctx, out)
+}
+
+func TestDHT_FindProvidersWithMetadataAsync_request_budget(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	c := newRandomContent(t)
+
+	top := NewTopology(t)
+	d1 := top.AddServer(nil)
+	d2 := top.AddServer(nil)
+	d3 := top.AddServer(nil)
+
+	top.ConnectChain(ctx, d1, d2, d3)
+
+	provider := peer.AddrInfo{ID: newPeerID(t)}
+	_, err := d3.backends[namespaceProviders].Store(ctx, string(c.Hash()), provider)
+	require.NoError(t, err)
+
+	// the lookup only asks d2 and finishes before it reaches d3, which knows the provider
+	out := d1.FindProvidersWithMetadataAsync(ctx, c, 1, RoutingRequestBudget(1))
+	kadtest.AssertClosed(t, ctx, out)
+}
+
+func TestDHT_FindProvidersWithMetadataAsync_termination_invalid(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	out := d.FindProvidersWithMetadataAsync(ctx, newRandomContent(t), 1, RoutingTimeBudget(0))
+	kadtest.AssertClosed(t, ctx, out)
+}
+
+func TestDHT_FindProvidersAsync_resolves_provider_addrs(t *testing.T) {
+	// Test setup:
+	// d1 is connected to d2 and d3. d2 holds a provider record for d3 but
+	// doesn't know d3's addresses. If enabled, d1 should look up d3's
+	// addresses before returning it as a provider.
+	ctx := kadtest.CtxShort(t)
+
+	c := newRandomContent(t)
+
+	cfg := DefaultConfig()
+	cfg.Query.ResolveProviderAddrs = true
+
+	top := NewTopology(t)
+	d1 := top.AddServer(cfg)
+	d2 := top.AddServer(nil)
+	d3 := top.AddServer(nil)
+
+	top.Connect(ctx, d1, d2)
+	top.Connect(ctx, d1, d3)
+
+	_, err := d2.backends[namespaceProviders].Store(ctx, string(c.Hash()), peer.AddrInfo{ID: d3.host.ID()})
+	require.NoError(t, err)
+	d2.host.Peerstore().ClearAddrs(d3.host.ID())
+
+	out := d1.FindProvidersAsync(ctx, c, 1)
+
+	val := kadtest.ReadItem(t, ctx, out)
+	assert.Equal(t, d3.host.ID(), val.ID)
+	assert.NotEmpty(t, val.Addrs)
+
+	kadtest.AssertClosed(t, ctx, out)
+}
+
+func TestDHT_FindProvidersAsync_provider_cache(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	c := newRandomContent(t)
+
+	cfg := DefaultConfig()
+	cfg.Query.ProviderCacheSize = 10
+
+	top := NewTopology(t)
+	d1 := top.AddServer(cfg)
+	d2 := top.AddServer(nil)
+
+	top.Connect(ctx, d1, d2)
+
+	provider := peer.AddrInfo{ID: newPeerID(t)}
+	_, err := d2.backends[namespaceProviders].Store(ctx, string(c.Hash()), provider)
+	require.NoError(t, err)
+
+	readAll := func(out <-chan ProviderInfo) []ProviderInfo {
+		var providers []ProviderInfo
+		for p := range out {
+			providers = append(providers, p)
+		}
+		return providers
+	}
+
+	// the first lookup populates the cache
+	found := readAll(d1.FindProvidersWithMetadataAsync(ctx, c, 0))
+	require.Len(t, found, 1)
+	assert.Equal(t, provider.ID, found[0].ID)
+
+	// remove the provider record from d2
+	be, err := typedBackend[*ProvidersBackend](d2, namespaceProviders)
+	require.NoError(t, err)
+	be.cache.Purge()
+	err = be.datastore.Delete(ctx, newDatastoreKey(namespaceProviders, string(c.Hash()), string(provider.ID)))
+	require.NoError(t, err)
+
+	// the second lookup is answered from the cache
+	found = readAll(d1.FindProvidersWithMetadataAsync(ctx, c, 0))
+	require.Len(t, found, 1)
+	assert.Equal(t, provider.ID, found[0].ID)
+
+	// bypassing the cache queries the network
+	found = readAll(d1.FindProvidersWithMetadataAsync(ctx, c, 0, RoutingBypassProviderCache()))
+	assert.Len(t, found, 0)
+
+	// FindProvidersAsync bypasses the cache through the context
+	var addrInfos []peer.AddrInfo
+	for p := range d1.FindProvidersAsync(ContextBypassProviderCache(ctx), c, 0) {
+		addrInfos = append(addrInfos, p)
+	}
+	assert.Len(t, addrInfos, 0)
+}
+
+func TestDHT_FindProvidersAsync_provider_cache_short(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+
+	c := newRandomContent(t)
+
+	cfg := DefaultConfig()
+	cfg.Query.ProviderCacheSize = 10
+
+	top := NewTopology(t)
+	d1 := top.AddServer(cfg)
+	d2 := top.AddServer(nil)
+
+	top.Connect(ctx, d1, d2)
+
+	local := peer.AddrInfo{ID: newPeerID(t)}
+	_, err := d1.backends[namespaceProviders].Store(ctx, string(c.Hash()), local)
+	require.NoError(t, err)
+
+	remote := peer.AddrInfo{ID: newPeerID(t)}
+	_, err = d2.backends[namespaceProviders].Store(ctx, string(c.Hash()), remote)
+	require.NoError(t, err)
+
+	// an incomplete lookup only found the provider that d1 stores itself
+	d1.providerCache.Add(string(c.Hash()), []ProviderInfo{{AddrInfo: local}, {AddrInfo: local}}, false)
+
+	// the cached providers are short of count, so the network is queried
+	var found []peer.ID
+	for p := range d1.FindProvidersAsync(ctx, c, 2) {
+		found = append(found, p.ID)
+	}
+	assert.ElementsMatch(t, []peer.ID{local.ID, remote.ID}, found)
+}
+
 func TestDHT_FindProvidersAsync_respects_cancelled_context_for_local_query(t *testing.T) {
 	// Test strategy:
 	// We let d know about providersCount providers for the CID c
@@ -807,6 +1064,88 @@ func TestDHT_SearchValue_quorum_negative(t *testing.T) {
 	assert.Nil(t, out)
 }
 
+func TestDHT_SearchValue_disjoint_paths_invalid(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	out, err := d.SearchValue(ctx, "/"+namespaceIPNS+"/some-key", RoutingDisjointPaths(0))
+	assert.ErrorContains(t, err, "disjoint paths must be greater than zero")
+	assert.Nil(t, out)
+}
+
+func TestDHT_SearchValue_termination_invalid(t *testing.T) {
+	ctx := kadtest.CtxShort(t)
+	d := newTestDHT(t)
+
+	out, err := d.SearchValue(ctx, "/"+namespaceIPNS+"/some-key", RoutingTerminateWhenStable(0))
+	assert.ErrorContains(t, err, "rounds must be greater than zero")
+	assert.Nil(t, out)
+}
+
+func TestQueryOptions_termination(t *testing.T) {
+	ropt := &routing.Options{}
+	require.NoError(t, ropt.Apply(RoutingRequestBudget(10)))
+
+	qopts := &coord.QueryOptions{}
+	for _, opt := range queryOptions(ropt) {
+		opt(qopts)
+	}
+	assert.Equal(t, query.RequestBudgetPolicy{Requests: 10}, qopts.Termination)
+
+	// several policies end the lookup as soon as any of them would end it
+	require.NoError(t, ropt.Apply(RoutingTimeBudget(time.Second)))
+
+	qopts = &coord.QueryOptions{}
+	for _, opt := range queryOptions(ropt) {
+		opt(qopts)
+	}
+	assert.Equal(t, query.AnyPolicy{
+		query.RequestBudgetPolicy{Requests: 10},
+		query.TimeBudgetPolicy{Budget: time.Second},
+	}, qopts.Termination)
+}
+
+func TestQueryOptions_lookup(t *testing.T) {
+	ropt := &routing.Options{}
+	require.NoError(t, ropt.Apply(
+		RoutingNumResults(5),
+		RoutingRequestConcurrency(2),
+		RoutingRequestTimeout(time.Second),
+		RoutingTimeout(time.Minute),
+		RoutingSeedCount(7),
+		RoutingBroadcastStrategy(BroadcastStatic),
+	))
+
+	qopts := &coord.QueryOptions{}
+	for _, opt := range queryOptions(ropt) {
+		opt(qopts)
+	}
+	assert.Equal(t, 5, qopts.NumResults)
+	assert.Equal(t, 2, qopts.Concurrency)
+	assert.Equal(t, time.Second, qopts.RequestTimeout)
+	assert.Equal(t, time.Minute, qopts.Timeout)
+	assert.Equal(t, 7, qopts.SeedCount)
+	assert.Equal(t, coord.BroadcastStatic, qopts.Broadcast)
+}
+
+func TestQueryOptions_invalid(t *testing.T) {
+	invalid := map[string]routing.Option{
+		"num_results":         RoutingNumResults(0),
+		"request_concurrency": RoutingRequestConcurrency(0),
+		"request_timeout":     RoutingRequestTimeout(0),
+		"timeout":             RoutingTimeout(0),
+		"seed_count":          RoutingSeedCount(0),
+		"broadcast_strategy":  RoutingBroadcastStrategy(BroadcastStrategy(-1)),
+		"query_stats":         RoutingQueryStats(nil),
+	}
+	for name, opt := range invalid {
+		t.Run(name, func(t *testing.T) {
+			ropt := &routing.Options{}
+			assert.Error(t, ropt.Apply(opt))
+		})
+	}
+}
+
 func TestDHT_SearchValue_invalid_key(t *testing.T) {
 	ctx := kadtest.CtxShort(t)
 	d := newTestDHT(t)
@@ -30,6 +30,7 @@ type Telemetry struct {
 	SentRequestErrors      metric.Int64Counter
 	SentBytes              metric.Int64Histogram
 	LRUCache               metric.Int64Counter
+	ProviderCache          metric.Int64Counter
 	NetworkSize            metric.Int64Counter
 }
 
@@ -118,6 +119,11 @@ func NewTelemetry(meterProvider metric.MeterProvider, tracerProvider trace.Trace
 		return nil, fmt.Errorf("lru_cache counter: %w", err)
 	}
 
+	t.ProviderCache, err = meter.Int64Counter("provider_cache", metric.WithDescription("Client-side provider lookup cache hit or miss counter"))
+	if err != nil {
+		return nil, fmt.Errorf("provider_cache counter: %w", err)
+	}
+
 	t.NetworkSize, err = meter.Int64Counter("network_size", metric.WithDescription("Network size estimation"))
 	if err != nil {
 		return nil, fmt.Errorf("network_size counter: %w", err)